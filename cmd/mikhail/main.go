@@ -0,0 +1,239 @@
+// Command mikhail runs the Kingdom-System authentication service.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/TwelveFacedJanus/Kingdom-System/internal/mikhail"
+)
+
+func main() {
+	configFile := flag.String("config", os.Getenv("MIKHAIL_CONFIG_FILE"), "path to a YAML configuration file; environment variables already set take precedence over its contents")
+	validateConfig := flag.Bool("validate-config", false, "load and validate configuration, then exit without starting the server")
+	healthcheck := flag.Bool("healthcheck", false, "probe this instance's own /readyz endpoint and exit 0 if ready or non-zero otherwise, for use as a container exec probe")
+	flag.Parse()
+
+	if *configFile != "" {
+		if err := mikhail.ApplyConfigFile(*configFile); err != nil {
+			log.Fatalf("mikhail: %v", err)
+		}
+	}
+
+	if *healthcheck {
+		if err := mikhail.RunHealthcheck(); err != nil {
+			log.Fatalf("mikhail: %v", err)
+		}
+		log.Println("mikhail: ready")
+		return
+	}
+
+	if *validateConfig {
+		if err := mikhail.ValidateAllConfig(); err != nil {
+			log.Fatalf("mikhail: config invalid: %v", err)
+		}
+		log.Println("mikhail: config OK")
+		return
+	}
+
+	environment, err := mikhail.LoadEnvironmentConfig()
+	if err != nil {
+		log.Fatalf("mikhail: loading environment config: %v", err)
+	}
+	if err := mikhail.ValidateSecurityConfig(environment); err != nil {
+		log.Fatalf("mikhail: %v", err)
+	}
+
+	secret := []byte(os.Getenv("MIKHAIL_JWT_SECRET"))
+	if len(secret) == 0 {
+		secret = []byte("dev-secret-do-not-use-in-production")
+	}
+
+	issuer, err := mikhail.NewHS256Issuer("kingdom-system/mikhail", secret)
+	if err != nil {
+		log.Fatalf("mikhail: building token issuer: %v", err)
+	}
+
+	config, err := mikhail.LoadConfig()
+	if err != nil {
+		log.Fatalf("mikhail: loading config: %v", err)
+	}
+
+	storageBackend := os.Getenv("MIKHAIL_TOKEN_STORAGE_BACKEND")
+	if storageBackend == "" {
+		storageBackend = string(mikhail.TokenStorageBackendMemory)
+	}
+	startupRetry, err := mikhail.LoadStartupRetryConfig()
+	if err != nil {
+		log.Fatalf("mikhail: loading startup retry config: %v", err)
+	}
+	storage, err := mikhail.ConnectTokenStorageWithRetry(startupRetry)
+	if err != nil {
+		log.Fatalf("mikhail: selecting token storage backend: %v", err)
+	}
+	server := mikhail.NewAuthServer(issuer, storage, config)
+	logLevel := mikhail.NewLogLevelController(slog.LevelInfo)
+	blocklistStore, err := mikhail.NewBlocklistStoreFromEnv()
+	if err != nil {
+		log.Fatalf("mikhail: constructing blocklist store: %v", err)
+	}
+	trustedProxies, err := mikhail.LoadTrustedProxyConfig()
+	if err != nil {
+		log.Fatalf("mikhail: loading trusted proxy config: %v", err)
+	}
+	server.WithTrustedProxyConfig(trustedProxies)
+	bruteForceDetector, err := mikhail.NewBruteForceDetectorFromEnv(mikhail.LogNotifier{}, blocklistStore)
+	if err != nil {
+		log.Fatalf("mikhail: constructing brute force detector: %v", err)
+	}
+	server.WithBruteForceDetector(bruteForceDetector)
+	auditLog, err := mikhail.NewAuditLogFromEnv()
+	if err != nil {
+		log.Fatalf("mikhail: constructing audit log: %v", err)
+	}
+	server.WithAuditLog(auditLog)
+	sessionAnomaly, err := mikhail.LoadSessionAnomalyConfig()
+	if err != nil {
+		log.Fatalf("mikhail: loading session anomaly config: %v", err)
+	}
+	server.WithSessionAnomalyConfig(sessionAnomaly)
+	log.Printf("mikhail: session anomaly detection: enabled=%t mode=%s", sessionAnomaly.Enabled, sessionAnomaly.Mode)
+	adminServer := mikhail.NewAdminServer(storage, nil, blocklistStore, auditLog, logLevel, server, storageBackend)
+
+	keepalive, err := mikhail.LoadKeepaliveConfig()
+	if err != nil {
+		log.Fatalf("mikhail: loading keepalive config: %v", err)
+	}
+	log.Printf("mikhail: grpc keepalive: max_connection_idle=%s max_connection_age=%s max_connection_age_grace=%s time=%s timeout=%s enforcement_min_time=%s enforcement_permit_without_stream=%t",
+		keepalive.MaxConnectionIdle, keepalive.MaxConnectionAge, keepalive.MaxConnectionAgeGrace, keepalive.Time, keepalive.Timeout, keepalive.EnforcementMinTime, keepalive.EnforcementPermitWithoutStream)
+
+	serverLimits, err := mikhail.LoadServerLimitsConfig()
+	if err != nil {
+		log.Fatalf("mikhail: loading server limits config: %v", err)
+	}
+	log.Printf("mikhail: grpc server limits: max_recv_msg_size_bytes=%d max_send_msg_size_bytes=%d max_concurrent_streams=%d",
+		serverLimits.MaxRecvMsgSizeBytes, serverLimits.MaxSendMsgSizeBytes, serverLimits.MaxConcurrentStreams)
+
+	log.Printf("mikhail: environment=%s debug=%t reflection_enabled=%t",
+		environment.Environment, environment.Debug, environment.ReflectionEnabled())
+
+	deadlines, err := mikhail.LoadDeadlineConfig()
+	if err != nil {
+		log.Fatalf("mikhail: loading deadline config: %v", err)
+	}
+
+	loadShedding, err := mikhail.LoadLoadSheddingConfig()
+	if err != nil {
+		log.Fatalf("mikhail: loading load shedding config: %v", err)
+	}
+	log.Printf("mikhail: grpc load shedding: max_in_flight=%d retry_after=%s", loadShedding.MaxInFlight, loadShedding.RetryAfter)
+
+	debugServerConfig, err := mikhail.LoadDebugServerConfig()
+	if err != nil {
+		log.Fatalf("mikhail: loading debug server config: %v", err)
+	}
+	if debugServerConfig.Enabled {
+		mikhail.StartDebugServer(mikhail.NewDebugServer(debugServerConfig, storage, logLevel))
+		log.Printf("mikhail: debug server listening on %s", debugServerConfig.Addr)
+	}
+
+	healthServerConfig, err := mikhail.LoadHealthServerConfig()
+	if err != nil {
+		log.Fatalf("mikhail: loading health server config: %v", err)
+	}
+	if healthServerConfig.Enabled {
+		mikhail.StartHealthServer(mikhail.NewHealthServer(healthServerConfig, storage, server))
+		log.Printf("mikhail: health server listening on %s", healthServerConfig.Addr)
+	}
+
+	accessLog, err := mikhail.LoadAccessLogConfig()
+	if err != nil {
+		log.Fatalf("mikhail: loading access log config: %v", err)
+	}
+	log.Printf("mikhail: access log: success_sample_rate=%g slow_request_threshold=%s", accessLog.SuccessSampleRate, accessLog.SlowRequestThreshold)
+
+	mikhail.SetupSIGHUPReload(logLevel.ReloadFromEnv, server.ReloadOAuthProvidersFromEnv)
+
+	readRateLimitConfig, err := mikhail.LoadReadRateLimitConfig()
+	if err != nil {
+		log.Fatalf("mikhail: loading read rate limit config: %v", err)
+	}
+	writeRateLimitConfig, err := mikhail.LoadWriteRateLimitConfig()
+	if err != nil {
+		log.Fatalf("mikhail: loading write rate limit config: %v", err)
+	}
+	readRateLimiter, err := mikhail.NewRateLimiterFromEnv(readRateLimitConfig)
+	if err != nil {
+		log.Fatalf("mikhail: constructing read rate limiter: %v", err)
+	}
+	writeRateLimiter, err := mikhail.NewRateLimiterFromEnv(writeRateLimitConfig)
+	if err != nil {
+		log.Fatalf("mikhail: constructing write rate limiter: %v", err)
+	}
+	rateLimit := mikhail.RateLimitInterceptor(readRateLimiter, writeRateLimiter, mikhail.LoadRateLimitPolicy(), trustedProxies)
+	log.Printf("mikhail: rate limiting: read_burst=%d read_refill_interval=%s write_burst=%d write_refill_interval=%s",
+		readRateLimitConfig.Burst, readRateLimitConfig.RefillInterval, writeRateLimitConfig.Burst, writeRateLimitConfig.RefillInterval)
+	rateLimitEvictionConfig, err := mikhail.LoadRateLimitEvictionConfig()
+	if err != nil {
+		log.Fatalf("mikhail: loading rate limit eviction config: %v", err)
+	}
+	for _, limiter := range []mikhail.RateLimiter{readRateLimiter, writeRateLimiter} {
+		if evictor, ok := limiter.(mikhail.IdleKeyEvictor); ok {
+			go mikhail.NewRateLimitEvictor(evictor, rateLimitEvictionConfig).Start(context.Background())
+		}
+	}
+	blocklist := mikhail.BlocklistInterceptor(blocklistStore, trustedProxies)
+
+	chainConfig := mikhail.LoadInterceptorChainConfig()
+	tenantBindings := mikhail.NewInMemoryTenantBindingStore()
+	registry := mikhail.DefaultUnaryInterceptorRegistry(server, nil, deadlines, loadShedding, accessLog, logLevel, nil, rateLimit, blocklist, tenantBindings)
+	chain, err := mikhail.BuildUnaryInterceptorChain(chainConfig, registry)
+	if err != nil {
+		log.Fatalf("mikhail: building interceptor chain: %v", err)
+	}
+	log.Printf("mikhail: grpc unary interceptor chain: %s", strings.Join(chainConfig.Order, ", "))
+
+	serverConfig, err := mikhail.LoadServerConfig()
+	if err != nil {
+		log.Fatalf("mikhail: loading server config: %v", err)
+	}
+	var oauthProvider string
+	if names := server.OAuthProviderNames(); len(names) > 0 {
+		oauthProvider = names[0]
+	}
+	mainServer := mikhail.NewMainServer(serverConfig, mikhail.NewGatewayMux(server, oauthProvider, chain))
+	mikhail.StartMainServer(mainServer)
+	log.Printf("mikhail: auth server listening on %s", serverConfig.Addr)
+
+	adminServerConfig, err := mikhail.LoadAdminServerConfig()
+	if err != nil {
+		log.Fatalf("mikhail: loading admin server config: %v", err)
+	}
+	var adminListener *http.Server
+	if adminServerConfig.Enabled {
+		adminChain := mikhail.ChainUnaryInterceptors(
+			mikhail.RecoveryInterceptor(),
+			mikhail.AuthenticationInterceptor(server, nil),
+			mikhail.AdminAuthorizationInterceptor(),
+			mikhail.StatusMappingInterceptor(false),
+		)
+		adminListener, err = mikhail.NewAdminServerListener(adminServerConfig, mikhail.NewAdminGatewayMux(adminServer, adminChain))
+		if err != nil {
+			log.Fatalf("mikhail: building admin server TLS config: %v", err)
+		}
+		mikhail.StartAdminServer(adminListener)
+		if adminListener.TLSConfig != nil {
+			log.Printf("mikhail: admin server listening on %s (mTLS)", adminServerConfig.Addr)
+		} else {
+			log.Printf("mikhail: admin server listening on %s (no TLS configured; bearer-token admins only)", adminServerConfig.Addr)
+		}
+	}
+
+	mikhail.WaitForShutdownSignal(mainServer, adminListener)
+	log.Println("mikhail: auth server stopped")
+}
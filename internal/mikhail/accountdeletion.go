@@ -0,0 +1,115 @@
+package mikhail
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrAccountDeleted is returned by SignIn (and anything else that should
+// treat a scheduled-for-deletion account as gone) once DeleteAccount has
+// been called for it.
+var ErrAccountDeleted = errors.New("mikhail: account scheduled for deletion")
+
+// AccountDeletionInfo is what Mikhail keeps about a pending account
+// deletion.
+type AccountDeletionInfo struct {
+	UserID      string
+	RequestedAt time.Time
+	// PurgeAt is when the account becomes eligible for hard deletion by
+	// an out-of-band cleanup job. Mikhail itself only enforces the soft
+	// delete (rejecting SignIn); it does not run the purge job.
+	PurgeAt time.Time
+}
+
+// Due reports whether the account's grace period has elapsed as of now.
+func (i AccountDeletionInfo) Due(now time.Time) bool {
+	return now.After(i.PurgeAt)
+}
+
+// AccountDeletionStore persists pending account deletions, keyed by user
+// ID. Implementations must be safe for concurrent use.
+type AccountDeletionStore interface {
+	Store(userID string, info AccountDeletionInfo) error
+	Get(userID string) (AccountDeletionInfo, bool, error)
+	Delete(userID string) error
+}
+
+// InMemoryAccountDeletionStore is an AccountDeletionStore backed by a
+// guarded map, suitable for local development and single-node
+// deployments.
+type InMemoryAccountDeletionStore struct {
+	mu       sync.Mutex
+	deletion map[string]AccountDeletionInfo
+}
+
+// NewInMemoryAccountDeletionStore returns an empty
+// InMemoryAccountDeletionStore.
+func NewInMemoryAccountDeletionStore() *InMemoryAccountDeletionStore {
+	return &InMemoryAccountDeletionStore{deletion: make(map[string]AccountDeletionInfo)}
+}
+
+func (s *InMemoryAccountDeletionStore) Store(userID string, info AccountDeletionInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deletion[userID] = info
+	return nil
+}
+
+func (s *InMemoryAccountDeletionStore) Get(userID string) (AccountDeletionInfo, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, ok := s.deletion[userID]
+	return info, ok, nil
+}
+
+func (s *InMemoryAccountDeletionStore) Delete(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.deletion, userID)
+	return nil
+}
+
+// YandexOAuthRevoker revokes the Yandex OAuth grant backing a linked
+// account, e.g. when the account is deleted. Revocation itself is
+// best-effort (a lingering grant at Yandex is not worth failing account
+// deletion over), so Revoke only ever returns an error for a failure to
+// even look up the account's sessions; anything past that is logged for
+// audit purposes, not surfaced to the caller.
+type YandexOAuthRevoker interface {
+	Revoke(userID string) error
+}
+
+// LogYandexOAuthRevoker is a YandexOAuthRevoker that only logs, for local
+// development and until a real Yandex OAuth client is wired in.
+type LogYandexOAuthRevoker struct{}
+
+func (LogYandexOAuthRevoker) Revoke(userID string) error {
+	log.Printf("mikhail: revoking yandex oauth grant for user %s", userID)
+	return nil
+}
+
+// StoredYandexOAuthRevoker is the real YandexOAuthRevoker: it looks up
+// userID's stored sessions for any that went through the "yandex"
+// OAuthProvider and revokes each one's stored provider access token.
+// It is a no-op, not an error, for an account with no Yandex-backed
+// session to revoke.
+type StoredYandexOAuthRevoker struct {
+	Storage   TokenStorage
+	Providers *OAuthProviderRegistry
+}
+
+func (r StoredYandexOAuthRevoker) Revoke(userID string) error {
+	sessions, err := r.Storage.ListByUser(userID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.Provider == "yandex" && session.ProviderAccessToken != "" {
+			revokeProviderGrant(context.Background(), r.Providers, "yandex", session.ProviderAccessToken, userID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,497 @@
+package mikhail
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrAdminAccessDenied is returned by AdminAuthorizationInterceptor when
+// a call to AdminServer arrives without an admin-authorized Principal or
+// a mTLS client certificate.
+var ErrAdminAccessDenied = errors.New("mikhail: admin access denied")
+
+// ExpiredTokenVacuumer is implemented by a TokenStorage backend that can
+// reap its own expired rows on demand (today, only *SQLiteTokenStorage;
+// Redis/DynamoDB/etcd expire natively and have nothing to vacuum).
+// AdminServer.TriggerCleanup uses it to satisfy an operator's ad-hoc
+// cleanup request without waiting for whatever background vacuumer or
+// poller is already scheduled.
+type ExpiredTokenVacuumer interface {
+	VacuumExpired(now time.Time) (int64, error)
+}
+
+// RateLimitState is a snapshot of one rate-limit key's bucket, for
+// AdminServer.RateLimitState to report. It mirrors the shape a
+// token-bucket limiter naturally keeps; InMemoryRateLimiter and
+// RedisRateLimiter both fill it in from their own bucket state.
+type RateLimitState struct {
+	Key       string
+	Remaining int
+	Limit     int
+	ResetAt   time.Time
+}
+
+// RateLimitStateProvider is satisfied by InMemoryRateLimiter and
+// RedisRateLimiter, so AdminServer.RateLimitState can report live limiter
+// state without AdminServer depending on a concrete limiter
+// implementation. An AdminServer constructed with a nil
+// RateLimitStateProvider always reports not found from RateLimitState.
+type RateLimitStateProvider interface {
+	RateLimitState(key string) (RateLimitState, bool)
+}
+
+// AdminServer implements AdminService, a collection of operator actions
+// - looking up a token, listing or revoking a user's sessions, viewing
+// rate-limit state, and triggering a storage cleanup - that today are
+// only reachable with redis-cli (or a SQLite shell) directly against
+// whatever TokenStorage backend is configured. Every method assumes the
+// call already passed AdminAuthorizationInterceptor; AdminServer itself
+// does not re-check the caller.
+type AdminServer struct {
+	storage        TokenStorage
+	rateLimits     RateLimitStateProvider
+	blocklist      BlocklistStore
+	auditLog       AuditLog
+	logLevel       *LogLevelController
+	authServer     *AuthServer
+	storageBackend string
+}
+
+// NewAdminServer returns an AdminServer backed by storage. rateLimits
+// may be nil; see RateLimitStateProvider. blocklist may be nil, in which
+// case Ban/Unban/ListBlocklist/BlocklistAudit report
+// ErrBlocklistControlUnavailable. auditLog may be nil, in which case
+// QueryAuditLog reports ErrAuditLogUnavailable. logLevel may be nil, in which
+// case GetLogLevel/SetLogLevel report ErrLogLevelControlUnavailable.
+// authServer and storageBackend feed GetServerInfo only; authServer may
+// be nil, in which case GetServerInfo reports an empty TokenFormat and
+// no OAuthProviders.
+func NewAdminServer(storage TokenStorage, rateLimits RateLimitStateProvider, blocklist BlocklistStore, auditLog AuditLog, logLevel *LogLevelController, authServer *AuthServer, storageBackend string) *AdminServer {
+	return &AdminServer{storage: storage, rateLimits: rateLimits, blocklist: blocklist, auditLog: auditLog, logLevel: logLevel, authServer: authServer, storageBackend: storageBackend}
+}
+
+// AdminLookupTokenRequest is AdminServer.LookupToken's request.
+type AdminLookupTokenRequest struct {
+	RefreshToken string
+}
+
+// AdminLookupTokenResponse is AdminServer.LookupToken's response.
+type AdminLookupTokenResponse struct {
+	Found bool
+	Info  TokenInfo
+}
+
+// LookupToken returns the TokenInfo on record for req.RefreshToken, the
+// RPC equivalent of a Redis GET against the token's key.
+func (s *AdminServer) LookupToken(ctx context.Context, req *AdminLookupTokenRequest) (*AdminLookupTokenResponse, error) {
+	info, ok, err := s.storage.Get(req.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	return &AdminLookupTokenResponse{Found: ok, Info: info}, nil
+}
+
+// AdminListSessionsRequest is AdminServer.ListSessions's request.
+type AdminListSessionsRequest struct {
+	UserID string
+}
+
+// AdminListSessionsResponse is AdminServer.ListSessions's response.
+type AdminListSessionsResponse struct {
+	Sessions []TokenInfo
+}
+
+// ListSessions returns every session on record for req.UserID.
+func (s *AdminServer) ListSessions(ctx context.Context, req *AdminListSessionsRequest) (*AdminListSessionsResponse, error) {
+	sessions, err := s.storage.ListByUser(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &AdminListSessionsResponse{Sessions: sessions}, nil
+}
+
+// AdminRevokeSessionsRequest is AdminServer.RevokeSessions's request.
+type AdminRevokeSessionsRequest struct {
+	UserID string
+	// ExceptFamilyID, if set, preserves one session family while revoking
+	// every other one for UserID, the same carve-out
+	// RevokeAllForUserExceptFamily offers a signed-in caller revoking
+	// their other devices but not the one they are using.
+	ExceptFamilyID string
+}
+
+// AdminRevokeSessionsResponse is AdminServer.RevokeSessions's response.
+type AdminRevokeSessionsResponse struct{}
+
+// RevokeSessions force-revokes every session for req.UserID, e.g. in
+// response to a compromised-account report, without requiring the user
+// to still hold a valid session of their own to do it through SignOut.
+func (s *AdminServer) RevokeSessions(ctx context.Context, req *AdminRevokeSessionsRequest) (*AdminRevokeSessionsResponse, error) {
+	var err error
+	if req.ExceptFamilyID != "" {
+		err = s.storage.RevokeAllForUserExceptFamily(req.UserID, req.ExceptFamilyID)
+	} else {
+		err = s.storage.RevokeAllForUser(req.UserID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &AdminRevokeSessionsResponse{}, nil
+}
+
+// AdminRateLimitStateRequest is AdminServer.RateLimitState's request.
+type AdminRateLimitStateRequest struct {
+	Key string
+}
+
+// AdminRateLimitStateResponse is AdminServer.RateLimitState's response.
+type AdminRateLimitStateResponse struct {
+	Found bool
+	State RateLimitState
+}
+
+// RateLimitState reports the current rate-limit bucket for req.Key, or
+// Found=false if s has no RateLimitStateProvider configured or the
+// provider has no bucket for that key.
+func (s *AdminServer) RateLimitState(ctx context.Context, req *AdminRateLimitStateRequest) (*AdminRateLimitStateResponse, error) {
+	if s.rateLimits == nil {
+		return &AdminRateLimitStateResponse{}, nil
+	}
+	state, ok := s.rateLimits.RateLimitState(req.Key)
+	return &AdminRateLimitStateResponse{Found: ok, State: state}, nil
+}
+
+// AdminRateLimiterStatsRequest is AdminServer.RateLimiterStats's
+// request. It carries no fields.
+type AdminRateLimiterStatsRequest struct{}
+
+// AdminRateLimiterStatsResponse is AdminServer.RateLimiterStats's
+// response.
+type AdminRateLimiterStatsResponse struct {
+	// Supported is false if s's RateLimitStateProvider does not also
+	// track a key count (e.g. RedisRateLimiter, whose keys expire
+	// natively in Redis and are never counted in this process). TrackedKeys
+	// is meaningless when Supported is false.
+	Supported   bool
+	TrackedKeys int
+}
+
+// RateLimiterStats reports how many distinct rate-limit keys s's
+// RateLimitStateProvider is currently tracking, for watching that
+// RateLimitEvictor is keeping it bounded.
+func (s *AdminServer) RateLimiterStats(ctx context.Context, req *AdminRateLimiterStatsRequest) (*AdminRateLimiterStatsResponse, error) {
+	counter, ok := s.rateLimits.(IdleKeyEvictor)
+	if !ok {
+		return &AdminRateLimiterStatsResponse{}, nil
+	}
+	return &AdminRateLimiterStatsResponse{Supported: true, TrackedKeys: counter.TrackedKeyCount()}, nil
+}
+
+// ErrBlocklistControlUnavailable is returned by AdminServer.Ban,
+// Unban, ListBlocklist, and BlocklistAudit when s was constructed with
+// a nil BlocklistStore.
+var ErrBlocklistControlUnavailable = errors.New("mikhail: blocklist is not wired up on this instance")
+
+// AdminBanRequest is AdminServer.Ban's request.
+type AdminBanRequest struct {
+	Type BlocklistEntryType
+	// Value is the CIDR, user ID, or device ID to ban, matching Type.
+	Value   string
+	Reason  string
+	ActorID string
+	// TTL bans Value until now+TTL; zero bans it permanently.
+	TTL time.Duration
+}
+
+// AdminBanResponse is AdminServer.Ban's response.
+type AdminBanResponse struct{}
+
+// Ban places a ban on req.Value, e.g. in response to a brute-force or
+// credential-stuffing report naming an offending IP range, user ID, or
+// device ID.
+func (s *AdminServer) Ban(ctx context.Context, req *AdminBanRequest) (*AdminBanResponse, error) {
+	if s.blocklist == nil {
+		return nil, ErrBlocklistControlUnavailable
+	}
+	if err := s.blocklist.Ban(req.Type, req.Value, req.Reason, req.ActorID, req.TTL); err != nil {
+		return nil, err
+	}
+	s.recordAdminAuditEvent(ctx, req.ActorID, "ban:"+string(req.Type)+":"+req.Value)
+	return &AdminBanResponse{}, nil
+}
+
+// AdminUnbanRequest is AdminServer.Unban's request.
+type AdminUnbanRequest struct {
+	Type    BlocklistEntryType
+	Value   string
+	ActorID string
+}
+
+// AdminUnbanResponse is AdminServer.Unban's response.
+type AdminUnbanResponse struct{}
+
+// Unban lifts a ban early, e.g. once an operator has confirmed a flagged
+// IP or account was a false positive.
+func (s *AdminServer) Unban(ctx context.Context, req *AdminUnbanRequest) (*AdminUnbanResponse, error) {
+	if s.blocklist == nil {
+		return nil, ErrBlocklistControlUnavailable
+	}
+	if err := s.blocklist.Unban(req.Type, req.Value, req.ActorID); err != nil {
+		return nil, err
+	}
+	s.recordAdminAuditEvent(ctx, req.ActorID, "unban:"+string(req.Type)+":"+req.Value)
+	return &AdminUnbanResponse{}, nil
+}
+
+// AdminListBlocklistRequest is AdminServer.ListBlocklist's request. It
+// carries no fields; ListBlocklist always reports every active ban.
+type AdminListBlocklistRequest struct{}
+
+// AdminListBlocklistResponse is AdminServer.ListBlocklist's response.
+type AdminListBlocklistResponse struct {
+	Entries []BlocklistEntry
+}
+
+// ListBlocklist reports every currently active ban.
+func (s *AdminServer) ListBlocklist(ctx context.Context, req *AdminListBlocklistRequest) (*AdminListBlocklistResponse, error) {
+	if s.blocklist == nil {
+		return nil, ErrBlocklistControlUnavailable
+	}
+	entries, err := s.blocklist.List()
+	if err != nil {
+		return nil, err
+	}
+	return &AdminListBlocklistResponse{Entries: entries}, nil
+}
+
+// AdminBlocklistAuditRequest is AdminServer.BlocklistAudit's request.
+type AdminBlocklistAuditRequest struct {
+	// Limit caps how many of the most recent audit entries are returned;
+	// 0 means no cap.
+	Limit int
+}
+
+// AdminBlocklistAuditResponse is AdminServer.BlocklistAudit's response.
+type AdminBlocklistAuditResponse struct {
+	Entries []BlocklistAuditEntry
+}
+
+// BlocklistAudit reports the most recent ban, unban, and blocked-request
+// decisions, newest last, for a security review of who has been banned,
+// by whom, and which blocked calls those bans actually stopped.
+func (s *AdminServer) BlocklistAudit(ctx context.Context, req *AdminBlocklistAuditRequest) (*AdminBlocklistAuditResponse, error) {
+	if s.blocklist == nil {
+		return nil, ErrBlocklistControlUnavailable
+	}
+	entries, err := s.blocklist.Audit(req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return &AdminBlocklistAuditResponse{Entries: entries}, nil
+}
+
+// ErrAuditLogUnavailable is returned by AdminServer.QueryAuditLog when s
+// was constructed with a nil AuditLog.
+var ErrAuditLogUnavailable = errors.New("mikhail: audit log is not wired up on this instance")
+
+// AdminQueryAuditLogRequest is AdminServer.QueryAuditLog's request.
+type AdminQueryAuditLogRequest struct {
+	// ActorID, if set, restricts results to events recorded against
+	// exactly this actor.
+	ActorID string
+	// Type, if set, restricts results to exactly this AuditEventType.
+	Type AuditEventType
+	// Since, if non-zero, excludes anything recorded before it.
+	Since time.Time
+	// Limit caps how many of the most recent matching entries are
+	// returned; 0 means no cap.
+	Limit int
+}
+
+// AdminQueryAuditLogResponse is AdminServer.QueryAuditLog's response.
+type AdminQueryAuditLogResponse struct {
+	Entries []AuditEntry
+}
+
+// QueryAuditLog reports the security-relevant events recorded by
+// AuthServer.recordAuditEvent and AdminServer's own admin-action hooks
+// (e.g. Ban, Unban) matching req, newest last, for a security review of
+// who did what, from where, and when.
+func (s *AdminServer) QueryAuditLog(ctx context.Context, req *AdminQueryAuditLogRequest) (*AdminQueryAuditLogResponse, error) {
+	if s.auditLog == nil {
+		return nil, ErrAuditLogUnavailable
+	}
+	entries, err := s.auditLog.Query(AuditQuery{ActorID: req.ActorID, Type: req.Type, Since: req.Since, Limit: req.Limit})
+	if err != nil {
+		return nil, err
+	}
+	return &AdminQueryAuditLogResponse{Entries: entries}, nil
+}
+
+// recordAdminAuditEvent appends an AuditEventAdminAction entry to
+// s.auditLog, if one is configured, the same best-effort way
+// AuthServer.recordAuditEvent does: a logging failure never fails the
+// admin action itself.
+func (s *AdminServer) recordAdminAuditEvent(ctx context.Context, actorID, detail string) {
+	if s.auditLog == nil {
+		return
+	}
+	requestID, _ := RequestIDFromContext(ctx)
+	_ = s.auditLog.Record(AuditEntry{
+		Type:      AuditEventAdminAction,
+		ActorID:   actorID,
+		IP:        ClientIP(ctx, DefaultTrustedProxyConfig()),
+		RequestID: requestID,
+		Detail:    detail,
+		Success:   true,
+		At:        time.Now(),
+	})
+}
+
+// AdminTriggerCleanupRequest is AdminServer.TriggerCleanup's request.
+type AdminTriggerCleanupRequest struct{}
+
+// AdminTriggerCleanupResponse is AdminServer.TriggerCleanup's response.
+type AdminTriggerCleanupResponse struct {
+	// Removed is how many expired tokens were deleted.
+	Removed int64
+}
+
+// ErrCleanupNotSupported is returned by AdminServer.TriggerCleanup when
+// the configured TokenStorage backend has no ExpiredTokenVacuumer of its
+// own to trigger (it expires tokens natively instead).
+var ErrCleanupNotSupported = errors.New("mikhail: configured token storage backend does not support on-demand cleanup")
+
+// TriggerCleanup reaps expired tokens from the configured storage
+// backend immediately, rather than waiting for whatever background
+// vacuumer or poller is already scheduled.
+func (s *AdminServer) TriggerCleanup(ctx context.Context, req *AdminTriggerCleanupRequest) (*AdminTriggerCleanupResponse, error) {
+	vacuumer, ok := s.storage.(ExpiredTokenVacuumer)
+	if !ok {
+		return nil, ErrCleanupNotSupported
+	}
+	removed, err := vacuumer.VacuumExpired(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &AdminTriggerCleanupResponse{Removed: removed}, nil
+}
+
+// ErrLogLevelControlUnavailable is returned by AdminServer.GetLogLevel
+// and AdminServer.SetLogLevel when s was constructed with a nil
+// LogLevelController.
+var ErrLogLevelControlUnavailable = errors.New("mikhail: log level control is not wired up on this instance")
+
+// AdminGetLogLevelRequest is AdminServer.GetLogLevel's request.
+type AdminGetLogLevelRequest struct{}
+
+// AdminGetLogLevelResponse is AdminServer.GetLogLevel's response.
+type AdminGetLogLevelResponse struct {
+	Level string
+}
+
+// GetLogLevel reports the process's current log level.
+func (s *AdminServer) GetLogLevel(ctx context.Context, req *AdminGetLogLevelRequest) (*AdminGetLogLevelResponse, error) {
+	if s.logLevel == nil {
+		return nil, ErrLogLevelControlUnavailable
+	}
+	return &AdminGetLogLevelResponse{Level: s.logLevel.Level().String()}, nil
+}
+
+// AdminSetLogLevelRequest is AdminServer.SetLogLevel's request.
+type AdminSetLogLevelRequest struct {
+	// Level is one of "debug", "info", "warn", or "error".
+	Level string
+}
+
+// AdminSetLogLevelResponse is AdminServer.SetLogLevel's response.
+type AdminSetLogLevelResponse struct {
+	Level string
+}
+
+// SetLogLevel changes the process's log level with immediate effect, so
+// an operator can switch a live instance to debug while diagnosing an
+// auth problem and back to info afterward, without a restart that would
+// drop every in-flight session.
+func (s *AdminServer) SetLogLevel(ctx context.Context, req *AdminSetLogLevelRequest) (*AdminSetLogLevelResponse, error) {
+	if s.logLevel == nil {
+		return nil, ErrLogLevelControlUnavailable
+	}
+	level, err := ParseLogLevel(req.Level)
+	if err != nil {
+		return nil, &ValidationError{Violations: []FieldViolation{{Field: "level", Description: err.Error()}}}
+	}
+	s.logLevel.SetLevel(level)
+	return &AdminSetLogLevelResponse{Level: level.String()}, nil
+}
+
+// AdminGetServerInfoRequest is AdminServer.GetServerInfo's request. It
+// carries no fields; GetServerInfo always reports the whole of this
+// process's build and runtime identity.
+type AdminGetServerInfoRequest struct{}
+
+// AdminGetServerInfoResponse is AdminServer.GetServerInfo's response.
+type AdminGetServerInfoResponse struct {
+	// Version, GitCommit, and BuildDate identify the build running, set
+	// at link time; see the mikhail.Version package variable.
+	Version   string
+	GitCommit string
+	BuildDate string
+	// Uptime is how long this process has been running.
+	Uptime time.Duration
+	// StorageBackend is the TokenStorageBackend this process was
+	// configured with (e.g. "redis"), as supplied to NewAdminServer.
+	StorageBackend string
+	// TokenFormat is the access token format issued by this process
+	// (e.g. "JWT-HS256"), or "" if no AuthServer was supplied to
+	// NewAdminServer.
+	TokenFormat string
+	// OAuthProviders lists every registered OAuth provider's name, or
+	// nil if no AuthServer was supplied to NewAdminServer.
+	OAuthProviders []string
+}
+
+// GetServerInfo reports this process's version, build info, enabled
+// features, and uptime, so the gateway and ops tooling in front of it
+// can verify what is actually deployed without trusting a deploy
+// pipeline's own bookkeeping.
+func (s *AdminServer) GetServerInfo(ctx context.Context, req *AdminGetServerInfoRequest) (*AdminGetServerInfoResponse, error) {
+	resp := &AdminGetServerInfoResponse{
+		Version:        Version,
+		GitCommit:      GitCommit,
+		BuildDate:      BuildDate,
+		Uptime:         Uptime(),
+		StorageBackend: s.storageBackend,
+	}
+	if s.authServer != nil {
+		resp.TokenFormat = s.authServer.TokenFormat()
+		resp.OAuthProviders = s.authServer.OAuthProviderNames()
+	}
+	return resp, nil
+}
+
+// AdminAuthorizationInterceptor returns a UnaryServerInterceptor that
+// rejects any call to AdminServer with ErrAdminAccessDenied unless the
+// caller presents either a client certificate (mTLS) or a bearer token
+// whose Principal holds RoleAdmin. Install it ahead of AdminServer's
+// methods in a chain built separately from AuthenticateService's -
+// AdminService is operator-facing and should never be reachable through
+// the same public listener or interceptor chain as sign-in/sign-up.
+func AdminAuthorizationInterceptor() UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error) {
+		if _, ok := ClientCertIdentityFromContext(ctx); ok {
+			return handler(ctx, req)
+		}
+		principal, ok := PrincipalFromContext(ctx)
+		if !ok {
+			return nil, ErrAdminAccessDenied
+		}
+		for _, role := range principal.Roles {
+			if Role(role) == RoleAdmin {
+				return handler(ctx, req)
+			}
+		}
+		return nil, ErrAdminAccessDenied
+	}
+}
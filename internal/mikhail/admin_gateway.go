@@ -0,0 +1,401 @@
+package mikhail
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+)
+
+// AdminServerConfig configures the optional admin HTTP listener
+// NewAdminGatewayMux is served from. It is off by default and, when
+// enabled, binds to localhost only by default: AdminServer exposes
+// session revocation, blocklist control, and log level changes, none of
+// which should be reachable from outside the host without an operator
+// deliberately choosing to widen Addr.
+type AdminServerConfig struct {
+	// Enabled turns the admin server on. Defaults to false.
+	Enabled bool
+	// Addr is the address the admin server listens on. Defaults to
+	// "127.0.0.1:9444".
+	Addr string
+	// TLSCertFile, TLSKeyFile, and TLSClientCAFile, if all set, make the
+	// admin listener terminate TLS with NewMutualTLSConfig instead of
+	// serving plaintext HTTP - required for AdminAuthorizationInterceptor's
+	// mTLS path (ClientCertIdentityFromContext) to ever see a certificate,
+	// since that identity can only come from a completed TLS handshake. An
+	// admin server with any of the three unset falls back to plaintext, so
+	// ClientCertIdentityFromContext never matches and every call must
+	// authenticate with an admin bearer token instead.
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
+}
+
+// DefaultAdminServerConfig returns the admin server disabled, bound to
+// localhost if it is ever turned on, with no TLS material configured.
+func DefaultAdminServerConfig() AdminServerConfig {
+	return AdminServerConfig{Enabled: false, Addr: "127.0.0.1:9444"}
+}
+
+// LoadAdminServerConfig builds an AdminServerConfig from
+// MIKHAIL_ADMIN_SERVER_ENABLED, MIKHAIL_ADMIN_SERVER_ADDR,
+// MIKHAIL_ADMIN_SERVER_TLS_CERT_FILE, MIKHAIL_ADMIN_SERVER_TLS_KEY_FILE,
+// and MIKHAIL_ADMIN_SERVER_TLS_CLIENT_CA_FILE, falling back to
+// DefaultAdminServerConfig for anything unset. An operator who sets
+// MIKHAIL_ADMIN_SERVER_ADDR to a non-localhost address has made that
+// call themselves; this function does not second-guess it.
+func LoadAdminServerConfig() (AdminServerConfig, error) {
+	cfg := DefaultAdminServerConfig()
+	if err := overrideBool(&cfg.Enabled, "MIKHAIL_ADMIN_SERVER_ENABLED"); err != nil {
+		return AdminServerConfig{}, err
+	}
+	if raw := os.Getenv("MIKHAIL_ADMIN_SERVER_ADDR"); raw != "" {
+		cfg.Addr = raw
+	}
+	cfg.TLSCertFile = os.Getenv("MIKHAIL_ADMIN_SERVER_TLS_CERT_FILE")
+	cfg.TLSKeyFile = os.Getenv("MIKHAIL_ADMIN_SERVER_TLS_KEY_FILE")
+	cfg.TLSClientCAFile = os.Getenv("MIKHAIL_ADMIN_SERVER_TLS_CLIENT_CA_FILE")
+	return cfg, nil
+}
+
+// NewAdminServerListener wraps handler in an *http.Server listening on
+// cfg.Addr. If cfg.TLSCertFile, cfg.TLSKeyFile, and cfg.TLSClientCAFile
+// are all set, the server's TLSConfig is built with NewMutualTLSConfig,
+// requiring and verifying a client certificate on every connection; the
+// returned server is not started either way - call StartAdminServer.
+func NewAdminServerListener(cfg AdminServerConfig, handler http.Handler) (*http.Server, error) {
+	srv := &http.Server{Addr: cfg.Addr, Handler: handler}
+	if cfg.TLSCertFile != "" && cfg.TLSKeyFile != "" && cfg.TLSClientCAFile != "" {
+		tlsConfig, err := NewMutualTLSConfig(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		srv.TLSConfig = tlsConfig
+	}
+	return srv, nil
+}
+
+// StartAdminServer starts srv in its own goroutine, serving TLS with the
+// certificate already loaded into srv.TLSConfig (see
+// NewAdminServerListener) if one is set, or plaintext HTTP otherwise -
+// the same fire-and-log pattern StartHealthServer and StartDebugServer
+// use.
+func StartAdminServer(srv *http.Server) {
+	go func() {
+		var err error
+		if srv.TLSConfig != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("mikhail: admin server on %s stopped: %v", srv.Addr, err)
+		}
+	}()
+}
+
+// adminDispatch runs req through chain, the same adaptation dispatch
+// provides for the main gateway mux. The admin handlers report its
+// errors through writeAdminError, which defaults every response status
+// to 403: unlike SignIn or RefreshToken, every error an
+// AdminService call can return - ErrAdminAccessDenied first among them -
+// is the caller's to fix by authenticating as an admin, not a 401
+// inviting them to retry with a different bearer token.
+func adminDispatch(r *http.Request, chain UnaryServerInterceptor, fullMethod string, req interface{}, handler UnaryHandler) (interface{}, error) {
+	return dispatch(contextFromHTTPRequest(r), chain, fullMethod, req, handler)
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v interface{}) {
+	writeGatewayJSON(w, status, v)
+}
+
+func writeAdminError(w http.ResponseWriter, err error) {
+	writeGatewayError(w, http.StatusForbidden, err)
+}
+
+// adminLookupTokenHandler implements POST /admin/v1/tokens/lookup, the
+// JSON/HTTP mapping of AdminService.LookupToken.
+func adminLookupTokenHandler(admin *AdminServer, chain UnaryServerInterceptor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req AdminLookupTokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeGatewayError(w, http.StatusBadRequest, err)
+			return
+		}
+		result, err := adminDispatch(r, chain, "/kingdom.auth.v1.AdminService/LookupToken", &req,
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return admin.LookupToken(ctx, req.(*AdminLookupTokenRequest))
+			})
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, result.(*AdminLookupTokenResponse))
+	}
+}
+
+// adminListSessionsHandler implements POST /admin/v1/sessions/list, the
+// JSON/HTTP mapping of AdminService.ListSessions.
+func adminListSessionsHandler(admin *AdminServer, chain UnaryServerInterceptor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req AdminListSessionsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeGatewayError(w, http.StatusBadRequest, err)
+			return
+		}
+		result, err := adminDispatch(r, chain, "/kingdom.auth.v1.AdminService/ListSessions", &req,
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return admin.ListSessions(ctx, req.(*AdminListSessionsRequest))
+			})
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, result.(*AdminListSessionsResponse))
+	}
+}
+
+// adminRevokeSessionsHandler implements POST /admin/v1/sessions/revoke,
+// the JSON/HTTP mapping of AdminService.RevokeSessions.
+func adminRevokeSessionsHandler(admin *AdminServer, chain UnaryServerInterceptor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req AdminRevokeSessionsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeGatewayError(w, http.StatusBadRequest, err)
+			return
+		}
+		result, err := adminDispatch(r, chain, "/kingdom.auth.v1.AdminService/RevokeSessions", &req,
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return admin.RevokeSessions(ctx, req.(*AdminRevokeSessionsRequest))
+			})
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, result.(*AdminRevokeSessionsResponse))
+	}
+}
+
+// adminBanHandler implements POST /admin/v1/blocklist/ban, the JSON/HTTP
+// mapping of AdminService.Ban.
+func adminBanHandler(admin *AdminServer, chain UnaryServerInterceptor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req AdminBanRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeGatewayError(w, http.StatusBadRequest, err)
+			return
+		}
+		result, err := adminDispatch(r, chain, "/kingdom.auth.v1.AdminService/Ban", &req,
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return admin.Ban(ctx, req.(*AdminBanRequest))
+			})
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, result.(*AdminBanResponse))
+	}
+}
+
+// adminUnbanHandler implements POST /admin/v1/blocklist/unban, the
+// JSON/HTTP mapping of AdminService.Unban.
+func adminUnbanHandler(admin *AdminServer, chain UnaryServerInterceptor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req AdminUnbanRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeGatewayError(w, http.StatusBadRequest, err)
+			return
+		}
+		result, err := adminDispatch(r, chain, "/kingdom.auth.v1.AdminService/Unban", &req,
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return admin.Unban(ctx, req.(*AdminUnbanRequest))
+			})
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, result.(*AdminUnbanResponse))
+	}
+}
+
+// adminListBlocklistHandler implements GET /admin/v1/blocklist, the
+// JSON/HTTP mapping of AdminService.ListBlocklist.
+func adminListBlocklistHandler(admin *AdminServer, chain UnaryServerInterceptor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		req := &AdminListBlocklistRequest{}
+		result, err := adminDispatch(r, chain, "/kingdom.auth.v1.AdminService/ListBlocklist", req,
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return admin.ListBlocklist(ctx, req.(*AdminListBlocklistRequest))
+			})
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, result.(*AdminListBlocklistResponse))
+	}
+}
+
+// adminQueryAuditLogHandler implements GET /admin/v1/audit-log, the
+// JSON/HTTP mapping of AdminService.QueryAuditLog.
+func adminQueryAuditLogHandler(admin *AdminServer, chain UnaryServerInterceptor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		req := &AdminQueryAuditLogRequest{ActorID: r.URL.Query().Get("actor_id")}
+		result, err := adminDispatch(r, chain, "/kingdom.auth.v1.AdminService/QueryAuditLog", req,
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return admin.QueryAuditLog(ctx, req.(*AdminQueryAuditLogRequest))
+			})
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, result.(*AdminQueryAuditLogResponse))
+	}
+}
+
+// adminTriggerCleanupHandler implements POST /admin/v1/storage/cleanup,
+// the JSON/HTTP mapping of AdminService.TriggerCleanup.
+func adminTriggerCleanupHandler(admin *AdminServer, chain UnaryServerInterceptor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		req := &AdminTriggerCleanupRequest{}
+		result, err := adminDispatch(r, chain, "/kingdom.auth.v1.AdminService/TriggerCleanup", req,
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return admin.TriggerCleanup(ctx, req.(*AdminTriggerCleanupRequest))
+			})
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, result.(*AdminTriggerCleanupResponse))
+	}
+}
+
+// adminGetLogLevelHandler implements GET /admin/v1/log-level, the
+// JSON/HTTP mapping of AdminService.GetLogLevel.
+func adminGetLogLevelHandler(admin *AdminServer, chain UnaryServerInterceptor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		req := &AdminGetLogLevelRequest{}
+		result, err := adminDispatch(r, chain, "/kingdom.auth.v1.AdminService/GetLogLevel", req,
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return admin.GetLogLevel(ctx, req.(*AdminGetLogLevelRequest))
+			})
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, result.(*AdminGetLogLevelResponse))
+	}
+}
+
+// adminSetLogLevelHandler implements POST /admin/v1/log-level, the
+// JSON/HTTP mapping of AdminService.SetLogLevel.
+func adminSetLogLevelHandler(admin *AdminServer, chain UnaryServerInterceptor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req AdminSetLogLevelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeGatewayError(w, http.StatusBadRequest, err)
+			return
+		}
+		result, err := adminDispatch(r, chain, "/kingdom.auth.v1.AdminService/SetLogLevel", &req,
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return admin.SetLogLevel(ctx, req.(*AdminSetLogLevelRequest))
+			})
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, result.(*AdminSetLogLevelResponse))
+	}
+}
+
+// adminGetServerInfoHandler implements GET /admin/v1/server-info, the
+// JSON/HTTP mapping of AdminService.GetServerInfo.
+func adminGetServerInfoHandler(admin *AdminServer, chain UnaryServerInterceptor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		req := &AdminGetServerInfoRequest{}
+		result, err := adminDispatch(r, chain, "/kingdom.auth.v1.AdminService/GetServerInfo", req,
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return admin.GetServerInfo(ctx, req.(*AdminGetServerInfoRequest))
+			})
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, result.(*AdminGetServerInfoResponse))
+	}
+}
+
+// NewAdminGatewayMux returns an *http.ServeMux exposing AdminService
+// over HTTP/JSON, the same hand-written grpc-gateway equivalent
+// NewGatewayMux is for AuthenticateService. chain is built from a
+// registry that includes AdminAuthorizationInterceptor (see
+// AdminAuthorizationInterceptor's own doc comment); every route
+// dispatches through it before reaching admin, so an unauthenticated or
+// non-admin caller never reaches AdminServer's methods at all. Mount
+// this on its own *http.Server (see NewAdminServerListener) - never
+// behind the same listener NewGatewayMux serves from.
+func NewAdminGatewayMux(admin *AdminServer, chain UnaryServerInterceptor) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/v1/tokens/lookup", adminLookupTokenHandler(admin, chain))
+	mux.HandleFunc("/admin/v1/sessions/list", adminListSessionsHandler(admin, chain))
+	mux.HandleFunc("/admin/v1/sessions/revoke", adminRevokeSessionsHandler(admin, chain))
+	mux.HandleFunc("/admin/v1/blocklist/ban", adminBanHandler(admin, chain))
+	mux.HandleFunc("/admin/v1/blocklist/unban", adminUnbanHandler(admin, chain))
+	mux.HandleFunc("/admin/v1/blocklist", adminListBlocklistHandler(admin, chain))
+	mux.HandleFunc("/admin/v1/audit-log", adminQueryAuditLogHandler(admin, chain))
+	mux.HandleFunc("/admin/v1/storage/cleanup", adminTriggerCleanupHandler(admin, chain))
+	mux.HandleFunc("/admin/v1/log-level", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			adminGetLogLevelHandler(admin, chain)(w, r)
+			return
+		}
+		adminSetLogLevelHandler(admin, chain)(w, r)
+	})
+	mux.HandleFunc("/admin/v1/server-info", adminGetServerInfoHandler(admin, chain))
+	return mux
+}
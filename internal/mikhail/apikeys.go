@@ -0,0 +1,104 @@
+package mikhail
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// apiKeyPrefix marks a token as a Mikhail API key rather than a JWT
+// access token, so VerifyToken can route it to the right store without
+// attempting a JWT parse first.
+const apiKeyPrefix = "mik_"
+
+// ErrAPIKeyRevoked is returned when a presented API key has been revoked.
+var ErrAPIKeyRevoked = errors.New("mikhail: API key revoked")
+
+// APIKey describes a long-lived credential for a machine client. Unlike
+// client-credentials access tokens, API keys do not expire on their own;
+// they are valid until explicitly revoked.
+type APIKey struct {
+	KeyID     string
+	OwnerID   string
+	Scopes    []string
+	CreatedAt time.Time
+	Revoked   bool
+
+	secretHash string
+}
+
+// APIKeyStore persists API keys. Implementations must be safe for
+// concurrent use.
+type APIKeyStore interface {
+	Create(ownerID string, scopes []string) (rawKey string, key APIKey, err error)
+	Verify(rawKey string) (APIKey, bool, error)
+	Revoke(keyID string) error
+}
+
+// InMemoryAPIKeyStore is an APIKeyStore backed by a guarded map.
+type InMemoryAPIKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]APIKey // keyed by secretHash
+}
+
+// NewInMemoryAPIKeyStore returns an empty InMemoryAPIKeyStore.
+func NewInMemoryAPIKeyStore() *InMemoryAPIKeyStore {
+	return &InMemoryAPIKeyStore{keys: make(map[string]APIKey)}
+}
+
+func (s *InMemoryAPIKeyStore) Create(ownerID string, scopes []string) (string, APIKey, error) {
+	secret, err := generateAuthToken()
+	if err != nil {
+		return "", APIKey{}, err
+	}
+	rawKey := apiKeyPrefix + secret
+	hash := hashAPIKey(rawKey)
+
+	keyID, err := generateAuthToken()
+	if err != nil {
+		return "", APIKey{}, err
+	}
+	key := APIKey{
+		KeyID:      keyID,
+		OwnerID:    ownerID,
+		Scopes:     scopes,
+		CreatedAt:  time.Now(),
+		secretHash: hash,
+	}
+
+	s.mu.Lock()
+	s.keys[hash] = key
+	s.mu.Unlock()
+
+	return rawKey, key, nil
+}
+
+func (s *InMemoryAPIKeyStore) Verify(rawKey string) (APIKey, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[hashAPIKey(rawKey)]
+	if !ok {
+		return APIKey{}, false, nil
+	}
+	return key, true, nil
+}
+
+func (s *InMemoryAPIKeyStore) Revoke(keyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, key := range s.keys {
+		if key.KeyID == keyID {
+			key.Revoked = true
+			s.keys[hash] = key
+			return nil
+		}
+	}
+	return nil
+}
+
+func hashAPIKey(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(sum[:])
+}
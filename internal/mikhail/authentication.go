@@ -0,0 +1,151 @@
+package mikhail
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/TwelveFacedJanus/Kingdom-System/internal/authpb"
+)
+
+// ErrMissingBearerToken is returned by AuthenticationInterceptor when the
+// incoming call carries no "authorization" metadata header, or one not
+// in "Bearer <token>" form.
+var ErrMissingBearerToken = errors.New("mikhail: missing bearer token")
+
+// ErrInvalidBearerToken is returned by AuthenticationInterceptor when the
+// bearer token is present but VerifyToken rejects it.
+var ErrInvalidBearerToken = errors.New("mikhail: invalid or expired bearer token")
+
+// Metadata is the minimal per-call header carrier Mikhail's own
+// interceptors need, mirroring google.golang.org/grpc/metadata.MD's
+// shape (a header name maps to every value sent under it) closely
+// enough that a deployment wiring AuthenticationInterceptor into a real
+// grpc.Server can populate it from metadata.FromIncomingContext with a
+// one-line adapter, without this module depending on grpc itself.
+type Metadata map[string][]string
+
+// Get returns the first value of key, case-insensitively, or "" if key
+// was not sent.
+func (m Metadata) Get(key string) string {
+	for k, values := range m {
+		if strings.EqualFold(k, key) && len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+type incomingMetadataKey struct{}
+
+// ContextWithIncomingMetadata returns a context carrying md, for a
+// transport layer to attach before dispatching to the interceptor chain
+// (the same way it would call ContextWithClientCertIdentity after a
+// mTLS handshake).
+func ContextWithIncomingMetadata(ctx context.Context, md Metadata) context.Context {
+	return context.WithValue(ctx, incomingMetadataKey{}, md)
+}
+
+// IncomingMetadataFromContext returns the Metadata ContextWithIncomingMetadata
+// attached to ctx, or ok=false if none was.
+func IncomingMetadataFromContext(ctx context.Context) (Metadata, bool) {
+	md, ok := ctx.Value(incomingMetadataKey{}).(Metadata)
+	return md, ok
+}
+
+// Principal is the authenticated caller AuthenticationInterceptor
+// attaches to a call's context after validating its bearer token, for
+// GetMe and future protected RPCs to trust instead of an unauthenticated
+// request field like GetMeRequest.UserID.
+type Principal struct {
+	UserID string
+	Scopes []string
+	Roles  []string
+}
+
+type principalKey struct{}
+
+// ContextWithPrincipal returns a context carrying principal.
+func ContextWithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the Principal AuthenticationInterceptor
+// attached to ctx, or ok=false if the call was never authenticated (no
+// bearer token, or AuthenticationInterceptor is not installed).
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// bearerToken extracts the token from a "Bearer <token>" authorization
+// header value, or ok=false if header is empty or not in that form.
+func bearerToken(header string) (token string, ok bool) {
+	const prefix = "Bearer "
+	if !strings.HasPrefix(header, prefix) {
+		return "", false
+	}
+	token = strings.TrimSpace(header[len(prefix):])
+	return token, token != ""
+}
+
+// DefaultPublicMethods lists the AuthenticateService RPCs
+// AuthenticationInterceptor lets through with no bearer token at all:
+// the ways a caller obtains one in the first place (plus VerifyToken,
+// whose whole job is validating a token that might not be valid yet).
+// Every other RPC requires one. A deployment adding its own public RPC
+// passes a superset of this to AuthenticationInterceptor instead.
+var DefaultPublicMethods = map[string]bool{
+	"/kingdom.auth.v1.AuthenticateService/SignIn":         true,
+	"/kingdom.auth.v1.AuthenticateService/SignUp":         true,
+	"/kingdom.auth.v1.AuthenticateService/RefreshToken":   true,
+	"/kingdom.auth.v1.AuthenticateService/OAuth2Callback": true,
+	"/kingdom.auth.v1.AuthenticateService/VerifyToken":    true,
+}
+
+// AuthenticationInterceptor returns a UnaryServerInterceptor that reads
+// the bearer token out of ctx's incoming "authorization" metadata header
+// (attached by the transport layer via ContextWithIncomingMetadata),
+// validates it through server's VerifyToken the same way the VerifyToken
+// RPC itself does, and attaches the result to ctx as a Principal for the
+// handler (and any interceptor after this one) to read via
+// PrincipalFromContext. Calls to a method in publicMethods skip all of
+// that and run with no Principal attached - see DefaultPublicMethods.
+//
+// A call with no bearer token, or one VerifyToken rejects, is failed
+// with ErrMissingBearerToken/ErrInvalidBearerToken rather than passed
+// through unauthenticated.
+//
+// A call that already carries a ClientCertIdentity (see
+// ContextWithClientCertIdentity) is let through with no Principal
+// attached, the same way AdminAuthorizationInterceptor treats mTLS as an
+// alternative to a bearer token rather than a second credential on top
+// of one: a caller authenticated by its certificate has already proven
+// who it is.
+func AuthenticationInterceptor(server *AuthServer, publicMethods map[string]bool) UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error) {
+		if _, ok := ClientCertIdentityFromContext(ctx); ok {
+			return handler(ctx, req)
+		}
+		if info != nil && publicMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		md, _ := IncomingMetadataFromContext(ctx)
+		token, ok := bearerToken(md.Get("authorization"))
+		if !ok {
+			return nil, ErrMissingBearerToken
+		}
+
+		result, err := server.VerifyToken(ctx, &authpb.VerifyTokenRequest{Token: token})
+		if err != nil {
+			return nil, err
+		}
+		if !result.Valid {
+			return nil, ErrInvalidBearerToken
+		}
+
+		ctx = ContextWithPrincipal(ctx, Principal{UserID: result.UserID, Scopes: result.Scopes, Roles: result.Roles})
+		return handler(ctx, req)
+	}
+}
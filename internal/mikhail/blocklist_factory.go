@@ -0,0 +1,40 @@
+package mikhail
+
+import (
+	"fmt"
+	"os"
+)
+
+// BlocklistBackend names a BlocklistStore implementation
+// NewBlocklistStoreFromEnv knows how to select.
+type BlocklistBackend string
+
+const (
+	BlocklistBackendMemory BlocklistBackend = "memory"
+	BlocklistBackendRedis  BlocklistBackend = "redis"
+)
+
+// NewBlocklistStoreFromEnv selects and constructs a BlocklistStore from
+// MIKHAIL_BLOCKLIST_BACKEND, defaulting to in-memory so a deployment
+// that never sets it keeps working unchanged. The redis backend reads
+// MIKHAIL_REDIS_ADDR, the same variable NewTokenStorageFromEnv's and
+// NewRateLimiterFromEnv's redis backends use.
+func NewBlocklistStoreFromEnv() (BlocklistStore, error) {
+	backend := BlocklistBackend(os.Getenv("MIKHAIL_BLOCKLIST_BACKEND"))
+	if backend == "" {
+		backend = BlocklistBackendMemory
+	}
+
+	switch backend {
+	case BlocklistBackendMemory:
+		return NewInMemoryBlocklistStore(), nil
+	case BlocklistBackendRedis:
+		addr := os.Getenv("MIKHAIL_REDIS_ADDR")
+		if addr == "" {
+			return nil, fmt.Errorf("mikhail: MIKHAIL_BLOCKLIST_BACKEND=redis requires MIKHAIL_REDIS_ADDR")
+		}
+		return NewRedisBlocklistStore(addr)
+	default:
+		return nil, fmt.Errorf("mikhail: unknown MIKHAIL_BLOCKLIST_BACKEND %q", backend)
+	}
+}
@@ -0,0 +1,276 @@
+package mikhail
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RedisBlocklistStore is a BlocklistStore backed by Redis, so a ban an
+// operator places takes effect across every Mikhail replica rather than
+// just the one the admin call happened to land on (what
+// InMemoryBlocklistStore gives). Each ban is a Redis hash (reason,
+// actor_id) with Redis's own EXPIRE enforcing its TTL, plus a per-type
+// set tracking which values have an entry at all, since Redis has no
+// "list every key matching a pattern" operation this client implements.
+type RedisBlocklistStore struct {
+	mu     sync.Mutex
+	client *redisClient
+	addr   string
+}
+
+// NewRedisBlocklistStore dials addr ("host:port") and returns a
+// RedisBlocklistStore.
+func NewRedisBlocklistStore(addr string) (*RedisBlocklistStore, error) {
+	client, err := dialRedis(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisBlocklistStore{client: client, addr: addr}, nil
+}
+
+const redisBlocklistKeyPrefix = "mikhail:blocklist:"
+
+// redisBlocklistAuditKey is the list RedisBlocklistStore.Audit reads
+// from, capped at redisBlocklistAuditCap entries so an instance left
+// running for months does not grow it forever.
+const redisBlocklistAuditKey = redisBlocklistKeyPrefix + "audit"
+
+const redisBlocklistAuditCap = 1000
+
+func redisBlocklistEntryKey(typ BlocklistEntryType, value string) string {
+	return redisBlocklistKeyPrefix + "entry:" + string(typ) + ":" + value
+}
+
+func redisBlocklistSetKey(typ BlocklistEntryType) string {
+	return redisBlocklistKeyPrefix + "set:" + string(typ)
+}
+
+func (s *RedisBlocklistStore) Ban(typ BlocklistEntryType, value, reason, actorID string, ttl time.Duration) error {
+	entryKey := redisBlocklistEntryKey(typ, value)
+	err := s.withClient(func(c *redisClient) error {
+		if _, _, err := c.Eval(redisBlocklistBanScript, 1, []string{
+			entryKey, reason, actorID, strconv.Itoa(int(ttl.Seconds())),
+		}); err != nil {
+			return err
+		}
+		if err := c.SAdd(redisBlocklistSetKey(typ), value); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.appendAudit(BlocklistAuditEntry{Type: typ, Value: value, Action: "ban", Reason: reason, ActorID: actorID, At: time.Now()})
+}
+
+// redisBlocklistBanScript writes an entry hash and, only if ARGV[3]'s
+// TTL is positive, sets its expiry; a plain HMSET followed by a
+// conditional EXPIRE from Go would leave a brief window where the hash
+// exists with no TTL if the process crashed between the two calls.
+//
+// KEYS: 1 the entry hash.
+// ARGV: 1 reason, 2 actor_id, 3 TTL in seconds (0 for a permanent ban).
+const redisBlocklistBanScript = `
+redis.call('HMSET', KEYS[1], 'reason', ARGV[1], 'actor_id', ARGV[2])
+local ttl = tonumber(ARGV[3])
+if ttl > 0 then
+	redis.call('EXPIRE', KEYS[1], ttl)
+end
+return '1'
+`
+
+func (s *RedisBlocklistStore) Unban(typ BlocklistEntryType, value, actorID string) error {
+	entryKey := redisBlocklistEntryKey(typ, value)
+	var existed bool
+	err := s.withClient(func(c *redisClient) error {
+		fields, oks, err := c.HMGet(entryKey, "reason")
+		if err != nil {
+			return err
+		}
+		existed = len(fields) == 1 && oks[0]
+		if !existed {
+			return nil
+		}
+		if err := c.Del(entryKey); err != nil {
+			return err
+		}
+		return c.SRem(redisBlocklistSetKey(typ), value)
+	})
+	if err != nil {
+		return err
+	}
+	if !existed {
+		return ErrNotBanned
+	}
+	return s.appendAudit(BlocklistAuditEntry{Type: typ, Value: value, Action: "unban", ActorID: actorID, At: time.Now()})
+}
+
+// checkExact reports whether typ/value has a live (non-expired) entry,
+// lazily dropping value from typ's set if the entry itself has already
+// expired out of Redis.
+func (s *RedisBlocklistStore) checkExact(typ BlocklistEntryType, value string) (bool, string, error) {
+	entryKey := redisBlocklistEntryKey(typ, value)
+	var reason string
+	var found bool
+	err := s.withClient(func(c *redisClient) error {
+		fields, oks, err := c.HMGet(entryKey, "reason")
+		if err != nil {
+			return err
+		}
+		if len(fields) == 1 && oks[0] {
+			found, reason = true, fields[0]
+			return nil
+		}
+		return c.SRem(redisBlocklistSetKey(typ), value)
+	})
+	if err != nil {
+		return false, "", err
+	}
+	return found, reason, nil
+}
+
+func (s *RedisBlocklistStore) CheckUserID(userID string) (bool, string, error) {
+	return s.checkExact(BlocklistEntryUserID, userID)
+}
+
+func (s *RedisBlocklistStore) CheckDeviceID(deviceID string) (bool, string, error) {
+	return s.checkExact(BlocklistEntryDeviceID, deviceID)
+}
+
+func (s *RedisBlocklistStore) CheckIP(ip string) (bool, string, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false, "", nil
+	}
+	var cidrs []string
+	if err := s.withClient(func(c *redisClient) error {
+		members, err := c.SMembers(redisBlocklistSetKey(BlocklistEntryCIDR))
+		cidrs = members
+		return err
+	}); err != nil {
+		return false, "", err
+	}
+	for _, cidr := range cidrs {
+		blocked, reason, err := s.checkExact(BlocklistEntryCIDR, cidr)
+		if err != nil {
+			return false, "", err
+		}
+		if !blocked {
+			continue
+		}
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		if ipnet.Contains(parsed) {
+			return true, reason, nil
+		}
+	}
+	return false, "", nil
+}
+
+func (s *RedisBlocklistStore) RecordBlockedRequest(typ BlocklistEntryType, value, reason, fullMethod string) error {
+	return s.appendAudit(BlocklistAuditEntry{Type: typ, Value: value, Action: "blocked_request", Reason: reason, FullMethod: fullMethod, At: time.Now()})
+}
+
+func (s *RedisBlocklistStore) appendAudit(entry BlocklistAuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return s.withClient(func(c *redisClient) error {
+		if err := c.RPush(redisBlocklistAuditKey, string(line)); err != nil {
+			return err
+		}
+		return c.LTrim(redisBlocklistAuditKey, -redisBlocklistAuditCap, -1)
+	})
+}
+
+// List returns every entry in the blocklist. ExpiresAt is always zero in
+// its results, temporary bans included: the remaining TTL of an entry's
+// key is not read back.
+func (s *RedisBlocklistStore) List() ([]BlocklistEntry, error) {
+	var out []BlocklistEntry
+	for _, typ := range []BlocklistEntryType{BlocklistEntryCIDR, BlocklistEntryUserID, BlocklistEntryDeviceID} {
+		var values []string
+		if err := s.withClient(func(c *redisClient) error {
+			members, err := c.SMembers(redisBlocklistSetKey(typ))
+			values = members
+			return err
+		}); err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			entryKey := redisBlocklistEntryKey(typ, value)
+			var fields []string
+			var oks []bool
+			if err := s.withClient(func(c *redisClient) error {
+				f, o, err := c.HMGet(entryKey, "reason", "actor_id")
+				fields, oks = f, o
+				return err
+			}); err != nil {
+				return nil, err
+			}
+			if len(fields) != 2 || !oks[0] {
+				continue
+			}
+			out = append(out, BlocklistEntry{Type: typ, Value: value, Reason: fields[0], ActorID: fields[1]})
+		}
+	}
+	return out, nil
+}
+
+func (s *RedisBlocklistStore) Audit(limit int) ([]BlocklistAuditEntry, error) {
+	if limit <= 0 || limit > redisBlocklistAuditCap {
+		limit = redisBlocklistAuditCap
+	}
+	var lines []string
+	if err := s.withClient(func(c *redisClient) error {
+		l, err := c.LRange(redisBlocklistAuditKey, -limit, -1)
+		lines = l
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	out := make([]BlocklistAuditEntry, 0, len(lines))
+	for _, line := range lines {
+		var entry BlocklistAuditEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("mikhail: decoding blocklist audit entry: %w", err)
+		}
+		out = append(out, entry)
+	}
+	return out, nil
+}
+
+// withClient runs op against the current connection, redialing addr and
+// retrying once if op's first attempt fails. Mirrors RedisTokenStorage's
+// withClient, minus Sentinel support.
+func (s *RedisBlocklistStore) withClient(op func(*redisClient) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := op(s.client)
+	if err == nil {
+		return nil
+	}
+	client, dialErr := dialRedis(s.addr)
+	if dialErr != nil {
+		return err
+	}
+	s.client.Close()
+	s.client = client
+	return op(s.client)
+}
+
+// Ping reports whether the underlying Redis connection is reachable, so
+// RedisBlocklistStore satisfies Pinger the same way RedisTokenStorage
+// does.
+func (s *RedisBlocklistStore) Ping() error {
+	return s.withClient(func(c *redisClient) error { return c.Ping() })
+}
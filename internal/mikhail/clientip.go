@@ -0,0 +1,100 @@
+package mikhail
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+)
+
+// TrustedProxyConfig lists the CIDR ranges ClientIP trusts to set an
+// "x-forwarded-for" header truthfully, e.g. the ingress gateway's own
+// subnet. A direct peer outside every listed range is never a proxy
+// Mikhail is willing to take a client IP's word from - its own
+// PeerInfo.Addr is used instead, the same way a web server ignores
+// X-Forwarded-For from a peer it does not recognize as its load
+// balancer.
+type TrustedProxyConfig struct {
+	CIDRs []*net.IPNet
+}
+
+// DefaultTrustedProxyConfig trusts nothing: with no configured proxy
+// range, ClientIP always falls back to the raw peer address, the safe
+// default for a deployment that has not told Mikhail what its gateway's
+// subnet is.
+func DefaultTrustedProxyConfig() TrustedProxyConfig {
+	return TrustedProxyConfig{}
+}
+
+// LoadTrustedProxyConfig builds a TrustedProxyConfig from the
+// comma-separated CIDR list in MIKHAIL_TRUSTED_PROXY_CIDRS (e.g.
+// "10.0.0.0/8,172.16.0.0/12" for a gateway running in a private
+// subnet), falling back to DefaultTrustedProxyConfig when unset.
+func LoadTrustedProxyConfig() (TrustedProxyConfig, error) {
+	cfg := DefaultTrustedProxyConfig()
+	raw := os.Getenv("MIKHAIL_TRUSTED_PROXY_CIDRS")
+	if raw == "" {
+		return cfg, nil
+	}
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		_, cidr, err := net.ParseCIDR(entry)
+		if err != nil {
+			return TrustedProxyConfig{}, err
+		}
+		cfg.CIDRs = append(cfg.CIDRs, cidr)
+	}
+	return cfg, nil
+}
+
+// trusts reports whether host (a bare IP, no port) falls inside one of
+// cfg's trusted ranges.
+func (cfg TrustedProxyConfig) trusts(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range cfg.CIDRs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the bare IP (no port) Mikhail should treat as the
+// caller's for rate limiting, blocklist checks, and audit records. It
+// prefers the leftmost address in an
+// "x-forwarded-for" header - the original client, per the usual
+// left-to-right append-only convention every hop follows - but only
+// when the direct peer on PeerInfoFromContext's address is itself inside
+// cfg's trusted ranges; otherwise a caller could simply send its own
+// forged X-Forwarded-For and rate-limit as someone else. With no
+// PeerInfo on ctx at all (no transport attached one, e.g. a call made
+// directly in-process) ClientIP returns "".
+func ClientIP(ctx context.Context, cfg TrustedProxyConfig) string {
+	peer, ok := PeerInfoFromContext(ctx)
+	if !ok || peer.Addr == "" {
+		return ""
+	}
+	peerHost := peer.Addr
+	if host, _, err := net.SplitHostPort(peer.Addr); err == nil {
+		peerHost = host
+	}
+
+	if cfg.trusts(peerHost) {
+		if md, ok := IncomingMetadataFromContext(ctx); ok {
+			if xff := md.Get("x-forwarded-for"); xff != "" {
+				first := strings.TrimSpace(strings.Split(xff, ",")[0])
+				if first != "" {
+					return first
+				}
+			}
+		}
+	}
+
+	return peerHost
+}
@@ -0,0 +1,70 @@
+package mikhail
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrInvalidClientCredentials is returned when a client ID/secret pair
+// does not match a known service account.
+var ErrInvalidClientCredentials = errors.New("mikhail: invalid client credentials")
+
+// ServiceClient is a registered machine client allowed to use the
+// client-credentials grant.
+type ServiceClient struct {
+	ClientID      string
+	SecretHash    string
+	AllowedScopes []string
+	// TenantID is the Kingdom-System tenant this client belongs to, for
+	// AuthServer.ClientCredentials to stamp into the access tokens it
+	// issues on this client's behalf. Empty means the client is
+	// unscoped, the single-tenant case.
+	TenantID string
+}
+
+// ClientStore looks up registered service clients for the
+// client-credentials grant.
+type ClientStore interface {
+	Verify(clientID, clientSecret string) (ServiceClient, bool)
+}
+
+// InMemoryClientStore is a ClientStore backed by a guarded map. It is
+// meant for local development; a real deployment would back this with the
+// same user store as credentialStore or a dedicated clients table.
+type InMemoryClientStore struct {
+	mu      sync.RWMutex
+	clients map[string]ServiceClient
+}
+
+// NewInMemoryClientStore returns an empty InMemoryClientStore.
+func NewInMemoryClientStore() *InMemoryClientStore {
+	return &InMemoryClientStore{clients: make(map[string]ServiceClient)}
+}
+
+// Register adds a service client, hashing its secret the same way user
+// passwords are hashed. tenantID may be empty for an unscoped client.
+func (c *InMemoryClientStore) Register(clientID, clientSecret, tenantID string, allowedScopes []string) error {
+	secretHash, err := hashPassword(clientSecret)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clients[clientID] = ServiceClient{
+		ClientID:      clientID,
+		SecretHash:    secretHash,
+		AllowedScopes: allowedScopes,
+		TenantID:      tenantID,
+	}
+	return nil
+}
+
+func (c *InMemoryClientStore) Verify(clientID, clientSecret string) (ServiceClient, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	client, ok := c.clients[clientID]
+	if !ok || !verifyPassword(client.SecretHash, clientSecret) {
+		return ServiceClient{}, false
+	}
+	return client, true
+}
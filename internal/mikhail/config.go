@@ -0,0 +1,223 @@
+package mikhail
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config holds the token lifetimes AuthServer issues tokens with. They
+// used to be hardcoded constants; pulling them out lets operators tune
+// session length per deployment without a code change.
+type Config struct {
+	AccessTokenTTL time.Duration
+	// RefreshTokenTTL is the refresh-token lifetime for a standard
+	// session, i.e. one where the caller did not ask to be remembered.
+	RefreshTokenTTL time.Duration
+	// RememberMeRefreshTokenTTL is the refresh-token lifetime for a
+	// session started with RememberMe set, typically much longer than
+	// RefreshTokenTTL.
+	RememberMeRefreshTokenTTL time.Duration
+	// YandexTokenTTL bounds how long a session started via Yandex OAuth
+	// is trusted before Mikhail re-checks the upstream grant.
+	YandexTokenTTL time.Duration
+	// ClientCredentialsTokenTTL bounds service-to-service access tokens
+	// issued via the client-credentials grant. These are short-lived
+	// since there is no refresh token to rotate them.
+	ClientCredentialsTokenTTL time.Duration
+	// RefreshGraceWindow is how long a rotated refresh token may still be
+	// presented (returning its successor's pair) before it is treated as
+	// reuse of a stolen token. This absorbs clients retrying a
+	// RefreshToken call whose response was lost in transit.
+	RefreshGraceWindow time.Duration
+	// PasswordResetTokenTTL bounds how long a RequestPasswordReset token
+	// is valid before CompletePasswordReset rejects it.
+	PasswordResetTokenTTL time.Duration
+	// OTPTTL bounds how long a SendOtp code (and the verified state it
+	// leaves behind) is valid before VerifyOtp/SignUp rejects it.
+	OTPTTL time.Duration
+	// OTPMaxAttempts is how many wrong codes VerifyOtp tolerates before
+	// refusing to check any more, even a correct one.
+	OTPMaxAttempts int
+	// EmailVerificationTokenTTL bounds how long a RequestEmailVerification
+	// link is valid before VerifyEmail rejects it.
+	EmailVerificationTokenTTL time.Duration
+	// SignInChallengeTTL bounds how long a SignIn 2FA challenge may be
+	// completed with CompleteSignInChallenge before it must be restarted.
+	SignInChallengeTTL time.Duration
+	// TOTPSkewPeriods is how many adjacent 30-second periods on either
+	// side of "now" a TOTP code is still accepted for, to absorb clock
+	// drift between the server and the authenticator app.
+	TOTPSkewPeriods int
+	// WebAuthnChallengeTTL bounds how long a BeginWebAuthnRegistration or
+	// BeginWebAuthnAssertion challenge is valid before it must be
+	// restarted.
+	WebAuthnChallengeTTL time.Duration
+	// AccountDeletionGracePeriod is how long DeleteAccount keeps an
+	// account's record around (barred from signing in) before it becomes
+	// eligible for an out-of-band hard-delete job to purge it.
+	AccountDeletionGracePeriod time.Duration
+	// MagicLinkTTL bounds how long a RequestMagicLink token is valid
+	// before CompleteMagicLink rejects it.
+	MagicLinkTTL time.Duration
+	// ProfileSyncTTL bounds how long a profile synced from an external
+	// provider is served from the local cache before GetMe triggers a
+	// fresh SyncProfile pull.
+	ProfileSyncTTL time.Duration
+}
+
+// DefaultConfig returns the lifetimes Mikhail used before they became
+// configurable: a 24h access token, a 24h refresh token for a standard
+// session, and a 30 day refresh token for a remember-me session.
+func DefaultConfig() Config {
+	return Config{
+		AccessTokenTTL:             24 * time.Hour,
+		RefreshTokenTTL:            24 * time.Hour,
+		RememberMeRefreshTokenTTL:  30 * 24 * time.Hour,
+		YandexTokenTTL:             24 * time.Hour,
+		ClientCredentialsTokenTTL:  time.Hour,
+		RefreshGraceWindow:         10 * time.Second,
+		PasswordResetTokenTTL:      15 * time.Minute,
+		OTPTTL:                     5 * time.Minute,
+		OTPMaxAttempts:             5,
+		EmailVerificationTokenTTL:  24 * time.Hour,
+		SignInChallengeTTL:         2 * time.Minute,
+		TOTPSkewPeriods:            1,
+		WebAuthnChallengeTTL:       2 * time.Minute,
+		AccountDeletionGracePeriod: 30 * 24 * time.Hour,
+		MagicLinkTTL:               15 * time.Minute,
+		ProfileSyncTTL:             time.Hour,
+	}
+}
+
+// LoadConfig builds a Config from environment variables, falling back to
+// DefaultConfig for anything unset. It returns an error if a variable is
+// set but cannot be parsed, or if the resulting Config fails Validate.
+func LoadConfig() (Config, error) {
+	cfg := DefaultConfig()
+
+	if err := overrideDuration(&cfg.AccessTokenTTL, "MIKHAIL_ACCESS_TOKEN_TTL"); err != nil {
+		return Config{}, err
+	}
+	if err := overrideDuration(&cfg.RefreshTokenTTL, "MIKHAIL_REFRESH_TOKEN_TTL"); err != nil {
+		return Config{}, err
+	}
+	if err := overrideDuration(&cfg.RememberMeRefreshTokenTTL, "MIKHAIL_REMEMBER_ME_REFRESH_TOKEN_TTL"); err != nil {
+		return Config{}, err
+	}
+	if err := overrideDuration(&cfg.YandexTokenTTL, "MIKHAIL_YANDEX_TOKEN_TTL"); err != nil {
+		return Config{}, err
+	}
+	if err := overrideDuration(&cfg.ClientCredentialsTokenTTL, "MIKHAIL_CLIENT_CREDENTIALS_TOKEN_TTL"); err != nil {
+		return Config{}, err
+	}
+	if err := overrideDuration(&cfg.RefreshGraceWindow, "MIKHAIL_REFRESH_GRACE_WINDOW"); err != nil {
+		return Config{}, err
+	}
+	if err := overrideDuration(&cfg.PasswordResetTokenTTL, "MIKHAIL_PASSWORD_RESET_TOKEN_TTL"); err != nil {
+		return Config{}, err
+	}
+	if err := overrideDuration(&cfg.OTPTTL, "MIKHAIL_OTP_TTL"); err != nil {
+		return Config{}, err
+	}
+	if err := overrideInt(&cfg.OTPMaxAttempts, "MIKHAIL_OTP_MAX_ATTEMPTS"); err != nil {
+		return Config{}, err
+	}
+	if err := overrideDuration(&cfg.EmailVerificationTokenTTL, "MIKHAIL_EMAIL_VERIFICATION_TOKEN_TTL"); err != nil {
+		return Config{}, err
+	}
+	if err := overrideDuration(&cfg.SignInChallengeTTL, "MIKHAIL_SIGN_IN_CHALLENGE_TTL"); err != nil {
+		return Config{}, err
+	}
+	if err := overrideInt(&cfg.TOTPSkewPeriods, "MIKHAIL_TOTP_SKEW_PERIODS"); err != nil {
+		return Config{}, err
+	}
+	if err := overrideDuration(&cfg.WebAuthnChallengeTTL, "MIKHAIL_WEBAUTHN_CHALLENGE_TTL"); err != nil {
+		return Config{}, err
+	}
+	if err := overrideDuration(&cfg.AccountDeletionGracePeriod, "MIKHAIL_ACCOUNT_DELETION_GRACE_PERIOD"); err != nil {
+		return Config{}, err
+	}
+	if err := overrideDuration(&cfg.MagicLinkTTL, "MIKHAIL_MAGIC_LINK_TTL"); err != nil {
+		return Config{}, err
+	}
+	if err := overrideDuration(&cfg.ProfileSyncTTL, "MIKHAIL_PROFILE_SYNC_TTL"); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate reports an error if any TTL is not strictly positive, or if
+// RefreshGraceWindow is negative (zero disables the grace window).
+func (c Config) Validate() error {
+	for name, ttl := range map[string]time.Duration{
+		"AccessTokenTTL":             c.AccessTokenTTL,
+		"RefreshTokenTTL":            c.RefreshTokenTTL,
+		"RememberMeRefreshTokenTTL":  c.RememberMeRefreshTokenTTL,
+		"YandexTokenTTL":             c.YandexTokenTTL,
+		"ClientCredentialsTokenTTL":  c.ClientCredentialsTokenTTL,
+		"PasswordResetTokenTTL":      c.PasswordResetTokenTTL,
+		"OTPTTL":                     c.OTPTTL,
+		"EmailVerificationTokenTTL":  c.EmailVerificationTokenTTL,
+		"SignInChallengeTTL":         c.SignInChallengeTTL,
+		"WebAuthnChallengeTTL":       c.WebAuthnChallengeTTL,
+		"AccountDeletionGracePeriod": c.AccountDeletionGracePeriod,
+		"MagicLinkTTL":               c.MagicLinkTTL,
+		"ProfileSyncTTL":             c.ProfileSyncTTL,
+	} {
+		if ttl <= 0 {
+			return fmt.Errorf("mikhail: config.%s must be positive, got %s", name, ttl)
+		}
+	}
+	if c.RefreshGraceWindow < 0 {
+		return fmt.Errorf("mikhail: config.RefreshGraceWindow must not be negative, got %s", c.RefreshGraceWindow)
+	}
+	if c.OTPMaxAttempts <= 0 {
+		return fmt.Errorf("mikhail: config.OTPMaxAttempts must be positive, got %d", c.OTPMaxAttempts)
+	}
+	if c.TOTPSkewPeriods < 0 {
+		return fmt.Errorf("mikhail: config.TOTPSkewPeriods must not be negative, got %d", c.TOTPSkewPeriods)
+	}
+	return nil
+}
+
+func overrideDuration(dst *time.Duration, envVar string) error {
+	raw := os.Getenv(envVar)
+	if raw == "" {
+		return nil
+	}
+	parsed, err := time.ParseDuration(raw)
+	if err != nil {
+		return fmt.Errorf("mikhail: parsing %s=%q: %w", envVar, raw, err)
+	}
+	*dst = parsed
+	return nil
+}
+
+func overrideInt(dst *int, envVar string) error {
+	raw := os.Getenv(envVar)
+	if raw == "" {
+		return nil
+	}
+	parsed, err := strconv.Atoi(raw)
+	if err != nil {
+		return fmt.Errorf("mikhail: parsing %s=%q: %w", envVar, raw, err)
+	}
+	*dst = parsed
+	return nil
+}
+
+func overrideBool(dst *bool, envVar string) error {
+	raw := os.Getenv(envVar)
+	if raw == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseBool(raw)
+	if err != nil {
+		return fmt.Errorf("mikhail: parsing %s=%q: %w", envVar, raw, err)
+	}
+	*dst = parsed
+	return nil
+}
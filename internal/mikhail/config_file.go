@@ -0,0 +1,177 @@
+package mikhail
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ApplyConfigFile reads a YAML configuration file at path and calls
+// os.Setenv for every entry it finds, so the existing Load*Config
+// functions throughout this package (each already reading its own
+// MIKHAIL_* environment variables) pick the values up without any
+// change to how they are called. An environment variable already set
+// when ApplyConfigFile runs always wins over the file: ApplyConfigFile
+// only sets a variable that is still unset, the same "environment
+// overrides file" precedence every twelve-factor config loader gives.
+//
+// The YAML subset supported is intentionally small - this package has
+// no YAML dependency and does not take one on just for this - and
+// covers everything Mikhail's own config needs: flat "key: value" pairs,
+// optionally grouped one level deep under a "section:" header, e.g.
+//
+//	environment: production
+//	token_storage:
+//	  backend: redis
+//	  cache_ttl: 5m
+//	redis:
+//	  addr: redis.internal:6379
+//
+// becomes MIKHAIL_ENVIRONMENT, MIKHAIL_TOKEN_STORAGE_BACKEND,
+// MIKHAIL_TOKEN_STORAGE_CACHE_TTL, and MIKHAIL_REDIS_ADDR. Comments
+// (`#` to end of line), blank lines, and single- or double-quoted values
+// are supported; lists, multi-line scalars, and more than one level of
+// nesting are not, and produce an error rather than being silently
+// misread.
+func ApplyConfigFile(path string) error {
+	entries, err := parseConfigFile(path)
+	if err != nil {
+		return err
+	}
+	for key, value := range entries {
+		if _, set := os.LookupEnv(key); set {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("mikhail: config file %s: setting %s: %w", path, key, err)
+		}
+	}
+	return nil
+}
+
+// parseConfigFile parses path into a map of MIKHAIL_* environment
+// variable name to value, per the subset ApplyConfigFile documents.
+func parseConfigFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("mikhail: opening config file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	entries := make(map[string]string)
+	var section string
+	lineNo := 0
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lineNo++
+		raw := scanner.Text()
+		line := stripConfigFileComment(raw)
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		trimmed := strings.TrimSpace(line)
+
+		key, value, hasValue := strings.Cut(trimmed, ":")
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if !hasValue || key == "" {
+			return nil, fmt.Errorf("mikhail: config file %s:%d: expected \"key: value\" or \"key:\", got %q", path, lineNo, raw)
+		}
+
+		switch {
+		case indent == 0 && value == "":
+			section = key
+		case indent == 0:
+			section = ""
+			entries[configFileEnvName("", key)] = unquoteConfigFileValue(value)
+		case indent > 0 && value == "":
+			return nil, fmt.Errorf("mikhail: config file %s:%d: nested section %q is not supported", path, lineNo, key)
+		case indent > 0 && section != "":
+			entries[configFileEnvName(section, key)] = unquoteConfigFileValue(value)
+		default:
+			return nil, fmt.Errorf("mikhail: config file %s:%d: indented key %q outside of any section", path, lineNo, key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("mikhail: reading config file %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+func stripConfigFileComment(line string) string {
+	inSingle, inDouble := false, false
+	for i, r := range line {
+		switch r {
+		case '\'':
+			if !inDouble {
+				inSingle = !inSingle
+			}
+		case '"':
+			if !inSingle {
+				inDouble = !inDouble
+			}
+		case '#':
+			if !inSingle && !inDouble {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+func unquoteConfigFileValue(value string) string {
+	if len(value) >= 2 {
+		if (value[0] == '"' && value[len(value)-1] == '"') || (value[0] == '\'' && value[len(value)-1] == '\'') {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
+
+// ValidateAllConfig loads every configuration subsystem Mikhail reads at
+// startup - ports and server limits, token storage TTLs, OAuth provider
+// credentials, deadlines, load shedding, access logging, the debug and
+// health servers, and startup retry - and returns the first error any of
+// them reports, without connecting to a real dependency (token storage
+// backend selection is not attempted; Redis reachability is a readiness
+// concern, not a configuration one). It backs the --validate-config
+// flag: an operator can catch a typo'd duration or an out-of-range
+// sample rate before rolling the change out, rather than during it.
+func ValidateAllConfig() error {
+	loaders := []func() error{
+		func() error { _, err := LoadConfig(); return err },
+		func() error { _, err := LoadKeepaliveConfig(); return err },
+		func() error { _, err := LoadServerLimitsConfig(); return err },
+		func() error {
+			environment, err := LoadEnvironmentConfig()
+			if err != nil {
+				return err
+			}
+			return ValidateSecurityConfig(environment)
+		},
+		func() error { _, err := LoadDeadlineConfig(); return err },
+		func() error { _, err := LoadLoadSheddingConfig(); return err },
+		func() error { _, err := LoadDebugServerConfig(); return err },
+		func() error { _, err := LoadHealthServerConfig(); return err },
+		func() error { _, err := LoadAccessLogConfig(); return err },
+		func() error { _, err := LoadStartupRetryConfig(); return err },
+	}
+	for _, load := range loaders {
+		if err := load(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func configFileEnvName(section, key string) string {
+	name := strings.ToUpper(key)
+	if section != "" {
+		name = strings.ToUpper(section) + "_" + name
+	}
+	return "MIKHAIL_" + name
+}
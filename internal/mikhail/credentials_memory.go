@@ -0,0 +1,147 @@
+package mikhail
+
+import (
+	"fmt"
+	"sync"
+)
+
+// inMemoryCredentialStore is a placeholder credentialStore used until a
+// real user store (e.g. Postgres) is wired into AuthServer.
+type inMemoryCredentialStore struct {
+	mu       sync.Mutex
+	nextID   int
+	accounts map[string]struct {
+		userID       string
+		passwordHash string
+	}
+	identifierLinks IdentifierLinkStore
+}
+
+func newInMemoryCredentialStore() *inMemoryCredentialStore {
+	return &inMemoryCredentialStore{
+		accounts: make(map[string]struct {
+			userID       string
+			passwordHash string
+		}),
+		identifierLinks: NewInMemoryIdentifierLinkStore(),
+	}
+}
+
+func (c *inMemoryCredentialStore) verify(phone, password string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	account, ok := c.accounts[phone]
+	if !ok || !verifyPassword(account.passwordHash, password) {
+		return "", false
+	}
+	return account.userID, true
+}
+
+func (c *inMemoryCredentialStore) lookup(phone string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	account, ok := c.accounts[phone]
+	if !ok {
+		return "", false
+	}
+	return account.userID, true
+}
+
+// verifyIdentifier resolves identifier to a user ID before checking
+// password, accepting a phone number, email address, or username.
+// Phone numbers are normalized and checked against accounts directly;
+// emails and usernames resolve through identifierLinks, which callers
+// populate via linkIdentifier once they have claimed one (e.g. during
+// sign-up or profile setup).
+func (c *inMemoryCredentialStore) verifyIdentifier(identifier, password string) (string, bool) {
+	kind, normalized := NormalizeIdentifier(identifier)
+	if kind == IdentifierPhone {
+		return c.verify(normalized, password)
+	}
+	userID, ok := c.identifierLinks.Lookup(kind, normalized)
+	if !ok || !c.verifyByID(userID, password) {
+		return "", false
+	}
+	return userID, true
+}
+
+func (c *inMemoryCredentialStore) lookupIdentifier(identifier string) (string, bool) {
+	kind, normalized := NormalizeIdentifier(identifier)
+	if kind == IdentifierPhone {
+		return c.lookup(normalized)
+	}
+	return c.identifierLinks.Lookup(kind, normalized)
+}
+
+// linkIdentifier claims a non-phone identifier for userID, failing if it
+// is already claimed by a different user.
+func (c *inMemoryCredentialStore) linkIdentifier(userID string, kind IdentifierKind, normalized string) error {
+	return c.identifierLinks.Link(kind, normalized, userID)
+}
+
+func (c *inMemoryCredentialStore) verifyByID(userID, password string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, account := range c.accounts {
+		if account.userID == userID {
+			return verifyPassword(account.passwordHash, password)
+		}
+	}
+	return false
+}
+
+func (c *inMemoryCredentialStore) setPassword(userID, password string) error {
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for phone, account := range c.accounts {
+		if account.userID == userID {
+			account.passwordHash = passwordHash
+			c.accounts[phone] = account
+			return nil
+		}
+	}
+	return fmt.Errorf("mikhail: unknown user %q", userID)
+}
+
+func (c *inMemoryCredentialStore) hasCredential(userID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, account := range c.accounts {
+		if account.userID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *inMemoryCredentialStore) create(phone, password string) (string, error) {
+	c.mu.Lock()
+	c.nextID++
+	userID := fmt.Sprintf("user-%d", c.nextID)
+	c.mu.Unlock()
+	if err := c.createWithID(userID, phone, password); err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+func (c *inMemoryCredentialStore) createWithID(userID, phone, password string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.accounts[phone]; exists {
+		return ErrPhoneAlreadyRegistered
+	}
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	c.accounts[phone] = struct {
+		userID       string
+		passwordHash string
+	}{userID: userID, passwordHash: passwordHash}
+	return nil
+}
@@ -0,0 +1,218 @@
+package mikhail
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// PostgresUserStore is a credentialStore backed by Postgres. It expects a
+// schema along these lines:
+//
+//	CREATE TABLE users (
+//	    id           TEXT PRIMARY KEY,
+//	    phone_number TEXT UNIQUE,
+//	    email        TEXT UNIQUE,
+//	    username     TEXT UNIQUE,
+//	    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+//	);
+//
+//	CREATE TABLE credentials (
+//	    user_id       TEXT PRIMARY KEY REFERENCES users(id),
+//	    password_hash TEXT NOT NULL
+//	);
+//
+//	CREATE TABLE profiles (
+//	    user_id TEXT PRIMARY KEY REFERENCES users(id)
+//	);
+//
+// PostgresUserStore only depends on database/sql: callers are responsible
+// for opening db with a registered Postgres driver (e.g. lib/pq or pgx).
+type PostgresUserStore struct {
+	db *sql.DB
+}
+
+// NewPostgresUserStore wraps an already-open Postgres connection pool.
+func NewPostgresUserStore(db *sql.DB) *PostgresUserStore {
+	return &PostgresUserStore{db: db}
+}
+
+func (p *PostgresUserStore) verify(phone, password string) (string, bool) {
+	row := p.db.QueryRowContext(context.Background(),
+		`SELECT users.id, credentials.password_hash
+		   FROM users
+		   JOIN credentials ON credentials.user_id = users.id
+		  WHERE users.phone_number = $1`, phone)
+
+	var userID, passwordHash string
+	if err := row.Scan(&userID, &passwordHash); err != nil {
+		return "", false
+	}
+	if !verifyPassword(passwordHash, password) {
+		return "", false
+	}
+	return userID, true
+}
+
+func (p *PostgresUserStore) lookup(phone string) (string, bool) {
+	var userID string
+	row := p.db.QueryRowContext(context.Background(),
+		`SELECT id FROM users WHERE phone_number = $1`, phone)
+	if err := row.Scan(&userID); err != nil {
+		return "", false
+	}
+	return userID, true
+}
+
+// verifyIdentifier resolves identifier to a user ID before checking
+// password, accepting a phone number, email address, or username. The
+// three columns are each individually unique, so at most one row can
+// match.
+func (p *PostgresUserStore) verifyIdentifier(identifier, password string) (string, bool) {
+	kind, normalized := NormalizeIdentifier(identifier)
+	if kind == IdentifierPhone {
+		return p.verify(normalized, password)
+	}
+
+	column := "username"
+	if kind == IdentifierEmail {
+		column = "email"
+	}
+	row := p.db.QueryRowContext(context.Background(),
+		fmt.Sprintf(`SELECT users.id, credentials.password_hash
+		   FROM users
+		   JOIN credentials ON credentials.user_id = users.id
+		  WHERE users.%s = $1`, column), normalized)
+
+	var userID, passwordHash string
+	if err := row.Scan(&userID, &passwordHash); err != nil {
+		return "", false
+	}
+	if !verifyPassword(passwordHash, password) {
+		return "", false
+	}
+	return userID, true
+}
+
+func (p *PostgresUserStore) lookupIdentifier(identifier string) (string, bool) {
+	kind, normalized := NormalizeIdentifier(identifier)
+	if kind == IdentifierPhone {
+		return p.lookup(normalized)
+	}
+
+	column := "username"
+	if kind == IdentifierEmail {
+		column = "email"
+	}
+	var userID string
+	row := p.db.QueryRowContext(context.Background(),
+		fmt.Sprintf(`SELECT id FROM users WHERE %s = $1`, column), normalized)
+	if err := row.Scan(&userID); err != nil {
+		return "", false
+	}
+	return userID, true
+}
+
+func (p *PostgresUserStore) verifyByID(userID, password string) bool {
+	row := p.db.QueryRowContext(context.Background(),
+		`SELECT password_hash FROM credentials WHERE user_id = $1`, userID)
+
+	var passwordHash string
+	if err := row.Scan(&passwordHash); err != nil {
+		return false
+	}
+	return verifyPassword(passwordHash, password)
+}
+
+func (p *PostgresUserStore) setPassword(userID, password string) error {
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	_, err = p.db.ExecContext(context.Background(),
+		`UPDATE credentials SET password_hash = $1 WHERE user_id = $2`, passwordHash, userID)
+	return err
+}
+
+func (p *PostgresUserStore) hasCredential(userID string) bool {
+	row := p.db.QueryRowContext(context.Background(),
+		`SELECT 1 FROM credentials WHERE user_id = $1`, userID)
+	var exists int
+	return row.Scan(&exists) == nil
+}
+
+func (p *PostgresUserStore) create(phone, password string) (string, error) {
+	ctx := context.Background()
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var userID string
+	row := tx.QueryRowContext(ctx,
+		`INSERT INTO users (id, phone_number) VALUES (gen_random_uuid()::text, $1) RETURNING id`, phone)
+	if err := row.Scan(&userID); err != nil {
+		if isUniqueViolation(err) {
+			return "", ErrPhoneAlreadyRegistered
+		}
+		return "", err
+	}
+
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return "", err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO credentials (user_id, password_hash) VALUES ($1, $2)`, userID, passwordHash); err != nil {
+		return "", err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO profiles (user_id) VALUES ($1)`, userID); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+func (p *PostgresUserStore) createWithID(userID, phone, password string) error {
+	ctx := context.Background()
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO users (id, phone_number) VALUES ($1, $2)`, userID, phone); err != nil {
+		if isUniqueViolation(err) {
+			return ErrPhoneAlreadyRegistered
+		}
+		return err
+	}
+
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO credentials (user_id, password_hash) VALUES ($1, $2)`, userID, passwordHash); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO profiles (user_id) VALUES ($1)`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// isUniqueViolation reports whether err looks like a unique-constraint
+// violation. It matches on the error text rather than a driver-specific
+// error type so PostgresUserStore stays usable with any database/sql
+// driver, not just one particular package.
+func isUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), "unique")
+}
@@ -0,0 +1,127 @@
+package mikhail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrDeadlineTooShort is returned by DeadlineInterceptor when the
+// client-supplied deadline leaves less than DeadlineConfig.MinRemaining
+// for the call, too little for the handler to do anything useful with
+// before the client gives up anyway.
+var ErrDeadlineTooShort = errors.New("mikhail: remaining deadline too short to be useful")
+
+// DeadlineConfig holds the default per-RPC timeouts DeadlineInterceptor
+// applies when a caller didn't set one of its own, plus the minimum
+// remaining deadline a caller-supplied one must leave.
+type DeadlineConfig struct {
+	// Default is the timeout applied to any method not present in
+	// PerMethod, when the incoming context has no deadline of its own.
+	Default time.Duration
+	// PerMethod overrides Default for specific RPCs, keyed by
+	// UnaryServerInfo.FullMethod (e.g.
+	// "/kingdom.auth.v1.AuthenticateService/RefreshToken").
+	// RPCs that do real outbound work (OAuth2Callback's token exchange,
+	// SendOtp's SMS dispatch) typically need a longer default than a
+	// pure-storage RPC like VerifyToken.
+	PerMethod map[string]time.Duration
+	// MinRemaining is the minimum deadline a caller-supplied context must
+	// still have when it reaches DeadlineInterceptor; anything less is
+	// rejected with ErrDeadlineTooShort rather than silently attempted
+	// and almost certainly abandoned by the caller before it finishes.
+	MinRemaining time.Duration
+}
+
+// DefaultDeadlineConfig returns a generous blanket default with no
+// per-method overrides and a small minimum-remaining floor, so leaving
+// every MIKHAIL_GRPC_DEADLINE_* variable unset does not change behavior
+// for a caller that already sets its own sensible deadlines.
+func DefaultDeadlineConfig() DeadlineConfig {
+	return DeadlineConfig{
+		Default:      30 * time.Second,
+		PerMethod:    map[string]time.Duration{},
+		MinRemaining: 50 * time.Millisecond,
+	}
+}
+
+// LoadDeadlineConfig builds a DeadlineConfig from environment variables,
+// falling back to DefaultDeadlineConfig for anything unset.
+// MIKHAIL_GRPC_METHOD_DEADLINES holds per-method overrides as
+// comma-separated "method=duration" pairs, e.g.
+// "/kingdom.auth.v1.AuthenticateService/RefreshToken=5s,/kingdom.auth.v1.AuthenticateService/SendOtp=15s".
+func LoadDeadlineConfig() (DeadlineConfig, error) {
+	cfg := DefaultDeadlineConfig()
+
+	if err := overrideDuration(&cfg.Default, "MIKHAIL_GRPC_DEFAULT_DEADLINE"); err != nil {
+		return DeadlineConfig{}, err
+	}
+	if err := overrideDuration(&cfg.MinRemaining, "MIKHAIL_GRPC_MIN_REMAINING_DEADLINE"); err != nil {
+		return DeadlineConfig{}, err
+	}
+
+	if raw := os.Getenv("MIKHAIL_GRPC_METHOD_DEADLINES"); raw != "" {
+		for _, pair := range strings.Split(raw, ",") {
+			method, durationStr, ok := strings.Cut(pair, "=")
+			if !ok {
+				return DeadlineConfig{}, fmt.Errorf("mikhail: parsing MIKHAIL_GRPC_METHOD_DEADLINES: %q is not method=duration", pair)
+			}
+			parsed, err := time.ParseDuration(durationStr)
+			if err != nil {
+				return DeadlineConfig{}, fmt.Errorf("mikhail: parsing MIKHAIL_GRPC_METHOD_DEADLINES entry %q: %w", pair, err)
+			}
+			cfg.PerMethod[method] = parsed
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate reports an error if Default, MinRemaining, or any PerMethod
+// override is not strictly positive.
+func (c DeadlineConfig) Validate() error {
+	if c.Default <= 0 {
+		return fmt.Errorf("mikhail: deadline config.Default must be positive, got %s", c.Default)
+	}
+	if c.MinRemaining <= 0 {
+		return fmt.Errorf("mikhail: deadline config.MinRemaining must be positive, got %s", c.MinRemaining)
+	}
+	for method, d := range c.PerMethod {
+		if d <= 0 {
+			return fmt.Errorf("mikhail: deadline config.PerMethod[%q] must be positive, got %s", method, d)
+		}
+	}
+	return nil
+}
+
+func (c DeadlineConfig) defaultFor(fullMethod string) time.Duration {
+	if d, ok := c.PerMethod[fullMethod]; ok {
+		return d
+	}
+	return c.Default
+}
+
+// DeadlineInterceptor returns a UnaryServerInterceptor enforcing cfg: a
+// call whose context already carries a deadline is rejected with
+// ErrDeadlineTooShort if less than cfg.MinRemaining of it is left;
+// otherwise a call with no deadline at all gets one applied from
+// cfg.defaultFor(info.FullMethod), so a handler can always rely on ctx
+// eventually expiring instead of running unbounded for a client that
+// never set a deadline of its own.
+func DeadlineInterceptor(cfg DeadlineConfig) UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error) {
+		if deadline, ok := ctx.Deadline(); ok {
+			if time.Until(deadline) < cfg.MinRemaining {
+				return nil, ErrDeadlineTooShort
+			}
+			return handler(ctx, req)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, cfg.defaultFor(info.FullMethod))
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
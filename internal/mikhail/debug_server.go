@@ -0,0 +1,169 @@
+package mikhail
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	httppprof "net/http/pprof"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// TokenCacheSizer is implemented by a TokenStorage that keeps an
+// in-memory cache (e.g. *CachedTokenStorage) worth reporting through the
+// debug server's expvar counters. A TokenStorage that does not implement
+// it (a bare Redis or SQLite backend) simply has no cache size counter
+// published.
+type TokenCacheSizer interface {
+	CacheSize() int
+}
+
+// TokenWriteQueueDepther is implemented by a TokenStorage that buffers
+// writes on a channel (e.g. *AsyncTokenWriter) worth reporting through
+// the debug server's expvar counters.
+type TokenWriteQueueDepther interface {
+	QueueDepth() int
+}
+
+// DebugServerConfig configures the optional debug HTTP listener
+// NewDebugServer builds: net/http/pprof, a handful of expvar counters,
+// and a goroutine dump endpoint. It is off by default and, when enabled,
+// binds to localhost only by default, since every one of those endpoints
+// hands out information (or CPU) an operator would not want reachable
+// from outside the host.
+type DebugServerConfig struct {
+	// Enabled turns the debug server on. Defaults to false.
+	Enabled bool
+	// Addr is the address the debug server listens on. Defaults to
+	// "127.0.0.1:6060", matching net/http/pprof's usual convention but
+	// bound to localhost rather than every interface.
+	Addr string
+}
+
+// DefaultDebugServerConfig returns the debug server disabled, bound to
+// localhost if it is ever turned on.
+func DefaultDebugServerConfig() DebugServerConfig {
+	return DebugServerConfig{Enabled: false, Addr: "127.0.0.1:6060"}
+}
+
+// LoadDebugServerConfig builds a DebugServerConfig from environment
+// variables, falling back to DefaultDebugServerConfig for anything
+// unset. An operator who sets MIKHAIL_DEBUG_SERVER_ADDR to a
+// non-localhost address has made that call themselves; this function
+// does not second-guess it.
+func LoadDebugServerConfig() (DebugServerConfig, error) {
+	cfg := DefaultDebugServerConfig()
+
+	if err := overrideBool(&cfg.Enabled, "MIKHAIL_DEBUG_SERVER_ENABLED"); err != nil {
+		return DebugServerConfig{}, err
+	}
+	if raw := os.Getenv("MIKHAIL_DEBUG_SERVER_ADDR"); raw != "" {
+		cfg.Addr = raw
+	}
+
+	return cfg, nil
+}
+
+// NewDebugServer builds the debug HTTP server described by cfg. storage
+// is inspected for TokenCacheSizer/TokenWriteQueueDepther to decide which
+// expvar counters to publish; it may be nil or implement neither, in
+// which case only the always-available goroutine counter is published.
+// logLevel, if non-nil, is exposed at GET/PUT /debug/loglevel so an
+// operator can read or change the process's log level without a
+// restart; if nil, that endpoint reports 503.
+// The returned *http.Server is not started; call StartDebugServer only
+// when cfg.Enabled.
+func NewDebugServer(cfg DebugServerConfig, storage TokenStorage, logLevel *LogLevelController) *http.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/pprof/", httppprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", httppprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", httppprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", httppprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", httppprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/goroutines", debugGoroutineDumpHandler)
+	mux.HandleFunc("/debug/loglevel", debugLogLevelHandler(logLevel))
+
+	publishDebugExpvars(storage)
+
+	return &http.Server{Addr: cfg.Addr, Handler: mux}
+}
+
+// debugLogLevelHandler returns a handler for /debug/loglevel: GET
+// reports the current level as plain text, PUT/POST changes it from the
+// request body (e.g. "debug"). A nil logLevel means this instance has no
+// LogLevelController wired in, so every request is rejected with 503.
+func debugLogLevelHandler(logLevel *LogLevelController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if logLevel == nil {
+			http.Error(w, "mikhail: log level control is not wired up on this instance", http.StatusServiceUnavailable)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			fmt.Fprintln(w, logLevel.Level().String())
+		case http.MethodPut, http.MethodPost:
+			body, err := io.ReadAll(io.LimitReader(r.Body, 64))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			level, err := ParseLogLevel(string(body))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			logLevel.SetLevel(level)
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			fmt.Fprintln(w, logLevel.Level().String())
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, "mikhail: method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// debugGoroutineDumpHandler writes a full goroutine stack dump, the same
+// content runtime/pprof's "goroutine" profile with debug=2 produces, as
+// plain text, for a quick look without a pprof-aware client.
+func debugGoroutineDumpHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	pprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+// publishDebugExpvars registers the debug server's expvar counters.
+// Mikhail has no other expvar.Publish caller, so this is safe to call
+// once per process; expvar panics on a duplicate name, which would only
+// happen if NewDebugServer were (incorrectly) called twice in the same
+// process.
+func publishDebugExpvars(storage TokenStorage) {
+	expvar.Publish("mikhail_goroutines", expvar.Func(func() interface{} {
+		return runtime.NumGoroutine()
+	}))
+	if sizer, ok := storage.(TokenCacheSizer); ok {
+		expvar.Publish("mikhail_token_cache_size", expvar.Func(func() interface{} {
+			return sizer.CacheSize()
+		}))
+	}
+	if depther, ok := storage.(TokenWriteQueueDepther); ok {
+		expvar.Publish("mikhail_token_write_queue_depth", expvar.Func(func() interface{} {
+			return depther.QueueDepth()
+		}))
+	}
+}
+
+// StartDebugServer starts srv in a background goroutine and logs any
+// error ListenAndServe returns other than http.ErrServerClosed (the
+// expected error on a graceful Shutdown). It does not block.
+func StartDebugServer(srv *http.Server) {
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("mikhail: debug server on %s stopped: %v", srv.Addr, err)
+		}
+	}()
+}
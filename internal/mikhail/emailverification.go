@@ -0,0 +1,94 @@
+package mikhail
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrEmailVerificationTokenInvalid is returned by VerifyEmail when the
+// token is unknown or expired.
+var ErrEmailVerificationTokenInvalid = errors.New("mikhail: email verification token invalid or expired")
+
+// ErrEmailNotVerified is returned by RequireVerifiedEmail when a feature
+// gated on a verified email is used before VerifyEmail has succeeded for
+// that user.
+var ErrEmailNotVerified = errors.New("mikhail: email not verified")
+
+// EmailVerificationTokenInfo is what Mikhail keeps about an issued email
+// verification token.
+type EmailVerificationTokenInfo struct {
+	UserID    string
+	Email     string
+	ExpiresAt time.Time
+}
+
+// Expired reports whether the token is past its expiry time as of now.
+func (t EmailVerificationTokenInfo) Expired(now time.Time) bool {
+	return now.After(t.ExpiresAt)
+}
+
+// EmailVerificationStore persists outstanding email verification tokens
+// and the verified flag they eventually set. Implementations must be
+// safe for concurrent use.
+type EmailVerificationStore interface {
+	StoreToken(token string, info EmailVerificationTokenInfo) error
+	GetToken(token string) (EmailVerificationTokenInfo, bool, error)
+	DeleteToken(token string) error
+	// MarkVerified sets the email_verified flag for userID.
+	MarkVerified(userID string) error
+	// IsVerified reports whether userID has a verified email on file.
+	IsVerified(userID string) (bool, error)
+}
+
+// InMemoryEmailVerificationStore is an EmailVerificationStore backed by
+// guarded maps, suitable for local development and single-node
+// deployments.
+type InMemoryEmailVerificationStore struct {
+	mu       sync.Mutex
+	tokens   map[string]EmailVerificationTokenInfo
+	verified map[string]bool
+}
+
+// NewInMemoryEmailVerificationStore returns an empty
+// InMemoryEmailVerificationStore.
+func NewInMemoryEmailVerificationStore() *InMemoryEmailVerificationStore {
+	return &InMemoryEmailVerificationStore{
+		tokens:   make(map[string]EmailVerificationTokenInfo),
+		verified: make(map[string]bool),
+	}
+}
+
+func (s *InMemoryEmailVerificationStore) StoreToken(token string, info EmailVerificationTokenInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[token] = info
+	return nil
+}
+
+func (s *InMemoryEmailVerificationStore) GetToken(token string) (EmailVerificationTokenInfo, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, ok := s.tokens[token]
+	return info, ok, nil
+}
+
+func (s *InMemoryEmailVerificationStore) DeleteToken(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, token)
+	return nil
+}
+
+func (s *InMemoryEmailVerificationStore) MarkVerified(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.verified[userID] = true
+	return nil
+}
+
+func (s *InMemoryEmailVerificationStore) IsVerified(userID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.verified[userID], nil
+}
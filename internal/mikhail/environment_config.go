@@ -0,0 +1,91 @@
+package mikhail
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Environment identifies which tier a Mikhail process is running in,
+// the single source of truth EnvironmentConfig derives every other
+// debug-only toggle from.
+type Environment string
+
+const (
+	EnvironmentDevelopment Environment = "development"
+	EnvironmentStaging     Environment = "staging"
+	EnvironmentProduction  Environment = "production"
+)
+
+// EnvironmentConfig gates every debug-only behavior Mikhail has behind
+// one place instead of a scattered DEBUG=1 check per feature: gRPC
+// server reflection (google.golang.org/grpc/reflection, registered by
+// whatever constructs Mikhail's real grpc.Server), verbose logging that
+// would otherwise print token values, and any future debug-only
+// behavior should all read Environment/Debug from here rather than their
+// own ad-hoc env var.
+type EnvironmentConfig struct {
+	// Environment is the running tier. Defaults to
+	// EnvironmentProduction: an unset ENVIRONMENT variable should never
+	// silently enable debug behavior.
+	Environment Environment
+	// Debug force-enables every debug-only behavior regardless of
+	// Environment, for a developer who wants them in a staging-like
+	// deployment without relabeling it "development". Defaults to false.
+	Debug bool
+}
+
+// DefaultEnvironmentConfig returns EnvironmentProduction with Debug
+// false: the safe default when MIKHAIL_ENVIRONMENT/MIKHAIL_DEBUG are
+// unset, consistent with "reflection is always on" being exactly the
+// footgun this config exists to close.
+func DefaultEnvironmentConfig() EnvironmentConfig {
+	return EnvironmentConfig{Environment: EnvironmentProduction, Debug: false}
+}
+
+// LoadEnvironmentConfig builds an EnvironmentConfig from
+// MIKHAIL_ENVIRONMENT ("development", "staging", or "production") and
+// MIKHAIL_DEBUG (a bool), falling back to DefaultEnvironmentConfig for
+// anything unset.
+func LoadEnvironmentConfig() (EnvironmentConfig, error) {
+	cfg := DefaultEnvironmentConfig()
+
+	if raw := os.Getenv("MIKHAIL_ENVIRONMENT"); raw != "" {
+		env := Environment(strings.ToLower(raw))
+		switch env {
+		case EnvironmentDevelopment, EnvironmentStaging, EnvironmentProduction:
+			cfg.Environment = env
+		default:
+			return EnvironmentConfig{}, fmt.Errorf("mikhail: MIKHAIL_ENVIRONMENT=%q must be one of development, staging, production", raw)
+		}
+	}
+	if err := overrideBool(&cfg.Debug, "MIKHAIL_DEBUG"); err != nil {
+		return EnvironmentConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+// DebugFeaturesEnabled reports whether debug-only behaviors (reflection,
+// verbose token logging, and anything else gated on this config) should
+// be active: either Debug was explicitly set, or Environment is
+// development.
+func (c EnvironmentConfig) DebugFeaturesEnabled() bool {
+	return c.Debug || c.Environment == EnvironmentDevelopment
+}
+
+// ReflectionEnabled reports whether a real grpc.Server should register
+// google.golang.org/grpc/reflection. Exposing server reflection lets
+// anyone with network access enumerate every RPC and message shape
+// Mikhail has, which is a reasonable development convenience and an
+// unreasonable production one.
+func (c EnvironmentConfig) ReflectionEnabled() bool {
+	return c.DebugFeaturesEnabled()
+}
+
+// VerboseTokenLoggingEnabled reports whether log lines are allowed to
+// include raw token values (access tokens, refresh tokens, OTP codes)
+// instead of redacting them, for local debugging only.
+func (c EnvironmentConfig) VerboseTokenLoggingEnabled() bool {
+	return c.DebugFeaturesEnabled()
+}
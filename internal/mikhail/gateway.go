@@ -0,0 +1,272 @@
+package mikhail
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/TwelveFacedJanus/Kingdom-System/internal/authpb"
+)
+
+// gatewayAuthResult mirrors authpb.AuthResult with JSON field names in the
+// snake_case grpc-gateway would generate from the .proto field names, so a
+// web frontend gets the same wire shape a real grpc-gateway/protojson mux
+// would produce.
+type gatewayAuthResult struct {
+	AccessToken  string   `json:"access_token"`
+	RefreshToken string   `json:"refresh_token"`
+	ExpiresAt    int64    `json:"expires_at"`
+	Scopes       []string `json:"scopes,omitempty"`
+	IDToken      string   `json:"id_token,omitempty"`
+}
+
+func newGatewayAuthResult(result *authpb.AuthResult) *gatewayAuthResult {
+	if result == nil {
+		return nil
+	}
+	return &gatewayAuthResult{
+		AccessToken:  result.AccessToken,
+		RefreshToken: result.RefreshToken,
+		ExpiresAt:    result.ExpiresAt,
+		Scopes:       result.Scopes,
+		IDToken:      result.IDToken,
+	}
+}
+
+// gatewayErrorResponse is the JSON body written for any error response, the
+// shape grpc-gateway's default error handler produces for a plain
+// google.rpc.Status-less error.
+type gatewayErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeGatewayJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeGatewayError(w http.ResponseWriter, status int, err error) {
+	writeGatewayJSON(w, status, gatewayErrorResponse{Error: err.Error()})
+}
+
+// gatewaySignInRequest is the JSON body for POST /v1/signin.
+type gatewaySignInRequest struct {
+	Identifier        string   `json:"identifier"`
+	PhoneNumber       string   `json:"phone_number"`
+	Password          string   `json:"password"`
+	Scopes            []string `json:"scopes,omitempty"`
+	DeviceID          string   `json:"device_id,omitempty"`
+	DeviceFingerprint string   `json:"device_fingerprint,omitempty"`
+	RememberMe        bool     `json:"remember_me,omitempty"`
+	Audience          string   `json:"audience,omitempty"`
+}
+
+type gatewaySignInResponse struct {
+	Result       *gatewayAuthResult `json:"result,omitempty"`
+	MfaChallenge string             `json:"mfa_challenge,omitempty"`
+}
+
+// gatewaySignInHandler implements POST /v1/signin, the JSON/HTTP mapping of
+// AuthenticateService.SignIn a grpc-gateway annotation on that RPC would
+// generate. Every call is dispatched through chain first, so recovery,
+// rate limiting, blocklisting, and the rest of the configured interceptor
+// chain run exactly as they would for a real grpc.Server.
+func gatewaySignInHandler(server *AuthServer, chain UnaryServerInterceptor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var body gatewaySignInRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeGatewayError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		req := &authpb.SignInRequest{
+			Identifier:        body.Identifier,
+			PhoneNumber:       body.PhoneNumber,
+			Password:          body.Password,
+			Scopes:            body.Scopes,
+			DeviceID:          body.DeviceID,
+			DeviceFingerprint: body.DeviceFingerprint,
+			RememberMe:        body.RememberMe,
+			Audience:          body.Audience,
+		}
+		result, err := dispatch(contextFromHTTPRequest(r), chain, "/kingdom.auth.v1.AuthenticateService/SignIn", req,
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return server.SignIn(ctx, req.(*authpb.SignInRequest))
+			})
+		if err != nil {
+			writeGatewayError(w, http.StatusUnauthorized, err)
+			return
+		}
+
+		resp := result.(*authpb.SignInResponse)
+		writeGatewayJSON(w, http.StatusOK, gatewaySignInResponse{
+			Result:       newGatewayAuthResult(resp.Result),
+			MfaChallenge: resp.MfaChallenge,
+		})
+	}
+}
+
+// gatewayRefreshRequest is the JSON body for POST /v1/refresh.
+type gatewayRefreshRequest struct {
+	RefreshToken      string `json:"refresh_token"`
+	DeviceFingerprint string `json:"device_fingerprint,omitempty"`
+}
+
+type gatewayRefreshResponse struct {
+	Result *gatewayAuthResult `json:"result,omitempty"`
+}
+
+// gatewayRefreshHandler implements POST /v1/refresh, the JSON/HTTP mapping
+// of AuthenticateService.RefreshToken.
+func gatewayRefreshHandler(server *AuthServer, chain UnaryServerInterceptor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var body gatewayRefreshRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeGatewayError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		req := &authpb.RefreshTokenRequest{
+			RefreshToken:      body.RefreshToken,
+			DeviceFingerprint: body.DeviceFingerprint,
+		}
+		result, err := dispatch(contextFromHTTPRequest(r), chain, "/kingdom.auth.v1.AuthenticateService/RefreshToken", req,
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return server.RefreshToken(ctx, req.(*authpb.RefreshTokenRequest))
+			})
+		if err != nil {
+			writeGatewayError(w, http.StatusUnauthorized, err)
+			return
+		}
+
+		resp := result.(*authpb.RefreshTokenResponse)
+		writeGatewayJSON(w, http.StatusOK, gatewayRefreshResponse{Result: newGatewayAuthResult(resp.Result)})
+	}
+}
+
+// gatewayMeResponse is the JSON body for GET /v1/me.
+type gatewayMeResponse struct {
+	FirstName   string `json:"first_name,omitempty"`
+	LastName    string `json:"last_name,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
+	Email       string `json:"email,omitempty"`
+}
+
+// gatewayMeHandler implements GET /v1/me, the JSON/HTTP mapping of
+// AuthenticateService.GetMe. Unlike the others, GetMe requires an
+// authenticated caller: chain's AuthenticationInterceptor member (see
+// DefaultUnaryInterceptorRegistry) verifies the bearer token and attaches
+// a Principal before this handler's inner closure ever runs, and its
+// Principal's UserID drives the lookup, never a client-supplied one.
+func gatewayMeHandler(server *AuthServer, chain UnaryServerInterceptor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		result, err := dispatch(contextFromHTTPRequest(r), chain, "/kingdom.auth.v1.AuthenticateService/GetMe", &authpb.GetMeRequest{},
+			func(ctx context.Context, _ interface{}) (interface{}, error) {
+				principal, ok := PrincipalFromContext(ctx)
+				if !ok {
+					return nil, ErrMissingBearerToken
+				}
+				return server.GetMe(ctx, &authpb.GetMeRequest{UserID: principal.UserID})
+			})
+		if err != nil {
+			writeGatewayError(w, http.StatusUnauthorized, err)
+			return
+		}
+
+		resp := result.(*authpb.GetMeResponse)
+		writeGatewayJSON(w, http.StatusOK, gatewayMeResponse{
+			FirstName:   resp.FirstName,
+			LastName:    resp.LastName,
+			DisplayName: resp.DisplayName,
+			AvatarURL:   resp.AvatarURL,
+			Email:       resp.Email,
+		})
+	}
+}
+
+type gatewayOAuth2CallbackResponse struct {
+	Result                *gatewayAuthResult `json:"result,omitempty"`
+	LinkedExistingAccount bool               `json:"linked_existing_account,omitempty"`
+}
+
+// gatewayOAuth2CallbackHandler implements GET /v1/oauth/callback, the
+// JSON/HTTP mapping of AuthenticateService.OAuth2Callback: it returns the
+// issued token pair directly as JSON rather than redirecting the browser.
+// It complements, rather than replaces, OAuth2CallbackHTTPHandler: that
+// handler is for mounting at a third-party provider's own redirect URI,
+// where the caller is the user's browser and tokens must not end up in a
+// URL it retains in history; this one is for a frontend that already owns
+// the redirect and PKCE/code exchange and just wants the RPC's JSON
+// response, the way grpc-gateway would generate it from the annotation.
+func gatewayOAuth2CallbackHandler(server *AuthServer, provider string, chain UnaryServerInterceptor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		query := r.URL.Query()
+
+		req := &authpb.OAuth2CallbackRequest{
+			Code:         query.Get("code"),
+			State:        query.Get("state"),
+			Provider:     provider,
+			CodeVerifier: query.Get("code_verifier"),
+		}
+		result, err := dispatch(contextFromHTTPRequest(r), chain, "/kingdom.auth.v1.AuthenticateService/OAuth2Callback", req,
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return server.OAuth2Callback(ctx, req.(*authpb.OAuth2CallbackRequest))
+			})
+		if err != nil {
+			writeGatewayError(w, http.StatusUnauthorized, err)
+			return
+		}
+
+		resp := result.(*authpb.OAuth2CallbackResponse)
+		writeGatewayJSON(w, http.StatusOK, gatewayOAuth2CallbackResponse{
+			Result:                newGatewayAuthResult(resp.Result),
+			LinkedExistingAccount: resp.LinkedExistingAccount,
+		})
+	}
+}
+
+// NewGatewayMux returns an *http.ServeMux exposing AuthenticateService over
+// HTTP/JSON: POST /v1/signin, POST /v1/refresh, GET /v1/me, and GET
+// /v1/oauth/callback, the mapping a grpc-gateway annotation on each of
+// those RPCs would generate. Mikhail has no protoc-gen-grpc-gateway or
+// grpc-gateway runtime dependency of its own (this environment cannot
+// vendor one); this mux is a hand-written equivalent over the same
+// AuthServer methods the real gRPC service dispatches to, so a web
+// frontend without gRPC support can integrate directly without Mikhail
+// depending on grpc or grpc-gateway at all. oauthProvider selects the
+// OAuthProvider name GET /v1/oauth/callback completes the callback
+// against; mount a separate mux (or add a route by hand) per provider if
+// a deployment registers more than one. chain is the
+// UnaryServerInterceptor chain (see BuildUnaryInterceptorChain) every
+// route dispatches each call through before it reaches server, the same
+// way a real grpc.Server would run it; pass nil to serve with no
+// interceptors applied at all.
+//
+// This is cmd/mikhail's main listener's handler; see NewMainServer.
+func NewGatewayMux(server *AuthServer, oauthProvider string, chain UnaryServerInterceptor) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/signin", gatewaySignInHandler(server, chain))
+	mux.HandleFunc("/v1/refresh", gatewayRefreshHandler(server, chain))
+	mux.HandleFunc("/v1/me", gatewayMeHandler(server, chain))
+	mux.HandleFunc("/v1/oauth/callback", gatewayOAuth2CallbackHandler(server, oauthProvider, chain))
+	return mux
+}
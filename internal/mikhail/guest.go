@@ -0,0 +1,61 @@
+package mikhail
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrNotAGuest is returned by UpgradeGuest when the given user ID was
+// never issued by CreateGuestSession, or has already been upgraded.
+var ErrNotAGuest = errors.New("mikhail: user is not an upgradeable guest")
+
+// ErrUpgradeGuestMissingIdentity is returned by UpgradeGuest when
+// neither a phone number nor a Yandex authorization code was given to
+// upgrade to.
+var ErrUpgradeGuestMissingIdentity = errors.New("mikhail: upgrade guest requires a phone number or yandex code")
+
+// guestIDPrefix marks a user ID as belonging to an anonymous guest
+// session, so logs and downstream services can recognize one on sight
+// even without querying GuestStore.
+const guestIDPrefix = "guest-"
+
+// GuestStore tracks which user IDs belong to anonymous guest sessions
+// created by CreateGuestSession, so UpgradeGuest can confirm a user ID
+// is eligible before converting it into a full account.
+type GuestStore interface {
+	MarkGuest(userID string) error
+	IsGuest(userID string) (bool, error)
+	MarkUpgraded(userID string) error
+}
+
+// InMemoryGuestStore is a GuestStore backed by a guarded map, suitable
+// for local development and single-node deployments.
+type InMemoryGuestStore struct {
+	mu     sync.Mutex
+	guests map[string]bool
+}
+
+// NewInMemoryGuestStore returns an empty InMemoryGuestStore.
+func NewInMemoryGuestStore() *InMemoryGuestStore {
+	return &InMemoryGuestStore{guests: make(map[string]bool)}
+}
+
+func (s *InMemoryGuestStore) MarkGuest(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.guests[userID] = true
+	return nil
+}
+
+func (s *InMemoryGuestStore) IsGuest(userID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.guests[userID], nil
+}
+
+func (s *InMemoryGuestStore) MarkUpgraded(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.guests, userID)
+	return nil
+}
@@ -0,0 +1,156 @@
+package mikhail
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Pinger is implemented by a TokenStorage backend with a live connection
+// worth checking before declaring readiness (today, only
+// *RedisTokenStorage; the in-memory, SQLite, etcd, and DynamoDB backends
+// have no connection to go stale, so ReadinessHandler only checks it
+// when storage implements Pinger).
+type Pinger interface {
+	Ping() error
+}
+
+// HealthServerConfig configures the HTTP listener NewHealthServer
+// builds. Unlike DebugServerConfig, this is on by default and bound to
+// every interface, since an orchestrator's liveness/readiness probe
+// usually comes from outside the pod/container rather than from
+// localhost.
+type HealthServerConfig struct {
+	// Enabled turns the health server on. Defaults to true.
+	Enabled bool
+	// Addr is the address the health server listens on. Defaults to
+	// ":8081".
+	Addr string
+}
+
+// DefaultHealthServerConfig returns the health server enabled on ":8081".
+func DefaultHealthServerConfig() HealthServerConfig {
+	return HealthServerConfig{Enabled: true, Addr: ":8081"}
+}
+
+// LoadHealthServerConfig builds a HealthServerConfig from environment
+// variables, falling back to DefaultHealthServerConfig for anything
+// unset.
+func LoadHealthServerConfig() (HealthServerConfig, error) {
+	cfg := DefaultHealthServerConfig()
+
+	if err := overrideBool(&cfg.Enabled, "MIKHAIL_HEALTH_SERVER_ENABLED"); err != nil {
+		return HealthServerConfig{}, err
+	}
+	if raw := os.Getenv("MIKHAIL_HEALTH_SERVER_ADDR"); raw != "" {
+		cfg.Addr = raw
+	}
+
+	return cfg, nil
+}
+
+// NewHealthServer builds the health HTTP server described by cfg, with
+// two endpoints: /livez always reports 200 once the process is up enough
+// to serve HTTP at all, and /readyz reports 200 only when this instance
+// is fit to receive traffic - storage is reachable (checked via Pinger
+// if storage implements it), OAuth configuration is complete, and a
+// signing key is available - or 503 with the first failing reason
+// otherwise. authServer may be nil, in which case the OAuth-completeness
+// and signing-key checks are skipped (report ready) since there is
+// nothing configured to be incomplete.
+// The returned *http.Server is not started; call StartHealthServer only
+// when cfg.Enabled.
+func NewHealthServer(cfg HealthServerConfig, storage TokenStorage, authServer *AuthServer) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/livez", livezHandler)
+	mux.HandleFunc("/readyz", readyzHandler(storage, authServer))
+	return &http.Server{Addr: cfg.Addr, Handler: mux}
+}
+
+func livezHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// readyzHandler returns a handler for /readyz that runs every readiness
+// check in turn, stopping at (and reporting) the first failure.
+func readyzHandler(storage TokenStorage, authServer *AuthServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+		if pinger, ok := storage.(Pinger); ok {
+			if err := pinger.Ping(); err != nil {
+				http.Error(w, "storage unreachable: "+err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+		if authServer != nil {
+			if !authServer.OAuthConfigComplete() {
+				http.Error(w, "oauth configuration incomplete", http.StatusServiceUnavailable)
+				return
+			}
+			if !authServer.SigningKeyAvailable() {
+				http.Error(w, "signing key unavailable", http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		w.Write([]byte("ok\n"))
+	}
+}
+
+// RunHealthcheck probes this instance's own /readyz endpoint over HTTP
+// and returns an error if it is unreachable or reports not-ready. It is
+// what the --healthcheck flag runs, so a container orchestrator's exec
+// probe (`mikhail --healthcheck`) can check readiness without installing
+// a separate HTTP client or grpcurl in the image.
+func RunHealthcheck() error {
+	cfg, err := LoadHealthServerConfig()
+	if err != nil {
+		return err
+	}
+	if !cfg.Enabled {
+		return errors.New("mikhail: health server is disabled (MIKHAIL_HEALTH_SERVER_ENABLED=false); nothing to probe")
+	}
+
+	resp, err := http.Get(healthcheckURL(cfg.Addr))
+	if err != nil {
+		return fmt.Errorf("mikhail: dialing health server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("mikhail: not ready: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// healthcheckURL turns a HealthServerConfig.Addr like ":8081" or
+// "0.0.0.0:8081" into a /readyz URL reachable from inside the same
+// container, since RunHealthcheck always probes itself rather than a
+// remote instance.
+func healthcheckURL(addr string) string {
+	host := addr
+	if strings.HasPrefix(host, ":") {
+		host = "127.0.0.1" + host
+	} else if strings.HasPrefix(host, "0.0.0.0:") {
+		host = "127.0.0.1" + strings.TrimPrefix(host, "0.0.0.0")
+	}
+	return "http://" + host + "/readyz"
+}
+
+// StartHealthServer starts srv in a background goroutine and logs any
+// error ListenAndServe returns other than http.ErrServerClosed (the
+// expected error on a graceful Shutdown). It does not block.
+func StartHealthServer(srv *http.Server) {
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("mikhail: health server on %s stopped: %v", srv.Addr, err)
+		}
+	}()
+}
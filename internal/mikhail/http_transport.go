@@ -0,0 +1,41 @@
+package mikhail
+
+import (
+	"context"
+	"net/http"
+)
+
+// contextFromHTTPRequest builds the context an interceptor chain expects
+// to see for r: the same attachments a real grpc.Server's transport
+// makes per call (incoming metadata, peer address, and - under mTLS - a
+// ClientCertIdentity) before any UnaryServerInterceptor runs. Both
+// NewGatewayMux and NewAdminGatewayMux call this for every request they
+// serve, since an *http.ServeMux is the transport in a deployment with
+// no grpc.Server of its own.
+func contextFromHTTPRequest(r *http.Request) context.Context {
+	ctx := r.Context()
+	ctx = ContextWithIncomingMetadata(ctx, Metadata(r.Header))
+	ctx = ContextWithPeerInfo(ctx, PeerInfo{Addr: r.RemoteAddr})
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		cert := r.TLS.PeerCertificates[0]
+		ctx = ContextWithClientCertIdentity(ctx, ClientCertIdentity{
+			CommonName:   cert.Subject.CommonName,
+			Organization: cert.Subject.Organization,
+			SerialNumber: cert.SerialNumber.String(),
+		})
+	}
+	return ctx
+}
+
+// dispatch runs req through chain (recovery, auth, rate limiting, and
+// whatever else a deployment configured) before handler, the adaptation
+// UnaryServerInterceptor's doc comment describes for "whatever actually
+// serves traffic" - here, an HTTP gateway mux rather than a real
+// grpc.Server. chain may be nil, in which case handler runs directly
+// with no interceptors applied.
+func dispatch(ctx context.Context, chain UnaryServerInterceptor, fullMethod string, req interface{}, handler UnaryHandler) (interface{}, error) {
+	if chain == nil {
+		return handler(ctx, req)
+	}
+	return chain(ctx, req, &UnaryServerInfo{FullMethod: fullMethod}, handler)
+}
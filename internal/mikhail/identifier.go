@@ -0,0 +1,113 @@
+package mikhail
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// IdentifierKind is which of the three namespaces an identifier passed
+// to SignIn belongs to.
+type IdentifierKind string
+
+const (
+	IdentifierPhone    IdentifierKind = "phone"
+	IdentifierEmail    IdentifierKind = "email"
+	IdentifierUsername IdentifierKind = "username"
+)
+
+// NormalizeIdentifier classifies raw as a phone number, email address,
+// or username, and returns it in the canonical form credentialStore
+// implementations key their lookups on: emails and usernames lowercased
+// and trimmed, phone numbers reduced to an optional leading "+" and
+// digits only.
+func NormalizeIdentifier(raw string) (kind IdentifierKind, normalized string) {
+	trimmed := strings.TrimSpace(raw)
+	if strings.Contains(trimmed, "@") {
+		return IdentifierEmail, strings.ToLower(trimmed)
+	}
+	if looksLikePhoneNumber(trimmed) {
+		return IdentifierPhone, normalizePhoneNumber(trimmed)
+	}
+	return IdentifierUsername, strings.ToLower(trimmed)
+}
+
+// looksLikePhoneNumber reports whether raw is, once punctuation commonly
+// used to format phone numbers is ignored, an optional leading "+"
+// followed by at least 7 digits and nothing else.
+func looksLikePhoneNumber(raw string) bool {
+	digits := 0
+	for i, r := range raw {
+		switch {
+		case r == '+' && i == 0:
+		case r == ' ' || r == '-' || r == '(' || r == ')':
+		case r >= '0' && r <= '9':
+			digits++
+		default:
+			return false
+		}
+	}
+	return digits >= 7
+}
+
+// normalizePhoneNumber strips everything from raw except a leading "+"
+// and digits.
+func normalizePhoneNumber(raw string) string {
+	var b strings.Builder
+	for i, r := range raw {
+		if r == '+' && i == 0 {
+			b.WriteRune(r)
+			continue
+		}
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// IdentifierLinkStore maps normalized, non-phone identifiers (emails and
+// usernames) to the Mikhail user ID that claimed them. It exists so that
+// email- and username-based sign-in can be layered on top of a
+// credentialStore without disturbing the phone-keyed accounts the store
+// already manages: phone lookups still go through credentialStore.verify
+// directly, while email/username lookups resolve through here first.
+// Implementations must be safe for concurrent use and must reject a link
+// whose identifier is already claimed by a different user.
+type IdentifierLinkStore interface {
+	Link(kind IdentifierKind, normalized, userID string) error
+	Lookup(kind IdentifierKind, normalized string) (userID string, ok bool)
+}
+
+// InMemoryIdentifierLinkStore is an IdentifierLinkStore backed by a
+// guarded map, suitable for local development and single-node
+// deployments.
+type InMemoryIdentifierLinkStore struct {
+	mu    sync.Mutex
+	links map[string]string
+}
+
+// NewInMemoryIdentifierLinkStore returns an empty
+// InMemoryIdentifierLinkStore.
+func NewInMemoryIdentifierLinkStore() *InMemoryIdentifierLinkStore {
+	return &InMemoryIdentifierLinkStore{links: make(map[string]string)}
+}
+
+func (s *InMemoryIdentifierLinkStore) Link(kind IdentifierKind, normalized, userID string) error {
+	key := string(kind) + ":" + normalized
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.links[key]; ok && existing != userID {
+		return fmt.Errorf("mikhail: %s %q already claimed", kind, normalized)
+	}
+	s.links[key] = userID
+	return nil
+}
+
+func (s *InMemoryIdentifierLinkStore) Lookup(kind IdentifierKind, normalized string) (string, bool) {
+	key := string(kind) + ":" + normalized
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	userID, ok := s.links[key]
+	return userID, ok
+}
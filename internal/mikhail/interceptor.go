@@ -0,0 +1,27 @@
+package mikhail
+
+import "context"
+
+// UnaryServerInfo carries per-call metadata to a UnaryServerInterceptor,
+// mirroring the shape grpc.UnaryServerInfo has in google.golang.org/grpc
+// closely enough that a deployment wiring Mikhail's interceptors into a
+// real grpc.Server can adapt between the two with a one-line shim. This
+// package does not depend on grpc itself (nothing in this environment
+// can vendor it), so AuthServer's RPC methods are invoked directly by
+// their caller rather than dispatched through a generic handler;
+// UnaryServerInterceptor and this type exist so call-wrapping logic like
+// ClientCertInterceptor can be written once, in the shape a real gRPC
+// server expects, and adapted into whatever actually serves traffic.
+type UnaryServerInfo struct {
+	// FullMethod is the RPC name the call is for, e.g.
+	// "/kingdom.auth.v1.AuthenticateService/SignIn".
+	FullMethod string
+}
+
+// UnaryHandler is the RPC handler a UnaryServerInterceptor wraps.
+type UnaryHandler func(ctx context.Context, req interface{}) (interface{}, error)
+
+// UnaryServerInterceptor wraps a single RPC call: it can inspect or
+// replace ctx and req before calling handler, and inspect or replace the
+// response and error handler returns.
+type UnaryServerInterceptor func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error)
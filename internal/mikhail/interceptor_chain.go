@@ -0,0 +1,205 @@
+package mikhail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ChainUnaryInterceptors combines interceptors into a single
+// UnaryServerInterceptor, the same composition grpc.ChainUnaryServerInterceptor
+// performs on a real grpc.Server: the first interceptor in the list runs
+// outermost (it sees the call first and the response/error last), each
+// one wrapping the next, with handler itself innermost.
+func ChainUnaryInterceptors(interceptors ...UnaryServerInterceptor) UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error) {
+		chained := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			next := chained
+			interceptor := interceptors[i]
+			chained = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, next)
+			}
+		}
+		return chained(ctx, req)
+	}
+}
+
+// ServerStream is the minimal streaming-call surface
+// StreamServerInterceptor needs, mirroring
+// google.golang.org/grpc.ServerStream closely enough that a deployment
+// wiring a StreamServerInterceptor into a real grpc.Server can pass the
+// grpc.ServerStream straight through. AuthenticateService defines no
+// streaming RPCs today, so nothing in this package implements
+// ServerStream yet; these types exist so the composable chain this file
+// builds can be handed a streaming interceptor the day one does.
+type ServerStream interface {
+	Context() context.Context
+}
+
+// StreamServerInfo mirrors google.golang.org/grpc.StreamServerInfo.
+type StreamServerInfo struct {
+	FullMethod string
+}
+
+// StreamHandler is the signature of a streaming RPC's handler body,
+// mirroring grpc.StreamHandler.
+type StreamHandler func(srv interface{}, stream ServerStream) error
+
+// StreamServerInterceptor mirrors grpc.StreamServerInterceptor.
+type StreamServerInterceptor func(srv interface{}, stream ServerStream, info *StreamServerInfo, handler StreamHandler) error
+
+// ChainStreamInterceptors is ChainUnaryInterceptors' streaming
+// counterpart.
+func ChainStreamInterceptors(interceptors ...StreamServerInterceptor) StreamServerInterceptor {
+	return func(srv interface{}, stream ServerStream, info *StreamServerInfo, handler StreamHandler) error {
+		chained := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			next := chained
+			interceptor := interceptors[i]
+			chained = func(srv interface{}, stream ServerStream) error {
+				return interceptor(srv, stream, info, next)
+			}
+		}
+		return chained(srv, stream)
+	}
+}
+
+// Interceptor chain member names, the keys BuildUnaryInterceptorChain
+// looks up in a UnaryServerInterceptor registry and the values
+// InterceptorChainConfig.Order holds.
+const (
+	InterceptorNameRecovery      = "recovery"
+	InterceptorNameTracing       = "tracing"
+	InterceptorNameLogging       = "logging"
+	InterceptorNameLoadShedding  = "load_shedding"
+	InterceptorNameTenant        = "tenant"
+	InterceptorNameAuth          = "auth"
+	InterceptorNameClientCert    = "client_cert"
+	InterceptorNameBlocklist     = "blocklist"
+	InterceptorNameRateLimit     = "rate_limit"
+	InterceptorNameMetrics       = "metrics"
+	InterceptorNameValidation    = "validation"
+	InterceptorNameDeadline      = "deadline"
+	InterceptorNameStatusMapping = "status_mapping"
+)
+
+// DefaultInterceptorChainOrder is the order Mikhail applies interceptors
+// in when MIKHAIL_GRPC_INTERCEPTOR_CHAIN is unset: recovery wraps
+// everything so a panic anywhere downstream is still caught; load
+// shedding and the deadline/blocklist/rate-limit checks run before
+// anything expensive, with blocklist ahead of rate-limit so a banned
+// caller does not spend a rate-limit token on a call that was always
+// going to be rejected; tenant resolution runs right before auth so a
+// handler issuing tokens can stamp the resolved tenant into them; logging
+// runs right after auth so its access log
+// entries can include the caller's Principal, at the cost of not timing
+// the interceptors ahead of it; status mapping runs last so it converts
+// whatever error came out of the handler and every interceptor before
+// it. InterceptorNameClientCert is deliberately left out: it rejects any
+// call with no client certificate outright, which is right for a
+// deployment-specific subset of RPCs (see ClientCertInterceptor's doc
+// comment) but would reject every ordinary SignIn/SignUp call from a
+// browser or mobile client if applied by default; an operator who wants
+// mTLS enforced service-wide adds "client_cert" to
+// MIKHAIL_GRPC_INTERCEPTOR_CHAIN explicitly.
+var DefaultInterceptorChainOrder = []string{
+	InterceptorNameRecovery,
+	InterceptorNameTracing,
+	InterceptorNameLoadShedding,
+	InterceptorNameDeadline,
+	InterceptorNameBlocklist,
+	InterceptorNameRateLimit,
+	InterceptorNameTenant,
+	InterceptorNameAuth,
+	InterceptorNameLogging,
+	InterceptorNameValidation,
+	InterceptorNameMetrics,
+	InterceptorNameStatusMapping,
+}
+
+// InterceptorChainConfig holds the ordered list of interceptor chain
+// member names BuildUnaryInterceptorChain assembles, so an operator can
+// reorder, add, or drop members (e.g. skip "auth" entirely for a
+// deployment with no authenticated RPCs) from configuration instead of
+// a code change to main.go.
+type InterceptorChainConfig struct {
+	Order []string
+}
+
+// DefaultInterceptorChainConfig returns DefaultInterceptorChainOrder.
+func DefaultInterceptorChainConfig() InterceptorChainConfig {
+	return InterceptorChainConfig{Order: append([]string(nil), DefaultInterceptorChainOrder...)}
+}
+
+// LoadInterceptorChainConfig builds an InterceptorChainConfig from
+// MIKHAIL_GRPC_INTERCEPTOR_CHAIN, a comma-separated list of interceptor
+// names in application order, falling back to
+// DefaultInterceptorChainConfig when unset.
+func LoadInterceptorChainConfig() InterceptorChainConfig {
+	raw := os.Getenv("MIKHAIL_GRPC_INTERCEPTOR_CHAIN")
+	if raw == "" {
+		return DefaultInterceptorChainConfig()
+	}
+	names := strings.Split(raw, ",")
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+	}
+	return InterceptorChainConfig{Order: names}
+}
+
+// BuildUnaryInterceptorChain looks up each name in cfg.Order against
+// registry, in order, and combines the results with
+// ChainUnaryInterceptors. It returns an error naming the first entry in
+// cfg.Order that registry has no interceptor for, rather than silently
+// skipping a misconfigured chain member.
+func BuildUnaryInterceptorChain(cfg InterceptorChainConfig, registry map[string]UnaryServerInterceptor) (UnaryServerInterceptor, error) {
+	interceptors := make([]UnaryServerInterceptor, 0, len(cfg.Order))
+	for _, name := range cfg.Order {
+		interceptor, ok := registry[name]
+		if !ok {
+			return nil, fmt.Errorf("mikhail: interceptor chain: no interceptor registered for %q", name)
+		}
+		interceptors = append(interceptors, interceptor)
+	}
+	return ChainUnaryInterceptors(interceptors...), nil
+}
+
+// DefaultUnaryInterceptorRegistry builds the registry
+// BuildUnaryInterceptorChain looks names up in from Mikhail's own
+// interceptors, wiring server, tracer, metrics, logLevel, and the
+// deadline/load shedding/access log config the ones that need
+// configuration were built with. rateLimit and blocklist may be nil, in which case
+// their registry entries are a no-op passthrough; a caller that wants
+// them enforced passes the result of RateLimitInterceptor (built from
+// NewRateLimiterFromEnv and a RateLimitPolicy) and BlocklistInterceptor
+// (built from NewBlocklistStoreFromEnv), as cmd/mikhail does. tenantBindings
+// may also be nil, in which case TenantIDFromContext never resolves a
+// tenant for any caller - the single-tenant case.
+func DefaultUnaryInterceptorRegistry(server *AuthServer, tracer Tracer, deadlines DeadlineConfig, loadShedding LoadSheddingConfig, accessLog AccessLogConfig, logLevel *LogLevelController, metrics MetricsRecorder, rateLimit UnaryServerInterceptor, blocklist UnaryServerInterceptor, tenantBindings TenantBindingStore) map[string]UnaryServerInterceptor {
+	noop := func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error) {
+		return handler(ctx, req)
+	}
+	if rateLimit == nil {
+		rateLimit = noop
+	}
+	if blocklist == nil {
+		blocklist = noop
+	}
+	return map[string]UnaryServerInterceptor{
+		InterceptorNameRecovery:      RecoveryInterceptor(),
+		InterceptorNameTracing:       UnaryTracingInterceptor(tracer),
+		InterceptorNameLogging:       LoggingInterceptor(accessLog, logLevel),
+		InterceptorNameLoadShedding:  LoadSheddingInterceptor(loadShedding),
+		InterceptorNameTenant:        TenantInterceptor(tenantBindings),
+		InterceptorNameAuth:          AuthenticationInterceptor(server, DefaultPublicMethods),
+		InterceptorNameClientCert:    ClientCertInterceptor,
+		InterceptorNameRateLimit:     rateLimit,
+		InterceptorNameBlocklist:     blocklist,
+		InterceptorNameMetrics:       MetricsInterceptor(metrics),
+		InterceptorNameValidation:    ValidationInterceptor(),
+		InterceptorNameDeadline:      DeadlineInterceptor(deadlines),
+		InterceptorNameStatusMapping: StatusMappingInterceptor(false),
+	}
+}
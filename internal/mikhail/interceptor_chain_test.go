@@ -0,0 +1,91 @@
+package mikhail
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func markerInterceptor(name string, order *[]string) UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error) {
+		*order = append(*order, name)
+		return handler(ctx, req)
+	}
+}
+
+func TestChainUnaryInterceptorsRunsInOrderOutermostFirst(t *testing.T) {
+	var order []string
+	chain := ChainUnaryInterceptors(
+		markerInterceptor("first", &order),
+		markerInterceptor("second", &order),
+		markerInterceptor("third", &order),
+	)
+
+	_, err := chain(context.Background(), nil, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		order = append(order, "handler")
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("chain: %v", err)
+	}
+
+	want := []string{"first", "second", "third", "handler"}
+	if len(order) != len(want) {
+		t.Fatalf("call order = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("call order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestChainUnaryInterceptorsShortCircuitsOnError(t *testing.T) {
+	wantErr := errors.New("rejected")
+	var order []string
+	chain := ChainUnaryInterceptors(
+		markerInterceptor("first", &order),
+		func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error) {
+			return nil, wantErr
+		},
+		markerInterceptor("never-reached", &order),
+	)
+
+	_, err := chain(context.Background(), nil, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		order = append(order, "handler")
+		return nil, nil
+	})
+	if err != wantErr {
+		t.Fatalf("chain: got err %v, want %v", err, wantErr)
+	}
+	if len(order) != 1 || order[0] != "first" {
+		t.Fatalf("call order = %v, want only [first] - a rejecting interceptor must stop the chain", order)
+	}
+}
+
+func TestBuildUnaryInterceptorChainUnknownNameErrors(t *testing.T) {
+	_, err := BuildUnaryInterceptorChain(InterceptorChainConfig{Order: []string{"not-a-real-interceptor"}}, map[string]UnaryServerInterceptor{})
+	if err == nil {
+		t.Fatal("BuildUnaryInterceptorChain with an unregistered name: got nil error, want one")
+	}
+}
+
+func TestBuildUnaryInterceptorChainAppliesRegisteredInterceptors(t *testing.T) {
+	var order []string
+	registry := map[string]UnaryServerInterceptor{
+		"a": markerInterceptor("a", &order),
+		"b": markerInterceptor("b", &order),
+	}
+	chain, err := BuildUnaryInterceptorChain(InterceptorChainConfig{Order: []string{"b", "a"}}, registry)
+	if err != nil {
+		t.Fatalf("BuildUnaryInterceptorChain: %v", err)
+	}
+	if _, err := chain(context.Background(), nil, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}); err != nil {
+		t.Fatalf("chain: %v", err)
+	}
+	if len(order) != 2 || order[0] != "b" || order[1] != "a" {
+		t.Fatalf("call order = %v, want [b a] - the registry must apply cfg.Order, not map iteration order", order)
+	}
+}
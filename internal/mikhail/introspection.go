@@ -0,0 +1,61 @@
+package mikhail
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/TwelveFacedJanus/Kingdom-System/internal/authpb"
+)
+
+// IntrospectionResponse is the RFC 7662 token introspection response
+// shape. Only the fields Mikhail can actually populate are included;
+// RFC 7662 treats every field but "active" as optional.
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+}
+
+// IntrospectionHandler returns an http.HandlerFunc implementing the RFC
+// 7662 OAuth 2.0 Token Introspection endpoint on top of AuthServer's
+// VerifyToken. Downstream services that talk HTTP instead of gRPC can use
+// this to validate tokens without a Mikhail client library.
+func IntrospectionHandler(server *AuthServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		token := r.PostFormValue("token")
+		if token == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		result, err := server.VerifyToken(contextFromHTTPRequest(r), &authpb.VerifyTokenRequest{Token: token})
+		if err != nil || !result.Valid {
+			writeIntrospection(w, IntrospectionResponse{Active: false})
+			return
+		}
+
+		writeIntrospection(w, IntrospectionResponse{
+			Active:    true,
+			Scope:     strings.Join(result.Scopes, " "),
+			Sub:       result.UserID,
+			Exp:       result.ExpiresAt,
+			TokenType: "Bearer",
+		})
+	}
+}
+
+func writeIntrospection(w http.ResponseWriter, resp IntrospectionResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,233 @@
+package mikhail
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SigningMethod selects the JWS algorithm used to sign access tokens.
+type SigningMethod string
+
+const (
+	// SigningMethodHS256 signs tokens with a shared secret (HMAC-SHA256).
+	SigningMethodHS256 SigningMethod = "HS256"
+	// SigningMethodRS256 signs tokens with an RSA private key (RSASSA-PKCS1-v1_5 SHA256).
+	SigningMethodRS256 SigningMethod = "RS256"
+)
+
+// ErrTokenExpired is returned by ParseAccessToken when the token's exp
+// claim is in the past.
+var ErrTokenExpired = errors.New("mikhail: access token expired")
+
+// ErrTokenSignature is returned by ParseAccessToken when the token's
+// signature does not verify.
+var ErrTokenSignature = errors.New("mikhail: access token signature invalid")
+
+// AccessTokenIssuer issues and verifies access tokens. JWTIssuer and
+// PASETOIssuer both implement it, letting AuthServer be configured with
+// either token format without caring which one it got.
+type AccessTokenIssuer interface {
+	Issue(userID, phone, tenantID string, scopes, roles []string, ttl time.Duration) (string, AccessClaims, error)
+	ParseAccessToken(token string) (AccessClaims, error)
+}
+
+// AccessClaims are the claims embedded in issued JWT access tokens.
+type AccessClaims struct {
+	Issuer  string `json:"iss"`
+	Subject string `json:"sub"`
+	Phone   string `json:"phone"`
+	// TenantID is the Kingdom-System tenant this token was issued for,
+	// or "" in a single-tenant deployment. A service verifying a token
+	// should compare this against its own tenant when it runs more than
+	// one.
+	TenantID  string   `json:"tenant_id,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	IssuedAt  int64    `json:"iat"`
+	ExpiresAt int64    `json:"exp"`
+}
+
+// JWTIssuer issues and verifies signed access tokens for Mikhail. It
+// replaces the legacy behavior of handing out random opaque strings with
+// tokens that downstream Kingdom-System services can verify on their own.
+type JWTIssuer struct {
+	method     SigningMethod
+	issuer     string
+	hmacSecret []byte
+	rsaPrivate *rsa.PrivateKey
+	rsaPublic  *rsa.PublicKey
+}
+
+// NewHS256Issuer builds a JWTIssuer that signs tokens with a shared secret.
+func NewHS256Issuer(issuer string, secret []byte) (*JWTIssuer, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("mikhail: HS256 secret must not be empty")
+	}
+	return &JWTIssuer{method: SigningMethodHS256, issuer: issuer, hmacSecret: secret}, nil
+}
+
+// NewRS256Issuer builds a JWTIssuer that signs tokens with an RSA private
+// key. The matching public key is used for verification.
+func NewRS256Issuer(issuer string, private *rsa.PrivateKey) (*JWTIssuer, error) {
+	if private == nil {
+		return nil, errors.New("mikhail: RS256 private key must not be nil")
+	}
+	return &JWTIssuer{method: SigningMethodRS256, issuer: issuer, rsaPrivate: private, rsaPublic: &private.PublicKey}, nil
+}
+
+// Issue signs and returns a new access token for the given user, expiring
+// after ttl.
+func (j *JWTIssuer) Issue(userID, phone, tenantID string, scopes, roles []string, ttl time.Duration) (string, AccessClaims, error) {
+	now := time.Now()
+	claims := AccessClaims{
+		Issuer:    j.issuer,
+		Subject:   userID,
+		Phone:     phone,
+		TenantID:  tenantID,
+		Scopes:    scopes,
+		Roles:     roles,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+
+	header := map[string]string{"alg": string(j.method), "typ": "JWT"}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", AccessClaims{}, err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", AccessClaims{}, err
+	}
+
+	signingInput := encodeSegment(headerJSON) + "." + encodeSegment(claimsJSON)
+	signature, err := j.sign(signingInput)
+	if err != nil {
+		return "", AccessClaims{}, err
+	}
+
+	return signingInput + "." + encodeSegment(signature), claims, nil
+}
+
+// ParseAccessToken verifies the signature and expiry of a token issued by
+// Issue and returns its claims.
+func (j *JWTIssuer) ParseAccessToken(token string) (AccessClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return AccessClaims{}, errors.New("mikhail: malformed access token")
+	}
+
+	signature, err := decodeSegment(parts[2])
+	if err != nil {
+		return AccessClaims{}, err
+	}
+	if err := j.verify(parts[0]+"."+parts[1], signature); err != nil {
+		return AccessClaims{}, err
+	}
+
+	claimsJSON, err := decodeSegment(parts[1])
+	if err != nil {
+		return AccessClaims{}, err
+	}
+	var claims AccessClaims
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		return AccessClaims{}, err
+	}
+	if time.Now().Unix() > claims.ExpiresAt {
+		return AccessClaims{}, ErrTokenExpired
+	}
+	return claims, nil
+}
+
+func (j *JWTIssuer) sign(signingInput string) ([]byte, error) {
+	switch j.method {
+	case SigningMethodHS256:
+		mac := hmac.New(sha256.New, j.hmacSecret)
+		mac.Write([]byte(signingInput))
+		return mac.Sum(nil), nil
+	case SigningMethodRS256:
+		sum := sha256.Sum256([]byte(signingInput))
+		return rsa.SignPKCS1v15(rand.Reader, j.rsaPrivate, crypto.SHA256, sum[:])
+	default:
+		return nil, fmt.Errorf("mikhail: unsupported signing method %q", j.method)
+	}
+}
+
+func (j *JWTIssuer) verify(signingInput string, signature []byte) error {
+	switch j.method {
+	case SigningMethodHS256:
+		mac := hmac.New(sha256.New, j.hmacSecret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return ErrTokenSignature
+		}
+		return nil
+	case SigningMethodRS256:
+		sum := sha256.Sum256([]byte(signingInput))
+		if err := rsa.VerifyPKCS1v15(j.rsaPublic, crypto.SHA256, sum[:], signature); err != nil {
+			return ErrTokenSignature
+		}
+		return nil
+	default:
+		return fmt.Errorf("mikhail: unsupported signing method %q", j.method)
+	}
+}
+
+// IDClaims are the claims embedded in an OIDC ID token: who authenticated
+// (sub), who they authenticated to (aud), and when.
+type IDClaims struct {
+	Issuer      string `json:"iss"`
+	Subject     string `json:"sub"`
+	Audience    string `json:"aud"`
+	PhoneNumber string `json:"phone_number,omitempty"`
+	IssuedAt    int64  `json:"iat"`
+	ExpiresAt   int64  `json:"exp"`
+}
+
+// IssueIDToken signs an OIDC-compliant ID token for userID, scoped to the
+// given audience (typically the client_id of the relying party).
+func (j *JWTIssuer) IssueIDToken(userID, phone, audience string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := IDClaims{
+		Issuer:      j.issuer,
+		Subject:     userID,
+		Audience:    audience,
+		PhoneNumber: phone,
+		IssuedAt:    now.Unix(),
+		ExpiresAt:   now.Add(ttl).Unix(),
+	}
+
+	header := map[string]string{"alg": string(j.method), "typ": "JWT"}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encodeSegment(headerJSON) + "." + encodeSegment(claimsJSON)
+	signature, err := j.sign(signingInput)
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + encodeSegment(signature), nil
+}
+
+func encodeSegment(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSegment(segment string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(segment)
+}
@@ -0,0 +1,101 @@
+package mikhail
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+)
+
+func TestHS256IssueAndParseRoundTrip(t *testing.T) {
+	issuer, err := NewHS256Issuer("mikhail-test", []byte("test-secret-value-not-for-prod"))
+	if err != nil {
+		t.Fatalf("NewHS256Issuer: %v", err)
+	}
+
+	token, claims, err := issuer.Issue("user-1", "+15551234567", "tenant-a", []string{"read"}, []string{"user"}, time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	parsed, err := issuer.ParseAccessToken(token)
+	if err != nil {
+		t.Fatalf("ParseAccessToken: %v", err)
+	}
+	if parsed.Subject != claims.Subject || parsed.TenantID != "tenant-a" {
+		t.Fatalf("ParseAccessToken returned %+v, want subject %q tenant %q", parsed, claims.Subject, "tenant-a")
+	}
+}
+
+func TestHS256ParseAccessTokenRejectsTamperedSignature(t *testing.T) {
+	issuer, err := NewHS256Issuer("mikhail-test", []byte("test-secret-value-not-for-prod"))
+	if err != nil {
+		t.Fatalf("NewHS256Issuer: %v", err)
+	}
+	token, _, err := issuer.Issue("user-1", "", "", nil, nil, time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	tampered := token[:len(token)-1] + "x"
+	if _, err := issuer.ParseAccessToken(tampered); err != ErrTokenSignature {
+		t.Fatalf("ParseAccessToken on a tampered token: got err %v, want ErrTokenSignature", err)
+	}
+}
+
+func TestHS256ParseAccessTokenRejectsWrongSecret(t *testing.T) {
+	issuer, err := NewHS256Issuer("mikhail-test", []byte("test-secret-value-not-for-prod"))
+	if err != nil {
+		t.Fatalf("NewHS256Issuer: %v", err)
+	}
+	other, err := NewHS256Issuer("mikhail-test", []byte("a-completely-different-secret!!"))
+	if err != nil {
+		t.Fatalf("NewHS256Issuer: %v", err)
+	}
+	token, _, err := issuer.Issue("user-1", "", "", nil, nil, time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if _, err := other.ParseAccessToken(token); err != ErrTokenSignature {
+		t.Fatalf("ParseAccessToken with the wrong secret: got err %v, want ErrTokenSignature", err)
+	}
+}
+
+func TestHS256ParseAccessTokenRejectsExpiredToken(t *testing.T) {
+	issuer, err := NewHS256Issuer("mikhail-test", []byte("test-secret-value-not-for-prod"))
+	if err != nil {
+		t.Fatalf("NewHS256Issuer: %v", err)
+	}
+	token, _, err := issuer.Issue("user-1", "", "", nil, nil, -time.Minute)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if _, err := issuer.ParseAccessToken(token); err != ErrTokenExpired {
+		t.Fatalf("ParseAccessToken on an already-expired token: got err %v, want ErrTokenExpired", err)
+	}
+}
+
+func TestRS256IssueAndParseRoundTrip(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: %v", err)
+	}
+	issuer, err := NewRS256Issuer("mikhail-test", private)
+	if err != nil {
+		t.Fatalf("NewRS256Issuer: %v", err)
+	}
+
+	token, _, err := issuer.Issue("user-1", "", "", []string{"read"}, []string{"user"}, time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if _, err := issuer.ParseAccessToken(token); err != nil {
+		t.Fatalf("ParseAccessToken: %v", err)
+	}
+}
+
+func TestNewHS256IssuerRejectsEmptySecret(t *testing.T) {
+	if _, err := NewHS256Issuer("mikhail-test", nil); err == nil {
+		t.Fatal("NewHS256Issuer with an empty secret: got nil error, want one")
+	}
+}
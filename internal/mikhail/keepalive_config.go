@@ -0,0 +1,110 @@
+package mikhail
+
+import (
+	"fmt"
+	"time"
+)
+
+// KeepaliveConfig holds the gRPC connection-management knobs Mikhail's
+// real grpc.Server construction (once it has one; see UnaryServerInfo in
+// interceptor.go for the same "define the shape now, wire it into a real
+// grpc.Server later" approach) passes into grpc.KeepaliveParams and
+// grpc.KeepaliveEnforcementPolicy. Operators running Mikhail behind an L4
+// load balancer need to tune these to the balancer's own idle-connection
+// timeout instead of living with grpc-go's library defaults, which is why
+// they are configuration rather than constants.
+type KeepaliveConfig struct {
+	// MaxConnectionIdle is how long a connection may go without any RPC
+	// activity before the server sends a GOAWAY, maps to
+	// keepalive.ServerParameters.MaxConnectionIdle.
+	MaxConnectionIdle time.Duration
+	// MaxConnectionAge is the maximum age of any connection before the
+	// server sends a GOAWAY, maps to
+	// keepalive.ServerParameters.MaxConnectionAge. Bounding this spreads
+	// load back out across a pool after a rolling deploy or scale-up
+	// instead of every client staying pinned to whichever instance it
+	// first connected to.
+	MaxConnectionAge time.Duration
+	// MaxConnectionAgeGrace is the grace period after MaxConnectionAge
+	// during which in-flight RPCs may finish before the connection is
+	// force-closed, maps to
+	// keepalive.ServerParameters.MaxConnectionAgeGrace.
+	MaxConnectionAgeGrace time.Duration
+	// Time is how often the server pings an idle connection to check it
+	// is still alive, maps to keepalive.ServerParameters.Time.
+	Time time.Duration
+	// Timeout is how long the server waits for a ping ack before closing
+	// the connection, maps to keepalive.ServerParameters.Timeout.
+	Timeout time.Duration
+	// EnforcementMinTime is the minimum interval a client is allowed to
+	// send keepalive pings at before the server considers it abusive and
+	// closes the connection, maps to
+	// keepalive.EnforcementPolicy.MinTime.
+	EnforcementMinTime time.Duration
+	// EnforcementPermitWithoutStream allows a client to send keepalive
+	// pings even with no active RPC, maps to
+	// keepalive.EnforcementPolicy.PermitWithoutStream.
+	EnforcementPermitWithoutStream bool
+}
+
+// DefaultKeepaliveConfig returns grpc-go's own library defaults, so
+// leaving every MIKHAIL_GRPC_KEEPALIVE_* variable unset reproduces
+// exactly the behavior Mikhail had before this was configurable.
+func DefaultKeepaliveConfig() KeepaliveConfig {
+	return KeepaliveConfig{
+		MaxConnectionIdle:              2 * time.Hour,
+		MaxConnectionAge:               2 * time.Hour,
+		MaxConnectionAgeGrace:          2 * time.Hour,
+		Time:                           2 * time.Hour,
+		Timeout:                        20 * time.Second,
+		EnforcementMinTime:             5 * time.Minute,
+		EnforcementPermitWithoutStream: false,
+	}
+}
+
+// LoadKeepaliveConfig builds a KeepaliveConfig from environment
+// variables, falling back to DefaultKeepaliveConfig for anything unset.
+func LoadKeepaliveConfig() (KeepaliveConfig, error) {
+	cfg := DefaultKeepaliveConfig()
+
+	if err := overrideDuration(&cfg.MaxConnectionIdle, "MIKHAIL_GRPC_KEEPALIVE_MAX_CONNECTION_IDLE"); err != nil {
+		return KeepaliveConfig{}, err
+	}
+	if err := overrideDuration(&cfg.MaxConnectionAge, "MIKHAIL_GRPC_KEEPALIVE_MAX_CONNECTION_AGE"); err != nil {
+		return KeepaliveConfig{}, err
+	}
+	if err := overrideDuration(&cfg.MaxConnectionAgeGrace, "MIKHAIL_GRPC_KEEPALIVE_MAX_CONNECTION_AGE_GRACE"); err != nil {
+		return KeepaliveConfig{}, err
+	}
+	if err := overrideDuration(&cfg.Time, "MIKHAIL_GRPC_KEEPALIVE_TIME"); err != nil {
+		return KeepaliveConfig{}, err
+	}
+	if err := overrideDuration(&cfg.Timeout, "MIKHAIL_GRPC_KEEPALIVE_TIMEOUT"); err != nil {
+		return KeepaliveConfig{}, err
+	}
+	if err := overrideDuration(&cfg.EnforcementMinTime, "MIKHAIL_GRPC_KEEPALIVE_ENFORCEMENT_MIN_TIME"); err != nil {
+		return KeepaliveConfig{}, err
+	}
+	if err := overrideBool(&cfg.EnforcementPermitWithoutStream, "MIKHAIL_GRPC_KEEPALIVE_ENFORCEMENT_PERMIT_WITHOUT_STREAM"); err != nil {
+		return KeepaliveConfig{}, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate reports an error if any duration is negative.
+func (c KeepaliveConfig) Validate() error {
+	for name, d := range map[string]time.Duration{
+		"MaxConnectionIdle":     c.MaxConnectionIdle,
+		"MaxConnectionAge":      c.MaxConnectionAge,
+		"MaxConnectionAgeGrace": c.MaxConnectionAgeGrace,
+		"Time":                  c.Time,
+		"Timeout":               c.Timeout,
+		"EnforcementMinTime":    c.EnforcementMinTime,
+	} {
+		if d < 0 {
+			return fmt.Errorf("mikhail: keepalive config.%s must not be negative, got %s", name, d)
+		}
+	}
+	return nil
+}
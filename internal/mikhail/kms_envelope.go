@@ -0,0 +1,153 @@
+package mikhail
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// KMSClient is the minimal KMS surface envelope encryption needs: wrap
+// (encrypt) and unwrap (decrypt) a short data key. Mikhail depends only
+// on this interface rather than on any particular KMS SDK, the same way
+// EtcdTokenStorage depends only on EtcdKV: AWS KMS, GCP KMS and Vault's
+// transit secrets engine all expose an encrypt/decrypt-style API that
+// fits it, so callers wrap whichever SDK client they use to satisfy it.
+type KMSClient interface {
+	Encrypt(ctx context.Context, plaintext []byte) (ciphertext []byte, err error)
+	Decrypt(ctx context.Context, ciphertext []byte) (plaintext []byte, err error)
+}
+
+// WrappedTokenKey is a TokenEncryptionKeyring data key as it is safe to
+// persist at rest: the plaintext key never leaves GenerateEnvelopeKey
+// and UnwrapEnvelopeKey, only its KMS-encrypted form does.
+type WrappedTokenKey struct {
+	Version    int
+	WrappedKey []byte
+}
+
+// GenerateEnvelopeKey creates a new random 32-byte AES-256 data key and
+// wraps it with kms. It returns the WrappedTokenKey to persist (next to
+// Redis config, in a small secrets table, wherever the deployment
+// already keeps such things) and the plaintext key to load into a
+// TokenEncryptionKeyring with AddKey. The plaintext key is never
+// returned wrapped in anything that would be persisted by accident.
+func GenerateEnvelopeKey(ctx context.Context, kms KMSClient, version int) (WrappedTokenKey, []byte, error) {
+	plaintext := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, plaintext); err != nil {
+		return WrappedTokenKey{}, nil, err
+	}
+	wrapped, err := kms.Encrypt(ctx, plaintext)
+	if err != nil {
+		return WrappedTokenKey{}, nil, err
+	}
+	return WrappedTokenKey{Version: version, WrappedKey: wrapped}, plaintext, nil
+}
+
+// UnwrapEnvelopeKey decrypts a WrappedTokenKey's data key via kms, for
+// loading a TokenEncryptionKeyring at startup without the plaintext key
+// ever having been stored anywhere itself.
+func UnwrapEnvelopeKey(ctx context.Context, kms KMSClient, wrapped WrappedTokenKey) ([]byte, error) {
+	return kms.Decrypt(ctx, wrapped.WrappedKey)
+}
+
+// LoadEnvelopeKeyring unwraps every key in wrapped via kms and returns a
+// TokenEncryptionKeyring populated with all of them, for restoring a
+// RedisTokenStorage's full keyring (every version still needed to
+// decrypt old ciphertexts, not just the current one) at startup.
+func LoadEnvelopeKeyring(ctx context.Context, kms KMSClient, wrapped []WrappedTokenKey) (*TokenEncryptionKeyring, error) {
+	keyring := NewTokenEncryptionKeyring()
+	for _, w := range wrapped {
+		plaintext, err := UnwrapEnvelopeKey(ctx, kms, w)
+		if err != nil {
+			return nil, err
+		}
+		if err := keyring.AddKey(w.Version, plaintext); err != nil {
+			return nil, err
+		}
+	}
+	return keyring, nil
+}
+
+// EnvelopeKeyRotationScheduler periodically generates a new envelope
+// data key, loads it into keyring as the new current version, and hands
+// the resulting WrappedTokenKey to OnRotate so the caller can persist
+// it wherever they keep the rest — no Redis flush is involved: existing
+// ciphertexts stay readable under their original key version, and
+// RedisTokenReencryptionScheduler (if running against the same keyring)
+// rewrites them under the new one over time.
+type EnvelopeKeyRotationScheduler struct {
+	kms     KMSClient
+	keyring *TokenEncryptionKeyring
+	// Interval is how often a new data key is generated.
+	Interval time.Duration
+	// OnRotate is called with each newly generated WrappedTokenKey so the
+	// caller can persist it. It must not be nil.
+	OnRotate func(WrappedTokenKey)
+
+	mu          sync.Mutex
+	nextVersion int
+	stop        chan struct{}
+}
+
+// NewEnvelopeKeyRotationScheduler returns an EnvelopeKeyRotationScheduler
+// that rotates keyring's data key via kms every interval, starting from
+// nextVersion for the first rotation (and incrementing from there).
+// Call Start to begin rotating.
+func NewEnvelopeKeyRotationScheduler(kms KMSClient, keyring *TokenEncryptionKeyring, nextVersion int, interval time.Duration, onRotate func(WrappedTokenKey)) *EnvelopeKeyRotationScheduler {
+	return &EnvelopeKeyRotationScheduler{
+		kms:         kms,
+		keyring:     keyring,
+		Interval:    interval,
+		OnRotate:    onRotate,
+		nextVersion: nextVersion,
+		stop:        make(chan struct{}),
+	}
+}
+
+// Start runs the rotation loop until ctx is done or Stop is called. It
+// is meant to be run in its own goroutine.
+func (sch *EnvelopeKeyRotationScheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(sch.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sch.stop:
+			return
+		case <-ticker.C:
+			sch.rotateOnce(ctx)
+		}
+	}
+}
+
+// Stop ends a running Start loop.
+func (sch *EnvelopeKeyRotationScheduler) Stop() {
+	close(sch.stop)
+}
+
+func (sch *EnvelopeKeyRotationScheduler) rotateOnce(ctx context.Context) {
+	sch.mu.Lock()
+	version := sch.nextVersion
+	sch.mu.Unlock()
+
+	wrapped, plaintext, err := GenerateEnvelopeKey(ctx, sch.kms, version)
+	if err != nil {
+		log.Printf("mikhail: envelope data key rotation failed: %v", err)
+		return
+	}
+	if err := sch.keyring.AddKey(wrapped.Version, plaintext); err != nil {
+		log.Printf("mikhail: loading rotated envelope data key failed: %v", err)
+		return
+	}
+
+	sch.mu.Lock()
+	sch.nextVersion = version + 1
+	sch.mu.Unlock()
+
+	sch.OnRotate(wrapped)
+	log.Printf("mikhail: rotated envelope data key to version %d", wrapped.Version)
+}
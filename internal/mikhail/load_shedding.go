@@ -0,0 +1,96 @@
+package mikhail
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// LoadSheddingError is returned by LoadSheddingInterceptor when the
+// in-flight request ceiling is exceeded. RetryAfter is surfaced on the
+// Status StatusFromError builds from it, mirroring
+// google.rpc.RetryInfo.retry_delay, so a client backs off instead of
+// retrying immediately into the same overload.
+type LoadSheddingError struct {
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface.
+func (e *LoadSheddingError) Error() string {
+	return fmt.Sprintf("mikhail: load shedding: in-flight request limit exceeded, retry after %s", e.RetryAfter)
+}
+
+// LoadSheddingConfig holds the bounded in-flight request limit
+// LoadSheddingInterceptor enforces.
+type LoadSheddingConfig struct {
+	// MaxInFlight is the maximum number of unary RPCs LoadSheddingInterceptor
+	// lets run concurrently before shedding load. A call that arrives at
+	// the ceiling is rejected rather than queued, so a traffic spike fails
+	// fast instead of piling up latency (and Redis/Yandex API load) behind
+	// it.
+	MaxInFlight int64
+	// RetryAfter is the duration a shed call's LoadSheddingError reports,
+	// a hint for how long a client should back off before retrying.
+	RetryAfter time.Duration
+}
+
+// DefaultLoadSheddingConfig returns a generous ceiling that only matters
+// under real overload, so leaving every MIKHAIL_GRPC_LOAD_SHEDDING_*
+// variable unset does not change behavior for ordinary traffic.
+func DefaultLoadSheddingConfig() LoadSheddingConfig {
+	return LoadSheddingConfig{
+		MaxInFlight: 512,
+		RetryAfter:  time.Second,
+	}
+}
+
+// LoadLoadSheddingConfig builds a LoadSheddingConfig from environment
+// variables, falling back to DefaultLoadSheddingConfig for anything
+// unset.
+func LoadLoadSheddingConfig() (LoadSheddingConfig, error) {
+	cfg := DefaultLoadSheddingConfig()
+
+	maxInFlight := int(cfg.MaxInFlight)
+	if err := overrideInt(&maxInFlight, "MIKHAIL_GRPC_MAX_INFLIGHT_REQUESTS"); err != nil {
+		return LoadSheddingConfig{}, err
+	}
+	cfg.MaxInFlight = int64(maxInFlight)
+
+	if err := overrideDuration(&cfg.RetryAfter, "MIKHAIL_GRPC_LOAD_SHEDDING_RETRY_AFTER"); err != nil {
+		return LoadSheddingConfig{}, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate reports an error if MaxInFlight or RetryAfter is not strictly
+// positive.
+func (c LoadSheddingConfig) Validate() error {
+	if c.MaxInFlight <= 0 {
+		return fmt.Errorf("mikhail: load shedding config.MaxInFlight must be positive, got %d", c.MaxInFlight)
+	}
+	if c.RetryAfter <= 0 {
+		return fmt.Errorf("mikhail: load shedding config.RetryAfter must be positive, got %s", c.RetryAfter)
+	}
+	return nil
+}
+
+// LoadSheddingInterceptor returns a UnaryServerInterceptor that tracks
+// the number of RPCs currently in flight across the whole process and
+// rejects a call with a *LoadSheddingError, instead of invoking handler,
+// once cfg.MaxInFlight is already in use. Install it early in the chain,
+// after RecoveryInterceptor but before anything that talks to Redis or
+// the Yandex OAuth API, so a spike that would otherwise exhaust those
+// downstreams is turned away at the edge instead.
+func LoadSheddingInterceptor(cfg LoadSheddingConfig) UnaryServerInterceptor {
+	var inFlight int64
+	return func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error) {
+		if atomic.AddInt64(&inFlight, 1) > cfg.MaxInFlight {
+			atomic.AddInt64(&inFlight, -1)
+			return nil, &LoadSheddingError{RetryAfter: cfg.RetryAfter}
+		}
+		defer atomic.AddInt64(&inFlight, -1)
+		return handler(ctx, req)
+	}
+}
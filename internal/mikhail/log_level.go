@@ -0,0 +1,84 @@
+package mikhail
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// LogLevelController is Mikhail's equivalent of zap's zap.AtomicLevel: a
+// shared, concurrency-safe log level an operator can change on a live
+// instance - to get more detail while diagnosing an auth problem,
+// without restarting the process and dropping every session a restart
+// would. Mikhail has no zap dependency; this wraps the standard
+// library's own atomically-updatable level, log/slog.LevelVar, which is
+// the same mechanism under a different name.
+type LogLevelController struct {
+	level *slog.LevelVar
+}
+
+// NewLogLevelController returns a LogLevelController starting at
+// initial.
+func NewLogLevelController(initial slog.Level) *LogLevelController {
+	v := &slog.LevelVar{}
+	v.Set(initial)
+	return &LogLevelController{level: v}
+}
+
+// Level returns the current level.
+func (c *LogLevelController) Level() slog.Level {
+	return c.level.Level()
+}
+
+// SetLevel changes the current level, effective for the very next log
+// statement that checks it.
+func (c *LogLevelController) SetLevel(level slog.Level) {
+	c.level.Set(level)
+}
+
+// DebugEnabled reports whether the current level is at or below
+// slog.LevelDebug, Mikhail's signal to include extra diagnostic detail
+// (e.g. full request/response payloads in LoggingInterceptor's access
+// log) that is too verbose to leave on by default.
+func (c *LogLevelController) DebugEnabled() bool {
+	return c.Level() <= slog.LevelDebug
+}
+
+// ReloadFromEnv re-reads MIKHAIL_LOG_LEVEL and applies it, for
+// SetupSIGHUPReload to call; it is a no-op if the variable is unset, so
+// an operator who only ever changes the level via the admin RPC or
+// /debug/loglevel does not have it reset out from under them on every
+// SIGHUP.
+func (c *LogLevelController) ReloadFromEnv() error {
+	raw := os.Getenv("MIKHAIL_LOG_LEVEL")
+	if raw == "" {
+		return nil
+	}
+	level, err := ParseLogLevel(raw)
+	if err != nil {
+		return err
+	}
+	c.SetLevel(level)
+	return nil
+}
+
+// ParseLogLevel parses one of "debug", "info", "warn" (or "warning"), or
+// "error" (case-insensitively, matching slog.Level.String()'s own names),
+// rejecting anything else rather than silently falling back to a
+// default: an operator who fat-fingers a level name should see an error
+// immediately, not switch the instance to the wrong level.
+func ParseLogLevel(raw string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("mikhail: unknown log level %q, want one of debug, info, warn, error", raw)
+	}
+}
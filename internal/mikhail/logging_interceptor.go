@@ -0,0 +1,247 @@
+package mikhail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	mathrand "math/rand"
+	"os"
+	"time"
+)
+
+// PeerInfo is the minimal per-connection peer identity access logging
+// needs, mirroring the CommonName/Organization shape ClientCertIdentity
+// already carries closely enough that a transport accepting plain TCP
+// (no client certificate) can still report something for LoggingInterceptor
+// to log.
+type PeerInfo struct {
+	// Addr is the peer's address, typically "ip:port", the same string
+	// net.Conn.RemoteAddr().String() returns.
+	Addr string
+}
+
+type peerInfoKey struct{}
+
+// ContextWithPeerInfo returns a context carrying info. The transport
+// layer attaches this once per connection before dispatching to the
+// interceptor chain, the same way it attaches a ClientCertIdentity.
+func ContextWithPeerInfo(ctx context.Context, info PeerInfo) context.Context {
+	return context.WithValue(ctx, peerInfoKey{}, info)
+}
+
+// PeerInfoFromContext returns the PeerInfo ContextWithPeerInfo attached
+// to ctx, or ok=false if none was.
+func PeerInfoFromContext(ctx context.Context) (PeerInfo, bool) {
+	info, ok := ctx.Value(peerInfoKey{}).(PeerInfo)
+	return info, ok
+}
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a context carrying id, for a handler (or
+// a later interceptor) to read back via RequestIDFromContext, e.g. to
+// include it in an error message or a downstream call it makes on the
+// caller's behalf.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID LoggingInterceptor attached
+// to ctx, or ok=false if LoggingInterceptor is not installed.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+// generateRequestID returns a random, opaque, URL-safe request ID, the
+// same shape generateAuthToken produces but shorter since this is only
+// ever compared for equality in a log search, never guessed against.
+func generateRequestID() (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// AccessLogConfig controls how much LoggingInterceptor logs: every error
+// and every slow request are always logged, but a high-volume success
+// path can be expensive to log in full, so successful, fast requests are
+// only sampled.
+type AccessLogConfig struct {
+	// SuccessSampleRate is the fraction (0.0 to 1.0) of successful
+	// requests faster than SlowRequestThreshold that get logged. Defaults
+	// to 1.0 (log every request) so leaving it unset does not silently
+	// drop access logs.
+	SuccessSampleRate float64
+	// SlowRequestThreshold is how long a request may take before it is
+	// always logged (and flagged Slow) regardless of SuccessSampleRate.
+	SlowRequestThreshold time.Duration
+}
+
+// DefaultAccessLogConfig returns SuccessSampleRate 1.0 and a generous
+// SlowRequestThreshold, i.e. "log everything" - the right default until
+// an operator has actual traffic volume to tune sampling against.
+func DefaultAccessLogConfig() AccessLogConfig {
+	return AccessLogConfig{SuccessSampleRate: 1.0, SlowRequestThreshold: time.Second}
+}
+
+// LoadAccessLogConfig builds an AccessLogConfig from environment
+// variables, falling back to DefaultAccessLogConfig for anything unset.
+func LoadAccessLogConfig() (AccessLogConfig, error) {
+	cfg := DefaultAccessLogConfig()
+
+	if raw := os.Getenv("MIKHAIL_ACCESS_LOG_SUCCESS_SAMPLE_RATE"); raw != "" {
+		var rate float64
+		if _, err := fmt.Sscanf(raw, "%g", &rate); err != nil {
+			return AccessLogConfig{}, fmt.Errorf("mikhail: parsing MIKHAIL_ACCESS_LOG_SUCCESS_SAMPLE_RATE=%q: %w", raw, err)
+		}
+		cfg.SuccessSampleRate = rate
+	}
+	if err := overrideDuration(&cfg.SlowRequestThreshold, "MIKHAIL_ACCESS_LOG_SLOW_REQUEST_THRESHOLD"); err != nil {
+		return AccessLogConfig{}, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate reports an error if SuccessSampleRate is outside [0, 1] or
+// SlowRequestThreshold is not positive.
+func (c AccessLogConfig) Validate() error {
+	if c.SuccessSampleRate < 0 || c.SuccessSampleRate > 1 {
+		return fmt.Errorf("mikhail: access log config.SuccessSampleRate must be between 0 and 1, got %g", c.SuccessSampleRate)
+	}
+	if c.SlowRequestThreshold <= 0 {
+		return fmt.Errorf("mikhail: access log config.SlowRequestThreshold must be positive, got %s", c.SlowRequestThreshold)
+	}
+	return nil
+}
+
+// accessLogEntry is the structured, JSON-encoded line LoggingInterceptor
+// emits per logged RPC.
+type accessLogEntry struct {
+	RequestID string `json:"request_id"`
+	Method    string `json:"method"`
+	// Caller is the authenticated Principal's UserID, or "" if the call
+	// carried no bearer token (AuthenticationInterceptor had not yet run,
+	// or the RPC does not require one).
+	Caller     string `json:"caller,omitempty"`
+	PeerAddr   string `json:"peer_addr,omitempty"`
+	Code       Code   `json:"code"`
+	DurationMS int64  `json:"duration_ms"`
+	ReqBytes   int    `json:"req_bytes"`
+	RespBytes  int    `json:"resp_bytes"`
+	Slow       bool   `json:"slow,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// Req/Resp are only populated when logLevel.DebugEnabled(), since a
+	// full request/response dump is far too verbose to leave on in
+	// production.
+	Req  interface{} `json:"req,omitempty"`
+	Resp interface{} `json:"resp,omitempty"`
+}
+
+// approximatePayloadSize returns the length of v JSON-marshaled, a
+// reasonable stand-in for wire size given Mikhail has no real protobuf
+// encoder of its own to measure with. v may be nil, in which case the
+// size is 0.
+func approximatePayloadSize(v interface{}) int {
+	if v == nil {
+		return 0
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
+
+// LoggingInterceptor returns a UnaryServerInterceptor that emits one
+// structured JSON access log line per RPC, including a request ID
+// (generated here and attached to ctx for the handler or a later
+// interceptor to read via RequestIDFromContext, or taken from an
+// incoming "x-request-id" header if the caller already set one), the
+// caller's identity and peer IP, the response code, and request/response
+// payload sizes. Every error and every request slower than
+// cfg.SlowRequestThreshold is always logged; other successful requests
+// are logged at cfg.SuccessSampleRate (all of them while logLevel reports
+// DebugEnabled), so a high-volume success path does not have to pay full
+// logging cost.
+//
+// Caller identity and peer IP are read best-effort from whatever
+// Principal/PeerInfo are already on ctx when handler returns: install
+// this after AuthenticationInterceptor in the chain order for Caller to
+// be populated. logLevel, if non-nil, is consulted on every logged entry:
+// when it reports DebugEnabled, the full request and response are
+// included too, for an operator who has switched a live instance to
+// debug level (see LogLevelController) while diagnosing an auth problem.
+// A nil logLevel never includes them.
+func LoggingInterceptor(cfg AccessLogConfig, logLevel *LogLevelController) UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error) {
+		md, _ := IncomingMetadataFromContext(ctx)
+		requestID := md.Get("x-request-id")
+		if requestID == "" {
+			var err error
+			requestID, err = generateRequestID()
+			if err != nil {
+				requestID = "-"
+			}
+		}
+		ctx = ContextWithRequestID(ctx, requestID)
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		duration := time.Since(start)
+
+		slow := duration >= cfg.SlowRequestThreshold
+		debug := logLevel != nil && logLevel.DebugEnabled()
+		if err == nil && !slow && !debug && !sampleAccessLog(cfg.SuccessSampleRate) {
+			return resp, err
+		}
+
+		entry := accessLogEntry{
+			RequestID:  requestID,
+			Method:     info.FullMethod,
+			Code:       StatusFromError(err).Code,
+			DurationMS: duration.Milliseconds(),
+			ReqBytes:   approximatePayloadSize(req),
+			RespBytes:  approximatePayloadSize(resp),
+			Slow:       slow,
+		}
+		if principal, ok := PrincipalFromContext(ctx); ok {
+			entry.Caller = principal.UserID
+		}
+		if peer, ok := PeerInfoFromContext(ctx); ok {
+			entry.PeerAddr = peer.Addr
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		if debug {
+			entry.Req = req
+			entry.Resp = resp
+		}
+
+		line, marshalErr := json.Marshal(entry)
+		if marshalErr != nil {
+			log.Printf("mikhail: rpc method=%s duration=%s error=%v (access log entry failed to marshal: %v)", info.FullMethod, duration, err, marshalErr)
+			return resp, err
+		}
+		log.Print(string(line))
+		return resp, err
+	}
+}
+
+// sampleAccessLog reports whether a successful, non-slow request should
+// be logged at rate (a fraction between 0 and 1).
+func sampleAccessLog(rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	return mathrand.Float64() < rate
+}
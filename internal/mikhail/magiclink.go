@@ -0,0 +1,75 @@
+package mikhail
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrMagicLinkInvalid is returned by CompleteMagicLink when the link
+// token is unknown, expired, or already used.
+var ErrMagicLinkInvalid = errors.New("mikhail: magic link token invalid or expired")
+
+// MagicLinkInfo is what Mikhail keeps about an issued passwordless
+// login link.
+type MagicLinkInfo struct {
+	UserID     string
+	Identifier string
+	IssuedAt   time.Time
+	ExpiresAt  time.Time
+	Used       bool
+}
+
+// Expired reports whether the magic link is past its expiry time as of
+// now.
+func (l MagicLinkInfo) Expired(now time.Time) bool {
+	return now.After(l.ExpiresAt)
+}
+
+// MagicLinkStore persists single-use magic link tokens, following the
+// same shape as PasswordResetStorage: a link carries no session state
+// of its own, and is deleted the moment it is redeemed rather than
+// rotated.
+type MagicLinkStore interface {
+	Store(token string, info MagicLinkInfo) error
+	Get(token string) (MagicLinkInfo, bool, error)
+	MarkUsed(token string) error
+}
+
+// InMemoryMagicLinkStore is a MagicLinkStore backed by a guarded map,
+// suitable for local development and single-node deployments.
+type InMemoryMagicLinkStore struct {
+	mu     sync.Mutex
+	tokens map[string]MagicLinkInfo
+}
+
+// NewInMemoryMagicLinkStore returns an empty InMemoryMagicLinkStore.
+func NewInMemoryMagicLinkStore() *InMemoryMagicLinkStore {
+	return &InMemoryMagicLinkStore{tokens: make(map[string]MagicLinkInfo)}
+}
+
+func (s *InMemoryMagicLinkStore) Store(token string, info MagicLinkInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[token] = info
+	return nil
+}
+
+func (s *InMemoryMagicLinkStore) Get(token string) (MagicLinkInfo, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, ok := s.tokens[token]
+	return info, ok, nil
+}
+
+func (s *InMemoryMagicLinkStore) MarkUsed(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, ok := s.tokens[token]
+	if !ok {
+		return nil
+	}
+	info.Used = true
+	s.tokens[token] = info
+	return nil
+}
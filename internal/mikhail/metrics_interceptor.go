@@ -0,0 +1,32 @@
+package mikhail
+
+import (
+	"context"
+	"time"
+)
+
+// MetricsRecorder is the minimal metrics surface MetricsInterceptor
+// needs, satisfied by wrapping a real metrics client (Prometheus,
+// StatsD, Datadog, ...) the same way Tracer wraps a real OpenTelemetry
+// tracer: Mikhail has no metrics client dependency of its own.
+type MetricsRecorder interface {
+	// RecordRPC is called once per RPC with the method, how long it
+	// took, and the gRPC-shaped Code the RPC finished with (CodeOK on
+	// success).
+	RecordRPC(method string, duration time.Duration, code Code)
+}
+
+// MetricsInterceptor returns a UnaryServerInterceptor that reports every
+// RPC to recorder. A nil recorder makes this a no-op passthrough, so it
+// can be left in a deployment's interceptor chain even before a real
+// MetricsRecorder is wired in.
+func MetricsInterceptor(recorder MetricsRecorder) UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		if recorder != nil {
+			recorder.RecordRPC(info.FullMethod, time.Since(start), StatusFromError(err).Code)
+		}
+		return resp, err
+	}
+}
@@ -0,0 +1,152 @@
+package mikhail
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"os"
+	"sync/atomic"
+)
+
+// ErrMutualTLSRequired is returned by ClientCertInterceptor when a call
+// reaches it without a ClientCertIdentity already attached to its
+// context, i.e. the call was not made over a mTLS connection.
+var ErrMutualTLSRequired = errors.New("mikhail: this method requires a client certificate")
+
+// ClientCertIdentity is the identity Kingdom-System extracts from a
+// client's TLS certificate under mTLS, for handlers to make
+// service-level authorization decisions from (e.g. "only the billing
+// service's certificate may call RevokeAllForUser") without re-parsing
+// the certificate themselves.
+type ClientCertIdentity struct {
+	CommonName   string
+	Organization []string
+	SerialNumber string
+}
+
+type clientCertIdentityKey struct{}
+
+// ContextWithClientCertIdentity returns a context carrying identity. The
+// transport layer accepting mTLS connections calls this (via
+// ClientCertIdentityFromConn) when building the context for each call,
+// before any UnaryServerInterceptor runs.
+func ContextWithClientCertIdentity(ctx context.Context, identity ClientCertIdentity) context.Context {
+	return context.WithValue(ctx, clientCertIdentityKey{}, identity)
+}
+
+// ClientCertIdentityFromContext returns the ClientCertIdentity attached
+// to ctx, or ok=false if the call was not made over mTLS.
+func ClientCertIdentityFromContext(ctx context.Context) (ClientCertIdentity, bool) {
+	identity, ok := ctx.Value(clientCertIdentityKey{}).(ClientCertIdentity)
+	return identity, ok
+}
+
+// NewMutualTLSConfig returns a *tls.Config requiring and verifying a
+// client certificate signed by a CA in clientCAFile, for a service that
+// must run in mTLS mode. certFile/keyFile are the server's own
+// certificate and key, presented to the client side of the handshake.
+func NewMutualTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	caPEM, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("mikhail: no certificates found in client CA file " + clientCAFile)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}, nil
+}
+
+// ClientCertIdentityFromConn extracts a ClientCertIdentity from conn's
+// completed TLS handshake state, or ok=false if conn is not a *tls.Conn
+// or presented no certificate. A transport accepting connections under a
+// mTLS tls.Config (see NewMutualTLSConfig) calls this once per connection
+// and attaches the result to every call's context with
+// ContextWithClientCertIdentity.
+func ClientCertIdentityFromConn(conn net.Conn) (ClientCertIdentity, bool) {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return ClientCertIdentity{}, false
+	}
+	state := tlsConn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return ClientCertIdentity{}, false
+	}
+	cert := state.PeerCertificates[0]
+	return ClientCertIdentity{
+		CommonName:   cert.Subject.CommonName,
+		Organization: cert.Subject.Organization,
+		SerialNumber: cert.SerialNumber.String(),
+	}, true
+}
+
+// TLSCertificateReloader holds a *tls.Config whose server certificate
+// and client CA pool can be swapped out live (e.g. on SIGHUP; see
+// SetupSIGHUPReload) without dropping connections already established
+// under the previous certificate - only the next handshake observes the
+// change, via tls.Config.GetConfigForClient.
+type TLSCertificateReloader struct {
+	certFile, keyFile, clientCAFile string
+	current                         atomic.Value // *tls.Config
+}
+
+// NewTLSCertificateReloader loads a server certificate/key and client CA
+// pool from the given files, the same material NewMutualTLSConfig loads,
+// and returns a TLSCertificateReloader serving it until Reload is
+// called.
+func NewTLSCertificateReloader(certFile, keyFile, clientCAFile string) (*TLSCertificateReloader, error) {
+	r := &TLSCertificateReloader{certFile: certFile, keyFile: keyFile, clientCAFile: clientCAFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload re-reads the certificate, key, and client CA pool from disk and
+// atomically swaps them in, for every TLS handshake from this point on.
+// It leaves the previously loaded material in place if the reload fails,
+// so a typo'd or expired file on disk cannot take a healthy listener
+// down.
+func (r *TLSCertificateReloader) Reload() error {
+	cfg, err := NewMutualTLSConfig(r.certFile, r.keyFile, r.clientCAFile)
+	if err != nil {
+		return err
+	}
+	r.current.Store(cfg)
+	return nil
+}
+
+// Config returns a *tls.Config that always uses the most recently loaded
+// certificate and client CA pool, suitable for passing to a real TLS
+// listener once: reloads after that point take effect on every
+// subsequent handshake without reconfiguring the listener itself.
+func (r *TLSCertificateReloader) Config() *tls.Config {
+	return &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current.Load().(*tls.Config), nil
+		},
+	}
+}
+
+// ClientCertInterceptor is a UnaryServerInterceptor that requires ctx to
+// already carry a ClientCertIdentity and rejects the call with
+// ErrMutualTLSRequired otherwise. Install it ahead of any interceptor or
+// handler that makes a service-level authorization decision based on
+// ClientCertIdentityFromContext, so that decision is never silently
+// skipped on a connection that was not actually mTLS.
+func ClientCertInterceptor(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error) {
+	if _, ok := ClientCertIdentityFromContext(ctx); !ok {
+		return nil, ErrMutualTLSRequired
+	}
+	return handler(ctx, req)
+}
@@ -0,0 +1,372 @@
+package mikhail
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// OAuthProviderError annotates an error from calling a third-party OAuth
+// provider's HTTP API with enough context for OAuth2Callback to classify
+// it into an OAuthErrorReason. Op identifies which OAuthProvider call
+// produced it ("exchange", "refresh", or "fetch_profile"); HTTPStatus is
+// the status code the provider's HTTP response carried, or 0 if the
+// request never got a response at all (e.g. the provider was
+// unreachable).
+type OAuthProviderError struct {
+	Op         string
+	HTTPStatus int
+	Err        error
+}
+
+func (e *OAuthProviderError) Error() string { return e.Err.Error() }
+func (e *OAuthProviderError) Unwrap() error { return e.Err }
+
+// StatusCode mirrors the subset of gRPC's codes.Code that Mikhail's RPCs
+// actually use, so callers can branch on failure category without a
+// dependency on google.golang.org/grpc (Mikhail has none yet; see
+// internal/authpb for why). A real gRPC transport can map these 1:1 onto
+// codes.Code when one is wired in.
+type StatusCode int
+
+// StatusCodes Mikhail's RPCs currently produce.
+const (
+	StatusUnknown StatusCode = iota
+	StatusInvalidArgument
+	StatusUnavailable
+	StatusFailedPrecondition
+)
+
+// OAuthErrorReason enumerates why an OAuth2Callback request failed, the
+// way a gRPC ErrorInfo detail's Reason field would, so clients can branch
+// on the specific failure instead of string-matching Error().
+type OAuthErrorReason string
+
+// OAuthErrorReasons OAuth2Callback currently produces.
+const (
+	// ReasonExpiredCode means the provider rejected the authorization
+	// code, typically because it already expired or was already redeemed.
+	ReasonExpiredCode OAuthErrorReason = "EXPIRED_CODE"
+	// ReasonProviderUnavailable means the provider's token or userinfo
+	// endpoint could not be reached, or returned a server error.
+	ReasonProviderUnavailable OAuthErrorReason = "PROVIDER_UNAVAILABLE"
+	// ReasonProfileFetchFailed means the code exchange succeeded but
+	// resolving the resulting token to an identity failed.
+	ReasonProfileFetchFailed OAuthErrorReason = "PROFILE_FETCH_FAILED"
+	// ReasonStateMismatch means the PKCE code_verifier presented did not
+	// match the code_challenge stored for the callback's state.
+	ReasonStateMismatch OAuthErrorReason = "STATE_MISMATCH"
+)
+
+// OAuthStatusError is an error annotated with a StatusCode and
+// OAuthErrorReason, the same shape a gRPC interceptor would build from a
+// google.golang.org/grpc/status.Status and an ErrorInfo detail once
+// Mikhail is wired to a real gRPC transport.
+type OAuthStatusError struct {
+	Code   StatusCode
+	Reason OAuthErrorReason
+	Err    error
+}
+
+func (e *OAuthStatusError) Error() string {
+	if e.Reason == "" {
+		return e.Err.Error()
+	}
+	return string(e.Reason) + ": " + e.Err.Error()
+}
+
+func (e *OAuthStatusError) Unwrap() error { return e.Err }
+
+// classifyOAuthError maps err, typically returned by an OAuthProvider
+// call, to an OAuthStatusError. Errors that are not an *OAuthProviderError
+// (e.g. ErrOAuthProviderNotRegistered) get StatusFailedPrecondition and no
+// reason, since they describe a configuration problem rather than a
+// specific OAuth failure a client would want to branch on.
+func classifyOAuthError(err error) error {
+	var provErr *OAuthProviderError
+	if !errors.As(err, &provErr) {
+		return &OAuthStatusError{Code: StatusFailedPrecondition, Err: err}
+	}
+	if provErr.Op == "fetch_profile" {
+		return &OAuthStatusError{Code: StatusUnavailable, Reason: ReasonProfileFetchFailed, Err: err}
+	}
+	if provErr.HTTPStatus == 0 || provErr.HTTPStatus >= 500 {
+		return &OAuthStatusError{Code: StatusUnavailable, Reason: ReasonProviderUnavailable, Err: err}
+	}
+	return &OAuthStatusError{Code: StatusInvalidArgument, Reason: ReasonExpiredCode, Err: err}
+}
+
+// revokeProviderGrant best-effort revokes accessToken at the OAuthProvider
+// registered under providerName, logging the outcome for audit purposes
+// either way. It is a no-op when no provider is registered under that
+// name, e.g. because it was unregistered since the token was issued.
+func revokeProviderGrant(ctx context.Context, providers *OAuthProviderRegistry, providerName, accessToken, userID string) {
+	provider, ok := providers.Get(providerName)
+	if !ok {
+		return
+	}
+	if err := provider.Revoke(ctx, accessToken); err != nil {
+		log.Printf("mikhail: revoking %s oauth grant for user %s failed: %v", providerName, userID, err)
+		return
+	}
+	log.Printf("mikhail: revoked %s oauth grant for user %s", providerName, userID)
+}
+
+// addPKCEChallenge adds the S256 PKCE parameters to an authorization
+// request's query values if codeChallenge is set, for OAuthProvider
+// implementations sharing the standard PKCE parameter names.
+func addPKCEChallenge(values url.Values, codeChallenge string) {
+	if codeChallenge == "" {
+		return
+	}
+	values.Set("code_challenge", codeChallenge)
+	values.Set("code_challenge_method", "S256")
+}
+
+// addPKCEVerifier adds the code_verifier form field to a token request
+// if codeVerifier is set, for OAuthProvider implementations sharing the
+// standard PKCE parameter name.
+func addPKCEVerifier(form url.Values, codeVerifier string) {
+	if codeVerifier == "" {
+		return
+	}
+	form.Set("code_verifier", codeVerifier)
+}
+
+// UserProfile is the identity Mikhail cares about from any third-party
+// OAuth provider, normalized so AuthServer never has to know which
+// provider's response shape produced it.
+type UserProfile struct {
+	ProviderUserID string
+	Email          string
+	DisplayName    string
+}
+
+// OAuthToken is the token pair an OAuthProvider returns from Exchange or
+// Refresh. RefreshToken is empty for providers that do not issue one.
+// Extra carries non-standard fields a provider's token endpoint returns
+// alongside the token itself (e.g. VK's user_id and email), for
+// FetchProfile implementations that need them.
+type OAuthToken struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+	Extra        map[string]string
+}
+
+// OAuthProvider is implemented by every third-party OAuth2 login
+// integration Mikhail supports. AuthServer talks to providers only
+// through this interface, looked up by name in an
+// OAuthProviderRegistry, so adding a new provider never requires
+// touching AuthServer.
+type OAuthProvider interface {
+	// Name is the provider's registry key, e.g. "yandex" or "google".
+	Name() string
+	// AuthURL returns the URL to redirect the user to begin login, with
+	// state echoed back unmodified in the callback. codeChallenge is a
+	// PKCE S256 code challenge for public clients (e.g. mobile apps)
+	// that cannot hold a client secret, and is empty for confidential
+	// clients using the ordinary authorization code flow.
+	AuthURL(state, codeChallenge string) string
+	// Exchange trades an authorization code from the callback for an
+	// access/refresh token pair. codeVerifier is the PKCE code verifier
+	// matching the code challenge passed to AuthURL, and is empty when
+	// the flow did not use PKCE.
+	Exchange(ctx context.Context, code, codeVerifier string) (*OAuthToken, error)
+	// Refresh trades a refresh token for a new access token.
+	Refresh(ctx context.Context, refreshToken string) (*OAuthToken, error)
+	// FetchProfile resolves a token returned by Exchange or Refresh to
+	// the identity it was issued for. It takes the full token, not just
+	// the bare access token string, because some providers (e.g. VK)
+	// return identity fields in the token response itself rather than
+	// from a separate profile endpoint.
+	FetchProfile(ctx context.Context, token *OAuthToken) (*UserProfile, error)
+	// Revoke invalidates an access token at the provider, e.g. on
+	// sign-out or account deletion.
+	Revoke(ctx context.Context, accessToken string) error
+}
+
+// OAuthProviderRegistry looks up an OAuthProvider by name. Implementations
+// register themselves with Register at construction time; AuthServer
+// resolves the provider named in a request with Get.
+type OAuthProviderRegistry struct {
+	mu        sync.RWMutex
+	providers map[string]OAuthProvider
+}
+
+// NewOAuthProviderRegistry returns an empty OAuthProviderRegistry.
+func NewOAuthProviderRegistry() *OAuthProviderRegistry {
+	return &OAuthProviderRegistry{providers: make(map[string]OAuthProvider)}
+}
+
+// Register adds provider to the registry, keyed by its Name.
+func (r *OAuthProviderRegistry) Register(provider OAuthProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[provider.Name()] = provider
+}
+
+// Get looks up a provider by name.
+func (r *OAuthProviderRegistry) Get(name string) (OAuthProvider, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	provider, ok := r.providers[name]
+	return provider, ok
+}
+
+// Names returns the names of every registered provider, for
+// introspection (e.g. GetServerInfo reporting which providers are
+// enabled) rather than any sign-in flow, which always looks a specific
+// provider up by name via Get instead.
+func (r *OAuthProviderRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ErrOAuthProviderNotRegistered is returned when a request names a
+// provider that has no OAuthProvider registered for it.
+var ErrOAuthProviderNotRegistered = errors.New("mikhail: oauth provider not registered")
+
+// ErrLastSignInMethod is returned by UnlinkProvider when removing the
+// named provider would leave the account with no linked provider and
+// no phone/password credential to sign in with.
+var ErrLastSignInMethod = errors.New("mikhail: cannot unlink the last sign-in method")
+
+// ErrNoLinkedProviderSession is returned by SyncProfile (and GetMe's
+// TTL-based auto-refresh) when the account has no active session that
+// started through an OAuthProvider to sync a profile from.
+var ErrNoLinkedProviderSession = errors.New("mikhail: no linked provider session to sync profile from")
+
+// oauthIdentityKey identifies a third-party identity by provider and
+// the ID that provider issued it, since different providers may assign
+// overlapping ID spaces.
+type oauthIdentityKey struct {
+	provider       string
+	providerUserID string
+}
+
+// OAuthIdentityStore maps (provider, provider user ID) pairs to the
+// Mikhail user ID they are linked to, across every registered
+// OAuthProvider. Implementations must be safe for concurrent use.
+type OAuthIdentityStore interface {
+	Link(provider, providerUserID, userID string) error
+	Lookup(provider, providerUserID string) (userID string, ok bool, err error)
+	// Unlink removes whatever identity userID has linked for provider,
+	// if any. It is not an error to unlink a provider that was never
+	// linked.
+	Unlink(provider, userID string) error
+	// LinkedProviders lists the provider names userID has a linked
+	// identity for, used to populate ListLinkedProviders and to check
+	// whether unlinking one would leave the account with none.
+	LinkedProviders(userID string) ([]string, error)
+}
+
+// InMemoryOAuthIdentityStore is an OAuthIdentityStore backed by guarded
+// maps, suitable for local development and single-node deployments.
+type InMemoryOAuthIdentityStore struct {
+	mu     sync.Mutex
+	links  map[oauthIdentityKey]string
+	byUser map[string]map[string]string // userID -> provider -> providerUserID
+}
+
+// NewInMemoryOAuthIdentityStore returns an empty InMemoryOAuthIdentityStore.
+func NewInMemoryOAuthIdentityStore() *InMemoryOAuthIdentityStore {
+	return &InMemoryOAuthIdentityStore{
+		links:  make(map[oauthIdentityKey]string),
+		byUser: make(map[string]map[string]string),
+	}
+}
+
+func (s *InMemoryOAuthIdentityStore) Link(provider, providerUserID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.links[oauthIdentityKey{provider, providerUserID}] = userID
+	if s.byUser[userID] == nil {
+		s.byUser[userID] = make(map[string]string)
+	}
+	s.byUser[userID][provider] = providerUserID
+	return nil
+}
+
+func (s *InMemoryOAuthIdentityStore) Lookup(provider, providerUserID string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	userID, ok := s.links[oauthIdentityKey{provider, providerUserID}]
+	return userID, ok, nil
+}
+
+func (s *InMemoryOAuthIdentityStore) Unlink(provider, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	providerUserID, ok := s.byUser[userID][provider]
+	if !ok {
+		return nil
+	}
+	delete(s.links, oauthIdentityKey{provider, providerUserID})
+	delete(s.byUser[userID], provider)
+	return nil
+}
+
+func (s *InMemoryOAuthIdentityStore) LinkedProviders(userID string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	providers := make([]string, 0, len(s.byUser[userID]))
+	for provider := range s.byUser[userID] {
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+// ErrPKCEVerificationFailed is returned by OAuth2Callback when the
+// code_verifier presented does not match the code_challenge stored for
+// the request's state.
+var ErrPKCEVerificationFailed = errors.New("mikhail: pkce code_verifier does not match code_challenge")
+
+// PKCEStore holds the PKCE code challenge a public client supplied to
+// BeginOAuth2Login, keyed by state, so OAuth2Callback can verify the
+// code_verifier presented alongside the authorization code. Entries are
+// consumed on lookup: a state is only ever good for one callback.
+// Implementations must be safe for concurrent use.
+type PKCEStore interface {
+	Store(state, codeChallenge string) error
+	Consume(state string) (codeChallenge string, ok bool, err error)
+}
+
+// InMemoryPKCEStore is a PKCEStore backed by a guarded map, suitable for
+// local development and single-node deployments.
+type InMemoryPKCEStore struct {
+	mu         sync.Mutex
+	challenges map[string]string
+}
+
+// NewInMemoryPKCEStore returns an empty InMemoryPKCEStore.
+func NewInMemoryPKCEStore() *InMemoryPKCEStore {
+	return &InMemoryPKCEStore{challenges: make(map[string]string)}
+}
+
+func (s *InMemoryPKCEStore) Store(state, codeChallenge string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.challenges[state] = codeChallenge
+	return nil
+}
+
+func (s *InMemoryPKCEStore) Consume(state string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	codeChallenge, ok := s.challenges[state]
+	if ok {
+		delete(s.challenges, state)
+	}
+	return codeChallenge, ok, nil
+}
@@ -0,0 +1,214 @@
+package mikhail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// GitHubOAuthClient is Mikhail's OAuthProvider for GitHub, mainly for
+// internal and developer-facing Kingdom-System tools. It exchanges
+// OAuth2 authorization codes via GitHub's token endpoint and resolves
+// access tokens to identities via the GitHub user and emails APIs.
+type GitHubOAuthClient struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	HTTPClient   *http.Client
+}
+
+// NewGitHubOAuthClient returns a GitHubOAuthClient for the given OAuth2
+// client credentials, using http.DefaultClient.
+func NewGitHubOAuthClient(clientID, clientSecret, redirectURI string) *GitHubOAuthClient {
+	return &GitHubOAuthClient{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURI:  redirectURI,
+		HTTPClient:   http.DefaultClient,
+	}
+}
+
+// NewGitHubOAuthClientFromEnv builds a GitHubOAuthClient from
+// MIKHAIL_GITHUB_CLIENT_ID, MIKHAIL_GITHUB_CLIENT_SECRET and
+// MIKHAIL_GITHUB_REDIRECT_URI.
+func NewGitHubOAuthClientFromEnv() *GitHubOAuthClient {
+	return NewGitHubOAuthClient(
+		os.Getenv("MIKHAIL_GITHUB_CLIENT_ID"),
+		os.Getenv("MIKHAIL_GITHUB_CLIENT_SECRET"),
+		os.Getenv("MIKHAIL_GITHUB_REDIRECT_URI"),
+	)
+}
+
+// Name identifies this provider in an OAuthProviderRegistry.
+func (c *GitHubOAuthClient) Name() string { return "github" }
+
+// AuthURL returns the URL to redirect the user to in order to begin a
+// GitHub login, with state echoed back unmodified in the callback.
+func (c *GitHubOAuthClient) AuthURL(state, codeChallenge string) string {
+	values := url.Values{
+		"client_id":    {c.ClientID},
+		"redirect_uri": {c.RedirectURI},
+		"scope":        {"read:user user:email"},
+		"state":        {state},
+	}
+	addPKCEChallenge(values, codeChallenge)
+	return "https://github.com/login/oauth/authorize?" + values.Encode()
+}
+
+// Exchange trades an authorization code for a GitHub access token.
+// GitHub's OAuth apps do not issue refresh tokens, so the returned
+// OAuthToken never has RefreshToken set.
+func (c *GitHubOAuthClient) Exchange(ctx context.Context, code, codeVerifier string) (*OAuthToken, error) {
+	form := url.Values{
+		"client_id":     {c.ClientID},
+		"client_secret": {c.ClientSecret},
+		"code":          {code},
+		"redirect_uri":  {c.RedirectURI},
+	}
+	addPKCEVerifier(form, codeVerifier)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://github.com/login/oauth/access_token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, &OAuthProviderError{Op: "exchange", Err: err}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &OAuthProviderError{Op: "exchange", HTTPStatus: resp.StatusCode, Err: fmt.Errorf("mikhail: github token exchange failed: %s: %s", resp.Status, body)}
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("mikhail: github token exchange returned no access_token: %s", token.Error)
+	}
+	return &OAuthToken{AccessToken: token.AccessToken}, nil
+}
+
+// Refresh is not supported by GitHub's OAuth apps: access tokens do not
+// expire and there is no refresh token to trade.
+func (c *GitHubOAuthClient) Refresh(ctx context.Context, refreshToken string) (*OAuthToken, error) {
+	return nil, fmt.Errorf("mikhail: github oauth does not support token refresh")
+}
+
+// FetchProfile resolves a GitHub access token to the identity it was
+// issued for, falling back to the user/emails API for the primary
+// email address when the user profile does not expose one publicly.
+func (c *GitHubOAuthClient) FetchProfile(ctx context.Context, token *OAuthToken) (*UserProfile, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	}
+	if err := c.getJSON(ctx, "https://api.github.com/user", token.AccessToken, &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, fmt.Errorf("mikhail: github user response missing id")
+	}
+
+	email := user.Email
+	if email == "" {
+		primary, err := c.fetchPrimaryEmail(ctx, token.AccessToken)
+		if err != nil {
+			return nil, err
+		}
+		email = primary
+	}
+
+	displayName := user.Name
+	if displayName == "" {
+		displayName = user.Login
+	}
+	return &UserProfile{ProviderUserID: strconv.FormatInt(user.ID, 10), Email: email, DisplayName: displayName}, nil
+}
+
+func (c *GitHubOAuthClient) fetchPrimaryEmail(ctx context.Context, accessToken string) (string, error) {
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := c.getJSON(ctx, "https://api.github.com/user/emails", accessToken, &emails); err != nil {
+		return "", err
+	}
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			return e.Email, nil
+		}
+	}
+	return "", nil
+}
+
+func (c *GitHubOAuthClient) getJSON(ctx context.Context, endpoint, accessToken string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return &OAuthProviderError{Op: "fetch_profile", Err: err}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &OAuthProviderError{Op: "fetch_profile", HTTPStatus: resp.StatusCode, Err: fmt.Errorf("mikhail: github request to %s failed: %s: %s", endpoint, resp.Status, body)}
+	}
+	return json.Unmarshal(body, out)
+}
+
+// Revoke invalidates a GitHub access token.
+func (c *GitHubOAuthClient) Revoke(ctx context.Context, accessToken string) error {
+	endpoint := fmt.Sprintf("https://api.github.com/applications/%s/grant", c.ClientID)
+	body, err := json.Marshal(struct {
+		AccessToken string `json:"access_token"`
+	}{AccessToken: accessToken})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, endpoint, strings.NewReader(string(body)))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.ClientID, c.ClientSecret)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("mikhail: github token revoke failed: %s: %s", resp.Status, respBody)
+	}
+	return nil
+}
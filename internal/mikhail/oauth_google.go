@@ -0,0 +1,187 @@
+package mikhail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// GoogleOAuthClient is Mikhail's OAuthProvider for Google. It exchanges
+// OAuth2 authorization codes and refresh tokens via Google's token
+// endpoint and resolves access tokens to identities via Google's
+// userinfo endpoint.
+type GoogleOAuthClient struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	HTTPClient   *http.Client
+}
+
+// NewGoogleOAuthClient returns a GoogleOAuthClient for the given OAuth2
+// client credentials, using http.DefaultClient. redirectURI must match
+// the one registered in the Google Cloud console for clientID.
+func NewGoogleOAuthClient(clientID, clientSecret, redirectURI string) *GoogleOAuthClient {
+	return &GoogleOAuthClient{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURI:  redirectURI,
+		HTTPClient:   http.DefaultClient,
+	}
+}
+
+// NewGoogleOAuthClientFromEnv builds a GoogleOAuthClient from
+// MIKHAIL_GOOGLE_CLIENT_ID, MIKHAIL_GOOGLE_CLIENT_SECRET and
+// MIKHAIL_GOOGLE_REDIRECT_URI.
+func NewGoogleOAuthClientFromEnv() *GoogleOAuthClient {
+	return NewGoogleOAuthClient(
+		os.Getenv("MIKHAIL_GOOGLE_CLIENT_ID"),
+		os.Getenv("MIKHAIL_GOOGLE_CLIENT_SECRET"),
+		os.Getenv("MIKHAIL_GOOGLE_REDIRECT_URI"),
+	)
+}
+
+// Name identifies this provider in an OAuthProviderRegistry.
+func (c *GoogleOAuthClient) Name() string { return "google" }
+
+// AuthURL returns the URL to redirect the user to in order to begin a
+// Google login, with state echoed back unmodified in the callback.
+func (c *GoogleOAuthClient) AuthURL(state, codeChallenge string) string {
+	values := url.Values{
+		"response_type": {"code"},
+		"client_id":     {c.ClientID},
+		"redirect_uri":  {c.RedirectURI},
+		"scope":         {"openid email profile"},
+		"access_type":   {"offline"},
+		"state":         {state},
+	}
+	addPKCEChallenge(values, codeChallenge)
+	return "https://accounts.google.com/o/oauth2/v2/auth?" + values.Encode()
+}
+
+// Exchange trades an authorization code for a Google access/refresh
+// token pair.
+func (c *GoogleOAuthClient) Exchange(ctx context.Context, code, codeVerifier string) (*OAuthToken, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"client_id":     {c.ClientID},
+		"client_secret": {c.ClientSecret},
+		"redirect_uri":  {c.RedirectURI},
+	}
+	addPKCEVerifier(form, codeVerifier)
+	return c.requestToken(ctx, form, "exchange")
+}
+
+// Refresh trades a Google refresh token for a new access token.
+func (c *GoogleOAuthClient) Refresh(ctx context.Context, refreshToken string) (*OAuthToken, error) {
+	return c.requestToken(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {c.ClientID},
+		"client_secret": {c.ClientSecret},
+	}, "refresh")
+}
+
+func (c *GoogleOAuthClient) requestToken(ctx context.Context, form url.Values, op string) (*OAuthToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://oauth2.googleapis.com/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, &OAuthProviderError{Op: op, Err: err}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &OAuthProviderError{Op: op, HTTPStatus: resp.StatusCode, Err: fmt.Errorf("mikhail: google token request failed: %s: %s", resp.Status, body)}
+	}
+
+	var token struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("mikhail: google token request returned no access_token")
+	}
+	result := &OAuthToken{AccessToken: token.AccessToken, RefreshToken: token.RefreshToken}
+	if token.ExpiresIn > 0 {
+		result.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return result, nil
+}
+
+// FetchProfile resolves a Google access token to the identity it was
+// issued for.
+func (c *GoogleOAuthClient) FetchProfile(ctx context.Context, token *OAuthToken) (*UserProfile, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://www.googleapis.com/oauth2/v3/userinfo", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, &OAuthProviderError{Op: "fetch_profile", Err: err}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &OAuthProviderError{Op: "fetch_profile", HTTPStatus: resp.StatusCode, Err: fmt.Errorf("mikhail: google userinfo request failed: %s: %s", resp.Status, body)}
+	}
+
+	var info struct {
+		Sub   string `json:"sub"`
+		Email string `json:"email"`
+		Name  string `json:"name"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, err
+	}
+	if info.Sub == "" {
+		return nil, fmt.Errorf("mikhail: google userinfo response missing sub")
+	}
+	return &UserProfile{ProviderUserID: info.Sub, Email: info.Email, DisplayName: info.Name}, nil
+}
+
+// Revoke invalidates a Google access token.
+func (c *GoogleOAuthClient) Revoke(ctx context.Context, accessToken string) error {
+	form := url.Values{"token": {accessToken}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://oauth2.googleapis.com/revoke", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("mikhail: google token revoke failed: %s: %s", resp.Status, body)
+	}
+	return nil
+}
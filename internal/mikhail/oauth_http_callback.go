@@ -0,0 +1,114 @@
+package mikhail
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/TwelveFacedJanus/Kingdom-System/internal/authpb"
+)
+
+// OAuthExchangeResult is the token pair an OAuthExchangeStore entry
+// redeems for, mirroring the fields of AuthResult that a browser-facing
+// caller needs to complete sign-in.
+type OAuthExchangeResult struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    int64
+	Scopes       []string
+}
+
+// OAuthExchangeStore holds the token pair OAuth2CallbackHTTPHandler issued
+// for a completed browser redirect, keyed by a one-time exchange code, so
+// the frontend can redeem it over a channel it controls instead of having
+// tokens appear in a URL the browser, proxies, and access logs all see.
+// Entries are consumed on lookup: a code is only ever good for one
+// exchange. Implementations must be safe for concurrent use.
+type OAuthExchangeStore interface {
+	Store(code string, result OAuthExchangeResult) error
+	Consume(code string) (result OAuthExchangeResult, ok bool, err error)
+}
+
+// InMemoryOAuthExchangeStore is an OAuthExchangeStore backed by a guarded
+// map, suitable for local development and single-node deployments.
+type InMemoryOAuthExchangeStore struct {
+	mu      sync.Mutex
+	results map[string]OAuthExchangeResult
+}
+
+// NewInMemoryOAuthExchangeStore returns an empty InMemoryOAuthExchangeStore.
+func NewInMemoryOAuthExchangeStore() *InMemoryOAuthExchangeStore {
+	return &InMemoryOAuthExchangeStore{results: make(map[string]OAuthExchangeResult)}
+}
+
+func (s *InMemoryOAuthExchangeStore) Store(code string, result OAuthExchangeResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[code] = result
+	return nil
+}
+
+func (s *InMemoryOAuthExchangeStore) Consume(code string) (OAuthExchangeResult, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result, ok := s.results[code]
+	if ok {
+		delete(s.results, code)
+	}
+	return result, ok, nil
+}
+
+// OAuth2CallbackHTTPHandler returns an http.HandlerFunc that bridges the
+// browser-facing side of a third-party OAuth2 redirect into AuthServer's
+// gRPC-shaped OAuth2Callback. Providers like Yandex redirect the user's
+// browser to the configured redirect URI with a plain HTTP GET, which
+// Mikhail otherwise has no way to speak; mounting this handler at that
+// redirect URI lets a deployment skip writing its own proxy for it.
+//
+// On success the browser is redirected to frontendRedirectURL with a
+// one-time code appended as a query parameter, redeemable once against
+// exchanges for the token pair OAuth2Callback issued, rather than putting
+// tokens directly in the redirect URL. On failure it is redirected there
+// instead with an error parameter. provider is the OAuthProvider name to
+// complete the callback against; mount one handler per provider redirect
+// URI if a deployment registers more than one.
+//
+// Disabled unless a deployment explicitly mounts it, consistent with the
+// rest of Mikhail's HTTP surface (see IntrospectionHandler).
+func OAuth2CallbackHTTPHandler(server *AuthServer, exchanges OAuthExchangeStore, provider, frontendRedirectURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		resp, err := server.OAuth2Callback(r.Context(), &authpb.OAuth2CallbackRequest{
+			Code:         query.Get("code"),
+			State:        query.Get("state"),
+			Provider:     provider,
+			CodeVerifier: query.Get("code_verifier"),
+		})
+		if err != nil {
+			redirectWithOAuthError(w, r, frontendRedirectURL, err)
+			return
+		}
+
+		code, err := generateAuthToken()
+		if err != nil {
+			redirectWithOAuthError(w, r, frontendRedirectURL, err)
+			return
+		}
+		if err := exchanges.Store(code, OAuthExchangeResult{
+			AccessToken:  resp.Result.AccessToken,
+			RefreshToken: resp.Result.RefreshToken,
+			ExpiresAt:    resp.Result.ExpiresAt,
+			Scopes:       resp.Result.Scopes,
+		}); err != nil {
+			redirectWithOAuthError(w, r, frontendRedirectURL, err)
+			return
+		}
+
+		http.Redirect(w, r, frontendRedirectURL+"?code="+url.QueryEscape(code), http.StatusFound)
+	}
+}
+
+func redirectWithOAuthError(w http.ResponseWriter, r *http.Request, frontendRedirectURL string, err error) {
+	http.Redirect(w, r, frontendRedirectURL+"?error="+url.QueryEscape(err.Error()), http.StatusFound)
+}
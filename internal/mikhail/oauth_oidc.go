@@ -0,0 +1,359 @@
+package mikhail
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// oidcDiscoveryDocument is the subset of a provider's
+// /.well-known/openid-configuration response Mikhail needs.
+type oidcDiscoveryDocument struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	RevocationEndpoint    string `json:"revocation_endpoint"`
+}
+
+// oidcJWKSet is a provider's published JSON Web Key Set, as served from
+// its jwks_uri.
+type oidcJWKSet struct {
+	Keys []oidcJWK `json:"keys"`
+}
+
+// oidcJWK is one RSA signing key from a JWK Set. Mikhail only supports
+// RS256-signed ID tokens, so only the RSA fields are modeled.
+type oidcJWK struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// OIDCProvider is Mikhail's OAuthProvider for any identity provider
+// that publishes a standard OpenID Connect discovery document, letting
+// operators add a new login provider by issuer URL alone instead of a
+// dedicated Go type. It validates ID tokens against the provider's JWKS
+// rather than trusting the token transport.
+type OIDCProvider struct {
+	name         string
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURI  string
+	httpClient   *http.Client
+
+	discovery oidcDiscoveryDocument
+
+	mu   sync.Mutex
+	jwks oidcJWKSet
+}
+
+// NewOIDCProvider fetches issuer's discovery document and returns an
+// OIDCProvider registered under name, e.g. "okta" or "auth0".
+func NewOIDCProvider(ctx context.Context, name, issuer, clientID, clientSecret, redirectURI string) (*OIDCProvider, error) {
+	p := &OIDCProvider{
+		name:         name,
+		issuer:       strings.TrimSuffix(issuer, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURI:  redirectURI,
+		httpClient:   http.DefaultClient,
+	}
+	discovery, err := p.fetchDiscoveryDocument(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.discovery = discovery
+	return p, nil
+}
+
+func (p *OIDCProvider) fetchDiscoveryDocument(ctx context.Context) (oidcDiscoveryDocument, error) {
+	var discovery oidcDiscoveryDocument
+	if err := p.getJSON(ctx, p.issuer+"/.well-known/openid-configuration", "discovery", &discovery); err != nil {
+		return oidcDiscoveryDocument{}, err
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return oidcDiscoveryDocument{}, fmt.Errorf("mikhail: oidc discovery document for %q missing required endpoints", p.issuer)
+	}
+	return discovery, nil
+}
+
+// Name identifies this provider in an OAuthProviderRegistry.
+func (p *OIDCProvider) Name() string { return p.name }
+
+// AuthURL returns the URL to redirect the user to in order to begin
+// login at the provider's authorization_endpoint, with state echoed
+// back unmodified in the callback.
+func (p *OIDCProvider) AuthURL(state, codeChallenge string) string {
+	values := url.Values{
+		"response_type": {"code"},
+		"client_id":     {p.clientID},
+		"redirect_uri":  {p.redirectURI},
+		"scope":         {"openid email profile"},
+		"state":         {state},
+	}
+	addPKCEChallenge(values, codeChallenge)
+	return p.discovery.AuthorizationEndpoint + "?" + values.Encode()
+}
+
+// Exchange trades an authorization code for a token pair at the
+// provider's token_endpoint. The raw id_token is kept in the returned
+// token's Extra map for FetchProfile to validate and decode.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier string) (*OAuthToken, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"client_id":     {p.clientID},
+		"client_secret": {p.clientSecret},
+		"redirect_uri":  {p.redirectURI},
+	}
+	addPKCEVerifier(form, codeVerifier)
+	return p.requestToken(ctx, form, "exchange")
+}
+
+// Refresh trades a refresh token for a new token pair at the provider's
+// token_endpoint.
+func (p *OIDCProvider) Refresh(ctx context.Context, refreshToken string) (*OAuthToken, error) {
+	return p.requestToken(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {p.clientID},
+		"client_secret": {p.clientSecret},
+	}, "refresh")
+}
+
+func (p *OIDCProvider) requestToken(ctx context.Context, form url.Values, op string) (*OAuthToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, &OAuthProviderError{Op: op, Err: err}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &OAuthProviderError{Op: op, HTTPStatus: resp.StatusCode, Err: fmt.Errorf("mikhail: oidc token request to %q failed: %s: %s", p.name, resp.Status, body)}
+	}
+
+	var token struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		IDToken      string `json:"id_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, err
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("mikhail: oidc token response from %q missing id_token", p.name)
+	}
+	result := &OAuthToken{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		Extra:        map[string]string{"id_token": token.IDToken},
+	}
+	if token.ExpiresIn > 0 {
+		result.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return result, nil
+}
+
+// oidcIDTokenClaims are the standard OIDC claims Mikhail maps to a
+// UserProfile.
+type oidcIDTokenClaims struct {
+	Issuer  string `json:"iss"`
+	Subject string `json:"sub"`
+	Email   string `json:"email"`
+	Name    string `json:"name"`
+}
+
+// FetchProfile validates the ID token returned by Exchange or Refresh
+// against the provider's JWKS and maps its standard claims to a
+// UserProfile.
+func (p *OIDCProvider) FetchProfile(ctx context.Context, token *OAuthToken) (*UserProfile, error) {
+	idToken := token.Extra["id_token"]
+	if idToken == "" {
+		return nil, fmt.Errorf("mikhail: oidc token has no id_token to validate")
+	}
+	claims, err := p.verifyIDToken(ctx, idToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("mikhail: oidc id_token from %q missing sub", p.name)
+	}
+	return &UserProfile{ProviderUserID: claims.Subject, Email: claims.Email, DisplayName: claims.Name}, nil
+}
+
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, idToken string) (oidcIDTokenClaims, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return oidcIDTokenClaims{}, fmt.Errorf("mikhail: malformed oidc id_token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return oidcIDTokenClaims{}, err
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return oidcIDTokenClaims{}, err
+	}
+	if header.Alg != "RS256" {
+		return oidcIDTokenClaims{}, fmt.Errorf("mikhail: unsupported oidc id_token signing algorithm %q", header.Alg)
+	}
+
+	key, err := p.publicKey(ctx, header.Kid)
+	if err != nil {
+		return oidcIDTokenClaims{}, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return oidcIDTokenClaims{}, err
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], signature); err != nil {
+		return oidcIDTokenClaims{}, ErrTokenSignature
+	}
+
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return oidcIDTokenClaims{}, err
+	}
+	var claims oidcIDTokenClaims
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		return oidcIDTokenClaims{}, err
+	}
+	return claims, nil
+}
+
+// publicKey resolves kid to an RSA public key, fetching and caching the
+// provider's JWKS on first use and re-fetching once if kid is not found,
+// to tolerate the provider having rotated keys since the last fetch.
+func (p *OIDCProvider) publicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	jwks := p.jwks
+	p.mu.Unlock()
+
+	key, ok := findJWK(jwks, kid)
+	if !ok {
+		refreshed, err := p.fetchJWKS(ctx)
+		if err != nil {
+			return nil, err
+		}
+		p.mu.Lock()
+		p.jwks = refreshed
+		p.mu.Unlock()
+		key, ok = findJWK(refreshed, kid)
+		if !ok {
+			return nil, fmt.Errorf("mikhail: oidc provider %q has no signing key %q", p.name, kid)
+		}
+	}
+	return key.rsaPublicKey()
+}
+
+func findJWK(set oidcJWKSet, kid string) (oidcJWK, bool) {
+	for _, key := range set.Keys {
+		if key.Kid == kid {
+			return key, true
+		}
+	}
+	return oidcJWK{}, false
+}
+
+func (p *OIDCProvider) fetchJWKS(ctx context.Context) (oidcJWKSet, error) {
+	var set oidcJWKSet
+	if err := p.getJSON(ctx, p.discovery.JWKSURI, "fetch_profile", &set); err != nil {
+		return oidcJWKSet{}, err
+	}
+	return set, nil
+}
+
+func (k oidcJWK) rsaPublicKey() (*rsa.PublicKey, error) {
+	if k.Kty != "RSA" {
+		return nil, fmt.Errorf("mikhail: unsupported oidc jwk key type %q", k.Kty)
+	}
+	nBytes, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(nBytes),
+		E: int(new(big.Int).SetBytes(eBytes).Int64()),
+	}, nil
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, endpoint, op string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return &OAuthProviderError{Op: op, Err: err}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &OAuthProviderError{Op: op, HTTPStatus: resp.StatusCode, Err: fmt.Errorf("mikhail: oidc request to %q failed: %s: %s", endpoint, resp.Status, body)}
+	}
+	return json.Unmarshal(body, out)
+}
+
+// Revoke invalidates an access token at the provider's
+// revocation_endpoint, if its discovery document published one.
+func (p *OIDCProvider) Revoke(ctx context.Context, accessToken string) error {
+	if p.discovery.RevocationEndpoint == "" {
+		return fmt.Errorf("mikhail: oidc provider %q has no revocation_endpoint", p.name)
+	}
+	form := url.Values{
+		"token":         {accessToken},
+		"client_id":     {p.clientID},
+		"client_secret": {p.clientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.discovery.RevocationEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("mikhail: oidc token revoke at %q failed: %s: %s", p.name, resp.Status, body)
+	}
+	return nil
+}
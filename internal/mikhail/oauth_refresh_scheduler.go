@@ -0,0 +1,96 @@
+package mikhail
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// ProviderTokenRefreshScheduler periodically scans TokenStorage for
+// sessions whose provider access token is nearing expiry and refreshes
+// them proactively, so long-idle sessions do not end up with a dead
+// provider token the next time Mikhail needs to act on the user's
+// behalf at the provider (e.g. to Revoke on sign-out).
+type ProviderTokenRefreshScheduler struct {
+	storage   TokenStorage
+	providers *OAuthProviderRegistry
+	// Interval is how often the scheduler scans for sessions to refresh.
+	Interval time.Duration
+	// RefreshBefore is how far ahead of a provider token's expiry the
+	// scheduler refreshes it.
+	RefreshBefore time.Duration
+
+	stop chan struct{}
+}
+
+// NewProviderTokenRefreshScheduler returns a ProviderTokenRefreshScheduler
+// with the given scan interval and refresh lead time. Call Start to begin
+// scanning.
+func NewProviderTokenRefreshScheduler(storage TokenStorage, providers *OAuthProviderRegistry, interval, refreshBefore time.Duration) *ProviderTokenRefreshScheduler {
+	return &ProviderTokenRefreshScheduler{
+		storage:       storage,
+		providers:     providers,
+		Interval:      interval,
+		RefreshBefore: refreshBefore,
+		stop:          make(chan struct{}),
+	}
+}
+
+// Start runs the scan loop until ctx is done or Stop is called. It is
+// meant to be run in its own goroutine.
+func (sch *ProviderTokenRefreshScheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(sch.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sch.stop:
+			return
+		case <-ticker.C:
+			sch.scanOnce(ctx)
+		}
+	}
+}
+
+// Stop ends a running Start loop.
+func (sch *ProviderTokenRefreshScheduler) Stop() {
+	close(sch.stop)
+}
+
+func (sch *ProviderTokenRefreshScheduler) scanOnce(ctx context.Context) {
+	sessions, err := sch.storage.ListProviderSessions()
+	if err != nil {
+		log.Printf("mikhail: provider token refresh scan failed: %v", err)
+		return
+	}
+	deadline := time.Now().Add(sch.RefreshBefore)
+	for refreshToken, info := range sessions {
+		if info.ProviderRefreshToken == "" || info.ProviderTokenExpiresAt.IsZero() {
+			continue
+		}
+		if info.ProviderTokenExpiresAt.After(deadline) {
+			continue
+		}
+		if err := sch.refreshOne(ctx, refreshToken, info); err != nil {
+			log.Printf("mikhail: provider token refresh failed for user %q via %q: %v", info.UserID, info.Provider, err)
+		}
+	}
+}
+
+func (sch *ProviderTokenRefreshScheduler) refreshOne(ctx context.Context, refreshToken string, info TokenInfo) error {
+	provider, ok := sch.providers.Get(info.Provider)
+	if !ok {
+		return ErrOAuthProviderNotRegistered
+	}
+	token, err := provider.Refresh(ctx, info.ProviderRefreshToken)
+	if err != nil {
+		return err
+	}
+	info.ProviderAccessToken = token.AccessToken
+	if token.RefreshToken != "" {
+		info.ProviderRefreshToken = token.RefreshToken
+	}
+	info.ProviderTokenExpiresAt = token.ExpiresAt
+	return sch.storage.Store(refreshToken, info)
+}
@@ -0,0 +1,196 @@
+package mikhail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// vkAPIVersion is the VK API version Mikhail was written against. VK
+// requires every request to pin a version explicitly.
+const vkAPIVersion = "5.131"
+
+// VKOAuthClient is Mikhail's OAuthProvider for VK ID. Unlike Yandex and
+// Google, VK's token endpoint returns the signed-in user's ID and email
+// directly in the token response rather than from a separate profile
+// endpoint, so FetchProfile reads those out of token.Extra and only
+// calls the users.get API for the fields VK does not hand back eagerly.
+type VKOAuthClient struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	HTTPClient   *http.Client
+}
+
+// NewVKOAuthClient returns a VKOAuthClient for the given OAuth2 client
+// credentials, using http.DefaultClient.
+func NewVKOAuthClient(clientID, clientSecret, redirectURI string) *VKOAuthClient {
+	return &VKOAuthClient{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURI:  redirectURI,
+		HTTPClient:   http.DefaultClient,
+	}
+}
+
+// NewVKOAuthClientFromEnv builds a VKOAuthClient from
+// MIKHAIL_VK_CLIENT_ID, MIKHAIL_VK_CLIENT_SECRET and
+// MIKHAIL_VK_REDIRECT_URI.
+func NewVKOAuthClientFromEnv() *VKOAuthClient {
+	return NewVKOAuthClient(
+		os.Getenv("MIKHAIL_VK_CLIENT_ID"),
+		os.Getenv("MIKHAIL_VK_CLIENT_SECRET"),
+		os.Getenv("MIKHAIL_VK_REDIRECT_URI"),
+	)
+}
+
+// Name identifies this provider in an OAuthProviderRegistry.
+func (c *VKOAuthClient) Name() string { return "vk" }
+
+// AuthURL returns the URL to redirect the user to in order to begin a
+// VK ID login, with state echoed back unmodified in the callback.
+func (c *VKOAuthClient) AuthURL(state, codeChallenge string) string {
+	values := url.Values{
+		"client_id":     {c.ClientID},
+		"redirect_uri":  {c.RedirectURI},
+		"display":       {"page"},
+		"scope":         {"email"},
+		"response_type": {"code"},
+		"v":             {vkAPIVersion},
+		"state":         {state},
+	}
+	addPKCEChallenge(values, codeChallenge)
+	return "https://oauth.vk.com/authorize?" + values.Encode()
+}
+
+// Exchange trades an authorization code for a VK access token. VK's
+// token endpoint is unusual in two ways: it is a GET request, not a
+// POST, and its response embeds the user_id and (if the email scope was
+// granted and the user has one on file) email fields alongside the
+// access token, rather than requiring a separate profile call for them.
+// Those are stashed in the returned token's Extra map.
+func (c *VKOAuthClient) Exchange(ctx context.Context, code, codeVerifier string) (*OAuthToken, error) {
+	values := url.Values{
+		"client_id":     {c.ClientID},
+		"client_secret": {c.ClientSecret},
+		"redirect_uri":  {c.RedirectURI},
+		"code":          {code},
+	}
+	addPKCEVerifier(values, codeVerifier)
+	return c.requestToken(ctx, values)
+}
+
+// Refresh is not supported by classic VK OAuth: access tokens are
+// long-lived and there is no refresh token to trade.
+func (c *VKOAuthClient) Refresh(ctx context.Context, refreshToken string) (*OAuthToken, error) {
+	return nil, fmt.Errorf("mikhail: vk oauth does not support token refresh")
+}
+
+func (c *VKOAuthClient) requestToken(ctx context.Context, values url.Values) (*OAuthToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://oauth.vk.com/access_token?"+values.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, &OAuthProviderError{Op: "exchange", Err: err}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &OAuthProviderError{Op: "exchange", HTTPStatus: resp.StatusCode, Err: fmt.Errorf("mikhail: vk token exchange failed: %s: %s", resp.Status, body)}
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+		UserID      int64  `json:"user_id"`
+		Email       string `json:"email"`
+		Error       string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("mikhail: vk token exchange returned no access_token: %s", token.Error)
+	}
+
+	result := &OAuthToken{
+		AccessToken: token.AccessToken,
+		Extra: map[string]string{
+			"user_id": fmt.Sprintf("%d", token.UserID),
+			"email":   token.Email,
+		},
+	}
+	if token.ExpiresIn > 0 {
+		result.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return result, nil
+}
+
+// FetchProfile resolves a VK access token to the identity it was issued
+// for, combining the user ID and email VK already returned from
+// Exchange with the display name fetched from users.get.
+func (c *VKOAuthClient) FetchProfile(ctx context.Context, token *OAuthToken) (*UserProfile, error) {
+	userID := token.Extra["user_id"]
+	if userID == "" || userID == "0" {
+		return nil, fmt.Errorf("mikhail: vk token missing user_id")
+	}
+
+	values := url.Values{
+		"access_token": {token.AccessToken},
+		"v":            {vkAPIVersion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.vk.com/method/users.get?"+values.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, &OAuthProviderError{Op: "fetch_profile", Err: err}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &OAuthProviderError{Op: "fetch_profile", HTTPStatus: resp.StatusCode, Err: fmt.Errorf("mikhail: vk users.get request failed: %s: %s", resp.Status, body)}
+	}
+
+	var result struct {
+		Response []struct {
+			FirstName string `json:"first_name"`
+			LastName  string `json:"last_name"`
+		} `json:"response"`
+		Error struct {
+			ErrorMsg string `json:"error_msg"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Response) == 0 {
+		return nil, fmt.Errorf("mikhail: vk users.get returned no profile: %s", result.Error.ErrorMsg)
+	}
+
+	displayName := strings.TrimSpace(result.Response[0].FirstName + " " + result.Response[0].LastName)
+	return &UserProfile{ProviderUserID: userID, Email: token.Extra["email"], DisplayName: displayName}, nil
+}
+
+// Revoke is not supported by VK's OAuth API: there is no endpoint to
+// invalidate a single access token server-side.
+func (c *VKOAuthClient) Revoke(ctx context.Context, accessToken string) error {
+	return fmt.Errorf("mikhail: vk oauth does not support token revocation")
+}
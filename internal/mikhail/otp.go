@@ -0,0 +1,159 @@
+package mikhail
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// ErrOTPInvalid is returned by VerifyOtp when the code does not match, has
+// expired, or no code was ever sent to the phone number.
+var ErrOTPInvalid = errors.New("mikhail: otp code invalid or expired")
+
+// ErrOTPAttemptsExceeded is returned by VerifyOtp once a code has been
+// guessed wrong MaxAttempts times, even if the next guess would have been
+// correct.
+var ErrOTPAttemptsExceeded = errors.New("mikhail: otp attempt limit exceeded")
+
+// ErrPhoneNotVerified is returned by SignUp when no verified OTP exists
+// for the phone number being registered.
+var ErrPhoneNotVerified = errors.New("mikhail: phone number not verified via otp")
+
+// OTPInfo is what Mikhail keeps about an outstanding one-time code.
+type OTPInfo struct {
+	Phone       string
+	CodeHash    string
+	ExpiresAt   time.Time
+	Attempts    int
+	MaxAttempts int
+	// Verified is set once VerifyOtp accepts the code. SignUp checks this
+	// rather than re-deriving a hash comparison.
+	Verified bool
+}
+
+// Expired reports whether the code is past its expiry time as of now.
+func (o OTPInfo) Expired(now time.Time) bool {
+	return now.After(o.ExpiresAt)
+}
+
+// OTPStore persists outstanding one-time codes, keyed by phone number.
+// Implementations must be safe for concurrent use.
+type OTPStore interface {
+	Store(phone string, info OTPInfo) error
+	Get(phone string) (OTPInfo, bool, error)
+	IncrementAttempts(phone string) error
+	Delete(phone string) error
+}
+
+// InMemoryOTPStore is an OTPStore backed by a guarded map, suitable for
+// local development and single-node deployments.
+type InMemoryOTPStore struct {
+	mu    sync.Mutex
+	codes map[string]OTPInfo
+}
+
+// NewInMemoryOTPStore returns an empty InMemoryOTPStore.
+func NewInMemoryOTPStore() *InMemoryOTPStore {
+	return &InMemoryOTPStore{codes: make(map[string]OTPInfo)}
+}
+
+func (s *InMemoryOTPStore) Store(phone string, info OTPInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.codes[phone] = info
+	return nil
+}
+
+func (s *InMemoryOTPStore) Get(phone string) (OTPInfo, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, ok := s.codes[phone]
+	return info, ok, nil
+}
+
+func (s *InMemoryOTPStore) IncrementAttempts(phone string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, ok := s.codes[phone]
+	if !ok {
+		return nil
+	}
+	info.Attempts++
+	s.codes[phone] = info
+	return nil
+}
+
+func (s *InMemoryOTPStore) Delete(phone string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.codes, phone)
+	return nil
+}
+
+// RedisOTPStore is an OTPStore backed by Redis, for deployments with more
+// than one Mikhail instance sharing OTP state. Records are stored
+// JSON-encoded with a TTL matching their expiry, so Redis itself reaps
+// expired codes.
+type RedisOTPStore struct {
+	client *redisClient
+}
+
+// NewRedisOTPStore dials addr ("host:port") and returns a RedisOTPStore
+// backed by that connection.
+func NewRedisOTPStore(addr string) (*RedisOTPStore, error) {
+	client, err := dialRedis(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisOTPStore{client: client}, nil
+}
+
+func otpRedisKey(phone string) string {
+	return "mikhail:otp:" + phone
+}
+
+func (s *RedisOTPStore) Store(phone string, info OTPInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(otpRedisKey(phone), string(data), time.Until(info.ExpiresAt))
+}
+
+func (s *RedisOTPStore) Get(phone string) (OTPInfo, bool, error) {
+	raw, ok, err := s.client.Get(otpRedisKey(phone))
+	if err != nil || !ok {
+		return OTPInfo{}, false, err
+	}
+	var info OTPInfo
+	if err := json.Unmarshal([]byte(raw), &info); err != nil {
+		return OTPInfo{}, false, err
+	}
+	return info, true, nil
+}
+
+func (s *RedisOTPStore) IncrementAttempts(phone string) error {
+	info, ok, err := s.Get(phone)
+	if err != nil || !ok {
+		return err
+	}
+	info.Attempts++
+	return s.Store(phone, info)
+}
+
+func (s *RedisOTPStore) Delete(phone string) error {
+	return s.client.Del(otpRedisKey(phone))
+}
+
+// generateOTPCode returns a random 6-digit numeric code, zero-padded.
+func generateOTPCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
@@ -0,0 +1,49 @@
+package mikhail
+
+import (
+	"crypto/sha256"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordHashCost is the bcrypt work factor hashPassword hashes new
+// passwords at. bcrypt.DefaultCost (10) is calibrated for an
+// interactive login on commodity hardware circa its own introduction;
+// Mikhail raises it two steps since password storage for a production
+// auth service should not settle for the floor.
+const passwordHashCost = bcrypt.DefaultCost + 2
+
+// prehash collapses password to a fixed-size SHA-256 digest before
+// bcrypt sees it. bcrypt silently truncates any input past 72 bytes and
+// only ever looks at the first NUL byte, so a caller hashing a long,
+// generated credential - clients.go's client secrets, in particular,
+// are longer than 72 bytes - would otherwise get materially weaker
+// protection than a short one. Hashing first makes every input the same
+// size and byte-safe for bcrypt to work on.
+func prehash(password string) []byte {
+	sum := sha256.Sum256([]byte(password))
+	return sum[:]
+}
+
+// hashPassword derives and encodes a salted password hash for storage in
+// place of the plaintext password, using bcrypt. Earlier revisions of
+// this function used a bespoke iterated-HMAC-SHA256 chain with no
+// memory-hardness, materially weaker against offline GPU/ASIC cracking
+// than bcrypt's Blowfish-based key schedule for the same wall-clock
+// login latency; bcrypt.GenerateFromPassword already encodes its own
+// cost and salt into the returned string, so there is no custom encoding
+// to maintain here.
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword(prehash(password), passwordHashCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// verifyPassword reports whether password matches an encoded hash
+// produced by hashPassword. bcrypt.CompareHashAndPassword runs in time
+// independent of where the comparison first diverges.
+func verifyPassword(encoded, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(encoded), prehash(password)) == nil
+}
@@ -0,0 +1,71 @@
+package mikhail
+
+import "testing"
+
+func TestHashPasswordVerifyRoundTrip(t *testing.T) {
+	hash, err := hashPassword("correct-horse-battery-staple")
+	if err != nil {
+		t.Fatalf("hashPassword: %v", err)
+	}
+	if !verifyPassword(hash, "correct-horse-battery-staple") {
+		t.Fatal("verifyPassword rejected the password it was just hashed from")
+	}
+}
+
+func TestVerifyPasswordRejectsWrongPassword(t *testing.T) {
+	hash, err := hashPassword("correct-horse-battery-staple")
+	if err != nil {
+		t.Fatalf("hashPassword: %v", err)
+	}
+	if verifyPassword(hash, "wrong-password") {
+		t.Fatal("verifyPassword accepted the wrong password")
+	}
+}
+
+func TestHashPasswordSaltsEachCall(t *testing.T) {
+	first, err := hashPassword("same-password")
+	if err != nil {
+		t.Fatalf("hashPassword: %v", err)
+	}
+	second, err := hashPassword("same-password")
+	if err != nil {
+		t.Fatalf("hashPassword: %v", err)
+	}
+	if first == second {
+		t.Fatal("hashPassword produced identical output for two calls with the same password; salt is not varying")
+	}
+}
+
+func TestVerifyPasswordRejectsMalformedEncoding(t *testing.T) {
+	for _, encoded := range []string{
+		"",
+		"not-the-expected-format",
+		"mikhail-pbkdf-hmac-sha256-v1$t=1$c2FsdA$aGFzaA",
+	} {
+		if verifyPassword(encoded, "anything") {
+			t.Fatalf("verifyPassword accepted malformed encoded hash %q", encoded)
+		}
+	}
+}
+
+// TestHashPasswordHandlesInputsLongerThanBcryptLimit checks that a
+// password longer than bcrypt's 72-byte input limit - clients.go hashes
+// generated client secrets this way, which routinely are - still round
+// trips and still distinguishes a near-miss from the real value, rather
+// than silently validating against only a truncated prefix.
+func TestHashPasswordHandlesInputsLongerThanBcryptLimit(t *testing.T) {
+	long := ""
+	for i := 0; i < 100; i++ {
+		long += "a"
+	}
+	hash, err := hashPassword(long)
+	if err != nil {
+		t.Fatalf("hashPassword: %v", err)
+	}
+	if !verifyPassword(hash, long) {
+		t.Fatal("verifyPassword rejected the long password it was just hashed from")
+	}
+	if verifyPassword(hash, long[:71]) {
+		t.Fatal("verifyPassword accepted a 71-byte prefix of a 100-byte password")
+	}
+}
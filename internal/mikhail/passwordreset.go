@@ -0,0 +1,96 @@
+package mikhail
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrResetTokenInvalid is returned by CompletePasswordReset when the reset
+// token is unknown, expired, or already used.
+var ErrResetTokenInvalid = errors.New("mikhail: password reset token invalid or expired")
+
+// ResetTokenInfo is what Mikhail keeps about an issued password reset
+// token.
+type ResetTokenInfo struct {
+	UserID    string
+	Phone     string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	Used      bool
+}
+
+// Expired reports whether the reset token is past its expiry time as of
+// now.
+func (t ResetTokenInfo) Expired(now time.Time) bool {
+	return now.After(t.ExpiresAt)
+}
+
+// PasswordResetStorage persists single-use password reset tokens. It is
+// kept separate from TokenStorage because reset tokens carry none of the
+// refresh-token session state (device binding, rotation, families) and
+// are deleted the moment they are used rather than rotated.
+type PasswordResetStorage interface {
+	Store(resetToken string, info ResetTokenInfo) error
+	Get(resetToken string) (ResetTokenInfo, bool, error)
+	MarkUsed(resetToken string) error
+}
+
+// InMemoryPasswordResetStorage is a PasswordResetStorage backed by a
+// guarded map, suitable for local development and single-node
+// deployments.
+type InMemoryPasswordResetStorage struct {
+	mu     sync.Mutex
+	tokens map[string]ResetTokenInfo
+}
+
+// NewInMemoryPasswordResetStorage returns an empty
+// InMemoryPasswordResetStorage.
+func NewInMemoryPasswordResetStorage() *InMemoryPasswordResetStorage {
+	return &InMemoryPasswordResetStorage{tokens: make(map[string]ResetTokenInfo)}
+}
+
+func (s *InMemoryPasswordResetStorage) Store(resetToken string, info ResetTokenInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[resetToken] = info
+	return nil
+}
+
+func (s *InMemoryPasswordResetStorage) Get(resetToken string) (ResetTokenInfo, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, ok := s.tokens[resetToken]
+	return info, ok, nil
+}
+
+func (s *InMemoryPasswordResetStorage) MarkUsed(resetToken string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, ok := s.tokens[resetToken]
+	if !ok {
+		return nil
+	}
+	info.Used = true
+	s.tokens[resetToken] = info
+	return nil
+}
+
+// Notifier delivers an out-of-band message to a user, e.g. a password
+// reset link over SMS or email. Implementations are pluggable so Mikhail
+// is not tied to one delivery channel or provider.
+type Notifier interface {
+	Notify(recipient, message string) error
+}
+
+// LogNotifier is a Notifier that writes to the standard logger. It is the
+// default until a real SMS or email provider is wired in. message often
+// embeds a password reset, magic link, or email verification token, so
+// this logs through SafeLogf rather than log.Printf directly, masking
+// any token-shaped substring before it reaches the log.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(recipient, message string) error {
+	SafeLogf("mikhail: notify %s: %s", recipient, message)
+	return nil
+}
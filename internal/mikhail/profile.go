@@ -0,0 +1,137 @@
+package mikhail
+
+import (
+	"sync"
+	"time"
+)
+
+// ProfileInfo is the subset of a user's profile Mikhail stores locally,
+// rather than proxying to whichever identity provider the account
+// signed up through.
+type ProfileInfo struct {
+	UserID      string
+	FirstName   string
+	LastName    string
+	DisplayName string
+	AvatarURL   string
+	// Email is the address an external identity provider reported for
+	// this account, kept in sync by SyncFromProvider.
+	Email string
+	// FetchedAt is when SyncFromProvider last refreshed the
+	// provider-sourced fields above, used by GetMe to decide whether the
+	// cached copy is stale enough to warrant a fresh SyncProfile pull.
+	// Zero means the profile has never been synced from a provider.
+	FetchedAt time.Time
+}
+
+// profileFieldPaths are the update_mask paths UpdateProfile accepts,
+// mirroring the field names on ProfileInfo/UpdateProfileRequest.
+const (
+	profileFieldFirstName   = "first_name"
+	profileFieldLastName    = "last_name"
+	profileFieldDisplayName = "display_name"
+	profileFieldAvatarURL   = "avatar_url"
+)
+
+// ProfileStore looks up and updates local profile data. Implementations
+// must be safe for concurrent use.
+type ProfileStore interface {
+	Get(userID string) (ProfileInfo, bool, error)
+	// Update applies the fields of patch named in mask to the profile on
+	// record for patch.UserID, creating it if it does not already exist,
+	// and returns the profile as it stands after the update.
+	Update(patch ProfileInfo, mask []string) (ProfileInfo, error)
+	// FillIfEmpty sets a field to value only if it is not already set,
+	// used to seed a profile from an external identity provider (e.g.
+	// Yandex) without clobbering anything the user has set themselves.
+	FillIfEmpty(userID, field, value string) error
+	// SyncFromProvider overwrites userID's DisplayName and Email with
+	// fresh values fetched from an OAuthProvider (leaving an empty field
+	// in fresh untouched, rather than clobbering a locally set value
+	// with nothing) and records fetchedAt, returning the profile as it
+	// stands afterward.
+	SyncFromProvider(userID string, fresh ProfileInfo, fetchedAt time.Time) (ProfileInfo, error)
+}
+
+// InMemoryProfileStore is a ProfileStore backed by a guarded map,
+// suitable for local development and single-node deployments.
+type InMemoryProfileStore struct {
+	mu       sync.Mutex
+	profiles map[string]ProfileInfo
+}
+
+// NewInMemoryProfileStore returns an empty InMemoryProfileStore.
+func NewInMemoryProfileStore() *InMemoryProfileStore {
+	return &InMemoryProfileStore{profiles: make(map[string]ProfileInfo)}
+}
+
+func (s *InMemoryProfileStore) Get(userID string) (ProfileInfo, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	profile, ok := s.profiles[userID]
+	return profile, ok, nil
+}
+
+func (s *InMemoryProfileStore) Update(patch ProfileInfo, mask []string) (ProfileInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	profile := s.profiles[patch.UserID]
+	profile.UserID = patch.UserID
+	for _, field := range mask {
+		switch field {
+		case profileFieldFirstName:
+			profile.FirstName = patch.FirstName
+		case profileFieldLastName:
+			profile.LastName = patch.LastName
+		case profileFieldDisplayName:
+			profile.DisplayName = patch.DisplayName
+		case profileFieldAvatarURL:
+			profile.AvatarURL = patch.AvatarURL
+		}
+	}
+	s.profiles[patch.UserID] = profile
+	return profile, nil
+}
+
+func (s *InMemoryProfileStore) FillIfEmpty(userID, field, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	profile := s.profiles[userID]
+	profile.UserID = userID
+	switch field {
+	case profileFieldFirstName:
+		if profile.FirstName == "" {
+			profile.FirstName = value
+		}
+	case profileFieldLastName:
+		if profile.LastName == "" {
+			profile.LastName = value
+		}
+	case profileFieldDisplayName:
+		if profile.DisplayName == "" {
+			profile.DisplayName = value
+		}
+	case profileFieldAvatarURL:
+		if profile.AvatarURL == "" {
+			profile.AvatarURL = value
+		}
+	}
+	s.profiles[userID] = profile
+	return nil
+}
+
+func (s *InMemoryProfileStore) SyncFromProvider(userID string, fresh ProfileInfo, fetchedAt time.Time) (ProfileInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	profile := s.profiles[userID]
+	profile.UserID = userID
+	if fresh.DisplayName != "" {
+		profile.DisplayName = fresh.DisplayName
+	}
+	if fresh.Email != "" {
+		profile.Email = fresh.Email
+	}
+	profile.FetchedAt = fetchedAt
+	s.profiles[userID] = profile
+	return profile, nil
+}
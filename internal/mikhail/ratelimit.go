@@ -0,0 +1,207 @@
+package mikhail
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimiter decides whether a caller identified by key may proceed
+// right now. Implementations must be safe for concurrent use. now is
+// passed in explicitly (rather than read via time.Now() internally) the
+// same way TokenInfo.Expired takes it, so tests can drive a limiter's
+// refill without sleeping.
+type RateLimiter interface {
+	// Allow records one attempt for key at now and reports whether it is
+	// within the limit.
+	Allow(key string, now time.Time) (bool, error)
+}
+
+// RateLimitConfig configures a token-bucket RateLimiter: Burst tokens
+// are available up front for a spike, and one more token refills every
+// RefillInterval after that, so sustained traffic is capped at one call
+// per RefillInterval once the burst is spent. Read and write RPCs get
+// independent RateLimitConfig values (see LoadReadRateLimitConfig,
+// LoadWriteRateLimitConfig) since a read is typically far cheaper to
+// serve than a write that hits the credential store or an OAuth
+// provider.
+type RateLimitConfig struct {
+	Burst          int
+	RefillInterval time.Duration
+}
+
+// Validate reports an error if Burst or RefillInterval is not strictly
+// positive.
+func (c RateLimitConfig) Validate() error {
+	if c.Burst <= 0 {
+		return fmt.Errorf("mikhail: rate limit config.Burst must be positive, got %d", c.Burst)
+	}
+	if c.RefillInterval <= 0 {
+		return fmt.Errorf("mikhail: rate limit config.RefillInterval must be positive, got %s", c.RefillInterval)
+	}
+	return nil
+}
+
+// refillRate is the token-bucket's refill rate in tokens per second.
+func (c RateLimitConfig) refillRate() float64 {
+	return 1 / c.RefillInterval.Seconds()
+}
+
+// DefaultReadRateLimitConfig returns a generous limit for read RPCs
+// (GetMe, VerifyToken, ListSessions, and the like): a burst of 60 with one
+// token refilling every second.
+func DefaultReadRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{Burst: 60, RefillInterval: time.Second}
+}
+
+// DefaultWriteRateLimitConfig returns a tighter limit for write RPCs
+// (SignIn, RefreshToken, OAuth2Callback, and the like, which hit the
+// credential store or an external OAuth provider): a burst of 10 with
+// one token refilling every 2 seconds.
+func DefaultWriteRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{Burst: 10, RefillInterval: 2 * time.Second}
+}
+
+// LoadReadRateLimitConfig builds a RateLimitConfig for read RPCs from
+// MIKHAIL_RATE_LIMIT_READ_BURST and MIKHAIL_RATE_LIMIT_READ_REFILL_INTERVAL,
+// falling back to DefaultReadRateLimitConfig for anything unset.
+func LoadReadRateLimitConfig() (RateLimitConfig, error) {
+	return loadRateLimitConfig(DefaultReadRateLimitConfig(), "MIKHAIL_RATE_LIMIT_READ_BURST", "MIKHAIL_RATE_LIMIT_READ_REFILL_INTERVAL")
+}
+
+// LoadWriteRateLimitConfig builds a RateLimitConfig for write RPCs from
+// MIKHAIL_RATE_LIMIT_WRITE_BURST and MIKHAIL_RATE_LIMIT_WRITE_REFILL_INTERVAL,
+// falling back to DefaultWriteRateLimitConfig for anything unset.
+func LoadWriteRateLimitConfig() (RateLimitConfig, error) {
+	return loadRateLimitConfig(DefaultWriteRateLimitConfig(), "MIKHAIL_RATE_LIMIT_WRITE_BURST", "MIKHAIL_RATE_LIMIT_WRITE_REFILL_INTERVAL")
+}
+
+func loadRateLimitConfig(cfg RateLimitConfig, burstVar, refillVar string) (RateLimitConfig, error) {
+	if err := overrideInt(&cfg.Burst, burstVar); err != nil {
+		return RateLimitConfig{}, err
+	}
+	if err := overrideDuration(&cfg.RefillInterval, refillVar); err != nil {
+		return RateLimitConfig{}, err
+	}
+	return cfg, cfg.Validate()
+}
+
+// InMemoryRateLimiter is a token-bucket RateLimiter backed by a guarded
+// map. It makes limits per-instance, the same way the in-memory
+// TokenStorage backend only shares state within one process; running
+// several Mikhail replicas behind it multiplies the effective limit by
+// the replica count. Use RedisRateLimiter instead when that matters.
+type InMemoryRateLimiter struct {
+	cfg RateLimitConfig
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// tokenBucket is one key's token-bucket state: tokens holds however many
+// whole-and-fractional tokens have accumulated since lastRefill, capped
+// at cfg.Burst by refill.
+type tokenBucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+// NewInMemoryRateLimiter returns an InMemoryRateLimiter enforcing cfg.
+func NewInMemoryRateLimiter(cfg RateLimitConfig) *InMemoryRateLimiter {
+	return &InMemoryRateLimiter{cfg: cfg, buckets: make(map[string]*tokenBucket)}
+}
+
+// Allow refills key's bucket up to now and admits this call if at least
+// one token is available, consuming it.
+func (l *InMemoryRateLimiter) Allow(key string, now time.Time) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.refill(key, now)
+	if b.tokens < 1 {
+		return false, nil
+	}
+	b.tokens--
+	return true, nil
+}
+
+// refill must be called with mu held. It returns key's bucket, topped up
+// for however much time has passed since it was last refilled, creating
+// a full bucket if key has not been seen before.
+func (l *InMemoryRateLimiter) refill(key string, now time.Time) *tokenBucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(l.cfg.Burst), lastRefill: now}
+		l.buckets[key] = b
+		return b
+	}
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	if elapsed > 0 {
+		b.tokens += elapsed * l.cfg.refillRate()
+		if b.tokens > float64(l.cfg.Burst) {
+			b.tokens = float64(l.cfg.Burst)
+		}
+		b.lastRefill = now
+	}
+	return b
+}
+
+// EvictIdle removes every bucket whose lastRefill is more than idleTTL
+// before now, and returns how many were removed. A bucket is only ever
+// touched by Allow/RateLimitState for its own key, so a key nobody has
+// called in idleTTL is safe to forget: the next call for it simply
+// starts a fresh, full bucket, indistinguishable from its first-ever
+// call. See RateLimitEvictor, which calls this periodically so a scan of
+// random keys (e.g. random or forged tokens) cannot grow buckets without
+// bound.
+func (l *InMemoryRateLimiter) EvictIdle(idleTTL time.Duration, now time.Time) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	evicted := 0
+	for key, b := range l.buckets {
+		if now.Sub(b.lastRefill) > idleTTL {
+			delete(l.buckets, key)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// TrackedKeyCount returns how many keys currently have a bucket, for
+// RateLimitEvictor's logging and AdminServer.RateLimiterStats's
+// tracked-key metric.
+func (l *InMemoryRateLimiter) TrackedKeyCount() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// RateLimitState reports key's current bucket occupancy, letting
+// InMemoryRateLimiter satisfy RateLimitStateProvider for AdminServer
+// without AdminServer depending on this concrete type. Unlike Allow, it
+// does not consume a token.
+func (l *InMemoryRateLimiter) RateLimitState(key string) (RateLimitState, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		return RateLimitState{}, false
+	}
+	now := time.Now()
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	tokens := b.tokens + elapsed*l.cfg.refillRate()
+	if tokens > float64(l.cfg.Burst) {
+		tokens = float64(l.cfg.Burst)
+	}
+
+	var resetAt time.Time
+	if tokens >= 1 {
+		resetAt = now
+	} else {
+		secondsToNextToken := (1 - tokens) / l.cfg.refillRate()
+		resetAt = now.Add(time.Duration(secondsToNextToken * float64(time.Second)))
+	}
+	return RateLimitState{Key: key, Remaining: int(tokens), Limit: l.cfg.Burst, ResetAt: resetAt}, true
+}
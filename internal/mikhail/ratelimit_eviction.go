@@ -0,0 +1,97 @@
+package mikhail
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// IdleKeyEvictor is implemented by a RateLimiter backend that tracks
+// per-key state in memory and can reap entries nobody has called in a
+// while, e.g. *InMemoryRateLimiter. RedisRateLimiter needs no equivalent:
+// its per-key state already expires natively through Redis TTLs.
+type IdleKeyEvictor interface {
+	// EvictIdle removes every tracked key last touched more than idleTTL
+	// before now, and returns how many were removed.
+	EvictIdle(idleTTL time.Duration, now time.Time) int
+	// TrackedKeyCount returns how many keys are currently tracked.
+	TrackedKeyCount() int
+}
+
+// RateLimitEvictionConfig controls RateLimitEvictor: how often it scans
+// for idle keys, and how long a key may sit untouched before it is
+// considered idle.
+type RateLimitEvictionConfig struct {
+	Interval time.Duration
+	IdleTTL  time.Duration
+}
+
+// DefaultRateLimitEvictionConfig returns a scan every 5 minutes for keys
+// idle more than 10 minutes - well beyond any RateLimitConfig.RefillInterval
+// in DefaultReadRateLimitConfig/DefaultWriteRateLimitConfig, so a key
+// still being actively rate-limited is never evicted out from under it.
+func DefaultRateLimitEvictionConfig() RateLimitEvictionConfig {
+	return RateLimitEvictionConfig{Interval: 5 * time.Minute, IdleTTL: 10 * time.Minute}
+}
+
+// LoadRateLimitEvictionConfig builds a RateLimitEvictionConfig from
+// MIKHAIL_RATE_LIMIT_EVICTION_INTERVAL and
+// MIKHAIL_RATE_LIMIT_EVICTION_IDLE_TTL, falling back to
+// DefaultRateLimitEvictionConfig for anything unset.
+func LoadRateLimitEvictionConfig() (RateLimitEvictionConfig, error) {
+	cfg := DefaultRateLimitEvictionConfig()
+	if err := overrideDuration(&cfg.Interval, "MIKHAIL_RATE_LIMIT_EVICTION_INTERVAL"); err != nil {
+		return RateLimitEvictionConfig{}, err
+	}
+	if err := overrideDuration(&cfg.IdleTTL, "MIKHAIL_RATE_LIMIT_EVICTION_IDLE_TTL"); err != nil {
+		return RateLimitEvictionConfig{}, err
+	}
+	return cfg, nil
+}
+
+// RateLimitEvictor periodically calls EvictIdle on target, the same
+// scan-and-reap shape TokenExpirationPoller uses for expired tokens, so
+// an IdleKeyEvictor's memory use stays bounded by how many distinct
+// keys were active within the last IdleTTL rather than growing with
+// every key ever seen.
+type RateLimitEvictor struct {
+	target IdleKeyEvictor
+	cfg    RateLimitEvictionConfig
+
+	stop chan struct{}
+}
+
+// NewRateLimitEvictor returns a RateLimitEvictor that scans target every
+// cfg.Interval. Call Start to begin scanning.
+func NewRateLimitEvictor(target IdleKeyEvictor, cfg RateLimitEvictionConfig) *RateLimitEvictor {
+	return &RateLimitEvictor{target: target, cfg: cfg, stop: make(chan struct{})}
+}
+
+// Start runs the scan loop until ctx is done or Stop is called. It is
+// meant to be run in its own goroutine.
+func (e *RateLimitEvictor) Start(ctx context.Context) {
+	ticker := time.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.stop:
+			return
+		case <-ticker.C:
+			e.evictOnce()
+		}
+	}
+}
+
+// Stop ends a running Start loop.
+func (e *RateLimitEvictor) Stop() {
+	close(e.stop)
+}
+
+func (e *RateLimitEvictor) evictOnce() {
+	evicted := e.target.EvictIdle(e.cfg.IdleTTL, time.Now())
+	if evicted > 0 {
+		log.Printf("mikhail: rate limit eviction: removed %d idle key(s), %d tracked", evicted, e.target.TrackedKeyCount())
+	}
+}
@@ -0,0 +1,43 @@
+package mikhail
+
+import (
+	"fmt"
+	"os"
+)
+
+// RateLimiterBackend names a RateLimiter implementation
+// NewRateLimiterFromEnv knows how to select.
+type RateLimiterBackend string
+
+const (
+	RateLimiterBackendMemory RateLimiterBackend = "memory"
+	RateLimiterBackendRedis  RateLimiterBackend = "redis"
+)
+
+// NewRateLimiterFromEnv selects and constructs a RateLimiter enforcing
+// cfg, from MIKHAIL_RATE_LIMITER_BACKEND, defaulting to in-memory so a
+// deployment that never sets it keeps working unchanged (with the same
+// per-instance-only caveat InMemoryRateLimiter documents). The redis
+// backend reads MIKHAIL_REDIS_ADDR, the same variable
+// NewTokenStorageFromEnv's redis backend uses, since a deployment
+// pointing token storage at Redis almost always wants the rate limiter
+// on the same instance.
+func NewRateLimiterFromEnv(cfg RateLimitConfig) (RateLimiter, error) {
+	backend := RateLimiterBackend(os.Getenv("MIKHAIL_RATE_LIMITER_BACKEND"))
+	if backend == "" {
+		backend = RateLimiterBackendMemory
+	}
+
+	switch backend {
+	case RateLimiterBackendMemory:
+		return NewInMemoryRateLimiter(cfg), nil
+	case RateLimiterBackendRedis:
+		addr := os.Getenv("MIKHAIL_REDIS_ADDR")
+		if addr == "" {
+			return nil, fmt.Errorf("mikhail: MIKHAIL_RATE_LIMITER_BACKEND=redis requires MIKHAIL_REDIS_ADDR")
+		}
+		return NewRedisRateLimiter(addr, cfg)
+	default:
+		return nil, fmt.Errorf("mikhail: unknown MIKHAIL_RATE_LIMITER_BACKEND %q", backend)
+	}
+}
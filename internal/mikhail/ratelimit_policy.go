@@ -0,0 +1,143 @@
+package mikhail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// RateLimitExceededError is returned by RateLimitInterceptor when the
+// caller has exhausted its token bucket. RetryAfter is surfaced on the
+// Status StatusFromError builds from it, mirroring
+// google.rpc.RetryInfo.retry_delay the same way LoadSheddingError's does,
+// so a client backs off instead of retrying immediately into the same
+// limit.
+type RateLimitExceededError struct {
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface.
+func (e *RateLimitExceededError) Error() string {
+	return fmt.Sprintf("mikhail: rate limit exceeded, retry after %s", e.RetryAfter)
+}
+
+// RateLimitPolicy decides, for a given RPC, which of two RateLimitConfig
+// classes (read or write; see LoadReadRateLimitConfig, LoadWriteRateLimitConfig)
+// applies. A method not listed in ReadMethods is treated as a write,
+// the conservative default for anything the policy was never told about.
+type RateLimitPolicy struct {
+	// ReadMethods holds the bare RPC names (the part of
+	// UnaryServerInfo.FullMethod after the last "/", e.g. "ListSessions")
+	// that count as reads. Everything else counts as a write.
+	ReadMethods map[string]bool
+}
+
+// DefaultRateLimitPolicy classifies Mikhail's own read-only RPCs - the
+// ones that only look up state rather than mutating credentials,
+// sessions, or an external provider - as reads; every other RPC,
+// including ones this policy has never heard of, is a write.
+func DefaultRateLimitPolicy() RateLimitPolicy {
+	return RateLimitPolicy{ReadMethods: map[string]bool{
+		"GetMe":               true,
+		"GetTokenMetadata":    true,
+		"VerifyToken":         true,
+		"ListSessions":        true,
+		"ListLinkedProviders": true,
+	}}
+}
+
+// LoadRateLimitPolicy builds a RateLimitPolicy from
+// DefaultRateLimitPolicy, adding any extra read methods named in the
+// comma-separated MIKHAIL_RATE_LIMIT_READ_METHODS (e.g.
+// "LookupToken,ListBlocklist" for a deployment that wants its
+// admin lookups rate-limited as reads rather than writes).
+func LoadRateLimitPolicy() RateLimitPolicy {
+	policy := DefaultRateLimitPolicy()
+	if raw := os.Getenv("MIKHAIL_RATE_LIMIT_READ_METHODS"); raw != "" {
+		for _, method := range strings.Split(raw, ",") {
+			if method = strings.TrimSpace(method); method != "" {
+				policy.ReadMethods[method] = true
+			}
+		}
+	}
+	return policy
+}
+
+// isRead reports whether fullMethod (e.g.
+// "/kingdom.auth.v1.AuthenticateService/SignIn") is classified as a read.
+func (p RateLimitPolicy) isRead(fullMethod string) bool {
+	name := fullMethod
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		name = fullMethod[i+1:]
+	}
+	return p.ReadMethods[name]
+}
+
+// rateLimitPrincipalKey resolves the principal dimension RateLimitInterceptor
+// limits per, preferring the strongest identity already available on
+// ctx: the authenticated Principal's UserID (only present if this
+// interceptor runs after AuthenticationInterceptor in the chain), then
+// an "x-api-key" metadata header, then the caller's client IP (see
+// ClientIP) - the dimension an unauthenticated call like SignIn or
+// OAuth2Callback falls back to, since neither of the first two tiers
+// ever applies to them. A call with none of those - no auth, no API
+// key, and no PeerInfo to derive a client IP from - shares a single
+// "anonymous" bucket with every other such call, the same
+// degraded-but-safe behavior a missing PeerInfo already has for access
+// logging.
+func rateLimitPrincipalKey(ctx context.Context, trustedProxies TrustedProxyConfig) string {
+	if principal, ok := PrincipalFromContext(ctx); ok && principal.UserID != "" {
+		return "user:" + principal.UserID
+	}
+	if md, ok := IncomingMetadataFromContext(ctx); ok {
+		if apiKey := md.Get("x-api-key"); apiKey != "" {
+			return "apikey:" + apiKey
+		}
+	}
+	if ip := ClientIP(ctx, trustedProxies); ip != "" {
+		return "ip:" + ip
+	}
+	return "anonymous"
+}
+
+// RateLimitInterceptor returns a UnaryServerInterceptor that enforces
+// readLimiter or writeLimiter, whichever policy.isRead(info.FullMethod)
+// selects, keyed by info.FullMethod plus rateLimitPrincipalKey(ctx) so a
+// limit is per method and per caller rather than a single ceiling shared
+// by every RPC and every caller.
+//
+// Installed at its default position in DefaultInterceptorChainOrder (before
+// AuthenticationInterceptor, to shed abusive load before the cost of
+// verifying a bearer token), rateLimitPrincipalKey only ever sees the
+// API-key/peer-address tiers, never the authenticated-user tier: a
+// deployment that wants per-user limits instead can reorder
+// MIKHAIL_GRPC_INTERCEPTOR_CHAIN to run "rate_limit" after "auth", at the
+// cost of no longer shedding unauthenticated load before paying for
+// token verification.
+func RateLimitInterceptor(readLimiter, writeLimiter RateLimiter, policy RateLimitPolicy, trustedProxies TrustedProxyConfig) UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error) {
+		limiter := writeLimiter
+		if policy.isRead(info.FullMethod) {
+			limiter = readLimiter
+		}
+		key := info.FullMethod + "|" + rateLimitPrincipalKey(ctx, trustedProxies)
+		allowed, err := limiter.Allow(key, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			retryAfter := time.Second
+			if provider, ok := limiter.(RateLimitStateProvider); ok {
+				if state, ok := provider.RateLimitState(key); ok && !state.ResetAt.IsZero() {
+					if d := time.Until(state.ResetAt); d > 0 {
+						retryAfter = d
+					}
+				}
+			}
+			return nil, &RateLimitExceededError{RetryAfter: retryAfter}
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,180 @@
+package mikhail
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RedisRateLimiter is a token-bucket RateLimiter backed by Redis, for a
+// deployment running several Mikhail replicas that need a limit to hold
+// across the whole fleet rather than per instance (what
+// InMemoryRateLimiter gives). Each key's bucket is kept as a Redis hash
+// (tokens, last refill time), refilled and checked in a single EVAL so a
+// race between two replicas calling Allow for the same key at once
+// cannot both observe a token available and consume it.
+type RedisRateLimiter struct {
+	cfg RateLimitConfig
+
+	mu     sync.Mutex
+	client *redisClient
+	addr   string
+}
+
+// NewRedisRateLimiter dials addr ("host:port") and returns a
+// RedisRateLimiter enforcing cfg.
+func NewRedisRateLimiter(addr string, cfg RateLimitConfig) (*RedisRateLimiter, error) {
+	client, err := dialRedis(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisRateLimiter{cfg: cfg, client: client, addr: addr}, nil
+}
+
+// redisRateLimitKeyPrefix namespaces RedisRateLimiter's bucket hashes
+// away from RedisTokenStorage's and RedisOTPStore's keys in a shared
+// Redis instance.
+const redisRateLimitKeyPrefix = "mikhail:ratelimit:"
+
+func redisRateLimitKey(key string) string {
+	return redisRateLimitKeyPrefix + key
+}
+
+// redisTokenBucketAllowScript refills KEYS[1]'s bucket for however much
+// time has passed since its 'ts' field (capped at ARGV[2] tokens), then
+// admits this call and consumes one token if at least one is available.
+// Refilling, checking and consuming all happen inside one EVAL so two
+// replicas racing on the same key cannot both consume the same token.
+//
+// KEYS: 1 the bucket hash.
+// ARGV: 1 now (unix nanoseconds), 2 burst, 3 refill rate (tokens per
+// second), 4 TTL in seconds for the hash, long enough to cover a full
+// refill from empty plus a margin so an idle key expires instead of
+// sitting in Redis forever.
+const redisTokenBucketAllowScript = `
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+local now = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+if tokens == nil then
+	tokens = burst
+	ts = now
+end
+local elapsed = (now - ts) / 1e9
+if elapsed > 0 then
+	tokens = math.min(burst, tokens + elapsed * tonumber(ARGV[3]))
+end
+local allowed = '0'
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = '1'
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+return allowed
+`
+
+// bucketTTLSeconds is how long an idle bucket's Redis hash is kept
+// before expiring: enough time to refill from empty plus a day's margin,
+// so a key that stops being called is eventually forgotten instead of
+// accumulating in Redis forever.
+func (l *RedisRateLimiter) bucketTTLSeconds() int {
+	return int(float64(l.cfg.Burst)/l.cfg.refillRate()) + 86400
+}
+
+// Allow refills key's bucket up to now and admits this call if at least
+// one token is available, consuming it.
+func (l *RedisRateLimiter) Allow(key string, now time.Time) (bool, error) {
+	keysAndArgs := []string{
+		redisRateLimitKey(key),
+		strconv.FormatInt(now.UnixNano(), 10),
+		strconv.Itoa(l.cfg.Burst),
+		strconv.FormatFloat(l.cfg.refillRate(), 'f', -1, 64),
+		strconv.Itoa(l.bucketTTLSeconds()),
+	}
+	var reply string
+	err := l.withClient(func(c *redisClient) error {
+		r, _, err := c.Eval(redisTokenBucketAllowScript, 1, keysAndArgs)
+		reply = r
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return reply == "1", nil
+}
+
+// RateLimitState reports key's current bucket occupancy, letting
+// RedisRateLimiter satisfy RateLimitStateProvider for AdminServer.
+// Unlike Allow, it does not consume a token, and (unlike Allow) is not
+// atomic with a concurrent Allow - acceptable for the admin-reporting use
+// it is for.
+func (l *RedisRateLimiter) RateLimitState(key string) (RateLimitState, bool) {
+	redisKey := redisRateLimitKey(key)
+
+	var fields []string
+	var oks []bool
+	err := l.withClient(func(c *redisClient) error {
+		f, o, err := c.HMGet(redisKey, "tokens", "ts")
+		fields, oks = f, o
+		return err
+	})
+	if err != nil || len(fields) != 2 || !oks[0] || !oks[1] {
+		return RateLimitState{}, false
+	}
+
+	tokens, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return RateLimitState{}, false
+	}
+	tsNanos, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return RateLimitState{}, false
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(time.Unix(0, tsNanos)).Seconds()
+	if elapsed > 0 {
+		tokens += elapsed * l.cfg.refillRate()
+		if tokens > float64(l.cfg.Burst) {
+			tokens = float64(l.cfg.Burst)
+		}
+	}
+
+	var resetAt time.Time
+	if tokens >= 1 {
+		resetAt = now
+	} else {
+		secondsToNextToken := (1 - tokens) / l.cfg.refillRate()
+		resetAt = now.Add(time.Duration(secondsToNextToken * float64(time.Second)))
+	}
+	return RateLimitState{Key: key, Remaining: int(tokens), Limit: l.cfg.Burst, ResetAt: resetAt}, true
+}
+
+// withClient runs op against the current connection, redialing addr and
+// retrying once if op's first attempt fails. Mirrors RedisTokenStorage's
+// withClient, minus Sentinel support, which a rate limiter has no
+// equivalent need for.
+func (l *RedisRateLimiter) withClient(op func(*redisClient) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err := op(l.client)
+	if err == nil {
+		return nil
+	}
+	client, dialErr := dialRedis(l.addr)
+	if dialErr != nil {
+		return err
+	}
+	l.client.Close()
+	l.client = client
+	return op(l.client)
+}
+
+// Ping reports whether the underlying Redis connection is reachable, so
+// RedisRateLimiter satisfies Pinger the same way RedisTokenStorage does.
+func (l *RedisRateLimiter) Ping() error {
+	return l.withClient(func(c *redisClient) error { return c.Ping() })
+}
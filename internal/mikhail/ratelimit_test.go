@@ -0,0 +1,84 @@
+package mikhail
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInMemoryRateLimiterAllowsUpToBurst(t *testing.T) {
+	l := NewInMemoryRateLimiter(RateLimitConfig{Burst: 3, RefillInterval: time.Minute})
+	now := time.Unix(1_700_000_000, 0)
+
+	for i := 0; i < 3; i++ {
+		allowed, err := l.Allow("key-1", now)
+		if err != nil {
+			t.Fatalf("Allow: %v", err)
+		}
+		if !allowed {
+			t.Fatalf("Allow call %d of the burst: got false, want true", i+1)
+		}
+	}
+
+	if allowed, err := l.Allow("key-1", now); err != nil || allowed {
+		t.Fatalf("Allow after exhausting the burst: got allowed=%t err=%v, want false, nil", allowed, err)
+	}
+}
+
+func TestInMemoryRateLimiterRefillsOverTime(t *testing.T) {
+	l := NewInMemoryRateLimiter(RateLimitConfig{Burst: 1, RefillInterval: time.Second})
+	now := time.Unix(1_700_000_000, 0)
+
+	if allowed, err := l.Allow("key-1", now); err != nil || !allowed {
+		t.Fatalf("first Allow: got allowed=%t err=%v, want true, nil", allowed, err)
+	}
+	if allowed, err := l.Allow("key-1", now); err != nil || allowed {
+		t.Fatalf("second Allow with no time elapsed: got allowed=%t err=%v, want false, nil", allowed, err)
+	}
+
+	later := now.Add(time.Second)
+	if allowed, err := l.Allow("key-1", later); err != nil || !allowed {
+		t.Fatalf("Allow one RefillInterval later: got allowed=%t err=%v, want true, nil", allowed, err)
+	}
+}
+
+func TestInMemoryRateLimiterKeysAreIndependent(t *testing.T) {
+	l := NewInMemoryRateLimiter(RateLimitConfig{Burst: 1, RefillInterval: time.Minute})
+	now := time.Unix(1_700_000_000, 0)
+
+	if allowed, err := l.Allow("key-1", now); err != nil || !allowed {
+		t.Fatalf("Allow(key-1): got allowed=%t err=%v, want true, nil", allowed, err)
+	}
+	if allowed, err := l.Allow("key-2", now); err != nil || !allowed {
+		t.Fatalf("Allow(key-2) after exhausting key-1's bucket: got allowed=%t err=%v, want true, nil", allowed, err)
+	}
+}
+
+func TestInMemoryRateLimiterEvictIdle(t *testing.T) {
+	l := NewInMemoryRateLimiter(RateLimitConfig{Burst: 1, RefillInterval: time.Minute})
+	now := time.Unix(1_700_000_000, 0)
+	if _, err := l.Allow("key-1", now); err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+
+	if evicted := l.EvictIdle(time.Hour, now.Add(time.Minute)); evicted != 0 {
+		t.Fatalf("EvictIdle before the idle TTL has passed: got %d evicted, want 0", evicted)
+	}
+	if evicted := l.EvictIdle(time.Hour, now.Add(2*time.Hour)); evicted != 1 {
+		t.Fatalf("EvictIdle after the idle TTL has passed: got %d evicted, want 1", evicted)
+	}
+	if got := l.TrackedKeyCount(); got != 0 {
+		t.Fatalf("TrackedKeyCount after eviction: got %d, want 0", got)
+	}
+}
+
+func TestRateLimitConfigValidate(t *testing.T) {
+	if err := (RateLimitConfig{Burst: 1, RefillInterval: time.Second}).Validate(); err != nil {
+		t.Fatalf("Validate on a valid config: got err %v, want nil", err)
+	}
+	if err := (RateLimitConfig{Burst: 0, RefillInterval: time.Second}).Validate(); err == nil {
+		t.Fatal("Validate with Burst=0: got nil error, want one")
+	}
+	if err := (RateLimitConfig{Burst: 1, RefillInterval: 0}).Validate(); err == nil {
+		t.Fatal("Validate with RefillInterval=0: got nil error, want one")
+	}
+}
@@ -0,0 +1,27 @@
+package mikhail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+)
+
+// RecoveryInterceptor returns a UnaryServerInterceptor that recovers a
+// panic in handler (or in any interceptor after this one in the chain)
+// and reports it as a CodeInternal Status instead of crashing the
+// process or, on a real grpc.Server with no recovery middleware of its
+// own, closing the connection out from under every other in-flight RPC
+// on it. Install it first in the interceptor chain so it covers
+// everything after it.
+func RecoveryInterceptor() UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("mikhail: recovered panic in rpc method=%s: %v\n%s", info.FullMethod, r, debug.Stack())
+				err = NewStatus(CodeInternal, ErrorReasonUnspecified, fmt.Errorf("mikhail: panic: %v", r))
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
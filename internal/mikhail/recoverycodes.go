@@ -0,0 +1,104 @@
+package mikhail
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrRecoveryCodeInvalid is returned when a presented recovery code does
+// not match any unused code on file for the account.
+var ErrRecoveryCodeInvalid = errors.New("mikhail: recovery code invalid")
+
+// recoveryCodeCount is how many one-time recovery codes are generated
+// each time 2FA is enabled or RegenerateRecoveryCodes is called. It
+// matches the count most authenticator-adjacent services hand out.
+const recoveryCodeCount = 10
+
+// RecoveryCodeStore persists the hashed recovery codes issued to a 2FA
+// account, keyed by user ID. Codes are hashed the same way passwords are
+// (via hashPassword/verifyPassword) since both are "does this secret
+// match one we stored" checks. Implementations must be safe for
+// concurrent use.
+type RecoveryCodeStore interface {
+	// Store replaces userID's set of recovery codes with hashes,
+	// discarding any codes issued before (regeneration consumes the old
+	// set, whether or not they were used).
+	Store(userID string, hashes []string) error
+	// Consume checks code against userID's unused recovery codes. If it
+	// matches, that code is removed so it cannot be used again and
+	// Consume returns true.
+	Consume(userID, code string) (bool, error)
+}
+
+// InMemoryRecoveryCodeStore is a RecoveryCodeStore backed by a guarded
+// map, suitable for local development and single-node deployments.
+type InMemoryRecoveryCodeStore struct {
+	mu    sync.Mutex
+	codes map[string][]string
+}
+
+// NewInMemoryRecoveryCodeStore returns an empty InMemoryRecoveryCodeStore.
+func NewInMemoryRecoveryCodeStore() *InMemoryRecoveryCodeStore {
+	return &InMemoryRecoveryCodeStore{codes: make(map[string][]string)}
+}
+
+func (s *InMemoryRecoveryCodeStore) Store(userID string, hashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.codes[userID] = hashes
+	return nil
+}
+
+func (s *InMemoryRecoveryCodeStore) Consume(userID, code string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hashes := s.codes[userID]
+	for i, hash := range hashes {
+		if verifyPassword(hash, code) {
+			s.codes[userID] = append(hashes[:i], hashes[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// generateRecoveryCodes returns recoveryCodeCount fresh recovery codes in
+// plaintext (to hand back to the caller once) along with their hashed
+// form (to persist via RecoveryCodeStore.Store).
+func generateRecoveryCodes() (plaintext, hashed []string, err error) {
+	plaintext = make([]string, recoveryCodeCount)
+	hashed = make([]string, recoveryCodeCount)
+	for i := range plaintext {
+		code, err := randomRecoveryCode()
+		if err != nil {
+			return nil, nil, err
+		}
+		hash, err := hashPassword(code)
+		if err != nil {
+			return nil, nil, err
+		}
+		plaintext[i] = code
+		hashed[i] = hash
+	}
+	return plaintext, hashed, nil
+}
+
+// randomRecoveryCode returns a code of the form "XXXXX-XXXXX" drawn from
+// crypto/rand, base32-encoded (Crockford-ish alphabet minus padding) to
+// keep it easy to type and unambiguous to read aloud.
+func randomRecoveryCode() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	encoded := strings.ToUpper(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf))
+	if len(encoded) < 10 {
+		return "", fmt.Errorf("mikhail: short recovery code encoding: %q", encoded)
+	}
+	encoded = encoded[:10]
+	return encoded[:5] + "-" + encoded[5:], nil
+}
@@ -0,0 +1,64 @@
+package mikhail
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// redactedKeyValuePattern matches "key=value" pairs, typically found in a
+// magic link or OAuth callback URL logged by something like LogNotifier,
+// whose key names something secret: an access/refresh token, an OTP or
+// password reset code, or a raw password. The value is masked, the key
+// is kept, so a redacted log line still reads as "...?token=[REDACTED]"
+// rather than losing the shape of what was logged entirely.
+var redactedKeyValuePattern = regexp.MustCompile(`(?i)\b(token|code|secret|password|refresh_token|access_token)=[^&\s"']+`)
+
+// bareSecretPattern matches a bare run of 24 or more base64url-alphabet
+// characters, long enough to rule out ordinary words or short IDs while
+// still catching an access token, refresh token, or JWT (whose header,
+// payload, and signature segments are each individually this long) that
+// ended up in a log line without a "key=" prefix to key off of.
+var bareSecretPattern = regexp.MustCompile(`[A-Za-z0-9_\-]{24,}`)
+
+// RedactSecrets scans s for token-shaped substrings - "key=value" pairs
+// whose key names a credential, and bare long opaque strings that look
+// like a token or JWT segment - and masks them, keeping enough of the
+// surrounding text that the redacted line is still useful for debugging.
+// It is a best-effort filter, not a guarantee: it exists so a log
+// statement that accidentally interpolates a token does not leak it in
+// full, not as a substitute for not logging tokens in the first place.
+func RedactSecrets(s string) string {
+	s = redactedKeyValuePattern.ReplaceAllStringFunc(s, func(match string) string {
+		key, _, _ := strings.Cut(match, "=")
+		return key + "=[REDACTED]"
+	})
+	return bareSecretPattern.ReplaceAllStringFunc(s, func(match string) string {
+		return match[:4] + "...[REDACTED]"
+	})
+}
+
+// Redacted wraps a secret value (a token, password, or code) so it can
+// be passed directly to log.Printf/fmt.Sprintf without exposing its
+// value: its String/Format both always print "[REDACTED]", regardless of
+// verb, so Redacted(token) is safe to interpolate with %v, %s, or %q.
+// This is Mikhail's equivalent of a zap.Field wrapper that masks its
+// value - there is no zap dependency here, so the masking has to happen
+// at the value's String method instead of a logging library's field
+// encoder.
+type Redacted string
+
+// String implements fmt.Stringer.
+func (Redacted) String() string { return "[REDACTED]" }
+
+// SafeLogf formats format/args the same as log.Printf, then runs
+// RedactSecrets over the result before writing it, so a token or secret
+// interpolated into format without being wrapped in Redacted is still
+// masked before it reaches the log. Call sites that already wrap every
+// secret argument in Redacted do not need this; it exists for call sites
+// formatting a value (e.g. a notification message containing a link)
+// whose contents are not fully under the caller's control.
+func SafeLogf(format string, args ...interface{}) {
+	log.Print(RedactSecrets(fmt.Sprintf(format, args...)))
+}
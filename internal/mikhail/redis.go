@@ -0,0 +1,358 @@
+package mikhail
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisClient is a minimal RESP2 client used by Redis-backed stores in
+// this package. It is hand-rolled rather than vendoring a client
+// library, since this environment cannot reach the module proxy; it only
+// implements the commands Mikhail's stores need (among them SET with a
+// TTL, GET, DEL, the batched MGET/DEL-many RedisTokenStorage's GetTokens
+// and DeleteTokens use, and the SADD/SREM/SMEMBERS set commands
+// RedisTokenStorage uses for its per-user and per-family indexes). It is
+// not a cluster client: it dials a single address and has no MOVED/ASK
+// redirection.
+type redisClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// dialRedis opens a connection to a Redis server at addr ("host:port").
+func dialRedis(addr string) (*redisClient, error) {
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return &redisClient{conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+func (c *redisClient) Close() error {
+	return c.conn.Close()
+}
+
+func (c *redisClient) writeCommand(args ...string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	_, err := io.WriteString(c.conn, b.String())
+	return err
+}
+
+func (c *redisClient) do(args ...string) (string, bool, error) {
+	if err := c.writeCommand(args...); err != nil {
+		return "", false, err
+	}
+	return c.readReply()
+}
+
+// doArray issues a command whose reply is a RESP array of bulk strings,
+// e.g. SMEMBERS.
+func (c *redisClient) doArray(args ...string) ([]string, error) {
+	if err := c.writeCommand(args...); err != nil {
+		return nil, err
+	}
+	return c.readArrayReply()
+}
+
+func (c *redisClient) readReply() (string, bool, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", false, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", false, fmt.Errorf("mikhail: empty redis reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		return line[1:], true, nil
+	case '-':
+		return "", false, fmt.Errorf("mikhail: redis error: %s", line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", false, err
+		}
+		if n < 0 {
+			return "", false, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return "", false, err
+		}
+		return string(buf[:n]), true, nil
+	case '*':
+		return "", false, fmt.Errorf("mikhail: unexpected redis array reply where a scalar was expected")
+	default:
+		return "", false, fmt.Errorf("mikhail: unsupported redis reply type %q", line[0])
+	}
+}
+
+// readArrayReply reads a RESP array of bulk strings, e.g. SMEMBERS.
+func (c *redisClient) readArrayReply() ([]string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) == 0 || line[0] != '*' {
+		return nil, fmt.Errorf("mikhail: expected redis array reply, got %q", line)
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, nil
+	}
+	items := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		item, ok, err := c.readReply()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// readPositionalArrayReply reads a RESP array reply the same way
+// readArrayReply does, but keeps a nil bulk string's position instead of
+// skipping it, e.g. for MGET where the caller needs to know which of the
+// requested keys were missing rather than just which values existed.
+func (c *redisClient) readPositionalArrayReply() ([]string, []bool, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) == 0 || line[0] != '*' {
+		return nil, nil, fmt.Errorf("mikhail: expected redis array reply, got %q", line)
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, nil, err
+	}
+	if n < 0 {
+		return nil, nil, nil
+	}
+	items := make([]string, n)
+	oks := make([]bool, n)
+	for i := 0; i < n; i++ {
+		item, ok, err := c.readReply()
+		if err != nil {
+			return nil, nil, err
+		}
+		items[i], oks[i] = item, ok
+	}
+	return items, oks, nil
+}
+
+// Ping sends a PING command and returns an error if the connection does
+// not respond with PONG.
+func (c *redisClient) Ping() error {
+	reply, _, err := c.do("PING")
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("mikhail: redis: unexpected PING reply %q", reply)
+	}
+	return nil
+}
+
+// Set stores value under key with an expiry of ttl.
+func (c *redisClient) Set(key, value string, ttl time.Duration) error {
+	seconds := int(ttl.Seconds())
+	if seconds <= 0 {
+		seconds = 1
+	}
+	_, _, err := c.do("SET", key, value, "EX", strconv.Itoa(seconds))
+	return err
+}
+
+// Get returns the value stored under key, or ok=false if it is unset or
+// expired.
+func (c *redisClient) Get(key string) (string, bool, error) {
+	return c.do("GET", key)
+}
+
+// Del removes key.
+func (c *redisClient) Del(key string) error {
+	_, _, err := c.do("DEL", key)
+	return err
+}
+
+// SAdd adds member to the set at key.
+func (c *redisClient) SAdd(key, member string) error {
+	_, _, err := c.do("SADD", key, member)
+	return err
+}
+
+// SRem removes member from the set at key.
+func (c *redisClient) SRem(key, member string) error {
+	_, _, err := c.do("SREM", key, member)
+	return err
+}
+
+// SMembers returns every member of the set at key.
+func (c *redisClient) SMembers(key string) ([]string, error) {
+	return c.doArray("SMEMBERS", key)
+}
+
+// MGet returns the value stored under each of keys in one round-trip,
+// preserving position: values[i] and oks[i] describe keys[i].
+func (c *redisClient) MGet(keys []string) (values []string, oks []bool, err error) {
+	if len(keys) == 0 {
+		return nil, nil, nil
+	}
+	if err := c.writeCommand(append([]string{"MGET"}, keys...)...); err != nil {
+		return nil, nil, err
+	}
+	return c.readPositionalArrayReply()
+}
+
+// DelMany removes every key in keys in one round-trip.
+func (c *redisClient) DelMany(keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, _, err := c.do(append([]string{"DEL"}, keys...)...)
+	return err
+}
+
+// Incr increments the integer at key by one, creating it at 0 first if
+// it does not exist, and returns the value after incrementing - the
+// same INCR semantics RedisBruteForceDetector relies on to count failed
+// sign-ins per identifier and per IP without a read-modify-write race
+// between two replicas incrementing the same key at once.
+func (c *redisClient) Incr(key string) (int64, error) {
+	reply, _, err := c.do("INCR", key)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(reply, 10, 64)
+}
+
+// SCard returns the number of members in the set at key.
+func (c *redisClient) SCard(key string) (int64, error) {
+	reply, _, err := c.do("SCARD", key)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(reply, 10, 64)
+}
+
+// Expire sets key's TTL to ttl, used to let Redis itself reap a key
+// (e.g. RedisBruteForceDetector's failure counters and per-IP
+// identifier set) that would otherwise sit
+// around forever for a key nobody calls again.
+func (c *redisClient) Expire(key string, ttl time.Duration) error {
+	seconds := int(ttl.Seconds())
+	if seconds <= 0 {
+		seconds = 1
+	}
+	_, _, err := c.do("EXPIRE", key, strconv.Itoa(seconds))
+	return err
+}
+
+// HMGet returns the value of each of fields in the hash at key in one
+// round-trip, preserving position the same way MGet does: values[i] and
+// oks[i] describe fields[i], used by RedisRateLimiter.RateLimitState to
+// read a token bucket's state without going through EVAL.
+func (c *redisClient) HMGet(key string, fields ...string) (values []string, oks []bool, err error) {
+	args := append([]string{"HMGET", key}, fields...)
+	if err := c.writeCommand(args...); err != nil {
+		return nil, nil, err
+	}
+	return c.readPositionalArrayReply()
+}
+
+// RPush appends value to the list at key, creating it if it does not
+// exist, used by RedisBlocklistStore to append audit entries in
+// chronological order.
+func (c *redisClient) RPush(key, value string) error {
+	_, _, err := c.do("RPUSH", key, value)
+	return err
+}
+
+// LTrim keeps only the elements of the list at key between indexes
+// start and stop inclusive (Redis's own negative-index convention
+// applies), used by RedisBlocklistStore to cap its audit list instead of
+// letting it grow forever.
+func (c *redisClient) LTrim(key string, start, stop int) error {
+	_, _, err := c.do("LTRIM", key, strconv.Itoa(start), strconv.Itoa(stop))
+	return err
+}
+
+// LRange returns the elements of the list at key between indexes start
+// and stop inclusive (Redis's own negative-index convention applies).
+func (c *redisClient) LRange(key string, start, stop int) ([]string, error) {
+	return c.doArray("LRANGE", key, strconv.Itoa(start), strconv.Itoa(stop))
+}
+
+// Eval runs script atomically via EVAL, the first numKeys entries of
+// keysAndArgs bound to KEYS and the rest to ARGV inside it. Every command
+// a script issues runs as a single atomic step from the server's point
+// of view, the same guarantee a MULTI/EXEC transaction gives, which is
+// what RedisTokenStorage.RotateToken relies on. Scripts are sent in full
+// on every call rather than cached server-side with SCRIPT LOAD/EVALSHA,
+// trading a little bandwidth for not having to track script identity
+// across reconnects in this minimal client.
+func (c *redisClient) Eval(script string, numKeys int, keysAndArgs []string) (string, bool, error) {
+	args := make([]string, 0, 3+len(keysAndArgs))
+	args = append(args, "EVAL", script, strconv.Itoa(numKeys))
+	args = append(args, keysAndArgs...)
+	return c.do(args...)
+}
+
+// ConfigSet issues CONFIG SET parameter value, e.g. to turn on
+// notify-keyspace-events for Subscribe's caller. It returns an error if
+// the server's ACL denies CONFIG to this connection, which a caller
+// subscribing to keyspace notifications should treat as "ask the
+// deployment to set it instead" rather than fatal.
+func (c *redisClient) ConfigSet(parameter, value string) error {
+	_, _, err := c.do("CONFIG", "SET", parameter, value)
+	return err
+}
+
+// Subscribe issues SUBSCRIBE for channel and consumes the server's
+// subscribe confirmation reply, leaving the connection in pub/sub mode:
+// every subsequent read on this connection must go through
+// ReceiveMessage, since a subscribed connection cannot issue ordinary
+// commands. Callers therefore dial a dedicated redisClient for a
+// subscription rather than sharing one from a connection pool.
+func (c *redisClient) Subscribe(channel string) error {
+	if err := c.writeCommand("SUBSCRIBE", channel); err != nil {
+		return err
+	}
+	_, err := c.readArrayReply()
+	return err
+}
+
+// ReceiveMessage blocks until a message arrives on a channel this
+// connection has Subscribed to, returning the channel it arrived on and
+// its payload. It only returns "message" replies; SUBSCRIBE's own
+// confirmation is consumed by Subscribe itself.
+func (c *redisClient) ReceiveMessage() (channel, payload string, err error) {
+	for {
+		reply, err := c.readArrayReply()
+		if err != nil {
+			return "", "", err
+		}
+		if len(reply) == 3 && reply[0] == "message" {
+			return reply[1], reply[2], nil
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package mikhail
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// SetupSIGHUPReload starts a background goroutine that calls every
+// reloader, in order, each time the process receives SIGHUP, logging any
+// error a reloader returns and continuing on to the rest rather than
+// stopping at the first failure - one provider's bad config should not
+// block the others from picking theirs up. Typical reloaders are
+// LogLevelController.ReloadFromEnv (if the level is set through
+// MIKHAIL_LOG_LEVEL),
+// AuthServer.ReloadOAuthProvidersFromEnv, and
+// TLSCertificateReloader.Reload; log level changes via
+// LogLevelController.SetLevel take effect immediately and need no
+// reloader of their own. None of this drops a live connection or
+// invalidates a session: every reloader swaps configuration state that
+// is read fresh per call or per handshake, not torn down and rebuilt.
+//
+// It returns a function that stops the signal watcher; callers that run
+// for the lifetime of the process can discard it.
+func SetupSIGHUPReload(reloaders ...func() error) func() {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-sighup:
+				log.Println("mikhail: SIGHUP received, reloading configuration")
+				for _, reload := range reloaders {
+					if err := reload(); err != nil {
+						log.Printf("mikhail: config reload: %v", err)
+					}
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sighup)
+		close(done)
+	}
+}
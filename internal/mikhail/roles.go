@@ -0,0 +1,111 @@
+package mikhail
+
+import (
+	"sync"
+	"time"
+)
+
+// Role is a coarse-grained permission grant on a Mikhail account. Roles
+// are stored with the user, embedded in issued access tokens, and
+// returned from VerifyToken so downstream services can authorize
+// requests without a separate lookup.
+type Role string
+
+const (
+	// RoleUser is held implicitly by every account and is never stored
+	// explicitly: RoleStore.Get returns it even for a userID with no
+	// recorded grants.
+	RoleUser Role = "user"
+	// RoleModerator is granted to staff who moderate user content.
+	RoleModerator Role = "moderator"
+	// RoleAdmin is granted to staff who administer Mikhail itself,
+	// including granting and revoking other accounts' roles.
+	RoleAdmin Role = "admin"
+	// RoleService is granted to machine accounts, e.g. those using the
+	// client-credentials grant.
+	RoleService Role = "service"
+)
+
+// RoleAuditEntry records one grant or revoke of a role, for GetRoleAudit
+// and for any downstream security review of who changed a user's
+// permissions and when.
+type RoleAuditEntry struct {
+	UserID  string
+	Role    Role
+	Granted bool
+	ActorID string
+	At      time.Time
+}
+
+// RoleStore looks up and changes the roles held by a user, and keeps an
+// audit trail of every change. Implementations must be safe for
+// concurrent use.
+type RoleStore interface {
+	// Get returns the roles held by userID, always including RoleUser
+	// even if nothing has been granted explicitly.
+	Get(userID string) ([]Role, error)
+	Grant(userID string, role Role, actorID string) error
+	Revoke(userID string, role Role, actorID string) error
+	Audit(userID string) ([]RoleAuditEntry, error)
+}
+
+// InMemoryRoleStore is a RoleStore backed by guarded maps, suitable for
+// local development and single-node deployments.
+type InMemoryRoleStore struct {
+	mu    sync.Mutex
+	roles map[string]map[Role]bool
+	audit map[string][]RoleAuditEntry
+}
+
+// NewInMemoryRoleStore returns an InMemoryRoleStore with no roles granted.
+func NewInMemoryRoleStore() *InMemoryRoleStore {
+	return &InMemoryRoleStore{
+		roles: make(map[string]map[Role]bool),
+		audit: make(map[string][]RoleAuditEntry),
+	}
+}
+
+func (s *InMemoryRoleStore) Get(userID string) ([]Role, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	roles := []Role{RoleUser}
+	for role := range s.roles[userID] {
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+func (s *InMemoryRoleStore) Grant(userID string, role Role, actorID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.roles[userID] == nil {
+		s.roles[userID] = make(map[Role]bool)
+	}
+	s.roles[userID][role] = true
+	s.audit[userID] = append(s.audit[userID], RoleAuditEntry{UserID: userID, Role: role, Granted: true, ActorID: actorID, At: time.Now()})
+	return nil
+}
+
+func (s *InMemoryRoleStore) Revoke(userID string, role Role, actorID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.roles[userID], role)
+	s.audit[userID] = append(s.audit[userID], RoleAuditEntry{UserID: userID, Role: role, Granted: false, ActorID: actorID, At: time.Now()})
+	return nil
+}
+
+func (s *InMemoryRoleStore) Audit(userID string) ([]RoleAuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]RoleAuditEntry(nil), s.audit[userID]...), nil
+}
+
+// rolesToStrings converts roles to their string form for embedding in
+// protobuf messages and token claims.
+func rolesToStrings(roles []Role) []string {
+	out := make([]string, len(roles))
+	for i, role := range roles {
+		out[i] = string(role)
+	}
+	return out
+}
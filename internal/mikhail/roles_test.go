@@ -0,0 +1,73 @@
+package mikhail
+
+import "testing"
+
+func TestInMemoryRoleStoreGetDefaultsToRoleUser(t *testing.T) {
+	s := NewInMemoryRoleStore()
+	roles, err := s.Get("user-1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(roles) != 1 || roles[0] != RoleUser {
+		t.Fatalf("Get on a user with no grants: got %v, want [RoleUser]", roles)
+	}
+}
+
+func TestInMemoryRoleStoreGrantAndRevoke(t *testing.T) {
+	s := NewInMemoryRoleStore()
+	if err := s.Grant("user-1", RoleAdmin, "actor-1"); err != nil {
+		t.Fatalf("Grant: %v", err)
+	}
+
+	roles, err := s.Get("user-1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !roleSetContains(roles, RoleAdmin) {
+		t.Fatalf("Get after Grant(RoleAdmin): got %v, want it to contain RoleAdmin", roles)
+	}
+
+	if err := s.Revoke("user-1", RoleAdmin, "actor-1"); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	roles, err = s.Get("user-1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if roleSetContains(roles, RoleAdmin) {
+		t.Fatalf("Get after Revoke(RoleAdmin): got %v, want it to no longer contain RoleAdmin", roles)
+	}
+}
+
+func TestInMemoryRoleStoreAuditRecordsGrantsAndRevokes(t *testing.T) {
+	s := NewInMemoryRoleStore()
+	if err := s.Grant("user-1", RoleModerator, "actor-1"); err != nil {
+		t.Fatalf("Grant: %v", err)
+	}
+	if err := s.Revoke("user-1", RoleModerator, "actor-2"); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+
+	entries, err := s.Audit("user-1")
+	if err != nil {
+		t.Fatalf("Audit: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Audit returned %d entries, want 2", len(entries))
+	}
+	if !entries[0].Granted || entries[0].ActorID != "actor-1" {
+		t.Fatalf("Audit entry 0 = %+v, want a grant by actor-1", entries[0])
+	}
+	if entries[1].Granted || entries[1].ActorID != "actor-2" {
+		t.Fatalf("Audit entry 1 = %+v, want a revoke by actor-2", entries[1])
+	}
+}
+
+func roleSetContains(roles []Role, target Role) bool {
+	for _, role := range roles {
+		if role == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package mikhail
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+)
+
+// ErrInvalidCredentials is returned when a phone number/password pair does
+// not match a known account.
+var ErrInvalidCredentials = errors.New("mikhail: invalid credentials")
+
+// ErrRefreshTokenReused is returned by RefreshToken when a refresh token
+// that was already rotated is presented again, which indicates the token
+// was stolen. The whole token family is revoked when this happens.
+var ErrRefreshTokenReused = errors.New("mikhail: refresh token reused, family revoked")
+
+// ErrDeviceFingerprintMismatch is returned by RefreshToken when the
+// device fingerprint presented does not match the one the refresh token
+// was issued with. The token family is revoked, since this usually means
+// the refresh token was stolen and is being replayed from another device.
+var ErrDeviceFingerprintMismatch = errors.New("mikhail: device fingerprint mismatch, family revoked")
+
+// ErrScopeNotAllowed is returned when a client-credentials request asks
+// for a scope the client is not registered for.
+var ErrScopeNotAllowed = errors.New("mikhail: requested scope not allowed for client")
+
+// ErrTokenNotFound is returned when a token presented to GetTokenMetadata
+// is neither a known refresh token nor a verifiable access token.
+var ErrTokenNotFound = errors.New("mikhail: token not found")
+
+// ErrPhoneAlreadyRegistered is returned by SignUp when the phone number
+// already has an account, instead of silently minting a second session
+// for it. Callers should direct the user to SignIn or password reset.
+var ErrPhoneAlreadyRegistered = errors.New("mikhail: phone number already registered, use sign-in or password reset instead")
+
+// scopesAllowed reports whether every scope in requested also appears in
+// allowed.
+func scopesAllowed(requested, allowed []string) bool {
+	allowedSet := make(map[string]struct{}, len(allowed))
+	for _, s := range allowed {
+		allowedSet[s] = struct{}{}
+	}
+	for _, s := range requested {
+		if _, ok := allowedSet[s]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// generateAuthToken returns a random, opaque, URL-safe token. It predates
+// JWT issuance and is kept around as the refresh-token format: refresh
+// tokens are never parsed by downstream services, so there is no need for
+// them to carry claims.
+func generateAuthToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
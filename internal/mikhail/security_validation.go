@@ -0,0 +1,93 @@
+package mikhail
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// defaultJWTSecret mirrors the development fallback cmd/mikhail falls
+// back to when MIKHAIL_JWT_SECRET is unset, so ValidateSecurityConfig
+// can refuse to let it reach a production deployment.
+const defaultJWTSecret = "dev-secret-do-not-use-in-production"
+
+// minJWTSecretBytes is the shortest HS256 secret ValidateSecurityConfig
+// accepts. RFC 7518 recommends an HMAC key be at least as long as the
+// hash output (32 bytes for SHA-256); Mikhail is more lenient than that
+// to avoid breaking an existing shorter secret outright, but still
+// refuses anything trivially brute-forceable.
+const minJWTSecretBytes = 16
+
+// oauthProviderEnvVars names the environment variables each optional
+// OAuth provider reads its credentials and redirect URL from, for
+// ValidateSecurityConfig to check consistently across providers.
+var oauthProviderEnvVars = []struct {
+	name        string
+	idVar       string
+	secretVar   string
+	redirectVar string
+}{
+	{"github", "MIKHAIL_GITHUB_CLIENT_ID", "MIKHAIL_GITHUB_CLIENT_SECRET", "MIKHAIL_GITHUB_REDIRECT_URI"},
+	{"google", "MIKHAIL_GOOGLE_CLIENT_ID", "MIKHAIL_GOOGLE_CLIENT_SECRET", "MIKHAIL_GOOGLE_REDIRECT_URI"},
+	{"vk", "MIKHAIL_VK_CLIENT_ID", "MIKHAIL_VK_CLIENT_SECRET", "MIKHAIL_VK_REDIRECT_URI"},
+}
+
+// ValidateSecurityConfig inspects security-critical configuration read
+// directly from the environment - the JWT signing secret and every
+// optional OAuth provider's credentials and redirect URL - and returns
+// an actionable error for the first problem it finds: a missing or
+// placeholder secret in production, a secret shorter than
+// minJWTSecretBytes, a provider with one of ClientID/ClientSecret set
+// but not the other, or a redirect URL that does not parse as an
+// absolute http(s) URL. Call it once at startup, before anything else,
+// so a misconfigured deployment fails immediately instead of serving
+// traffic with a weak or broken credential.
+func ValidateSecurityConfig(environment EnvironmentConfig) error {
+	if err := validateJWTSecret(environment); err != nil {
+		return err
+	}
+	for _, p := range oauthProviderEnvVars {
+		if err := validateOAuthProviderEnv(p.name, p.idVar, p.secretVar, p.redirectVar); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateJWTSecret(environment EnvironmentConfig) error {
+	secret := os.Getenv("MIKHAIL_JWT_SECRET")
+	if secret == "" || secret == defaultJWTSecret {
+		if environment.Environment == EnvironmentProduction {
+			return fmt.Errorf("mikhail: refusing to start in %s with MIKHAIL_JWT_SECRET unset (it would fall back to the publicly-known development secret)", environment.Environment)
+		}
+		return nil
+	}
+	if len(secret) < minJWTSecretBytes {
+		return fmt.Errorf("mikhail: MIKHAIL_JWT_SECRET is %d bytes, shorter than the %d-byte minimum", len(secret), minJWTSecretBytes)
+	}
+	return nil
+}
+
+func validateOAuthProviderEnv(name, idVar, secretVar, redirectVar string) error {
+	id := os.Getenv(idVar)
+	secret := os.Getenv(secretVar)
+	if (id == "") != (secret == "") {
+		return fmt.Errorf("mikhail: %s oauth: %s and %s must both be set, or both unset", name, idVar, secretVar)
+	}
+	if id == "" {
+		return nil
+	}
+
+	redirect := os.Getenv(redirectVar)
+	if redirect == "" {
+		return fmt.Errorf("mikhail: %s oauth: %s is set but %s is empty", name, idVar, redirectVar)
+	}
+	parsed, err := url.Parse(redirect)
+	if err != nil || !parsed.IsAbs() || parsed.Host == "" {
+		return fmt.Errorf("mikhail: %s oauth: %s=%q is not a valid absolute URL", name, redirectVar, redirect)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("mikhail: %s oauth: %s=%q must use http or https", name, redirectVar, redirect)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package mikhail
+
+import (
+	"log"
+	"net/http"
+	"os"
+)
+
+// ServerConfig configures Mikhail's main HTTP listener: the address
+// AuthenticateService's RPCs are actually served from (see
+// NewGatewayMux). Unlike HealthServerConfig and DebugServerConfig, this
+// is Mikhail's primary listener - a deployment that never starts it has
+// no way for a client to reach the service at all.
+type ServerConfig struct {
+	// Addr is the address the main server listens on. Defaults to
+	// ":9443".
+	Addr string
+}
+
+// DefaultServerConfig returns the main server bound to ":9443".
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{Addr: ":9443"}
+}
+
+// LoadServerConfig builds a ServerConfig from MIKHAIL_ADDR, falling back
+// to DefaultServerConfig when unset.
+func LoadServerConfig() (ServerConfig, error) {
+	cfg := DefaultServerConfig()
+	if raw := os.Getenv("MIKHAIL_ADDR"); raw != "" {
+		cfg.Addr = raw
+	}
+	return cfg, nil
+}
+
+// NewMainServer wraps handler in an *http.Server listening on cfg.Addr.
+// The returned server is not started; call StartMainServer.
+func NewMainServer(cfg ServerConfig, handler http.Handler) *http.Server {
+	return &http.Server{Addr: cfg.Addr, Handler: handler}
+}
+
+// StartMainServer starts srv in its own goroutine, the same fire-and-log
+// pattern StartHealthServer and StartDebugServer use.
+func StartMainServer(srv *http.Server) {
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("mikhail: main server on %s stopped: %v", srv.Addr, err)
+		}
+	}()
+}
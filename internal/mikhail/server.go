@@ -0,0 +1,1824 @@
+package mikhail
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TwelveFacedJanus/Kingdom-System/internal/authpb"
+)
+
+// credentialStore looks up and registers phone/password accounts. It is
+// intentionally minimal until a real user store is wired in.
+type credentialStore interface {
+	verify(phone, password string) (userID string, ok bool)
+	create(phone, password string) (userID string, err error)
+	// lookup resolves a phone number to a user ID without checking a
+	// password, used by flows like password reset that must find the
+	// account before the caller has proven they own it.
+	lookup(phone string) (userID string, ok bool)
+	// setPassword overwrites the password on record for userID, used once
+	// a password reset token has been validated.
+	setPassword(userID, password string) error
+	// verifyByID checks password against the account on record for
+	// userID, used by ChangePassword where the caller already has an
+	// authenticated session and a user ID rather than a phone number.
+	verifyByID(userID, password string) bool
+	// verifyIdentifier is like verify, but accepts a phone number, email
+	// address, or username rather than only a phone number, so web users
+	// who never registered a phone can still sign in.
+	verifyIdentifier(identifier, password string) (userID string, ok bool)
+	// lookupIdentifier is like lookup, but accepts a phone number, email
+	// address, or username, used by flows like RequestMagicLink that must
+	// find the account without a password.
+	lookupIdentifier(identifier string) (userID string, ok bool)
+	// createWithID is like create, but registers the account under an
+	// already-existing userID instead of generating a fresh one, used by
+	// UpgradeGuest to turn a guest session into a full account without
+	// changing the ID its tokens were issued under.
+	createWithID(userID, phone, password string) error
+	// hasCredential reports whether userID has a phone/password account
+	// on record at all, independent of what that password is. Used by
+	// UnlinkProvider to decide whether removing a linked OAuth identity
+	// would leave the account with no way to sign in.
+	hasCredential(userID string) bool
+}
+
+// AuthServer implements AuthenticateService (SignIn, SignUp,
+// RefreshToken and the rest of its RPCs). It is the Go-level counterpart of the gRPC service
+// described in proto/kingdom/auth/v1/auth.proto.
+type AuthServer struct {
+	issuer      AccessTokenIssuer
+	storage     TokenStorage
+	credentials credentialStore
+	clients     ClientStore
+	apiKeys     APIKeyStore
+	config      Config
+	// idTokenIssuer signs OIDC ID tokens. ID tokens are always JWTs per
+	// the OIDC spec, regardless of which AccessTokenIssuer is in use, so
+	// this is kept separate from issuer. nil disables ID token issuance.
+	idTokenIssuer       *JWTIssuer
+	passwordResets      PasswordResetStorage
+	notifier            Notifier
+	otps                OTPStore
+	emailVerifications  EmailVerificationStore
+	totp                TOTPStore
+	signInChallenges    *signInChallengeStore
+	webAuthnCredentials WebAuthnCredentialStore
+	webAuthnChallenges  WebAuthnChallengeStore
+	recoveryCodes       RecoveryCodeStore
+	accountDeletions    AccountDeletionStore
+	yandexRevoker       YandexOAuthRevoker
+	// yandexOAuth is nil unless WithYandexOAuthClient is used, which
+	// disables OAuth2Callback rather than defaulting it to a client with
+	// no credentials.
+	yandexOAuth     *YandexOAuthClient
+	oauthIdentities OAuthIdentityStore
+	oauthProviders  *OAuthProviderRegistry
+	roles           RoleStore
+	profiles        ProfileStore
+	magicLinks      MagicLinkStore
+	guests          GuestStore
+	suspensions     SuspensionStore
+	pkce            PKCEStore
+	// bruteForce is nil by default, disabling brute-force/credential-
+	// stuffing detection entirely; see WithBruteForceDetector.
+	bruteForce     BruteForceDetector
+	trustedProxies TrustedProxyConfig
+	// auditLog is nil by default, disabling the security audit trail
+	// entirely; see WithAuditLog.
+	auditLog AuditLog
+	// sessionAnomaly is disabled by default; see WithSessionAnomalyConfig.
+	sessionAnomaly SessionAnomalyConfig
+}
+
+// NewAuthServer wires a JWTIssuer, TokenStorage and Config into an
+// AuthServer.
+func NewAuthServer(issuer AccessTokenIssuer, storage TokenStorage, config Config) *AuthServer {
+	oauthProviders := NewOAuthProviderRegistry()
+	return &AuthServer{
+		issuer:              issuer,
+		storage:             storage,
+		credentials:         newInMemoryCredentialStore(),
+		clients:             NewInMemoryClientStore(),
+		apiKeys:             NewInMemoryAPIKeyStore(),
+		config:              config,
+		passwordResets:      NewInMemoryPasswordResetStorage(),
+		notifier:            LogNotifier{},
+		otps:                NewInMemoryOTPStore(),
+		emailVerifications:  NewInMemoryEmailVerificationStore(),
+		totp:                NewInMemoryTOTPStore(),
+		signInChallenges:    newSignInChallengeStore(),
+		webAuthnCredentials: NewInMemoryWebAuthnCredentialStore(),
+		webAuthnChallenges:  NewInMemoryWebAuthnChallengeStore(),
+		recoveryCodes:       NewInMemoryRecoveryCodeStore(),
+		accountDeletions:    NewInMemoryAccountDeletionStore(),
+		yandexRevoker:       StoredYandexOAuthRevoker{Storage: storage, Providers: oauthProviders},
+		oauthIdentities:     NewInMemoryOAuthIdentityStore(),
+		oauthProviders:      oauthProviders,
+		roles:               NewInMemoryRoleStore(),
+		profiles:            NewInMemoryProfileStore(),
+		magicLinks:          NewInMemoryMagicLinkStore(),
+		guests:              NewInMemoryGuestStore(),
+		suspensions:         NewInMemorySuspensionStore(),
+		pkce:                NewInMemoryPKCEStore(),
+		trustedProxies:      DefaultTrustedProxyConfig(),
+		sessionAnomaly:      DefaultSessionAnomalyConfig(),
+	}
+}
+
+// WithSuspensionStore replaces the default in-memory SuspensionStore.
+func (s *AuthServer) WithSuspensionStore(suspensions SuspensionStore) *AuthServer {
+	s.suspensions = suspensions
+	return s
+}
+
+// WithRoleStore replaces the default in-memory RoleStore.
+func (s *AuthServer) WithRoleStore(roles RoleStore) *AuthServer {
+	s.roles = roles
+	return s
+}
+
+// WithProfileStore replaces the default in-memory ProfileStore.
+func (s *AuthServer) WithProfileStore(profiles ProfileStore) *AuthServer {
+	s.profiles = profiles
+	return s
+}
+
+// WithMagicLinkStore replaces the default in-memory MagicLinkStore.
+func (s *AuthServer) WithMagicLinkStore(magicLinks MagicLinkStore) *AuthServer {
+	s.magicLinks = magicLinks
+	return s
+}
+
+// WithGuestStore replaces the default in-memory GuestStore.
+func (s *AuthServer) WithGuestStore(guests GuestStore) *AuthServer {
+	s.guests = guests
+	return s
+}
+
+// WithClientStore replaces the default in-memory ClientStore, e.g. to back
+// the client-credentials grant with a real service-account registry.
+func (s *AuthServer) WithClientStore(clients ClientStore) *AuthServer {
+	s.clients = clients
+	return s
+}
+
+// TokenFormat names the access token format this server was configured
+// with ("JWT-HS256", "JWT-RS256", or "PASETO"), for GetServerInfo to
+// report what a caller parsing one of this server's tokens needs to
+// handle. It reports "unknown" for an AccessTokenIssuer this package
+// does not recognize, e.g. a caller's own test double.
+func (s *AuthServer) TokenFormat() string {
+	switch issuer := s.issuer.(type) {
+	case *JWTIssuer:
+		return "JWT-" + string(issuer.method)
+	case *PASETOIssuer:
+		return "PASETO"
+	default:
+		return "unknown"
+	}
+}
+
+// OAuthProviderNames returns the names of every OAuth provider
+// registered on this server, for GetServerInfo to report.
+func (s *AuthServer) OAuthProviderNames() []string {
+	return s.oauthProviders.Names()
+}
+
+// OAuthConfigComplete reports whether every OAuth client this server was
+// given has both a ClientID and ClientSecret set, for ReadinessHandler
+// to check. A server with no OAuth client configured at all (yandexOAuth
+// nil) is trivially complete: there is nothing half-configured to fail
+// readiness over.
+func (s *AuthServer) OAuthConfigComplete() bool {
+	if s.yandexOAuth == nil {
+		return true
+	}
+	return s.yandexOAuth.ClientID != "" && s.yandexOAuth.ClientSecret != ""
+}
+
+// ReloadOAuthProvidersFromEnv re-reads GitHub, Google, and VK OAuth
+// credentials from the environment and re-registers any provider whose
+// client ID is set, replacing its previous registration. A provider
+// whose client ID is unset is left untouched rather than unregistered,
+// so clearing an env var by accident does not silently disable a
+// provider that was already configured. This is the OAuth half of
+// SIGHUP-triggered config reload (see SetupSIGHUPReload): registry
+// updates are made under OAuthProviderRegistry's own mutex, so in-flight
+// calls to Get never see a partially-updated provider.
+func (s *AuthServer) ReloadOAuthProvidersFromEnv() error {
+	if c := NewGitHubOAuthClientFromEnv(); c.ClientID != "" {
+		s.oauthProviders.Register(c)
+	}
+	if c := NewGoogleOAuthClientFromEnv(); c.ClientID != "" {
+		s.oauthProviders.Register(c)
+	}
+	if c := NewVKOAuthClientFromEnv(); c.ClientID != "" {
+		s.oauthProviders.Register(c)
+	}
+	return nil
+}
+
+// SigningKeyAvailable reports whether this server has a usable access
+// token signing/encryption key, for ReadinessHandler to check. Every
+// AccessTokenIssuer constructor validates its key material up front (see
+// NewHS256Issuer, NewRS256Issuer, NewPASETOIssuer), so this is only ever
+// false for a zero-value AuthServer with no issuer at all.
+func (s *AuthServer) SigningKeyAvailable() bool {
+	return s.issuer != nil
+}
+
+// WithIDTokenIssuer enables OIDC ID token issuance on SignIn/SignUp.
+func (s *AuthServer) WithIDTokenIssuer(issuer *JWTIssuer) *AuthServer {
+	s.idTokenIssuer = issuer
+	return s
+}
+
+// WithCredentialStore replaces the default in-memory credentialStore, e.g.
+// to back SignIn/SignUp with PostgresUserStore instead of the placeholder
+// used for early development.
+func (s *AuthServer) WithCredentialStore(credentials credentialStore) *AuthServer {
+	s.credentials = credentials
+	return s
+}
+
+// WithPasswordResetStorage replaces the default in-memory
+// PasswordResetStorage.
+func (s *AuthServer) WithPasswordResetStorage(storage PasswordResetStorage) *AuthServer {
+	s.passwordResets = storage
+	return s
+}
+
+// WithNotifier replaces the default LogNotifier, e.g. to deliver password
+// reset tokens and OTP codes over real SMS or email.
+func (s *AuthServer) WithNotifier(notifier Notifier) *AuthServer {
+	s.notifier = notifier
+	return s
+}
+
+// WithBruteForceDetector enables brute-force and credential-stuffing
+// detection on SignIn, disabled by default (a nil BruteForceDetector).
+// See NewBruteForceDetectorFromEnv.
+func (s *AuthServer) WithBruteForceDetector(detector BruteForceDetector) *AuthServer {
+	s.bruteForce = detector
+	return s
+}
+
+// WithTrustedProxyConfig replaces the default (trust nothing)
+// TrustedProxyConfig used to resolve the caller's client IP for brute-
+// force detection, the same config RateLimitInterceptor and
+// BlocklistInterceptor use for theirs.
+func (s *AuthServer) WithTrustedProxyConfig(cfg TrustedProxyConfig) *AuthServer {
+	s.trustedProxies = cfg
+	return s
+}
+
+// WithAuditLog enables the security audit trail (sign-in success and
+// failure, token refresh, session revocation, OAuth linking, and
+// password changes recorded via recordAuditEvent), disabled by default
+// (a nil AuditLog).
+func (s *AuthServer) WithAuditLog(auditLog AuditLog) *AuthServer {
+	s.auditLog = auditLog
+	return s
+}
+
+// WithSessionAnomalyConfig replaces the default (disabled) session
+// anomaly detection config RefreshToken checks every rotation against.
+// See LoadSessionAnomalyConfig.
+func (s *AuthServer) WithSessionAnomalyConfig(cfg SessionAnomalyConfig) *AuthServer {
+	s.sessionAnomaly = cfg
+	return s
+}
+
+// WithOTPStore replaces the default in-memory OTPStore, e.g. to back
+// SendOtp/VerifyOtp with RedisOTPStore for a multi-instance deployment.
+func (s *AuthServer) WithOTPStore(otps OTPStore) *AuthServer {
+	s.otps = otps
+	return s
+}
+
+// WithEmailVerificationStore replaces the default in-memory
+// EmailVerificationStore.
+func (s *AuthServer) WithEmailVerificationStore(store EmailVerificationStore) *AuthServer {
+	s.emailVerifications = store
+	return s
+}
+
+// WithTOTPStore replaces the default in-memory TOTPStore.
+func (s *AuthServer) WithTOTPStore(totp TOTPStore) *AuthServer {
+	s.totp = totp
+	return s
+}
+
+// WithWebAuthnCredentialStore replaces the default in-memory
+// WebAuthnCredentialStore.
+func (s *AuthServer) WithWebAuthnCredentialStore(store WebAuthnCredentialStore) *AuthServer {
+	s.webAuthnCredentials = store
+	return s
+}
+
+// WithWebAuthnChallengeStore replaces the default in-memory
+// WebAuthnChallengeStore, e.g. with RedisWebAuthnChallengeStore so
+// registration/assertion challenges survive across instances behind a
+// load balancer.
+func (s *AuthServer) WithWebAuthnChallengeStore(store WebAuthnChallengeStore) *AuthServer {
+	s.webAuthnChallenges = store
+	return s
+}
+
+// WithRecoveryCodeStore replaces the default in-memory RecoveryCodeStore.
+func (s *AuthServer) WithRecoveryCodeStore(store RecoveryCodeStore) *AuthServer {
+	s.recoveryCodes = store
+	return s
+}
+
+// WithAccountDeletionStore replaces the default in-memory
+// AccountDeletionStore.
+func (s *AuthServer) WithAccountDeletionStore(store AccountDeletionStore) *AuthServer {
+	s.accountDeletions = store
+	return s
+}
+
+// WithYandexOAuthRevoker replaces the default StoredYandexOAuthRevoker,
+// e.g. with LogYandexOAuthRevoker for local development.
+func (s *AuthServer) WithYandexOAuthRevoker(revoker YandexOAuthRevoker) *AuthServer {
+	s.yandexRevoker = revoker
+	return s
+}
+
+// WithYandexOAuthClient enables OAuth2Callback by giving AuthServer a
+// client to exchange authorization codes with Yandex. nil (the default)
+// leaves OAuth2Callback disabled.
+func (s *AuthServer) WithYandexOAuthClient(client *YandexOAuthClient) *AuthServer {
+	s.yandexOAuth = client
+	s.oauthProviders.Register(client)
+	return s
+}
+
+// WithOAuthProvider registers an additional OAuthProvider (e.g. Google,
+// GitHub) under its Name in AuthServer's registry.
+func (s *AuthServer) WithOAuthProvider(provider OAuthProvider) *AuthServer {
+	s.oauthProviders.Register(provider)
+	return s
+}
+
+// WithOAuthIdentityStore replaces the default in-memory
+// OAuthIdentityStore.
+func (s *AuthServer) WithOAuthIdentityStore(store OAuthIdentityStore) *AuthServer {
+	s.oauthIdentities = store
+	return s
+}
+
+// WithPKCEStore replaces the default in-memory PKCEStore.
+func (s *AuthServer) WithPKCEStore(store PKCEStore) *AuthServer {
+	s.pkce = store
+	return s
+}
+
+// SignIn verifies a password against either PhoneNumber (for backward
+// compatibility) or Identifier, which Identifier takes precedence over
+// and which may be a phone number, email address, or username,
+// classified and normalized by NormalizeIdentifier. It issues a fresh
+// token pair on success. If the account has confirmed TOTP enrollment,
+// SignIn instead returns an MfaChallenge that CompleteSignInChallenge
+// must redeem with a valid code before any tokens are issued.
+func (s *AuthServer) SignIn(ctx context.Context, req *authpb.SignInRequest) (*authpb.SignInResponse, error) {
+	identifier := req.Identifier
+	if identifier == "" {
+		identifier = req.PhoneNumber
+	}
+
+	var userID string
+	var ok bool
+	if req.Identifier == "" {
+		userID, ok = s.credentials.verify(req.PhoneNumber, req.Password)
+	} else {
+		userID, ok = s.credentials.verifyIdentifier(req.Identifier, req.Password)
+	}
+	if !ok {
+		if s.bruteForce != nil {
+			// Best-effort: a detector error (e.g. Redis unreachable) must
+			// never turn an ordinary failed sign-in into a 500.
+			_ = s.bruteForce.RecordFailure(identifier, ClientIP(ctx, s.trustedProxies))
+		}
+		s.recordAuditEvent(ctx, AuditEventSignInFailure, identifier, "", false)
+		return nil, ErrInvalidCredentials
+	}
+	if _, deleted, err := s.accountDeletions.Get(userID); err != nil {
+		return nil, err
+	} else if deleted {
+		return nil, ErrAccountDeleted
+	}
+
+	params := sessionParams{
+		UserID:            userID,
+		Phone:             identifier,
+		Scopes:            req.Scopes,
+		DeviceID:          req.DeviceID,
+		DeviceFingerprint: req.DeviceFingerprint,
+		RememberMe:        req.RememberMe,
+		TenantID:          tenantIDFromContextString(ctx),
+		IP:                ClientIP(ctx, s.trustedProxies),
+		UserAgent:         userAgentFromContext(ctx),
+	}
+
+	if enrollment, ok, err := s.totp.Get(userID); err != nil {
+		return nil, err
+	} else if ok && enrollment.Confirmed {
+		challenge, err := generateAuthToken()
+		if err != nil {
+			return nil, err
+		}
+		s.signInChallenges.store(challenge, pendingSignIn{
+			params:    params,
+			audience:  req.Audience,
+			expiresAt: time.Now().Add(s.config.SignInChallengeTTL),
+		})
+		return &authpb.SignInResponse{MfaChallenge: challenge}, nil
+	}
+
+	result, err := s.issueTokenFamily(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachIDToken(result, userID, identifier, req.Audience); err != nil {
+		return nil, err
+	}
+	s.recordAuditEvent(ctx, AuditEventSignInSuccess, userID, "", true)
+	return &authpb.SignInResponse{Result: result}, nil
+}
+
+// CompleteSignInChallenge redeems an MfaChallenge from SignIn with
+// either a TOTP code or, if the account owner has lost their
+// authenticator, one of their one-time recovery codes, issuing the
+// token pair SignIn would have issued directly if 2FA were not enabled.
+func (s *AuthServer) CompleteSignInChallenge(ctx context.Context, req *authpb.CompleteSignInChallengeRequest) (*authpb.CompleteSignInChallengeResponse, error) {
+	pending, ok := s.signInChallenges.take(req.ChallengeToken)
+	if !ok || pending.expired(time.Now()) {
+		return nil, ErrSignInChallengeInvalid
+	}
+
+	if req.RecoveryCode != "" {
+		consumed, err := s.recoveryCodes.Consume(pending.params.UserID, req.RecoveryCode)
+		if err != nil {
+			return nil, err
+		}
+		if !consumed {
+			return nil, ErrRecoveryCodeInvalid
+		}
+	} else {
+		enrollment, ok, err := s.totp.Get(pending.params.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok || !enrollment.Confirmed {
+			return nil, ErrTOTPNotEnrolled
+		}
+		if !validateTOTP(enrollment.Secret, req.Code, s.config.TOTPSkewPeriods, time.Now()) {
+			return nil, ErrTOTPCodeInvalid
+		}
+	}
+
+	result, err := s.issueTokenFamily(pending.params)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachIDToken(result, pending.params.UserID, pending.params.Phone, pending.audience); err != nil {
+		return nil, err
+	}
+	return &authpb.CompleteSignInChallengeResponse{Result: result}, nil
+}
+
+// EnrollTotp generates a new TOTP secret for userID and stores it
+// unconfirmed. The account is not challenged for 2FA on SignIn until
+// ConfirmTotp proves the secret was loaded into an authenticator app.
+func (s *AuthServer) EnrollTotp(ctx context.Context, req *authpb.EnrollTotpRequest) (*authpb.EnrollTotpResponse, error) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.totp.Store(req.UserID, TOTPEnrollment{Secret: secret}); err != nil {
+		return nil, err
+	}
+	return &authpb.EnrollTotpResponse{
+		Secret:          secret,
+		ProvisioningUri: totpProvisioningURI(req.UserID, secret),
+	}, nil
+}
+
+// ConfirmTotp proves userID's authenticator app has the secret from
+// EnrollTotp loaded, enabling the SignIn 2FA challenge for the account.
+// It also issues a fresh set of one-time recovery codes, since an
+// account with no way to sign in besides a phone that could be lost or
+// broken is not actually protected by 2FA, it is locked by it.
+func (s *AuthServer) ConfirmTotp(ctx context.Context, req *authpb.ConfirmTotpRequest) (*authpb.ConfirmTotpResponse, error) {
+	enrollment, ok, err := s.totp.Get(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrTOTPNotEnrolled
+	}
+	if !validateTOTP(enrollment.Secret, req.Code, s.config.TOTPSkewPeriods, time.Now()) {
+		return nil, ErrTOTPCodeInvalid
+	}
+
+	enrollment.Confirmed = true
+	if err := s.totp.Store(req.UserID, enrollment); err != nil {
+		return nil, err
+	}
+
+	plaintext, hashed, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.recoveryCodes.Store(req.UserID, hashed); err != nil {
+		return nil, err
+	}
+	return &authpb.ConfirmTotpResponse{RecoveryCodes: plaintext}, nil
+}
+
+// RegenerateRecoveryCodes discards userID's existing recovery codes and
+// issues a fresh set, e.g. after the account owner has used most of
+// them. A valid TOTP code is required, the same as DisableTotp, so an
+// attacker with only an access token cannot mint new recovery codes.
+func (s *AuthServer) RegenerateRecoveryCodes(ctx context.Context, req *authpb.RegenerateRecoveryCodesRequest) (*authpb.RegenerateRecoveryCodesResponse, error) {
+	enrollment, ok, err := s.totp.Get(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || !enrollment.Confirmed {
+		return nil, ErrTOTPNotEnrolled
+	}
+	if !validateTOTP(enrollment.Secret, req.Code, s.config.TOTPSkewPeriods, time.Now()) {
+		return nil, ErrTOTPCodeInvalid
+	}
+
+	plaintext, hashed, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.recoveryCodes.Store(req.UserID, hashed); err != nil {
+		return nil, err
+	}
+	return &authpb.RegenerateRecoveryCodesResponse{RecoveryCodes: plaintext}, nil
+}
+
+// DisableTotp removes userID's TOTP enrollment, requiring a valid code
+// first so an attacker who merely has the access token cannot strip 2FA
+// off an account.
+func (s *AuthServer) DisableTotp(ctx context.Context, req *authpb.DisableTotpRequest) (*authpb.DisableTotpResponse, error) {
+	enrollment, ok, err := s.totp.Get(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrTOTPNotEnrolled
+	}
+	if !validateTOTP(enrollment.Secret, req.Code, s.config.TOTPSkewPeriods, time.Now()) {
+		return nil, ErrTOTPCodeInvalid
+	}
+
+	if err := s.totp.Delete(req.UserID); err != nil {
+		return nil, err
+	}
+	return &authpb.DisableTotpResponse{}, nil
+}
+
+// SignUp creates a new account and issues its first token pair. The phone
+// number must already have a verified OTP on file from VerifyOtp, since
+// identity in Mikhail is phone-based.
+func (s *AuthServer) SignUp(ctx context.Context, req *authpb.SignUpRequest) (*authpb.SignUpResponse, error) {
+	otp, ok, err := s.otps.Get(req.PhoneNumber)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || !otp.Verified || otp.Expired(time.Now()) {
+		return nil, ErrPhoneNotVerified
+	}
+	if _, exists := s.credentials.lookup(req.PhoneNumber); exists {
+		return nil, ErrPhoneAlreadyRegistered
+	}
+
+	userID, err := s.credentials.create(req.PhoneNumber, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.otps.Delete(req.PhoneNumber); err != nil {
+		return nil, err
+	}
+	result, err := s.issueTokenFamily(sessionParams{
+		UserID:            userID,
+		Phone:             req.PhoneNumber,
+		Scopes:            req.Scopes,
+		DeviceID:          req.DeviceID,
+		DeviceFingerprint: req.DeviceFingerprint,
+		RememberMe:        req.RememberMe,
+		TenantID:          tenantIDFromContextString(ctx),
+		IP:                ClientIP(ctx, s.trustedProxies),
+		UserAgent:         userAgentFromContext(ctx),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachIDToken(result, userID, req.PhoneNumber, req.Audience); err != nil {
+		return nil, err
+	}
+	return &authpb.SignUpResponse{Result: result}, nil
+}
+
+// SendOtp generates a 6-digit verification code for a phone number and
+// delivers it through the configured Notifier, to be redeemed by
+// VerifyOtp before SignUp will accept that phone number.
+func (s *AuthServer) SendOtp(ctx context.Context, req *authpb.SendOtpRequest) (*authpb.SendOtpResponse, error) {
+	code, err := generateOTPCode()
+	if err != nil {
+		return nil, err
+	}
+	codeHash, err := hashPassword(code)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.otps.Store(req.PhoneNumber, OTPInfo{
+		Phone:       req.PhoneNumber,
+		CodeHash:    codeHash,
+		ExpiresAt:   time.Now().Add(s.config.OTPTTL),
+		MaxAttempts: s.config.OTPMaxAttempts,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := s.notifier.Notify(req.PhoneNumber, "Your Mikhail verification code: "+code); err != nil {
+		return nil, err
+	}
+	return &authpb.SendOtpResponse{}, nil
+}
+
+// VerifyOtp checks a code sent by SendOtp. A correct code before the
+// attempt limit is reached marks the phone number verified, which SignUp
+// requires before it will create an account for it.
+func (s *AuthServer) VerifyOtp(ctx context.Context, req *authpb.VerifyOtpRequest) (*authpb.VerifyOtpResponse, error) {
+	info, ok, err := s.otps.Get(req.PhoneNumber)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || info.Expired(time.Now()) {
+		return nil, ErrOTPInvalid
+	}
+	if info.Attempts >= info.MaxAttempts {
+		return nil, ErrOTPAttemptsExceeded
+	}
+
+	if !verifyPassword(info.CodeHash, req.Code) {
+		if err := s.otps.IncrementAttempts(req.PhoneNumber); err != nil {
+			return nil, err
+		}
+		return nil, ErrOTPInvalid
+	}
+
+	info.Verified = true
+	if err := s.otps.Store(req.PhoneNumber, info); err != nil {
+		return nil, err
+	}
+	return &authpb.VerifyOtpResponse{Verified: true}, nil
+}
+
+// RequestEmailVerification issues a single-use verification token for
+// userID's email address and delivers it through the configured Notifier.
+// It is used for Yandex-linked and other email-based accounts, which have
+// no phone number for SendOtp to confirm instead.
+func (s *AuthServer) RequestEmailVerification(ctx context.Context, req *authpb.RequestEmailVerificationRequest) (*authpb.RequestEmailVerificationResponse, error) {
+	token, err := generateAuthToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.emailVerifications.StoreToken(token, EmailVerificationTokenInfo{
+		UserID:    req.UserID,
+		Email:     req.Email,
+		ExpiresAt: time.Now().Add(s.config.EmailVerificationTokenTTL),
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := s.notifier.Notify(req.Email, "Verify your Mikhail email: "+token); err != nil {
+		return nil, err
+	}
+	return &authpb.RequestEmailVerificationResponse{}, nil
+}
+
+// VerifyEmail redeems a token issued by RequestEmailVerification, setting
+// email_verified for the account it was issued to.
+func (s *AuthServer) VerifyEmail(ctx context.Context, req *authpb.VerifyEmailRequest) (*authpb.VerifyEmailResponse, error) {
+	info, ok, err := s.emailVerifications.GetToken(req.Token)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || info.Expired(time.Now()) {
+		return nil, ErrEmailVerificationTokenInvalid
+	}
+
+	if err := s.emailVerifications.MarkVerified(info.UserID); err != nil {
+		return nil, err
+	}
+	if err := s.emailVerifications.DeleteToken(req.Token); err != nil {
+		return nil, err
+	}
+	return &authpb.VerifyEmailResponse{}, nil
+}
+
+// RequireVerifiedEmail is the enforcement hook other features call before
+// allowing an action that depends on a confirmed email address. It
+// returns ErrEmailNotVerified if userID has no verified email on file.
+func (s *AuthServer) RequireVerifiedEmail(userID string) error {
+	verified, err := s.emailVerifications.IsVerified(userID)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+// RequestPasswordReset issues a single-use password reset token for the
+// account with the given phone number and delivers it through the
+// configured Notifier. It always returns successfully, whether or not the
+// phone number is registered, so callers cannot use it to enumerate
+// accounts.
+func (s *AuthServer) RequestPasswordReset(ctx context.Context, req *authpb.RequestPasswordResetRequest) (*authpb.RequestPasswordResetResponse, error) {
+	userID, ok := s.credentials.lookup(req.PhoneNumber)
+	if !ok {
+		return &authpb.RequestPasswordResetResponse{}, nil
+	}
+
+	resetToken, err := generateAuthToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := s.passwordResets.Store(resetToken, ResetTokenInfo{
+		UserID:    userID,
+		Phone:     req.PhoneNumber,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(s.config.PasswordResetTokenTTL),
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := s.notifier.Notify(req.PhoneNumber, "Your Mikhail password reset code: "+resetToken); err != nil {
+		return nil, err
+	}
+	return &authpb.RequestPasswordResetResponse{}, nil
+}
+
+// CompletePasswordReset validates a reset token issued by
+// RequestPasswordReset, sets the new password, and revokes every existing
+// session for the account so a leaked old session cannot outlive the
+// password change.
+func (s *AuthServer) CompletePasswordReset(ctx context.Context, req *authpb.CompletePasswordResetRequest) (*authpb.CompletePasswordResetResponse, error) {
+	info, ok, err := s.passwordResets.Get(req.ResetToken)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || info.Used || info.Expired(time.Now()) {
+		return nil, ErrResetTokenInvalid
+	}
+
+	if err := s.credentials.setPassword(info.UserID, req.NewPassword); err != nil {
+		return nil, err
+	}
+	if err := s.passwordResets.MarkUsed(req.ResetToken); err != nil {
+		return nil, err
+	}
+	if err := s.storage.RevokeAllForUser(info.UserID); err != nil {
+		return nil, err
+	}
+	return &authpb.CompletePasswordResetResponse{}, nil
+}
+
+// RequestMagicLink issues a single-use passwordless login token for the
+// account behind req.Identifier (a phone number, email address, or
+// username) and delivers it through the configured Notifier, the same
+// way RequestPasswordReset does. It responds successfully even if
+// req.Identifier does not resolve to an account, so callers cannot use
+// it to probe which identifiers are registered.
+func (s *AuthServer) RequestMagicLink(ctx context.Context, req *authpb.RequestMagicLinkRequest) (*authpb.RequestMagicLinkResponse, error) {
+	userID, ok := s.credentials.lookupIdentifier(req.Identifier)
+	if !ok {
+		return &authpb.RequestMagicLinkResponse{}, nil
+	}
+
+	linkToken, err := generateAuthToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := s.magicLinks.Store(linkToken, MagicLinkInfo{
+		UserID:     userID,
+		Identifier: req.Identifier,
+		IssuedAt:   now,
+		ExpiresAt:  now.Add(s.config.MagicLinkTTL),
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := s.notifier.Notify(req.Identifier, "Your Mikhail sign-in link token: "+linkToken); err != nil {
+		return nil, err
+	}
+	return &authpb.RequestMagicLinkResponse{}, nil
+}
+
+// CompleteMagicLink redeems a token issued by RequestMagicLink for a
+// fresh token pair, following the same TokenStorage-backed issuance
+// path as SignIn.
+func (s *AuthServer) CompleteMagicLink(ctx context.Context, req *authpb.CompleteMagicLinkRequest) (*authpb.CompleteMagicLinkResponse, error) {
+	info, ok, err := s.magicLinks.Get(req.LinkToken)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || info.Used || info.Expired(time.Now()) {
+		return nil, ErrMagicLinkInvalid
+	}
+	if _, deleted, err := s.accountDeletions.Get(info.UserID); err != nil {
+		return nil, err
+	} else if deleted {
+		return nil, ErrAccountDeleted
+	}
+	if err := s.magicLinks.MarkUsed(req.LinkToken); err != nil {
+		return nil, err
+	}
+
+	result, err := s.issueTokenFamily(sessionParams{
+		UserID:            info.UserID,
+		Phone:             info.Identifier,
+		Scopes:            req.Scopes,
+		DeviceID:          req.DeviceID,
+		DeviceFingerprint: req.DeviceFingerprint,
+		RememberMe:        req.RememberMe,
+		TenantID:          tenantIDFromContextString(ctx),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachIDToken(result, info.UserID, info.Identifier, req.Audience); err != nil {
+		return nil, err
+	}
+	return &authpb.CompleteMagicLinkResponse{Result: result}, nil
+}
+
+// CreateGuestSession issues a token pair tied to a freshly generated,
+// anonymous user ID, so a client app can defer registration until the
+// user actually wants an account. UpgradeGuest later converts that user
+// ID into a full account without changing it, so the guest's existing
+// tokens keep working.
+func (s *AuthServer) CreateGuestSession(ctx context.Context, req *authpb.CreateGuestSessionRequest) (*authpb.CreateGuestSessionResponse, error) {
+	suffix, err := generateAuthToken()
+	if err != nil {
+		return nil, err
+	}
+	userID := guestIDPrefix + suffix
+	if err := s.guests.MarkGuest(userID); err != nil {
+		return nil, err
+	}
+
+	result, err := s.issueTokenFamily(sessionParams{
+		UserID:            userID,
+		Scopes:            req.Scopes,
+		DeviceID:          req.DeviceID,
+		DeviceFingerprint: req.DeviceFingerprint,
+		RememberMe:        req.RememberMe,
+		TenantID:          tenantIDFromContextString(ctx),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &authpb.CreateGuestSessionResponse{Result: result, UserID: userID}, nil
+}
+
+// UpgradeGuest converts a guest session created by CreateGuestSession
+// into a phone- or Yandex-backed account, preserving req.UserID so
+// tokens issued before the upgrade remain valid. Exactly one of
+// PhoneNumber or YandexCode must be set.
+func (s *AuthServer) UpgradeGuest(ctx context.Context, req *authpb.UpgradeGuestRequest) (*authpb.UpgradeGuestResponse, error) {
+	isGuest, err := s.guests.IsGuest(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !isGuest {
+		return nil, ErrNotAGuest
+	}
+
+	switch {
+	case req.PhoneNumber != "":
+		if err := s.credentials.createWithID(req.UserID, req.PhoneNumber, req.Password); err != nil {
+			return nil, err
+		}
+	case req.YandexCode != "":
+		if s.yandexOAuth == nil {
+			return nil, ErrYandexOAuthDisabled
+		}
+		token, err := s.yandexOAuth.Exchange(ctx, req.YandexCode, "")
+		if err != nil {
+			return nil, err
+		}
+		profile, err := s.yandexOAuth.FetchProfile(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.oauthIdentities.Link("yandex", profile.ProviderUserID, req.UserID); err != nil {
+			return nil, err
+		}
+		if err := s.profiles.FillIfEmpty(req.UserID, profileFieldDisplayName, profile.DisplayName); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUpgradeGuestMissingIdentity
+	}
+
+	if err := s.guests.MarkUpgraded(req.UserID); err != nil {
+		return nil, err
+	}
+	return &authpb.UpgradeGuestResponse{}, nil
+}
+
+// ChangePassword verifies req.CurrentPassword, sets req.NewPassword in its
+// place, and revokes every other session the account has open. The
+// session req.RefreshToken belongs to is left alone, so the caller is
+// not signed out by changing their own password.
+func (s *AuthServer) ChangePassword(ctx context.Context, req *authpb.ChangePasswordRequest) (*authpb.ChangePasswordResponse, error) {
+	if !s.credentials.verifyByID(req.UserID, req.CurrentPassword) {
+		return nil, ErrInvalidCredentials
+	}
+
+	current, ok, err := s.storage.Get(req.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || current.UserID != req.UserID {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := s.credentials.setPassword(req.UserID, req.NewPassword); err != nil {
+		return nil, err
+	}
+	if err := s.storage.RevokeAllForUserExceptFamily(req.UserID, current.FamilyID); err != nil {
+		return nil, err
+	}
+	s.recordAuditEvent(ctx, AuditEventPasswordChange, req.UserID, "", true)
+	return &authpb.ChangePasswordResponse{}, nil
+}
+
+// DeleteAccount soft-deletes req.UserID: it is barred from signing in
+// immediately, every existing session is revoked, and any linked Yandex
+// OAuth grant is revoked, but the account record itself is kept around
+// for Config.AccountDeletionGracePeriod in case the deletion needs to be
+// reversed. Hard deletion past the grace period is an out-of-band job,
+// not something Mikhail does itself.
+func (s *AuthServer) DeleteAccount(ctx context.Context, req *authpb.DeleteAccountRequest) (*authpb.DeleteAccountResponse, error) {
+	if !s.credentials.verifyByID(req.UserID, req.Password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	now := time.Now()
+	purgeAt := now.Add(s.config.AccountDeletionGracePeriod)
+	if err := s.accountDeletions.Store(req.UserID, AccountDeletionInfo{
+		UserID:      req.UserID,
+		RequestedAt: now,
+		PurgeAt:     purgeAt,
+	}); err != nil {
+		return nil, err
+	}
+	if err := s.storage.RevokeAllForUser(req.UserID); err != nil {
+		return nil, err
+	}
+	if err := s.yandexRevoker.Revoke(req.UserID); err != nil {
+		return nil, err
+	}
+	return &authpb.DeleteAccountResponse{PurgeAt: purgeAt.Unix()}, nil
+}
+
+// ExportMyData returns a JSON archive of req.UserID's active sessions.
+// The archive holds sessions only: profile data and audit-log entries
+// are not included. Mikhail
+// has no streaming transport wired up yet (see proto/kingdom/auth/v1),
+// so the archive is returned whole in ArchiveJson rather than as a
+// stream of chunks.
+func (s *AuthServer) ExportMyData(ctx context.Context, req *authpb.ExportMyDataRequest) (*authpb.ExportMyDataResponse, error) {
+	sessions, err := s.storage.ListByUser(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := dataExportArchive{
+		UserID:      req.UserID,
+		GeneratedAt: time.Now(),
+	}
+	for _, session := range sessions {
+		archive.Sessions = append(archive.Sessions, dataExportSession{
+			DeviceID:  session.DeviceID,
+			IssuedAt:  session.IssuedAt,
+			ExpiresAt: session.ExpiresAt,
+			Provider:  session.Provider,
+		})
+	}
+
+	archiveJSON, err := json.Marshal(archive)
+	if err != nil {
+		return nil, err
+	}
+	return &authpb.ExportMyDataResponse{ArchiveJson: archiveJSON}, nil
+}
+
+// dataExportArchive is the shape ExportMyData serializes to JSON. It
+// has no profile or audit-event section, only Sessions.
+type dataExportArchive struct {
+	UserID      string              `json:"user_id"`
+	GeneratedAt time.Time           `json:"generated_at"`
+	Sessions    []dataExportSession `json:"sessions"`
+}
+
+type dataExportSession struct {
+	DeviceID  string    `json:"device_id"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Provider  string    `json:"provider,omitempty"`
+}
+
+// BeginOAuth2Login returns the URL to redirect the caller to in order to
+// start a login with req.Provider, echoing req.State back in the
+// eventual OAuth2Callback request.
+func (s *AuthServer) BeginOAuth2Login(ctx context.Context, req *authpb.OAuth2LoginRequest) (*authpb.OAuth2LoginResponse, error) {
+	provider, ok := s.oauthProviders.Get(req.Provider)
+	if !ok {
+		return nil, ErrOAuthProviderNotRegistered
+	}
+	if req.CodeChallenge != "" {
+		if err := s.pkce.Store(req.State, req.CodeChallenge); err != nil {
+			return nil, err
+		}
+	}
+	return &authpb.OAuth2LoginResponse{AuthURL: provider.AuthURL(req.State, req.CodeChallenge)}, nil
+}
+
+// verifyPKCE checks that codeVerifier hashes to the code_challenge
+// BeginOAuth2Login stored for state, if any. The state entry is
+// consumed whether or not verification succeeds, so a PKCE state is
+// only ever good for one callback attempt. If BeginOAuth2Login never
+// stored a challenge for state, the call proceeds without PKCE (the
+// provider was never sent a code_challenge either); but once a
+// challenge was stored, codeVerifier is required - an empty one is
+// exactly what an attacker who intercepted only the authorization code
+// would supply, and PKCE exists to make that fail.
+func (s *AuthServer) verifyPKCE(state, codeVerifier string) error {
+	codeChallenge, ok, err := s.pkce.Consume(state)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	if codeVerifier == "" {
+		return ErrPKCEVerificationFailed
+	}
+	sum := sha256.Sum256([]byte(codeVerifier))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != codeChallenge {
+		return ErrPKCEVerificationFailed
+	}
+	return nil
+}
+
+// OAuth2Callback completes a third-party OAuth2 login, exchanging
+// req.Code for the caller's identity with req.Provider (Yandex if unset,
+// for backward compatibility) and issuing a token pair for it.
+//
+// If the identity is already linked to a Mikhail account, that account
+// signs in regardless of req.UserID. Otherwise, if req.UserID is set, it
+// means the call came from an already-signed-in user adding this
+// provider as a login method: the identity is linked to their existing
+// account instead of minting a new one. Only when neither applies does
+// OAuth2Callback fall back to its original behavior of creating a new,
+// provider-only account keyed by the provider's user ID.
+func (s *AuthServer) OAuth2Callback(ctx context.Context, req *authpb.OAuth2CallbackRequest) (*authpb.OAuth2CallbackResponse, error) {
+	providerName := req.Provider
+	if providerName == "" {
+		providerName = "yandex"
+	}
+	provider, ok := s.oauthProviders.Get(providerName)
+	if !ok {
+		if providerName == "yandex" {
+			return nil, ErrYandexOAuthDisabled
+		}
+		return nil, ErrOAuthProviderNotRegistered
+	}
+	if err := s.verifyPKCE(req.State, req.CodeVerifier); err != nil {
+		return nil, &OAuthStatusError{Code: StatusInvalidArgument, Reason: ReasonStateMismatch, Err: err}
+	}
+	token, err := provider.Exchange(ctx, req.Code, req.CodeVerifier)
+	if err != nil {
+		return nil, classifyOAuthError(err)
+	}
+	profile, err := provider.FetchProfile(ctx, token)
+	if err != nil {
+		return nil, classifyOAuthError(err)
+	}
+
+	linkedExisting := true
+	userID, ok, err := s.oauthIdentities.Lookup(providerName, profile.ProviderUserID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		linkedExisting = false
+		if req.UserID != "" {
+			userID = req.UserID
+		} else {
+			userID = providerName + "-" + profile.ProviderUserID
+		}
+		if err := s.oauthIdentities.Link(providerName, profile.ProviderUserID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.profiles.FillIfEmpty(userID, profileFieldDisplayName, profile.DisplayName); err != nil {
+		return nil, err
+	}
+
+	result, err := s.issueTokenFamily(sessionParams{
+		UserID:            userID,
+		Scopes:            req.Scopes,
+		DeviceID:          req.DeviceID,
+		DeviceFingerprint: req.DeviceFingerprint,
+		RememberMe:        req.RememberMe,
+		Provider:          providerName,
+		ProviderToken:     token,
+		TenantID:          tenantIDFromContextString(ctx),
+		IP:                ClientIP(ctx, s.trustedProxies),
+		UserAgent:         userAgentFromContext(ctx),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachIDToken(result, userID, profile.Email, req.Audience); err != nil {
+		return nil, err
+	}
+	if !linkedExisting {
+		s.recordAuditEvent(ctx, AuditEventOAuthLinked, userID, providerName, true)
+	}
+	return &authpb.OAuth2CallbackResponse{Result: result, LinkedExistingAccount: linkedExisting}, nil
+}
+
+// LinkProvider attaches an external identity to req.UserID, an already
+// signed-in account, exchanging req.Code the same way OAuth2Callback
+// does but without ever falling back to creating a new account.
+func (s *AuthServer) LinkProvider(ctx context.Context, req *authpb.LinkProviderRequest) (*authpb.LinkProviderResponse, error) {
+	provider, ok := s.oauthProviders.Get(req.Provider)
+	if !ok {
+		return nil, ErrOAuthProviderNotRegistered
+	}
+	token, err := provider.Exchange(ctx, req.Code, "")
+	if err != nil {
+		return nil, err
+	}
+	profile, err := provider.FetchProfile(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.oauthIdentities.Link(req.Provider, profile.ProviderUserID, req.UserID); err != nil {
+		return nil, err
+	}
+	if err := s.profiles.FillIfEmpty(req.UserID, profileFieldDisplayName, profile.DisplayName); err != nil {
+		return nil, err
+	}
+	s.recordAuditEvent(ctx, AuditEventOAuthLinked, req.UserID, req.Provider, true)
+	return &authpb.LinkProviderResponse{}, nil
+}
+
+// UnlinkProvider detaches req.Provider from req.UserID. It refuses with
+// ErrLastSignInMethod if the account has no phone/password credential
+// and this is the only provider it has linked, which would otherwise
+// leave the account with no way to sign in at all.
+func (s *AuthServer) UnlinkProvider(ctx context.Context, req *authpb.UnlinkProviderRequest) (*authpb.UnlinkProviderResponse, error) {
+	linked, err := s.oauthIdentities.LinkedProviders(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	isLinked := false
+	for _, provider := range linked {
+		if provider == req.Provider {
+			isLinked = true
+			break
+		}
+	}
+	if isLinked && len(linked) == 1 && !s.credentials.hasCredential(req.UserID) {
+		return nil, ErrLastSignInMethod
+	}
+	if err := s.oauthIdentities.Unlink(req.Provider, req.UserID); err != nil {
+		return nil, err
+	}
+	return &authpb.UnlinkProviderResponse{}, nil
+}
+
+// ListLinkedProviders returns the names of the providers req.UserID has
+// a linked identity for, for settings screens.
+func (s *AuthServer) ListLinkedProviders(ctx context.Context, req *authpb.ListLinkedProvidersRequest) (*authpb.ListLinkedProvidersResponse, error) {
+	providers, err := s.oauthIdentities.LinkedProviders(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &authpb.ListLinkedProvidersResponse{Providers: providers}, nil
+}
+
+// RefreshToken exchanges a valid refresh token for a new token pair. If the
+// presented token was already rotated once before, that is treated as
+// reuse of a stolen refresh token and the whole token family is revoked.
+func (s *AuthServer) RefreshToken(ctx context.Context, req *authpb.RefreshTokenRequest) (*authpb.RefreshTokenResponse, error) {
+	info, ok, err := s.storage.Get(req.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || info.Expired(time.Now()) {
+		return nil, ErrInvalidCredentials
+	}
+	if _, suspended, err := s.suspensions.Get(info.UserID); err != nil {
+		return nil, err
+	} else if suspended {
+		return nil, ErrAccountSuspended
+	}
+	if info.Rotated {
+		if s.config.RefreshGraceWindow > 0 && time.Since(info.RotatedAt) <= s.config.RefreshGraceWindow {
+			return s.reissueWithinGraceWindow(info)
+		}
+		if err := s.storage.RevokeFamily(info.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	if info.DeviceFingerprint != "" && req.DeviceFingerprint != info.DeviceFingerprint {
+		if err := s.storage.RevokeFamily(info.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrDeviceFingerprintMismatch
+	}
+
+	ip := ClientIP(ctx, s.trustedProxies)
+	userAgent := userAgentFromContext(ctx)
+	if s.sessionAnomaly.Enabled && sessionAnomalous(info.IssuedIP, info.IssuedUserAgent, ip, userAgent) {
+		s.recordAuditEvent(ctx, AuditEventSessionAnomaly, info.UserID,
+			fmt.Sprintf("ip %s->%s user-agent %q->%q", info.IssuedIP, ip, info.IssuedUserAgent, userAgent), false)
+		switch s.sessionAnomaly.Mode {
+		case SessionAnomalyModeNotify:
+			_ = s.notifier.Notify(info.UserID, "mikhail: your account was just refreshed from an unrecognized network; if this wasn't you, change your password.")
+		case SessionAnomalyModeReauth:
+			if err := s.storage.RevokeFamily(info.FamilyID); err != nil {
+				return nil, err
+			}
+			return nil, ErrSessionAnomalyReauthRequired
+		}
+	}
+
+	successor, err := generateAuthToken()
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.issueTokenInFamily(sessionParams{
+		UserID:            info.UserID,
+		Phone:             info.PhoneNumber,
+		FamilyID:          info.FamilyID,
+		Scopes:            info.Scopes,
+		DeviceID:          info.DeviceID,
+		DeviceFingerprint: info.DeviceFingerprint,
+		RememberMe:        info.RememberMe,
+		RefreshToken:      successor,
+		RotatedFrom:       req.RefreshToken,
+		Provider:          info.Provider,
+		ProviderToken: &OAuthToken{
+			AccessToken:  info.ProviderAccessToken,
+			RefreshToken: info.ProviderRefreshToken,
+			ExpiresAt:    info.ProviderTokenExpiresAt,
+		},
+		TenantID:  info.TenantID,
+		IP:        ip,
+		UserAgent: userAgent,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.recordAuditEvent(ctx, AuditEventTokenRefresh, info.UserID, "", true)
+	return &authpb.RefreshTokenResponse{Result: result}, nil
+}
+
+// VerifyToken validates a token issued by Mikhail, preferring local
+// verification of access tokens (JWT or PASETO) and falling back to a storage lookup for
+// opaque refresh tokens. A token minted under a tenant other than the
+// caller's own resolved TenantID (see tenantMatches) is rejected as
+// invalid, rather than validating identically regardless of which
+// tenant is asking - API keys are the exception, since APIKey carries
+// no TenantID of its own.
+func (s *AuthServer) VerifyToken(ctx context.Context, req *authpb.VerifyTokenRequest) (*authpb.VerifyTokenResponse, error) {
+	if strings.HasPrefix(req.Token, apiKeyPrefix) {
+		key, ok, err := s.apiKeys.Verify(req.Token)
+		if err != nil {
+			return nil, err
+		}
+		if !ok || key.Revoked {
+			return &authpb.VerifyTokenResponse{Valid: false}, nil
+		}
+		return &authpb.VerifyTokenResponse{Valid: true, UserID: key.OwnerID, Scopes: key.Scopes}, nil
+	}
+
+	if claims, err := s.issuer.ParseAccessToken(req.Token); err == nil {
+		if !tenantMatches(ctx, claims.TenantID) {
+			return &authpb.VerifyTokenResponse{Valid: false}, nil
+		}
+		if _, suspended, err := s.suspensions.Get(claims.Subject); err != nil {
+			return nil, err
+		} else if suspended {
+			return nil, ErrAccountSuspended
+		}
+		return &authpb.VerifyTokenResponse{Valid: true, UserID: claims.Subject, ExpiresAt: claims.ExpiresAt, Scopes: claims.Scopes, Roles: claims.Roles}, nil
+	}
+
+	info, ok, err := s.storage.Get(req.Token)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || info.Expired(time.Now()) || !tenantMatches(ctx, info.TenantID) {
+		return &authpb.VerifyTokenResponse{Valid: false}, nil
+	}
+	if _, suspended, err := s.suspensions.Get(info.UserID); err != nil {
+		return nil, err
+	} else if suspended {
+		return nil, ErrAccountSuspended
+	}
+	roles, err := s.roles.Get(info.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &authpb.VerifyTokenResponse{Valid: true, UserID: info.UserID, ExpiresAt: info.ExpiresAt.Unix(), Scopes: info.Scopes, Roles: rolesToStrings(roles)}, nil
+}
+
+// GetTokenMetadata returns the session details behind a refresh or access
+// token, for client account-security UIs and admin debugging. It never
+// returns the Yandex OAuth token itself, only whether the session came
+// from one.
+func (s *AuthServer) GetTokenMetadata(ctx context.Context, req *authpb.GetTokenMetadataRequest) (*authpb.GetTokenMetadataResponse, error) {
+	if info, ok, err := s.storage.Get(req.Token); err != nil {
+		return nil, err
+	} else if ok {
+		return &authpb.GetTokenMetadataResponse{
+			IssuedAt:        info.IssuedAt.Unix(),
+			ExpiresAt:       info.ExpiresAt.Unix(),
+			DeviceID:        info.DeviceID,
+			IsYandexSession: info.Provider == "yandex",
+		}, nil
+	}
+
+	if claims, err := s.issuer.ParseAccessToken(req.Token); err == nil {
+		return &authpb.GetTokenMetadataResponse{
+			IssuedAt:  claims.IssuedAt,
+			ExpiresAt: claims.ExpiresAt,
+		}, nil
+	}
+
+	return nil, ErrTokenNotFound
+}
+
+// SignOut revokes the session a refresh token belongs to, including every
+// token that family has rotated through, and best-effort revokes the
+// linked OAuth provider grant that session was issued through, if any.
+// Access tokens already issued for that session remain valid until they
+// expire, since they are stateless tokens (JWT or PASETO) that Mikhail
+// does not track.
+func (s *AuthServer) SignOut(ctx context.Context, req *authpb.SignOutRequest) (*authpb.SignOutResponse, error) {
+	info, ok, err := s.storage.Get(req.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return &authpb.SignOutResponse{}, nil
+	}
+	if err := s.storage.RevokeFamily(info.FamilyID); err != nil {
+		return nil, err
+	}
+	if info.Provider != "" && info.ProviderAccessToken != "" {
+		revokeProviderGrant(ctx, s.oauthProviders, info.Provider, info.ProviderAccessToken, info.UserID)
+	}
+	s.recordAuditEvent(ctx, AuditEventTokenRevoked, info.UserID, "sign_out", true)
+	return &authpb.SignOutResponse{}, nil
+}
+
+// RevokeAllForUser signs userID out of every session on every device.
+func (s *AuthServer) RevokeAllForUser(ctx context.Context, req *authpb.RevokeAllForUserRequest) (*authpb.RevokeAllForUserResponse, error) {
+	if err := s.storage.RevokeAllForUser(req.UserID); err != nil {
+		return nil, err
+	}
+	s.recordAuditEvent(ctx, AuditEventTokenRevoked, req.UserID, "revoke_all", true)
+	return &authpb.RevokeAllForUserResponse{}, nil
+}
+
+// ListSessions lists a user's active sessions, one per token family;
+// refresh tokens already rotated to a successor are skipped.
+func (s *AuthServer) ListSessions(ctx context.Context, req *authpb.ListSessionsRequest) (*authpb.ListSessionsResponse, error) {
+	tokens, err := s.storage.ListByUser(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]*authpb.Session, 0, len(tokens))
+	for _, info := range tokens {
+		if info.Rotated {
+			continue
+		}
+		sessions = append(sessions, &authpb.Session{
+			DeviceID:  info.DeviceID,
+			IssuedAt:  info.IssuedAt.Unix(),
+			ExpiresAt: info.ExpiresAt.Unix(),
+		})
+	}
+	return &authpb.ListSessionsResponse{Sessions: sessions}, nil
+}
+
+// ClientCredentials issues a short-lived access token for service-to-
+// service calls. Unlike SignIn/SignUp, no refresh token is minted: a
+// client simply requests a new access token with its credentials when
+// the old one expires.
+func (s *AuthServer) ClientCredentials(ctx context.Context, req *authpb.ClientCredentialsRequest) (*authpb.ClientCredentialsResponse, error) {
+	client, ok := s.clients.Verify(req.ClientID, req.ClientSecret)
+	if !ok {
+		return nil, ErrInvalidClientCredentials
+	}
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = client.AllowedScopes
+	} else if !scopesAllowed(scopes, client.AllowedScopes) {
+		return nil, ErrScopeNotAllowed
+	}
+	tenantID := client.TenantID
+	if tenantID == "" {
+		if id, ok := TenantIDFromContext(ctx); ok {
+			tenantID = string(id)
+		}
+	}
+	accessToken, claims, err := s.issuer.Issue(client.ClientID, "", tenantID, scopes, []string{string(RoleService)}, s.config.ClientCredentialsTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &authpb.ClientCredentialsResponse{AccessToken: accessToken, ExpiresAt: claims.ExpiresAt, Scopes: scopes}, nil
+}
+
+// CreateAPIKey mints a new long-lived API key for a machine client.
+// The raw key is only ever returned here; Mikhail stores a hash of it.
+func (s *AuthServer) CreateAPIKey(ctx context.Context, req *authpb.CreateAPIKeyRequest) (*authpb.CreateAPIKeyResponse, error) {
+	rawKey, key, err := s.apiKeys.Create(req.OwnerID, req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	return &authpb.CreateAPIKeyResponse{KeyID: key.KeyID, RawKey: rawKey}, nil
+}
+
+// RevokeAPIKey revokes a previously created API key by its ID.
+func (s *AuthServer) RevokeAPIKey(ctx context.Context, req *authpb.RevokeAPIKeyRequest) (*authpb.RevokeAPIKeyResponse, error) {
+	if err := s.apiKeys.Revoke(req.KeyID); err != nil {
+		return nil, err
+	}
+	return &authpb.RevokeAPIKeyResponse{}, nil
+}
+
+// GrantRole grants role to a user, recording the admin (or other actor)
+// who granted it. The grant takes effect on the user's next issued
+// token; it does not retroactively change tokens already in flight.
+func (s *AuthServer) GrantRole(ctx context.Context, req *authpb.GrantRoleRequest) (*authpb.GrantRoleResponse, error) {
+	if err := s.roles.Grant(req.UserID, Role(req.Role), req.ActorID); err != nil {
+		return nil, err
+	}
+	return &authpb.GrantRoleResponse{}, nil
+}
+
+// RevokeRole revokes a previously granted role from a user. Revoking
+// RoleUser is a no-op: every account holds it implicitly.
+func (s *AuthServer) RevokeRole(ctx context.Context, req *authpb.RevokeRoleRequest) (*authpb.RevokeRoleResponse, error) {
+	if err := s.roles.Revoke(req.UserID, Role(req.Role), req.ActorID); err != nil {
+		return nil, err
+	}
+	return &authpb.RevokeRoleResponse{}, nil
+}
+
+// GetRoleAudit returns the history of role grants and revocations for a
+// user, most recent last.
+func (s *AuthServer) GetRoleAudit(ctx context.Context, req *authpb.GetRoleAuditRequest) (*authpb.GetRoleAuditResponse, error) {
+	entries, err := s.roles.Audit(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*authpb.RoleAuditEntry, len(entries))
+	for i, entry := range entries {
+		out[i] = &authpb.RoleAuditEntry{
+			Role:    string(entry.Role),
+			Granted: entry.Granted,
+			ActorID: entry.ActorID,
+			At:      entry.At.Unix(),
+		}
+	}
+	return &authpb.GetRoleAuditResponse{Entries: out}, nil
+}
+
+// SuspendUser flags an account as suspended and immediately revokes all
+// of its refresh tokens via the per-user index, signing it out of every
+// session on every device. Until ReinstateUser is called, VerifyToken
+// and RefreshToken reject the account with ErrAccountSuspended.
+func (s *AuthServer) SuspendUser(ctx context.Context, req *authpb.SuspendUserRequest) (*authpb.SuspendUserResponse, error) {
+	if err := s.suspensions.Suspend(SuspensionInfo{
+		UserID:      req.UserID,
+		Reason:      req.Reason,
+		ActorID:     req.ActorID,
+		SuspendedAt: time.Now(),
+	}); err != nil {
+		return nil, err
+	}
+	if err := s.storage.RevokeAllForUser(req.UserID); err != nil {
+		return nil, err
+	}
+	return &authpb.SuspendUserResponse{}, nil
+}
+
+// ReinstateUser lifts a suspension placed by SuspendUser. It does not
+// restore any sessions revoked at suspension time; the user must sign
+// in again.
+func (s *AuthServer) ReinstateUser(ctx context.Context, req *authpb.ReinstateUserRequest) (*authpb.ReinstateUserResponse, error) {
+	if err := s.suspensions.Reinstate(req.UserID); err != nil {
+		return nil, err
+	}
+	return &authpb.ReinstateUserResponse{}, nil
+}
+
+// GetMe returns the caller's profile. Phone-only users are served
+// whatever they have set via UpdateProfile, which may be nothing;
+// Yandex-linked users additionally get any field OAuth2Callback seeded
+// from their Yandex identity and never overwrote, for fields they have
+// not set themselves.
+//
+// When AuthenticationInterceptor has attached a Principal to ctx, its
+// UserID is used instead of req.UserID, so a caller cannot read another
+// user's profile just by putting a different UserID in the request; a
+// deployment that has not wired the interceptor in yet falls back to
+// trusting req.UserID unchanged, as before Principal existed.
+func (s *AuthServer) GetMe(ctx context.Context, req *authpb.GetMeRequest) (*authpb.GetMeResponse, error) {
+	userID := req.UserID
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		userID = principal.UserID
+	}
+	profile, ok, err := s.profiles.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	if ok && time.Since(profile.FetchedAt) > s.config.ProfileSyncTTL {
+		if synced, err := s.syncProfileFromProvider(ctx, userID); err == nil {
+			profile = synced
+		}
+	}
+	return &authpb.GetMeResponse{
+		FirstName:   profile.FirstName,
+		LastName:    profile.LastName,
+		DisplayName: profile.DisplayName,
+		AvatarURL:   profile.AvatarURL,
+		Email:       profile.Email,
+	}, nil
+}
+
+// SyncProfile pulls fresh display_name/email from req.UserID's linked
+// OAuthProvider and persists them locally, for clients that want an
+// up-to-date profile without waiting for GetMe's TTL-based auto-refresh.
+func (s *AuthServer) SyncProfile(ctx context.Context, req *authpb.SyncProfileRequest) (*authpb.SyncProfileResponse, error) {
+	profile, err := s.syncProfileFromProvider(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &authpb.SyncProfileResponse{DisplayName: profile.DisplayName, Email: profile.Email}, nil
+}
+
+// syncProfileFromProvider finds a session userID started through a
+// linked OAuthProvider, fetches their current identity from it, and
+// writes the result into ProfileStore.
+func (s *AuthServer) syncProfileFromProvider(ctx context.Context, userID string) (ProfileInfo, error) {
+	sessions, err := s.storage.ListByUser(userID)
+	if err != nil {
+		return ProfileInfo{}, err
+	}
+	var session TokenInfo
+	found := false
+	for _, candidate := range sessions {
+		if candidate.Provider != "" && candidate.ProviderAccessToken != "" {
+			session = candidate
+			found = true
+			break
+		}
+	}
+	if !found {
+		return ProfileInfo{}, ErrNoLinkedProviderSession
+	}
+	provider, ok := s.oauthProviders.Get(session.Provider)
+	if !ok {
+		return ProfileInfo{}, ErrOAuthProviderNotRegistered
+	}
+	identity, err := provider.FetchProfile(ctx, &OAuthToken{
+		AccessToken:  session.ProviderAccessToken,
+		RefreshToken: session.ProviderRefreshToken,
+		ExpiresAt:    session.ProviderTokenExpiresAt,
+	})
+	if err != nil {
+		return ProfileInfo{}, err
+	}
+	return s.profiles.SyncFromProvider(userID, ProfileInfo{
+		DisplayName: identity.DisplayName,
+		Email:       identity.Email,
+	}, time.Now())
+}
+
+// UpdateProfile applies the fields named in req.UpdateMask to the
+// caller's profile, leaving every other field untouched.
+func (s *AuthServer) UpdateProfile(ctx context.Context, req *authpb.UpdateProfileRequest) (*authpb.UpdateProfileResponse, error) {
+	profile, err := s.profiles.Update(ProfileInfo{
+		UserID:      req.UserID,
+		FirstName:   req.FirstName,
+		LastName:    req.LastName,
+		DisplayName: req.DisplayName,
+		AvatarURL:   req.AvatarURL,
+	}, req.UpdateMask)
+	if err != nil {
+		return nil, err
+	}
+	return &authpb.UpdateProfileResponse{
+		FirstName:   profile.FirstName,
+		LastName:    profile.LastName,
+		DisplayName: profile.DisplayName,
+		AvatarURL:   profile.AvatarURL,
+	}, nil
+}
+
+// attachIDToken sets result.IDToken when OIDC ID token issuance is
+// enabled. It is a no-op otherwise, so deployments that have not set up
+// WithIDTokenIssuer keep getting plain access/refresh token pairs.
+func (s *AuthServer) attachIDToken(result *authpb.AuthResult, userID, phone, audience string) error {
+	if s.idTokenIssuer == nil {
+		return nil
+	}
+	idToken, err := s.idTokenIssuer.IssueIDToken(userID, phone, audience, s.config.AccessTokenTTL)
+	if err != nil {
+		return err
+	}
+	result.IDToken = idToken
+	return nil
+}
+
+// reissueWithinGraceWindow handles a rotated refresh token presented again
+// soon after rotation: rather than treating it as reuse, it returns the
+// pair belonging to the successor token that the original rotation already
+// produced (re-signing a fresh access token, since access tokens are not
+// themselves persisted).
+func (s *AuthServer) reissueWithinGraceWindow(rotated TokenInfo) (*authpb.RefreshTokenResponse, error) {
+	successor, ok, err := s.storage.Get(rotated.SuccessorToken)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || successor.Expired(time.Now()) {
+		return nil, ErrInvalidCredentials
+	}
+	roles, err := s.roles.Get(successor.UserID)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, claims, err := s.issuer.Issue(successor.UserID, successor.PhoneNumber, successor.TenantID, successor.Scopes, rolesToStrings(roles), s.config.AccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &authpb.RefreshTokenResponse{Result: &authpb.AuthResult{
+		AccessToken:  accessToken,
+		RefreshToken: rotated.SuccessorToken,
+		ExpiresAt:    claims.ExpiresAt,
+		Scopes:       successor.Scopes,
+	}}, nil
+}
+
+// sessionParams carries everything needed to mint a token pair for a
+// session, whether it is brand new (SignIn/SignUp) or a rotation of an
+// existing family (RefreshToken).
+type sessionParams struct {
+	UserID            string
+	Phone             string
+	FamilyID          string
+	Scopes            []string
+	DeviceID          string
+	DeviceFingerprint string
+	// RememberMe selects the refresh-token TTL tier: RememberMe sessions
+	// get Config.RememberMeRefreshTokenTTL instead of the short-lived
+	// Config.RefreshTokenTTL.
+	RememberMe bool
+	// RefreshToken, when set, is used as the new refresh token instead of
+	// generating a random one. RefreshToken's grace-window handling relies
+	// on this to make the successor it recorded in MarkRotated match the
+	// token actually stored.
+	RefreshToken string
+	// RotatedFrom, when set, names the refresh token this session's new
+	// one replaces. issueTokenInFamily then persists the new token with
+	// RotateToken instead of Store, so marking RotatedFrom rotated and
+	// storing the new token happen as a single atomic storage operation.
+	RotatedFrom string
+	// Provider identifies the identity provider that started this
+	// session, e.g. "yandex". Empty means Mikhail's own phone/password
+	// SignIn or SignUp.
+	Provider string
+	// ProviderToken is the OAuthToken Provider issued, stored on the
+	// session so the background refresh scheduler and Revoke-on-sign-out
+	// can use it later. Nil for a new session with no Provider;
+	// RefreshToken always sets it, with empty fields when Provider is
+	// empty.
+	ProviderToken *OAuthToken
+	// TenantID is the Kingdom-System tenant this session belongs to, or
+	// "" in a single-tenant deployment. See TokenInfo.TenantID.
+	TenantID string
+	// IP and UserAgent are stamped onto the issued TokenInfo as
+	// IssuedIP/IssuedUserAgent, for SessionAnomalyConfig to compare
+	// against on a later refresh.
+	IP        string
+	UserAgent string
+}
+
+// issueTokenFamily starts a brand new refresh-token family for a freshly
+// authenticated session (SignIn/SignUp).
+func (s *AuthServer) issueTokenFamily(p sessionParams) (*authpb.AuthResult, error) {
+	familyID, err := generateAuthToken()
+	if err != nil {
+		return nil, err
+	}
+	p.FamilyID = familyID
+	return s.issueTokenInFamily(p)
+}
+
+// issueTokenInFamily signs a new access token and mints an opaque
+// refresh token that extends p.FamilyID, persisting it in storage so
+// RefreshToken can find it (and so reuse of an earlier member of the
+// family, or a device-fingerprint mismatch, can be detected). Scopes,
+// DeviceID and DeviceFingerprint are carried forward unchanged on every
+// rotation within a family.
+func (s *AuthServer) issueTokenInFamily(p sessionParams) (*authpb.AuthResult, error) {
+	roles, err := s.roles.Get(p.UserID)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, claims, err := s.issuer.Issue(p.UserID, p.Phone, p.TenantID, p.Scopes, rolesToStrings(roles), s.config.AccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken := p.RefreshToken
+	if refreshToken == "" {
+		var err error
+		refreshToken, err = generateAuthToken()
+		if err != nil {
+			return nil, err
+		}
+	}
+	refreshTTL := s.config.RefreshTokenTTL
+	if p.RememberMe {
+		refreshTTL = s.config.RememberMeRefreshTokenTTL
+	}
+	now := time.Now()
+	info := TokenInfo{
+		UserID:            p.UserID,
+		PhoneNumber:       p.Phone,
+		IssuedAt:          now,
+		ExpiresAt:         now.Add(refreshTTL),
+		FamilyID:          p.FamilyID,
+		Scopes:            p.Scopes,
+		DeviceID:          p.DeviceID,
+		DeviceFingerprint: p.DeviceFingerprint,
+		RememberMe:        p.RememberMe,
+		Provider:          p.Provider,
+		TenantID:          p.TenantID,
+		IssuedIP:          p.IP,
+		IssuedUserAgent:   p.UserAgent,
+	}
+	if p.ProviderToken != nil {
+		info.ProviderAccessToken = p.ProviderToken.AccessToken
+		info.ProviderRefreshToken = p.ProviderToken.RefreshToken
+		info.ProviderTokenExpiresAt = p.ProviderToken.ExpiresAt
+	}
+	if p.RotatedFrom != "" {
+		if err := s.storage.RotateToken(p.RotatedFrom, refreshToken, info); err != nil {
+			return nil, err
+		}
+	} else if err := s.storage.Store(refreshToken, info); err != nil {
+		return nil, err
+	}
+
+	return &authpb.AuthResult{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    claims.ExpiresAt,
+		Scopes:       p.Scopes,
+	}, nil
+}
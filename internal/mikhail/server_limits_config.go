@@ -0,0 +1,73 @@
+package mikhail
+
+import "fmt"
+
+// ServerLimitsConfig holds the gRPC resource limits Mikhail's real
+// grpc.Server construction passes into grpc.MaxRecvMsgSize,
+// grpc.MaxSendMsgSize, and grpc.MaxConcurrentStreams, the same
+// "configuration a real grpc.Server is built from" role KeepaliveConfig
+// plays for connection management. Without a ceiling, a single
+// oversized request body or a client opening unbounded concurrent
+// streams can exhaust memory or goroutines on an otherwise healthy
+// instance.
+type ServerLimitsConfig struct {
+	// MaxRecvMsgSizeBytes is the largest message Mikhail will accept on
+	// any RPC, maps to grpc.MaxRecvMsgSize.
+	MaxRecvMsgSizeBytes int
+	// MaxSendMsgSizeBytes is the largest message Mikhail will send on
+	// any RPC, maps to grpc.MaxSendMsgSize.
+	MaxSendMsgSizeBytes int
+	// MaxConcurrentStreams bounds the number of concurrent RPCs a single
+	// client connection may have in flight, maps to
+	// grpc.MaxConcurrentStreams.
+	MaxConcurrentStreams uint32
+}
+
+// DefaultServerLimitsConfig returns grpc-go's own library default
+// message size (4 MiB each way) and a conservative concurrent-stream
+// cap, so leaving every MIKHAIL_GRPC_* limit variable unset reproduces
+// grpc-go's out-of-the-box behavior except for the stream cap, which
+// grpc-go otherwise leaves unbounded.
+func DefaultServerLimitsConfig() ServerLimitsConfig {
+	const defaultMsgSize = 4 * 1024 * 1024
+	return ServerLimitsConfig{
+		MaxRecvMsgSizeBytes:  defaultMsgSize,
+		MaxSendMsgSizeBytes:  defaultMsgSize,
+		MaxConcurrentStreams: 100,
+	}
+}
+
+// LoadServerLimitsConfig builds a ServerLimitsConfig from environment
+// variables, falling back to DefaultServerLimitsConfig for anything
+// unset.
+func LoadServerLimitsConfig() (ServerLimitsConfig, error) {
+	cfg := DefaultServerLimitsConfig()
+
+	if err := overrideInt(&cfg.MaxRecvMsgSizeBytes, "MIKHAIL_GRPC_MAX_RECV_MSG_SIZE_BYTES"); err != nil {
+		return ServerLimitsConfig{}, err
+	}
+	if err := overrideInt(&cfg.MaxSendMsgSizeBytes, "MIKHAIL_GRPC_MAX_SEND_MSG_SIZE_BYTES"); err != nil {
+		return ServerLimitsConfig{}, err
+	}
+	var maxConcurrentStreams int = int(cfg.MaxConcurrentStreams)
+	if err := overrideInt(&maxConcurrentStreams, "MIKHAIL_GRPC_MAX_CONCURRENT_STREAMS"); err != nil {
+		return ServerLimitsConfig{}, err
+	}
+	cfg.MaxConcurrentStreams = uint32(maxConcurrentStreams)
+
+	return cfg, cfg.Validate()
+}
+
+// Validate reports an error if any limit is not strictly positive.
+func (c ServerLimitsConfig) Validate() error {
+	if c.MaxRecvMsgSizeBytes <= 0 {
+		return fmt.Errorf("mikhail: server limits config.MaxRecvMsgSizeBytes must be positive, got %d", c.MaxRecvMsgSizeBytes)
+	}
+	if c.MaxSendMsgSizeBytes <= 0 {
+		return fmt.Errorf("mikhail: server limits config.MaxSendMsgSizeBytes must be positive, got %d", c.MaxSendMsgSizeBytes)
+	}
+	if c.MaxConcurrentStreams == 0 {
+		return fmt.Errorf("mikhail: server limits config.MaxConcurrentStreams must be positive, got %d", c.MaxConcurrentStreams)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package mikhail
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+
+	"github.com/TwelveFacedJanus/Kingdom-System/internal/authpb"
+)
+
+// pkceChallenge returns the code_challenge a PKCE client would send for
+// verifier, the same S256 transform verifyPKCE checks against.
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func newTestAuthServer(t *testing.T) *AuthServer {
+	t.Helper()
+	issuer, err := NewHS256Issuer("mikhail-test", []byte("test-secret-value-not-for-prod"))
+	if err != nil {
+		t.Fatalf("NewHS256Issuer: %v", err)
+	}
+	return NewAuthServer(issuer, NewInMemoryTokenStorage(), DefaultConfig())
+}
+
+// TestRefreshTokenRotation checks that a fresh family can be rotated
+// once: RefreshToken accepts the current refresh token and returns a
+// new pair.
+func TestRefreshTokenRotation(t *testing.T) {
+	s := newTestAuthServer(t)
+	ctx := context.Background()
+
+	first, err := s.issueTokenFamily(sessionParams{UserID: "user-1"})
+	if err != nil {
+		t.Fatalf("issueTokenFamily: %v", err)
+	}
+
+	resp, err := s.RefreshToken(ctx, &authpb.RefreshTokenRequest{RefreshToken: first.RefreshToken})
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if resp.Result.RefreshToken == "" || resp.Result.RefreshToken == first.RefreshToken {
+		t.Fatalf("expected a new refresh token distinct from the original, got %q", resp.Result.RefreshToken)
+	}
+}
+
+// TestRefreshTokenReuseRevokesFamily checks that presenting an
+// already-rotated refresh token a second time is treated as reuse of a
+// stolen token: it is rejected with ErrRefreshTokenReused, and the
+// whole family - including the successor that was never compromised -
+// is revoked, so a reused token can never be turned into a valid
+// session again.
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	s := newTestAuthServer(t)
+	s.config.RefreshGraceWindow = 0 // disable the grace window so a replay is unambiguously reuse, not a retry
+	ctx := context.Background()
+
+	first, err := s.issueTokenFamily(sessionParams{UserID: "user-1"})
+	if err != nil {
+		t.Fatalf("issueTokenFamily: %v", err)
+	}
+	second, err := s.RefreshToken(ctx, &authpb.RefreshTokenRequest{RefreshToken: first.RefreshToken})
+	if err != nil {
+		t.Fatalf("first RefreshToken: %v", err)
+	}
+
+	if _, err := s.RefreshToken(ctx, &authpb.RefreshTokenRequest{RefreshToken: first.RefreshToken}); err != ErrRefreshTokenReused {
+		t.Fatalf("replaying a rotated refresh token: got err %v, want ErrRefreshTokenReused", err)
+	}
+
+	if _, err := s.RefreshToken(ctx, &authpb.RefreshTokenRequest{RefreshToken: second.Result.RefreshToken}); err != ErrInvalidCredentials {
+		t.Fatalf("refreshing the never-compromised successor after family revocation: got err %v, want ErrInvalidCredentials", err)
+	}
+}
+
+// TestRefreshTokenDeviceFingerprintMismatchRevokesFamily checks that a
+// refresh request presenting a different device fingerprint than the
+// one the family was issued with is rejected, and revokes the family
+// the same way token reuse does.
+func TestRefreshTokenDeviceFingerprintMismatchRevokesFamily(t *testing.T) {
+	s := newTestAuthServer(t)
+	ctx := context.Background()
+
+	first, err := s.issueTokenFamily(sessionParams{UserID: "user-1", DeviceFingerprint: "device-a"})
+	if err != nil {
+		t.Fatalf("issueTokenFamily: %v", err)
+	}
+
+	if _, err := s.RefreshToken(ctx, &authpb.RefreshTokenRequest{RefreshToken: first.RefreshToken, DeviceFingerprint: "device-b"}); err != ErrDeviceFingerprintMismatch {
+		t.Fatalf("refreshing with a mismatched device fingerprint: got err %v, want ErrDeviceFingerprintMismatch", err)
+	}
+
+	if _, err := s.RefreshToken(ctx, &authpb.RefreshTokenRequest{RefreshToken: first.RefreshToken, DeviceFingerprint: "device-a"}); err != ErrInvalidCredentials {
+		t.Fatalf("refreshing again after the mismatch revoked the family: got err %v, want ErrInvalidCredentials", err)
+	}
+}
+
+// TestAdminRevokeSessionsInvalidatesTokens checks that
+// AdminServer.RevokeSessions (and the plain RevokeAllForUser it backs
+// onto) actually invalidates a user's existing session: a refresh
+// token that worked before the call fails afterward.
+func TestAdminRevokeSessionsInvalidatesTokens(t *testing.T) {
+	s := newTestAuthServer(t)
+	ctx := context.Background()
+	storage := NewInMemoryTokenStorage()
+	s.storage = storage
+
+	first, err := s.issueTokenFamily(sessionParams{UserID: "user-1"})
+	if err != nil {
+		t.Fatalf("issueTokenFamily: %v", err)
+	}
+
+	admin := NewAdminServer(storage, nil, nil, nil, nil, nil, "memory")
+	if _, err := admin.RevokeSessions(ctx, &AdminRevokeSessionsRequest{UserID: "user-1"}); err != nil {
+		t.Fatalf("RevokeSessions: %v", err)
+	}
+
+	if _, err := s.RefreshToken(ctx, &authpb.RefreshTokenRequest{RefreshToken: first.RefreshToken}); err != ErrInvalidCredentials {
+		t.Fatalf("refreshing after RevokeSessions: got err %v, want ErrInvalidCredentials", err)
+	}
+}
+
+// TestVerifyPKCERequiresVerifierOnceChallengeStored checks that
+// verifyPKCE rejects a callback that omits code_verifier once
+// BeginOAuth2Login stored a code_challenge for that state - exactly
+// what an attacker who intercepted only the authorization code would
+// send - rather than skipping verification entirely.
+func TestVerifyPKCERequiresVerifierOnceChallengeStored(t *testing.T) {
+	s := newTestAuthServer(t)
+	const state = "state-1"
+	const verifier = "a-pkce-code-verifier-of-sufficient-length"
+	sum := pkceChallenge(verifier)
+
+	if err := s.pkce.Store(state, sum); err != nil {
+		t.Fatalf("pkce.Store: %v", err)
+	}
+	if err := s.verifyPKCE(state, ""); err != ErrPKCEVerificationFailed {
+		t.Fatalf("verifyPKCE with no verifier after a challenge was stored: got err %v, want ErrPKCEVerificationFailed", err)
+	}
+}
+
+// TestVerifyPKCERejectsWrongVerifier checks that verifyPKCE rejects a
+// code_verifier that does not hash to the stored code_challenge.
+func TestVerifyPKCERejectsWrongVerifier(t *testing.T) {
+	s := newTestAuthServer(t)
+	const state = "state-2"
+	sum := pkceChallenge("the-real-verifier")
+
+	if err := s.pkce.Store(state, sum); err != nil {
+		t.Fatalf("pkce.Store: %v", err)
+	}
+	if err := s.verifyPKCE(state, "an-attacker-supplied-verifier"); err != ErrPKCEVerificationFailed {
+		t.Fatalf("verifyPKCE with a wrong verifier: got err %v, want ErrPKCEVerificationFailed", err)
+	}
+}
+
+// TestVerifyPKCEAcceptsMatchingVerifier checks the success path: a
+// verifier that hashes to the stored challenge passes.
+func TestVerifyPKCEAcceptsMatchingVerifier(t *testing.T) {
+	s := newTestAuthServer(t)
+	const state = "state-3"
+	const verifier = "a-pkce-code-verifier-of-sufficient-length"
+	sum := pkceChallenge(verifier)
+
+	if err := s.pkce.Store(state, sum); err != nil {
+		t.Fatalf("pkce.Store: %v", err)
+	}
+	if err := s.verifyPKCE(state, verifier); err != nil {
+		t.Fatalf("verifyPKCE with the matching verifier: got err %v, want nil", err)
+	}
+}
+
+// TestVerifyPKCENoChallengeStoredPassesThrough checks that a callback
+// for a state BeginOAuth2Login never stored a challenge for proceeds
+// without PKCE, the backward-compatible path for a client that never
+// sent a code_challenge.
+func TestVerifyPKCENoChallengeStoredPassesThrough(t *testing.T) {
+	s := newTestAuthServer(t)
+	if err := s.verifyPKCE("never-seen-state", ""); err != nil {
+		t.Fatalf("verifyPKCE with no stored challenge: got err %v, want nil", err)
+	}
+}
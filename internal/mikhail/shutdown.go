@@ -0,0 +1,42 @@
+package mikhail
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// shutdownGracePeriod bounds how long WaitForShutdownSignal waits for
+// in-flight requests to finish once a shutdown signal arrives, before
+// giving up on a graceful Shutdown and returning anyway.
+const shutdownGracePeriod = 10 * time.Second
+
+// WaitForShutdownSignal blocks until the process receives SIGINT or
+// SIGTERM, then calls Shutdown on every server in servers, in order,
+// logging any error each returns, and returns once all have stopped (or
+// shutdownGracePeriod elapses). This is what keeps main() alive:
+// everything cmd/mikhail does before calling this only builds
+// configuration and starts background goroutines, none of which block
+// the process on their own. servers may contain nil entries (a listener
+// a deployment left disabled), which are skipped.
+func WaitForShutdownSignal(servers ...*http.Server) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
+	log.Println("mikhail: shutdown signal received")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownGracePeriod)
+	defer cancel()
+	for _, srv := range servers {
+		if srv == nil {
+			continue
+		}
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("mikhail: shutting down server on %s: %v", srv.Addr, err)
+		}
+	}
+}
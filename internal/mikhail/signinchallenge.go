@@ -0,0 +1,52 @@
+package mikhail
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrSignInChallengeInvalid is returned when a CompleteSignInChallenge
+// token is unknown or has expired.
+var ErrSignInChallengeInvalid = errors.New("mikhail: sign-in challenge invalid or expired")
+
+// pendingSignIn is the state SignIn needs to finish issuing tokens once a
+// second-factor challenge is completed.
+type pendingSignIn struct {
+	params    sessionParams
+	audience  string
+	expiresAt time.Time
+}
+
+func (p pendingSignIn) expired(now time.Time) bool {
+	return now.After(p.expiresAt)
+}
+
+// signInChallengeStore holds pending 2FA challenges in memory. Unlike
+// TokenStorage or OTPStore, challenges live only a couple of minutes and
+// are single-use by construction, so there is no pluggable backend here:
+// a process restart mid-challenge just means the client retries SignIn.
+type signInChallengeStore struct {
+	mu      sync.Mutex
+	pending map[string]pendingSignIn
+}
+
+func newSignInChallengeStore() *signInChallengeStore {
+	return &signInChallengeStore{pending: make(map[string]pendingSignIn)}
+}
+
+func (s *signInChallengeStore) store(challenge string, p pendingSignIn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[challenge] = p
+}
+
+func (s *signInChallengeStore) take(challenge string) (pendingSignIn, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pending[challenge]
+	if ok {
+		delete(s.pending, challenge)
+	}
+	return p, ok
+}
@@ -0,0 +1,194 @@
+package mikhail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrAllSmsProvidersFailed is returned by FailoverSmsSender.Send when
+// every configured provider, across every retry, failed to deliver the
+// message.
+var ErrAllSmsProvidersFailed = errors.New("mikhail: all sms providers failed")
+
+// SmsSender delivers a text message to a phone number and returns the
+// provider's message ID on success. OTP and password-reset flows send
+// through a SmsSender (typically wrapped in SmsNotifier) rather than
+// talking to a provider directly, so the provider can be swapped or
+// failed over without touching those flows.
+type SmsSender interface {
+	Send(phoneNumber, message string) (messageID string, err error)
+}
+
+// SmsNotifier adapts a SmsSender to the Notifier interface, discarding
+// the provider message ID, so WithNotifier can be given an SMS provider
+// directly.
+type SmsNotifier struct {
+	Sender SmsSender
+}
+
+func (n SmsNotifier) Notify(recipient, message string) error {
+	_, err := n.Sender.Send(recipient, message)
+	return err
+}
+
+// TwilioSmsSender sends messages through Twilio's Programmable
+// Messaging API.
+type TwilioSmsSender struct {
+	AccountSID string
+	AuthToken  string
+	FromNumber string
+	HTTPClient *http.Client
+}
+
+// NewTwilioSmsSender builds a TwilioSmsSender using http.DefaultClient.
+func NewTwilioSmsSender(accountSID, authToken, fromNumber string) *TwilioSmsSender {
+	return &TwilioSmsSender{AccountSID: accountSID, AuthToken: authToken, FromNumber: fromNumber, HTTPClient: http.DefaultClient}
+}
+
+func (t *TwilioSmsSender) Send(phoneNumber, message string) (string, error) {
+	form := url.Values{
+		"To":   {phoneNumber},
+		"From": {t.FromNumber},
+		"Body": {message},
+	}
+	endpoint := fmt.Sprintf("https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json", t.AccountSID)
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(t.AccountSID, t.AuthToken)
+
+	resp, err := t.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("mikhail: twilio send failed: %s: %s", resp.Status, body)
+	}
+
+	var result struct {
+		SID string `json:"sid"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", err
+	}
+	return result.SID, nil
+}
+
+// SMSCSmsSender sends messages through the smsc.ru HTTP API, a common
+// choice for Russian-market SMS delivery.
+type SMSCSmsSender struct {
+	Login      string
+	Password   string
+	HTTPClient *http.Client
+}
+
+// NewSMSCSmsSender builds a SMSCSmsSender using http.DefaultClient.
+func NewSMSCSmsSender(login, password string) *SMSCSmsSender {
+	return &SMSCSmsSender{Login: login, Password: password, HTTPClient: http.DefaultClient}
+}
+
+func (s *SMSCSmsSender) Send(phoneNumber, message string) (string, error) {
+	query := url.Values{
+		"login":  {s.Login},
+		"psw":    {s.Password},
+		"phones": {phoneNumber},
+		"mes":    {message},
+		"fmt":    {"3"}, // fmt=3 requests a JSON response
+	}
+	resp, err := s.HTTPClient.Get("https://smsc.ru/sys/send.php?" + query.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("mikhail: smsc send failed: %s: %s", resp.Status, body)
+	}
+
+	var result struct {
+		ID    int    `json:"id"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", err
+	}
+	if result.Error != "" {
+		return "", fmt.Errorf("mikhail: smsc send failed: %s", result.Error)
+	}
+	return strconv.Itoa(result.ID), nil
+}
+
+// FailoverSmsSender tries each of its senders in order, retrying each
+// one maxRetries times before moving on to the next, and logs the
+// outcome of every attempt.
+type FailoverSmsSender struct {
+	senders    []SmsSender
+	maxRetries int
+}
+
+// NewFailoverSmsSender wraps senders so Send tries them in order,
+// retrying each up to maxRetries times on failure before moving to the
+// next. maxRetries < 0 is treated as 0.
+func NewFailoverSmsSender(maxRetries int, senders ...SmsSender) *FailoverSmsSender {
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	return &FailoverSmsSender{senders: senders, maxRetries: maxRetries}
+}
+
+func (f *FailoverSmsSender) Send(phoneNumber, message string) (string, error) {
+	for _, sender := range f.senders {
+		for attempt := 0; attempt <= f.maxRetries; attempt++ {
+			messageID, err := sender.Send(phoneNumber, message)
+			if err == nil {
+				log.Printf("mikhail: sms to %s delivered via %T on attempt %d: %s", phoneNumber, sender, attempt+1, messageID)
+				return messageID, nil
+			}
+			log.Printf("mikhail: sms to %s via %T failed on attempt %d: %v", phoneNumber, sender, attempt+1, err)
+		}
+	}
+	return "", ErrAllSmsProvidersFailed
+}
+
+// NewSmsSenderFromEnv builds a SmsSender selected by MIKHAIL_SMS_PROVIDER
+// ("twilio", "smsc", or "failover", case-sensitive), reading that
+// provider's credentials from its own environment variables. A
+// "failover" provider chains Twilio then SMSC, retrying each
+// MIKHAIL_SMS_MAX_RETRIES times (default 2).
+func NewSmsSenderFromEnv() (SmsSender, error) {
+	maxRetries := 2
+	if err := overrideInt(&maxRetries, "MIKHAIL_SMS_MAX_RETRIES"); err != nil {
+		return nil, err
+	}
+
+	switch provider := os.Getenv("MIKHAIL_SMS_PROVIDER"); provider {
+	case "", "twilio":
+		return NewTwilioSmsSender(os.Getenv("MIKHAIL_TWILIO_ACCOUNT_SID"), os.Getenv("MIKHAIL_TWILIO_AUTH_TOKEN"), os.Getenv("MIKHAIL_TWILIO_FROM_NUMBER")), nil
+	case "smsc":
+		return NewSMSCSmsSender(os.Getenv("MIKHAIL_SMSC_LOGIN"), os.Getenv("MIKHAIL_SMSC_PASSWORD")), nil
+	case "failover":
+		twilio := NewTwilioSmsSender(os.Getenv("MIKHAIL_TWILIO_ACCOUNT_SID"), os.Getenv("MIKHAIL_TWILIO_AUTH_TOKEN"), os.Getenv("MIKHAIL_TWILIO_FROM_NUMBER"))
+		smsc := NewSMSCSmsSender(os.Getenv("MIKHAIL_SMSC_LOGIN"), os.Getenv("MIKHAIL_SMSC_PASSWORD"))
+		return NewFailoverSmsSender(maxRetries, twilio, smsc), nil
+	default:
+		return nil, fmt.Errorf("mikhail: unknown MIKHAIL_SMS_PROVIDER %q", provider)
+	}
+}
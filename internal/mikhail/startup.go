@@ -0,0 +1,137 @@
+package mikhail
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// StartupRetryConfig controls how long and how aggressively
+// ConnectTokenStorageWithRetry retries a dependency that is not yet
+// reachable, the ordinary situation during an orderly cluster startup
+// where Redis (or another backend) may come up after Mikhail does.
+type StartupRetryConfig struct {
+	// MaxAttempts bounds how many times initialization is attempted
+	// before giving up. Defaults to 5.
+	MaxAttempts int
+	// InitialInterval is the delay before the first retry. Defaults to
+	// 500ms.
+	InitialInterval time.Duration
+	// MaxInterval caps the delay between retries; the delay doubles after
+	// each failed attempt up to this cap. Defaults to 10s.
+	MaxInterval time.Duration
+	// DegradedModeOnFailure, if true, falls back to in-memory token
+	// storage after MaxAttempts are exhausted instead of failing startup
+	// outright, trading durability and multi-instance session sharing for
+	// availability. Defaults to false: an operator who wants Mikhail to
+	// come up regardless must opt in explicitly.
+	DegradedModeOnFailure bool
+}
+
+// DefaultStartupRetryConfig returns 5 attempts, starting at 500ms and
+// doubling up to 10s, with degraded mode disabled.
+func DefaultStartupRetryConfig() StartupRetryConfig {
+	return StartupRetryConfig{
+		MaxAttempts:           5,
+		InitialInterval:       500 * time.Millisecond,
+		MaxInterval:           10 * time.Second,
+		DegradedModeOnFailure: false,
+	}
+}
+
+// LoadStartupRetryConfig builds a StartupRetryConfig from environment
+// variables, falling back to DefaultStartupRetryConfig for anything
+// unset.
+func LoadStartupRetryConfig() (StartupRetryConfig, error) {
+	cfg := DefaultStartupRetryConfig()
+
+	if err := overrideInt(&cfg.MaxAttempts, "MIKHAIL_STARTUP_RETRY_MAX_ATTEMPTS"); err != nil {
+		return StartupRetryConfig{}, err
+	}
+	if err := overrideDuration(&cfg.InitialInterval, "MIKHAIL_STARTUP_RETRY_INITIAL_INTERVAL"); err != nil {
+		return StartupRetryConfig{}, err
+	}
+	if err := overrideDuration(&cfg.MaxInterval, "MIKHAIL_STARTUP_RETRY_MAX_INTERVAL"); err != nil {
+		return StartupRetryConfig{}, err
+	}
+	if err := overrideBool(&cfg.DegradedModeOnFailure, "MIKHAIL_STARTUP_DEGRADED_MODE_ON_FAILURE"); err != nil {
+		return StartupRetryConfig{}, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate reports an error if MaxAttempts, InitialInterval, or
+// MaxInterval are not positive.
+func (c StartupRetryConfig) Validate() error {
+	if c.MaxAttempts <= 0 {
+		return fmt.Errorf("mikhail: startup retry config.MaxAttempts must be positive, got %d", c.MaxAttempts)
+	}
+	if c.InitialInterval <= 0 {
+		return fmt.Errorf("mikhail: startup retry config.InitialInterval must be positive, got %s", c.InitialInterval)
+	}
+	if c.MaxInterval <= 0 {
+		return fmt.Errorf("mikhail: startup retry config.MaxInterval must be positive, got %s", c.MaxInterval)
+	}
+	return nil
+}
+
+// retryWithBackoff calls attempt up to cfg.MaxAttempts times, doubling
+// the delay between attempts (starting at cfg.InitialInterval, capped at
+// cfg.MaxInterval) after every failure, logging each failed attempt. It
+// returns the last error if every attempt fails.
+func retryWithBackoff(cfg StartupRetryConfig, what string, attempt func() error) error {
+	delay := cfg.InitialInterval
+	var err error
+	for i := 1; i <= cfg.MaxAttempts; i++ {
+		if err = attempt(); err == nil {
+			return nil
+		}
+		if i == cfg.MaxAttempts {
+			break
+		}
+		log.Printf("mikhail: %s: attempt %d/%d failed: %v (retrying in %s)", what, i, cfg.MaxAttempts, err, delay)
+		time.Sleep(delay)
+		delay *= 2
+		if delay > cfg.MaxInterval {
+			delay = cfg.MaxInterval
+		}
+	}
+	return err
+}
+
+// ConnectTokenStorageWithRetry calls NewTokenStorageFromEnv, retrying
+// with backoff per cfg on failure (e.g. Redis not yet accepting
+// connections during an orderly cluster startup). If every attempt fails
+// and cfg.DegradedModeOnFailure is set, it logs a warning and falls back
+// to an in-memory TokenStorage instead of returning an error, so a
+// non-critical dependency outage does not crash-loop the whole process.
+func ConnectTokenStorageWithRetry(cfg StartupRetryConfig) (TokenStorage, error) {
+	var storage TokenStorage
+	err := retryWithBackoff(cfg, "connecting to token storage backend", func() error {
+		s, err := NewTokenStorageFromEnv()
+		if err != nil {
+			return err
+		}
+		if pinger, ok := s.(Pinger); ok {
+			if err := pinger.Ping(); err != nil {
+				return err
+			}
+		}
+		storage = s
+		return nil
+	})
+	if err == nil {
+		return storage, nil
+	}
+	if !cfg.DegradedModeOnFailure {
+		return nil, err
+	}
+	log.Printf("mikhail: connecting to token storage backend failed after %d attempts, falling back to in-memory storage (degraded mode): %v", cfg.MaxAttempts, err)
+	backend := os.Getenv("MIKHAIL_TOKEN_STORAGE_BACKEND")
+	if backend == "" || backend == string(TokenStorageBackendMemory) {
+		return nil, err
+	}
+	return NewInMemoryTokenStorage(), nil
+}
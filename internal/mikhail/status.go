@@ -0,0 +1,255 @@
+package mikhail
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Code mirrors google.golang.org/grpc/codes.Code's numbering exactly, so
+// a deployment wiring Mikhail into a real grpc.Server can convert one
+// with a plain codes.Code(status.Code) cast instead of a translation
+// table, the same "define the shape now, cast it into the real type at
+// the transport layer" approach UnaryServerInterceptor takes for grpc's
+// interceptor chain.
+type Code uint32
+
+const (
+	CodeOK                 Code = 0
+	CodeCanceled           Code = 1
+	CodeUnknown            Code = 2
+	CodeInvalidArgument    Code = 3
+	CodeDeadlineExceeded   Code = 4
+	CodeNotFound           Code = 5
+	CodeAlreadyExists      Code = 6
+	CodePermissionDenied   Code = 7
+	CodeResourceExhausted  Code = 8
+	CodeFailedPrecondition Code = 9
+	CodeAborted            Code = 10
+	CodeOutOfRange         Code = 11
+	CodeUnimplemented      Code = 12
+	CodeInternal           Code = 13
+	CodeUnavailable        Code = 14
+	CodeDataLoss           Code = 15
+	CodeUnauthenticated    Code = 16
+)
+
+// ErrorReason is a machine-readable reason string a client can switch on
+// without parsing Status.Message, mirroring the "reason" field of
+// google.rpc.ErrorInfo (the detail message grpc-gateway and
+// google.golang.org/genproto/googleapis/rpc/errdetails.ErrorInfo both
+// use), in the same UPPER_SNAKE_CASE convention.
+type ErrorReason string
+
+const (
+	ErrorReasonUnspecified                  ErrorReason = "ERROR_REASON_UNSPECIFIED"
+	ErrorReasonValidationFailed             ErrorReason = "VALIDATION_FAILED"
+	ErrorReasonInvalidCredentials           ErrorReason = "INVALID_CREDENTIALS"
+	ErrorReasonAccountSuspended             ErrorReason = "ACCOUNT_SUSPENDED"
+	ErrorReasonAccountDeleted               ErrorReason = "ACCOUNT_DELETED"
+	ErrorReasonPhoneAlreadyRegistered       ErrorReason = "PHONE_ALREADY_REGISTERED"
+	ErrorReasonTokenExpired                 ErrorReason = "TOKEN_EXPIRED"
+	ErrorReasonTokenNotFound                ErrorReason = "TOKEN_NOT_FOUND"
+	ErrorReasonRefreshTokenReused           ErrorReason = "REFRESH_TOKEN_REUSED"
+	ErrorReasonDeviceFingerprintMismatch    ErrorReason = "DEVICE_FINGERPRINT_MISMATCH"
+	ErrorReasonSessionAnomalyReauthRequired ErrorReason = "SESSION_ANOMALY_REAUTH_REQUIRED"
+	ErrorReasonScopeNotAllowed              ErrorReason = "SCOPE_NOT_ALLOWED"
+	ErrorReasonEmailNotVerified             ErrorReason = "EMAIL_NOT_VERIFIED"
+	ErrorReasonPhoneNotVerified             ErrorReason = "PHONE_NOT_VERIFIED"
+	ErrorReasonOTPInvalid                   ErrorReason = "OTP_INVALID"
+	ErrorReasonOTPAttemptsExceeded          ErrorReason = "OTP_ATTEMPTS_EXCEEDED"
+	ErrorReasonWebAuthnChallengeInvalid     ErrorReason = "WEBAUTHN_CHALLENGE_INVALID"
+	ErrorReasonWebAuthnCredentialUnknown    ErrorReason = "WEBAUTHN_CREDENTIAL_UNKNOWN"
+	ErrorReasonWebAuthnSignatureInvalid     ErrorReason = "WEBAUTHN_SIGNATURE_INVALID"
+	ErrorReasonTOTPNotEnrolled              ErrorReason = "TOTP_NOT_ENROLLED"
+	ErrorReasonTOTPCodeInvalid              ErrorReason = "TOTP_CODE_INVALID"
+	ErrorReasonRecoveryCodeInvalid          ErrorReason = "RECOVERY_CODE_INVALID"
+	ErrorReasonResetTokenInvalid            ErrorReason = "RESET_TOKEN_INVALID"
+	ErrorReasonMagicLinkInvalid             ErrorReason = "MAGIC_LINK_INVALID"
+	ErrorReasonSignInChallengeInvalid       ErrorReason = "SIGN_IN_CHALLENGE_INVALID"
+	ErrorReasonEmailVerificationInvalid     ErrorReason = "EMAIL_VERIFICATION_TOKEN_INVALID"
+	ErrorReasonAPIKeyRevoked                ErrorReason = "API_KEY_REVOKED"
+	ErrorReasonInvalidClientCredentials     ErrorReason = "INVALID_CLIENT_CREDENTIALS"
+	ErrorReasonNotAGuest                    ErrorReason = "NOT_A_GUEST"
+	ErrorReasonUpgradeGuestMissingIdentity  ErrorReason = "UPGRADE_GUEST_MISSING_IDENTITY"
+	ErrorReasonMissingBearerToken           ErrorReason = "MISSING_BEARER_TOKEN"
+	ErrorReasonInvalidBearerToken           ErrorReason = "INVALID_BEARER_TOKEN"
+	ErrorReasonMutualTLSRequired            ErrorReason = "MUTUAL_TLS_REQUIRED"
+	ErrorReasonOAuthProviderNotRegistered   ErrorReason = "OAUTH_PROVIDER_NOT_REGISTERED"
+	ErrorReasonLastSignInMethod             ErrorReason = "LAST_SIGN_IN_METHOD"
+	ErrorReasonNoLinkedProviderSession      ErrorReason = "NO_LINKED_PROVIDER_SESSION"
+	ErrorReasonPKCEVerificationFailed       ErrorReason = "PKCE_VERIFICATION_FAILED"
+	ErrorReasonOAuthProviderDisabled        ErrorReason = "OAUTH_PROVIDER_DISABLED"
+	ErrorReasonAllSmsProvidersFailed        ErrorReason = "ALL_SMS_PROVIDERS_FAILED"
+	ErrorReasonDeadlineTooShort             ErrorReason = "DEADLINE_TOO_SHORT"
+	ErrorReasonLoadShed                     ErrorReason = "LOAD_SHED"
+	ErrorReasonAdminAccessDenied            ErrorReason = "ADMIN_ACCESS_DENIED"
+	ErrorReasonRateLimitExceeded            ErrorReason = "RATE_LIMIT_EXCEEDED"
+	ErrorReasonBlocked                      ErrorReason = "BLOCKED"
+)
+
+// Status is Mikhail's transport-agnostic equivalent of
+// google.golang.org/grpc/status.Status plus a google.rpc.ErrorInfo
+// detail: a Code a real grpc.Server maps straight to a gRPC status code,
+// a human-readable Message, and a machine-readable Reason a client can
+// switch on without parsing Message. Err, if set, is the original
+// sentinel error (e.g. ErrInvalidCredentials) this Status was built
+// from, so existing errors.Is call sites and log lines keep working
+// unchanged even after a caller starts handling Status instead.
+type Status struct {
+	Code    Code
+	Reason  ErrorReason
+	Message string
+	Err     error
+	// Violations carries per-field validation failures, mirroring
+	// google.rpc.BadRequest.FieldViolation details, when Err wraps a
+	// *ValidationError. Empty otherwise.
+	Violations []FieldViolation
+	// RetryAfter mirrors google.rpc.RetryInfo.retry_delay: how long a
+	// client should wait before retrying, set when Err wraps a
+	// *LoadSheddingError or *RateLimitExceededError. Zero otherwise.
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface, so a *Status can be returned
+// from any AuthServer method exactly where it previously returned a
+// plain sentinel error.
+func (s *Status) Error() string {
+	if s.Message != "" {
+		return s.Message
+	}
+	if s.Err != nil {
+		return s.Err.Error()
+	}
+	return string(s.Reason)
+}
+
+// Unwrap exposes the original sentinel error to errors.Is/errors.As, so
+// a caller that still does if errors.Is(err, ErrInvalidCredentials)
+// after this migration keeps working unchanged.
+func (s *Status) Unwrap() error {
+	return s.Err
+}
+
+// NewStatus builds a Status from an explicit code, reason, and the
+// original error it replaces.
+func NewStatus(code Code, reason ErrorReason, err error) *Status {
+	message := string(reason)
+	if err != nil {
+		message = err.Error()
+	}
+	return &Status{Code: code, Reason: reason, Message: message, Err: err}
+}
+
+// statusByError maps each of Mikhail's sentinel errors to the gRPC code
+// and ErrorReason it should carry. Built once at package init rather
+// than as a long if/else chain in StatusFromError, so adding a new
+// sentinel error's mapping is a one-line addition here.
+var statusByError = map[error]struct {
+	code   Code
+	reason ErrorReason
+}{
+	ErrInvalidCredentials:            {CodeUnauthenticated, ErrorReasonInvalidCredentials},
+	ErrAccountSuspended:              {CodePermissionDenied, ErrorReasonAccountSuspended},
+	ErrAccountDeleted:                {CodePermissionDenied, ErrorReasonAccountDeleted},
+	ErrPhoneAlreadyRegistered:        {CodeAlreadyExists, ErrorReasonPhoneAlreadyRegistered},
+	ErrTokenExpired:                  {CodeUnauthenticated, ErrorReasonTokenExpired},
+	ErrTokenNotFound:                 {CodeUnauthenticated, ErrorReasonTokenNotFound},
+	ErrRefreshTokenReused:            {CodePermissionDenied, ErrorReasonRefreshTokenReused},
+	ErrDeviceFingerprintMismatch:     {CodePermissionDenied, ErrorReasonDeviceFingerprintMismatch},
+	ErrSessionAnomalyReauthRequired:  {CodePermissionDenied, ErrorReasonSessionAnomalyReauthRequired},
+	ErrScopeNotAllowed:               {CodePermissionDenied, ErrorReasonScopeNotAllowed},
+	ErrEmailNotVerified:              {CodeFailedPrecondition, ErrorReasonEmailNotVerified},
+	ErrPhoneNotVerified:              {CodeFailedPrecondition, ErrorReasonPhoneNotVerified},
+	ErrOTPInvalid:                    {CodeInvalidArgument, ErrorReasonOTPInvalid},
+	ErrOTPAttemptsExceeded:           {CodeResourceExhausted, ErrorReasonOTPAttemptsExceeded},
+	ErrWebAuthnChallengeInvalid:      {CodeInvalidArgument, ErrorReasonWebAuthnChallengeInvalid},
+	ErrWebAuthnCredentialUnknown:     {CodeNotFound, ErrorReasonWebAuthnCredentialUnknown},
+	ErrWebAuthnSignatureInvalid:      {CodeUnauthenticated, ErrorReasonWebAuthnSignatureInvalid},
+	ErrTOTPNotEnrolled:               {CodeFailedPrecondition, ErrorReasonTOTPNotEnrolled},
+	ErrTOTPCodeInvalid:               {CodeInvalidArgument, ErrorReasonTOTPCodeInvalid},
+	ErrRecoveryCodeInvalid:           {CodeInvalidArgument, ErrorReasonRecoveryCodeInvalid},
+	ErrResetTokenInvalid:             {CodeInvalidArgument, ErrorReasonResetTokenInvalid},
+	ErrMagicLinkInvalid:              {CodeInvalidArgument, ErrorReasonMagicLinkInvalid},
+	ErrSignInChallengeInvalid:        {CodeInvalidArgument, ErrorReasonSignInChallengeInvalid},
+	ErrEmailVerificationTokenInvalid: {CodeInvalidArgument, ErrorReasonEmailVerificationInvalid},
+	ErrAPIKeyRevoked:                 {CodePermissionDenied, ErrorReasonAPIKeyRevoked},
+	ErrInvalidClientCredentials:      {CodeUnauthenticated, ErrorReasonInvalidClientCredentials},
+	ErrNotAGuest:                     {CodeFailedPrecondition, ErrorReasonNotAGuest},
+	ErrUpgradeGuestMissingIdentity:   {CodeInvalidArgument, ErrorReasonUpgradeGuestMissingIdentity},
+	ErrMissingBearerToken:            {CodeUnauthenticated, ErrorReasonMissingBearerToken},
+	ErrInvalidBearerToken:            {CodeUnauthenticated, ErrorReasonInvalidBearerToken},
+	ErrMutualTLSRequired:             {CodeUnauthenticated, ErrorReasonMutualTLSRequired},
+	ErrOAuthProviderNotRegistered:    {CodeFailedPrecondition, ErrorReasonOAuthProviderNotRegistered},
+	ErrLastSignInMethod:              {CodeFailedPrecondition, ErrorReasonLastSignInMethod},
+	ErrNoLinkedProviderSession:       {CodeFailedPrecondition, ErrorReasonNoLinkedProviderSession},
+	ErrPKCEVerificationFailed:        {CodeUnauthenticated, ErrorReasonPKCEVerificationFailed},
+	ErrYandexOAuthDisabled:           {CodeFailedPrecondition, ErrorReasonOAuthProviderDisabled},
+	ErrAllSmsProvidersFailed:         {CodeUnavailable, ErrorReasonAllSmsProvidersFailed},
+	ErrDeadlineTooShort:              {CodeDeadlineExceeded, ErrorReasonDeadlineTooShort},
+	ErrAdminAccessDenied:             {CodePermissionDenied, ErrorReasonAdminAccessDenied},
+}
+
+// StatusFromError converts err into a Status: a known sentinel error
+// (compared with errors.Is, so a wrapped ErrInvalidCredentials still
+// matches) gets its mapped Code/Reason from statusByError; a nil err
+// gets CodeOK; anything else gets CodeInternal/ErrorReasonUnspecified.
+// A caller never has to nil-check before inspecting the result's Code.
+func StatusFromError(err error) *Status {
+	if err == nil {
+		return &Status{Code: CodeOK}
+	}
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		status := NewStatus(CodeInvalidArgument, ErrorReasonValidationFailed, err)
+		status.Violations = verr.Violations
+		return status
+	}
+	var lerr *LoadSheddingError
+	if errors.As(err, &lerr) {
+		status := NewStatus(CodeResourceExhausted, ErrorReasonLoadShed, err)
+		status.RetryAfter = lerr.RetryAfter
+		return status
+	}
+	var rerr *RateLimitExceededError
+	if errors.As(err, &rerr) {
+		status := NewStatus(CodeResourceExhausted, ErrorReasonRateLimitExceeded, err)
+		status.RetryAfter = rerr.RetryAfter
+		return status
+	}
+	var berr *BlockedError
+	if errors.As(err, &berr) {
+		return NewStatus(CodePermissionDenied, ErrorReasonBlocked, err)
+	}
+	for sentinel, mapped := range statusByError {
+		if errors.Is(err, sentinel) {
+			return NewStatus(mapped.code, mapped.reason, err)
+		}
+	}
+	return NewStatus(CodeInternal, ErrorReasonUnspecified, err)
+}
+
+// StatusMappingInterceptor returns a UnaryServerInterceptor that
+// converts whatever plain error an AuthServer method returns into a
+// *Status carrying a proper gRPC code and ErrorReason, so the transport
+// layer can send the client a real status code instead of the UNKNOWN
+// every plain Go error maps to today. *Status has no GRPCStatus()
+// method of its own: a real grpc.Server needs the transport layer to
+// convert Status.Code with a codes.Code cast (see Code).
+//
+// compatibilityMode, when true, disables the conversion and returns
+// handler's error unchanged: a deployment mid-migration that still has
+// clients pattern-matching on err.Error() substrings (instead of the new
+// Code/Reason) can install this interceptor ahead of time with
+// compatibilityMode true and flip it to false once every client has
+// moved off string-matching, without a second deploy to add the
+// interceptor itself.
+func StatusMappingInterceptor(compatibilityMode bool) UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err == nil || compatibilityMode {
+			return resp, err
+		}
+		return resp, StatusFromError(err)
+	}
+}
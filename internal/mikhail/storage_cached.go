@@ -0,0 +1,192 @@
+package mikhail
+
+import (
+	"sync"
+	"time"
+)
+
+// CachedTokenStorage wraps another TokenStorage with a short-TTL,
+// write-through in-memory cache of recently validated TokenInfo, to cut
+// the round-trip to Redis (or whichever TokenStorage Backend is) for
+// hot tokens being repeatedly validated during a traffic spike. Store
+// writes through to both the cache and Backend before returning; Get
+// serves from the cache when the cached entry has not yet expired and
+// otherwise falls through to Backend, populating the cache on the way
+// out. Delete and MarkRotated evict the affected entry from the cache
+// after the Backend call succeeds, so a revoked or rotated token cannot
+// keep being served stale out of cache. The family/user-wide revoke
+// methods cannot cheaply tell which cached entries they affected without
+// an inverse index the cache does not keep, so they clear the whole
+// cache instead: correct, if coarser than a per-token eviction.
+type CachedTokenStorage struct {
+	// Backend is the underlying TokenStorage the cache sits in front of.
+	Backend TokenStorage
+	// TTL is how long a cached TokenInfo is served before falling back
+	// to Backend again.
+	TTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedToken
+}
+
+type cachedToken struct {
+	info      TokenInfo
+	expiresAt time.Time
+}
+
+// NewCachedTokenStorage returns a CachedTokenStorage caching backend's
+// TokenInfo for up to ttl.
+func NewCachedTokenStorage(backend TokenStorage, ttl time.Duration) *CachedTokenStorage {
+	return &CachedTokenStorage{Backend: backend, TTL: ttl, cache: make(map[string]cachedToken)}
+}
+
+func (s *CachedTokenStorage) Store(refreshToken string, info TokenInfo) error {
+	if err := s.Backend.Store(refreshToken, info); err != nil {
+		return err
+	}
+	s.put(refreshToken, info)
+	return nil
+}
+
+func (s *CachedTokenStorage) Get(refreshToken string) (TokenInfo, bool, error) {
+	if info, ok := s.cached(refreshToken); ok {
+		return info, true, nil
+	}
+	info, ok, err := s.Backend.Get(refreshToken)
+	if err != nil || !ok {
+		return info, ok, err
+	}
+	s.put(refreshToken, info)
+	return info, true, nil
+}
+
+func (s *CachedTokenStorage) GetTokens(refreshTokens []string) (map[string]TokenInfo, error) {
+	found := make(map[string]TokenInfo, len(refreshTokens))
+	var misses []string
+	for _, refreshToken := range refreshTokens {
+		if info, ok := s.cached(refreshToken); ok {
+			found[refreshToken] = info
+		} else {
+			misses = append(misses, refreshToken)
+		}
+	}
+	if len(misses) == 0 {
+		return found, nil
+	}
+
+	fetched, err := s.Backend.GetTokens(misses)
+	if err != nil {
+		return nil, err
+	}
+	for refreshToken, info := range fetched {
+		s.put(refreshToken, info)
+		found[refreshToken] = info
+	}
+	return found, nil
+}
+
+func (s *CachedTokenStorage) DeleteTokens(refreshTokens []string) error {
+	if err := s.Backend.DeleteTokens(refreshTokens); err != nil {
+		return err
+	}
+	for _, refreshToken := range refreshTokens {
+		s.evict(refreshToken)
+	}
+	return nil
+}
+
+func (s *CachedTokenStorage) Delete(refreshToken string) error {
+	if err := s.Backend.Delete(refreshToken); err != nil {
+		return err
+	}
+	s.evict(refreshToken)
+	return nil
+}
+
+func (s *CachedTokenStorage) MarkRotated(refreshToken, successorToken string) error {
+	if err := s.Backend.MarkRotated(refreshToken, successorToken); err != nil {
+		return err
+	}
+	s.evict(refreshToken)
+	return nil
+}
+
+func (s *CachedTokenStorage) RotateToken(oldRefreshToken, newRefreshToken string, newInfo TokenInfo) error {
+	if err := s.Backend.RotateToken(oldRefreshToken, newRefreshToken, newInfo); err != nil {
+		return err
+	}
+	s.evict(oldRefreshToken)
+	s.put(newRefreshToken, newInfo)
+	return nil
+}
+
+func (s *CachedTokenStorage) RevokeFamily(familyID string) error {
+	if err := s.Backend.RevokeFamily(familyID); err != nil {
+		return err
+	}
+	s.clear()
+	return nil
+}
+
+func (s *CachedTokenStorage) RevokeAllForUser(userID string) error {
+	if err := s.Backend.RevokeAllForUser(userID); err != nil {
+		return err
+	}
+	s.clear()
+	return nil
+}
+
+func (s *CachedTokenStorage) RevokeAllForUserExceptFamily(userID, exceptFamilyID string) error {
+	if err := s.Backend.RevokeAllForUserExceptFamily(userID, exceptFamilyID); err != nil {
+		return err
+	}
+	s.clear()
+	return nil
+}
+
+func (s *CachedTokenStorage) ListByUser(userID string) ([]TokenInfo, error) {
+	return s.Backend.ListByUser(userID)
+}
+
+func (s *CachedTokenStorage) ListProviderSessions() (map[string]TokenInfo, error) {
+	return s.Backend.ListProviderSessions()
+}
+
+// CacheSize reports how many entries are currently cached, including any
+// already expired but not yet evicted by a lookup. Intended for the
+// debug server's expvar counters, not for any decision the cache itself
+// makes.
+func (s *CachedTokenStorage) CacheSize() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.cache)
+}
+
+func (s *CachedTokenStorage) cached(refreshToken string) (TokenInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.cache[refreshToken]
+	if !ok || time.Now().After(entry.expiresAt) {
+		delete(s.cache, refreshToken)
+		return TokenInfo{}, false
+	}
+	return entry.info, true
+}
+
+func (s *CachedTokenStorage) put(refreshToken string, info TokenInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache[refreshToken] = cachedToken{info: info, expiresAt: time.Now().Add(s.TTL)}
+}
+
+func (s *CachedTokenStorage) evict(refreshToken string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.cache, refreshToken)
+}
+
+func (s *CachedTokenStorage) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache = make(map[string]cachedToken)
+}
@@ -0,0 +1,283 @@
+package mikhail
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// EtcdKV is the minimal etcd surface EtcdTokenStorage needs: a
+// lease-backed put with a TTL, single-key get/delete, and a prefix
+// listing for the secondary indexes below. Mikhail depends only on this
+// interface rather than on etcd's client package, the same way
+// PostgresUserStore depends only on database/sql: callers wrap
+// go.etcd.io/etcd/client/v3 (clientv3.KV for Get/Delete/prefix Get,
+// clientv3.Lease for the TTL on Put) to satisfy it, so adding etcd
+// support here does not pull an etcd dependency into Mikhail itself.
+type EtcdKV interface {
+	// PutWithTTL writes value at key under a lease that expires the key
+	// automatically after ttl, which is how EtcdTokenStorage gets token
+	// expiration without a separate vacuum pass.
+	PutWithTTL(ctx context.Context, key, value string, ttl time.Duration) error
+	Get(ctx context.Context, key string) (value string, ok bool, err error)
+	Delete(ctx context.Context, key string) error
+	// ListByPrefix returns every key/value pair whose key starts with
+	// prefix, used to scan the user/family/provider indexes below.
+	ListByPrefix(ctx context.Context, prefix string) (map[string]string, error)
+}
+
+// Etcd key layout used by EtcdTokenStorage. The token itself is the
+// source of truth at tokensPrefix+refreshToken; the three index prefixes
+// below each map to that key so RevokeFamily, RevokeAllForUser and the
+// provider refresh scan can find it without a full scan. Every index
+// entry is written with the same lease as the token it points at, so it
+// expires alongside it instead of leaking stale index rows.
+const (
+	etcdTokensPrefix  = "mikhail/tokens/"
+	etcdUserIndex     = "mikhail/index/user/"
+	etcdFamilyIndex   = "mikhail/index/family/"
+	etcdProviderIndex = "mikhail/index/provider/"
+)
+
+// EtcdTokenStorage is a TokenStorage backed by etcd, for Kubernetes-
+// native deployments that already run etcd and want strong consistency
+// on session state instead of Redis's eventual one. Expiration is
+// native: each token's lease TTL is set to its remaining time to live,
+// so etcd itself reaps expired tokens without a background vacuumer.
+//
+// Watch-based invalidation (e.g. pushing SignOut/RevokeFamily events to
+// other Mikhail instances as they happen) is intentionally not wired in
+// here: EtcdKV is a plain KV contract, and Mikhail has no consumer today
+// that would subscribe to such a feed. A caller who needs that can watch
+// etcdTokensPrefix directly on their own clientv3.Watcher; EtcdTokenStorage
+// only needs to guarantee the key layout above is stable for them to do so.
+type EtcdTokenStorage struct {
+	kv EtcdKV
+}
+
+// NewEtcdTokenStorage wraps an EtcdKV implementation backed by an
+// already-connected etcd client.
+func NewEtcdTokenStorage(kv EtcdKV) *EtcdTokenStorage {
+	return &EtcdTokenStorage{kv: kv}
+}
+
+func (s *EtcdTokenStorage) Store(refreshToken string, info TokenInfo) error {
+	ctx := context.Background()
+	ttl := time.Until(info.ExpiresAt)
+	if ttl <= 0 {
+		return ErrTokenExpired
+	}
+
+	value, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	if err := s.kv.PutWithTTL(ctx, etcdTokensPrefix+refreshToken, string(value), ttl); err != nil {
+		return err
+	}
+	if err := s.kv.PutWithTTL(ctx, etcdUserIndex+info.UserID+"/"+refreshToken, refreshToken, ttl); err != nil {
+		return err
+	}
+	if err := s.kv.PutWithTTL(ctx, etcdFamilyIndex+info.FamilyID+"/"+refreshToken, refreshToken, ttl); err != nil {
+		return err
+	}
+	if info.Provider != "" {
+		if err := s.kv.PutWithTTL(ctx, etcdProviderIndex+refreshToken, refreshToken, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *EtcdTokenStorage) Get(refreshToken string) (TokenInfo, bool, error) {
+	value, ok, err := s.kv.Get(context.Background(), etcdTokensPrefix+refreshToken)
+	if err != nil || !ok {
+		return TokenInfo{}, ok, err
+	}
+	var info TokenInfo
+	if err := json.Unmarshal([]byte(value), &info); err != nil {
+		return TokenInfo{}, false, err
+	}
+	return info, true, nil
+}
+
+func (s *EtcdTokenStorage) Delete(refreshToken string) error {
+	info, ok, err := s.Get(refreshToken)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	return s.deleteWithIndexes(refreshToken, info)
+}
+
+// GetTokens looks up every refresh token in refreshTokens. EtcdKV has no
+// batched multi-key get (etcd's own Txn API could do one, but EtcdKV
+// does not expose it), so this loops Get the same as calling it once per
+// token would; it exists so callers can use the same TokenStorage
+// surface as the batched Redis/SQLite implementations.
+func (s *EtcdTokenStorage) GetTokens(refreshTokens []string) (map[string]TokenInfo, error) {
+	found := make(map[string]TokenInfo, len(refreshTokens))
+	for _, refreshToken := range refreshTokens {
+		info, ok, err := s.Get(refreshToken)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			found[refreshToken] = info
+		}
+	}
+	return found, nil
+}
+
+// DeleteTokens deletes every refresh token in refreshTokens. See
+// GetTokens for why this loops Delete rather than batching.
+func (s *EtcdTokenStorage) DeleteTokens(refreshTokens []string) error {
+	for _, refreshToken := range refreshTokens {
+		if err := s.Delete(refreshToken); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *EtcdTokenStorage) deleteWithIndexes(refreshToken string, info TokenInfo) error {
+	ctx := context.Background()
+	if err := s.kv.Delete(ctx, etcdTokensPrefix+refreshToken); err != nil {
+		return err
+	}
+	if err := s.kv.Delete(ctx, etcdUserIndex+info.UserID+"/"+refreshToken); err != nil {
+		return err
+	}
+	if err := s.kv.Delete(ctx, etcdFamilyIndex+info.FamilyID+"/"+refreshToken); err != nil {
+		return err
+	}
+	if info.Provider != "" {
+		if err := s.kv.Delete(ctx, etcdProviderIndex+refreshToken); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *EtcdTokenStorage) MarkRotated(refreshToken, successorToken string) error {
+	info, ok, err := s.Get(refreshToken)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	info.Rotated = true
+	info.RotatedAt = time.Now()
+	info.SuccessorToken = successorToken
+	return s.Store(refreshToken, info)
+}
+
+// RotateToken marks oldRefreshToken rotated and stores newInfo under
+// newRefreshToken. EtcdKV exposes no multi-key transaction (etcd's own
+// client has one via clientv3.Txn, but EtcdKV does not surface it), so
+// unlike RedisTokenStorage's Lua script or SQLiteTokenStorage's SQL
+// transaction, this is not actually atomic: a crash between the two
+// PutWithTTL calls can still leave only one applied. A caller that needs
+// a real guarantee here should extend EtcdKV with a Txn method backed by
+// clientv3.Txn and swap this implementation to use it.
+func (s *EtcdTokenStorage) RotateToken(oldRefreshToken, newRefreshToken string, newInfo TokenInfo) error {
+	oldInfo, ok, err := s.Get(oldRefreshToken)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrTokenExpired
+	}
+	oldInfo.Rotated = true
+	oldInfo.RotatedAt = time.Now()
+	oldInfo.SuccessorToken = newRefreshToken
+	if err := s.Store(oldRefreshToken, oldInfo); err != nil {
+		return err
+	}
+	return s.Store(newRefreshToken, newInfo)
+}
+
+func (s *EtcdTokenStorage) RevokeFamily(familyID string) error {
+	entries, err := s.kv.ListByPrefix(context.Background(), etcdFamilyIndex+familyID+"/")
+	if err != nil {
+		return err
+	}
+	for _, refreshToken := range entries {
+		if err := s.Delete(refreshToken); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *EtcdTokenStorage) RevokeAllForUser(userID string) error {
+	entries, err := s.kv.ListByPrefix(context.Background(), etcdUserIndex+userID+"/")
+	if err != nil {
+		return err
+	}
+	for _, refreshToken := range entries {
+		if err := s.Delete(refreshToken); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *EtcdTokenStorage) RevokeAllForUserExceptFamily(userID, exceptFamilyID string) error {
+	entries, err := s.kv.ListByPrefix(context.Background(), etcdUserIndex+userID+"/")
+	if err != nil {
+		return err
+	}
+	for _, refreshToken := range entries {
+		info, ok, err := s.Get(refreshToken)
+		if err != nil {
+			return err
+		}
+		if !ok || info.FamilyID == exceptFamilyID {
+			continue
+		}
+		if err := s.deleteWithIndexes(refreshToken, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *EtcdTokenStorage) ListByUser(userID string) ([]TokenInfo, error) {
+	entries, err := s.kv.ListByPrefix(context.Background(), etcdUserIndex+userID+"/")
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]TokenInfo, 0, len(entries))
+	for _, refreshToken := range entries {
+		info, ok, err := s.Get(refreshToken)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			sessions = append(sessions, info)
+		}
+	}
+	return sessions, nil
+}
+
+func (s *EtcdTokenStorage) ListProviderSessions() (map[string]TokenInfo, error) {
+	entries, err := s.kv.ListByPrefix(context.Background(), etcdProviderIndex)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make(map[string]TokenInfo, len(entries))
+	for _, refreshToken := range entries {
+		info, ok, err := s.Get(refreshToken)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			sessions[refreshToken] = info
+		}
+	}
+	return sessions, nil
+}
@@ -0,0 +1,113 @@
+package mikhail
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// TokenStorageBackend names a TokenStorage implementation
+// NewTokenStorageFromEnv knows how to select.
+type TokenStorageBackend string
+
+const (
+	TokenStorageBackendMemory   TokenStorageBackend = "memory"
+	TokenStorageBackendRedis    TokenStorageBackend = "redis"
+	TokenStorageBackendSQLite   TokenStorageBackend = "sqlite"
+	TokenStorageBackendEtcd     TokenStorageBackend = "etcd"
+	TokenStorageBackendDynamoDB TokenStorageBackend = "dynamodb"
+)
+
+// NewTokenStorageFromEnv selects and constructs a TokenStorage from
+// MIKHAIL_TOKEN_STORAGE_BACKEND, defaulting to in-memory storage when
+// unset so existing deployments that never set it keep working unchanged.
+//
+// Only the memory and redis backends can be fully constructed from
+// environment variables alone: sqlite, etcd and dynamodb each need a
+// caller-supplied handle (a *sql.DB opened with a registered driver, an
+// EtcdKV, or a DynamoDBClient respectively) that no string-keyed config
+// can produce on its own. For those, NewTokenStorageFromEnv only
+// validates that the name is recognized and returns a descriptive error
+// pointing at the matching constructor (NewSQLiteTokenStorage,
+// NewEtcdTokenStorage, NewDynamoDBTokenStorage) for the caller to use
+// directly instead.
+//
+// If MIKHAIL_TOKEN_STORAGE_CACHE_TTL is set, the selected backend is
+// wrapped in a CachedTokenStorage with that TTL, cutting round-trips to
+// a remote backend like Redis for hot tokens.
+//
+// If MIKHAIL_TOKEN_STORAGE_INSTRUMENT is "true", the result (including
+// any cache wrapping above) is further wrapped in an
+// InstrumentedTokenStorage; NewTokenStorageFromEnv's return type stays
+// TokenStorage, so a caller who wants at the stats has to keep the
+// concrete *InstrumentedTokenStorage themselves instead of going through
+// this function, e.g. by calling NewInstrumentedTokenStorage directly.
+func NewTokenStorageFromEnv() (TokenStorage, error) {
+	backend := TokenStorageBackend(os.Getenv("MIKHAIL_TOKEN_STORAGE_BACKEND"))
+	if backend == "" {
+		backend = TokenStorageBackendMemory
+	}
+
+	storage, err := newTokenStorageBackendFromEnv(backend)
+	if err != nil {
+		return nil, err
+	}
+
+	if rawTTL := os.Getenv("MIKHAIL_TOKEN_STORAGE_CACHE_TTL"); rawTTL != "" {
+		ttl, err := time.ParseDuration(rawTTL)
+		if err != nil {
+			return nil, fmt.Errorf("mikhail: invalid MIKHAIL_TOKEN_STORAGE_CACHE_TTL: %w", err)
+		}
+		storage = NewCachedTokenStorage(storage, ttl)
+	}
+
+	if os.Getenv("MIKHAIL_TOKEN_STORAGE_INSTRUMENT") == "true" {
+		storage = NewInstrumentedTokenStorage(storage)
+	}
+	return storage, nil
+}
+
+func newTokenStorageBackendFromEnv(backend TokenStorageBackend) (TokenStorage, error) {
+	switch backend {
+	case TokenStorageBackendMemory:
+		maxSize := defaultInMemoryTokenStorageCapacity
+		if err := overrideInt(&maxSize, "MIKHAIL_TOKEN_STORAGE_MAX_SIZE"); err != nil {
+			return nil, err
+		}
+		return NewInMemoryTokenStorageWithCapacity(maxSize), nil
+	case TokenStorageBackendRedis:
+		addr := os.Getenv("MIKHAIL_REDIS_ADDR")
+		if addr == "" {
+			return nil, fmt.Errorf("mikhail: MIKHAIL_TOKEN_STORAGE_BACKEND=redis requires MIKHAIL_REDIS_ADDR")
+		}
+		storage, err := NewRedisTokenStorage(addr)
+		if err != nil {
+			return nil, err
+		}
+		if secret := os.Getenv("MIKHAIL_REDIS_TOKEN_KEY_SECRET"); secret != "" {
+			storage.Secret = []byte(secret)
+		}
+		if replicaAddrs := os.Getenv("MIKHAIL_REDIS_READ_REPLICA_ADDRS"); replicaAddrs != "" {
+			storage.SetReplicas(strings.Split(replicaAddrs, ","))
+		}
+		return storage, nil
+	case TokenStorageBackendSQLite, TokenStorageBackendEtcd, TokenStorageBackendDynamoDB:
+		return nil, fmt.Errorf("mikhail: token storage backend %q requires a caller-constructed client; build it with New%sTokenStorage and pass it to NewAuthServer directly instead of NewTokenStorageFromEnv", backend, backendConstructorName(backend))
+	default:
+		return nil, fmt.Errorf("mikhail: unknown MIKHAIL_TOKEN_STORAGE_BACKEND %q", backend)
+	}
+}
+
+func backendConstructorName(backend TokenStorageBackend) string {
+	switch backend {
+	case TokenStorageBackendSQLite:
+		return "SQLite"
+	case TokenStorageBackendEtcd:
+		return "Etcd"
+	case TokenStorageBackendDynamoDB:
+		return "DynamoDB"
+	default:
+		return string(backend)
+	}
+}
@@ -0,0 +1,246 @@
+package mikhail
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyHistogramBounds are the upper bounds StorageOpLatency buckets
+// observed durations into. They are coarse on purpose: this package has
+// no metrics library to lean on, and knowing whether an operation
+// usually finishes in microseconds or has a long tail into hundreds of
+// milliseconds is enough to act on without needing real percentiles.
+var latencyHistogramBounds = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	25 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+}
+
+// StorageOpLatency is a cumulative latency histogram for one
+// InstrumentedTokenStorage operation.
+type StorageOpLatency struct {
+	mu      sync.Mutex
+	buckets [6]uint64 // len(latencyHistogramBounds) + 1, the last bucket is "+Inf"
+	count   uint64
+	sum     time.Duration
+}
+
+func (h *StorageOpLatency) observe(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.count++
+	h.sum += d
+	for i, bound := range latencyHistogramBounds {
+		if d <= bound {
+			h.buckets[i]++
+			return
+		}
+	}
+	h.buckets[len(latencyHistogramBounds)]++
+}
+
+// Snapshot returns the histogram's bucket counts (one more than
+// latencyHistogramBounds has entries, the extra bucket being "+Inf"),
+// the total observation count, and the mean observed duration.
+func (h *StorageOpLatency) Snapshot() (buckets []uint64, count uint64, mean time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	buckets = append(buckets, h.buckets[:]...)
+	if h.count > 0 {
+		mean = h.sum / time.Duration(h.count)
+	}
+	return buckets, h.count, mean
+}
+
+// StorageOpStats tracks how many times one TokenStorage method was
+// called through an InstrumentedTokenStorage, how many of those calls
+// returned an error, and how long they took.
+type StorageOpStats struct {
+	Calls   uint64
+	Errors  uint64
+	Latency StorageOpLatency
+}
+
+func (s *StorageOpStats) record(start time.Time, err error) {
+	atomic.AddUint64(&s.Calls, 1)
+	if err != nil {
+		atomic.AddUint64(&s.Errors, 1)
+	}
+	s.Latency.observe(time.Since(start))
+}
+
+// InstrumentedTokenStorage wraps another TokenStorage, recording a
+// StorageOpStats per method plus the Get/GetTokens hit/miss ratio and an estimate of
+// how many tokens Backend currently holds, all exposed as plain fields
+// and getters rather than through a metrics library this package does
+// not depend on; a caller wanting these on a /metrics endpoint reads
+// them from here and registers them with whichever metrics client their
+// binary already uses.
+//
+// Cardinality is necessarily an estimate: it is adjusted by Store,
+// RotateToken and Delete/DeleteTokens, but RevokeFamily,
+// RevokeAllForUser and RevokeAllForUserExceptFamily can remove an
+// unknown number of tokens in one call and are not accounted for, so
+// Cardinality can drift high after heavy use of those. Call Resync with
+// a fresh count (e.g. from ListByUser across every user, if a deployment
+// can afford that) to correct it.
+type InstrumentedTokenStorage struct {
+	Backend TokenStorage
+
+	StoreStats                        StorageOpStats
+	GetStats                          StorageOpStats
+	DeleteStats                       StorageOpStats
+	GetTokensStats                    StorageOpStats
+	DeleteTokensStats                 StorageOpStats
+	RotateTokenStats                  StorageOpStats
+	MarkRotatedStats                  StorageOpStats
+	RevokeFamilyStats                 StorageOpStats
+	RevokeAllForUserStats             StorageOpStats
+	RevokeAllForUserExceptFamilyStats StorageOpStats
+	ListByUserStats                   StorageOpStats
+	ListProviderSessionsStats         StorageOpStats
+
+	hits, misses uint64
+	cardinality  int64
+}
+
+// NewInstrumentedTokenStorage returns an InstrumentedTokenStorage
+// wrapping backend.
+func NewInstrumentedTokenStorage(backend TokenStorage) *InstrumentedTokenStorage {
+	return &InstrumentedTokenStorage{Backend: backend}
+}
+
+// HitRatio returns the cache-style hit ratio of Get and GetTokens: the
+// fraction of tokens looked up through either that were found, in
+// [0, 1]. It returns 0 if no token has been looked up yet.
+func (s *InstrumentedTokenStorage) HitRatio() float64 {
+	hits := atomic.LoadUint64(&s.hits)
+	misses := atomic.LoadUint64(&s.misses)
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+// Cardinality returns the current estimated token count. See the type
+// doc comment for how it is kept and where it can drift.
+func (s *InstrumentedTokenStorage) Cardinality() int64 {
+	return atomic.LoadInt64(&s.cardinality)
+}
+
+// Resync overwrites Cardinality with count, for a caller that has just
+// computed the true figure some other way (e.g. summing ListByUser
+// across every known user) and wants to correct any drift.
+func (s *InstrumentedTokenStorage) Resync(count int64) {
+	atomic.StoreInt64(&s.cardinality, count)
+}
+
+func (s *InstrumentedTokenStorage) Store(refreshToken string, info TokenInfo) error {
+	start := time.Now()
+	err := s.Backend.Store(refreshToken, info)
+	s.StoreStats.record(start, err)
+	if err == nil {
+		atomic.AddInt64(&s.cardinality, 1)
+	}
+	return err
+}
+
+func (s *InstrumentedTokenStorage) Get(refreshToken string) (TokenInfo, bool, error) {
+	start := time.Now()
+	info, ok, err := s.Backend.Get(refreshToken)
+	s.GetStats.record(start, err)
+	if err == nil {
+		if ok {
+			atomic.AddUint64(&s.hits, 1)
+		} else {
+			atomic.AddUint64(&s.misses, 1)
+		}
+	}
+	return info, ok, err
+}
+
+func (s *InstrumentedTokenStorage) Delete(refreshToken string) error {
+	start := time.Now()
+	err := s.Backend.Delete(refreshToken)
+	s.DeleteStats.record(start, err)
+	if err == nil {
+		atomic.AddInt64(&s.cardinality, -1)
+	}
+	return err
+}
+
+func (s *InstrumentedTokenStorage) GetTokens(refreshTokens []string) (map[string]TokenInfo, error) {
+	start := time.Now()
+	found, err := s.Backend.GetTokens(refreshTokens)
+	s.GetTokensStats.record(start, err)
+	if err == nil {
+		atomic.AddUint64(&s.hits, uint64(len(found)))
+		atomic.AddUint64(&s.misses, uint64(len(refreshTokens)-len(found)))
+	}
+	return found, err
+}
+
+func (s *InstrumentedTokenStorage) DeleteTokens(refreshTokens []string) error {
+	start := time.Now()
+	err := s.Backend.DeleteTokens(refreshTokens)
+	s.DeleteTokensStats.record(start, err)
+	if err == nil {
+		atomic.AddInt64(&s.cardinality, -int64(len(refreshTokens)))
+	}
+	return err
+}
+
+func (s *InstrumentedTokenStorage) RotateToken(oldRefreshToken, newRefreshToken string, newInfo TokenInfo) error {
+	start := time.Now()
+	err := s.Backend.RotateToken(oldRefreshToken, newRefreshToken, newInfo)
+	s.RotateTokenStats.record(start, err)
+	if err == nil {
+		atomic.AddInt64(&s.cardinality, 1)
+	}
+	return err
+}
+
+func (s *InstrumentedTokenStorage) MarkRotated(refreshToken, successorToken string) error {
+	start := time.Now()
+	err := s.Backend.MarkRotated(refreshToken, successorToken)
+	s.MarkRotatedStats.record(start, err)
+	return err
+}
+
+func (s *InstrumentedTokenStorage) RevokeFamily(familyID string) error {
+	start := time.Now()
+	err := s.Backend.RevokeFamily(familyID)
+	s.RevokeFamilyStats.record(start, err)
+	return err
+}
+
+func (s *InstrumentedTokenStorage) RevokeAllForUser(userID string) error {
+	start := time.Now()
+	err := s.Backend.RevokeAllForUser(userID)
+	s.RevokeAllForUserStats.record(start, err)
+	return err
+}
+
+func (s *InstrumentedTokenStorage) RevokeAllForUserExceptFamily(userID, exceptFamilyID string) error {
+	start := time.Now()
+	err := s.Backend.RevokeAllForUserExceptFamily(userID, exceptFamilyID)
+	s.RevokeAllForUserExceptFamilyStats.record(start, err)
+	return err
+}
+
+func (s *InstrumentedTokenStorage) ListByUser(userID string) ([]TokenInfo, error) {
+	start := time.Now()
+	sessions, err := s.Backend.ListByUser(userID)
+	s.ListByUserStats.record(start, err)
+	return sessions, err
+}
+
+func (s *InstrumentedTokenStorage) ListProviderSessions() (map[string]TokenInfo, error) {
+	start := time.Now()
+	sessions, err := s.Backend.ListProviderSessions()
+	s.ListProviderSessionsStats.record(start, err)
+	return sessions, err
+}
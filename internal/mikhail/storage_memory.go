@@ -0,0 +1,270 @@
+package mikhail
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// defaultInMemoryTokenStorageCapacity is the maxSize NewInMemoryTokenStorage
+// applies when the caller does not pick one explicitly.
+const defaultInMemoryTokenStorageCapacity = 100000
+
+// InMemoryTokenStorage is a TokenStorage backed by a guarded map. It is
+// meant for local development and tests; RedisTokenStorage is used in
+// production deployments.
+//
+// It is bounded by maxSize: once full, storing a new token evicts the
+// least recently used one (tracked via order/elements) rather than
+// rejecting the new token outright, so a burst of sign-ins past maxSize
+// degrades older sessions instead of locking out every new login.
+// Evictions is a running count of how many tokens have been evicted this
+// way, for callers to monitor how close maxSize is to causing churn.
+type InMemoryTokenStorage struct {
+	mu       sync.RWMutex
+	maxSize  int
+	tokens   map[string]TokenInfo
+	elements map[string]*list.Element
+	// order is the LRU list: Front is most recently used, Back is the
+	// next eviction candidate. Each element's Value is a refresh token.
+	order *list.List
+	// byUser indexes active refresh tokens by owning user so
+	// RevokeAllForUser does not need to scan every token.
+	byUser    map[string]map[string]struct{}
+	evictions uint64
+}
+
+// NewInMemoryTokenStorage returns an empty InMemoryTokenStorage bounded
+// at defaultInMemoryTokenStorageCapacity. Use
+// NewInMemoryTokenStorageWithCapacity for a different limit.
+func NewInMemoryTokenStorage() *InMemoryTokenStorage {
+	return NewInMemoryTokenStorageWithCapacity(defaultInMemoryTokenStorageCapacity)
+}
+
+// NewInMemoryTokenStorageWithCapacity returns an empty InMemoryTokenStorage
+// that evicts its least recently used token once it holds maxSize of
+// them. maxSize <= 0 means unbounded.
+func NewInMemoryTokenStorageWithCapacity(maxSize int) *InMemoryTokenStorage {
+	return &InMemoryTokenStorage{
+		maxSize:  maxSize,
+		tokens:   make(map[string]TokenInfo),
+		elements: make(map[string]*list.Element),
+		order:    list.New(),
+		byUser:   make(map[string]map[string]struct{}),
+	}
+}
+
+func (s *InMemoryTokenStorage) Store(refreshToken string, info TokenInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[refreshToken] = info
+	s.indexUser(info.UserID, refreshToken)
+	s.touch(refreshToken)
+	s.evictIfOverCapacity()
+	return nil
+}
+
+func (s *InMemoryTokenStorage) Get(refreshToken string) (TokenInfo, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, ok := s.tokens[refreshToken]
+	if ok {
+		s.touch(refreshToken)
+	}
+	return info, ok, nil
+}
+
+func (s *InMemoryTokenStorage) Delete(refreshToken string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(refreshToken)
+	return nil
+}
+
+func (s *InMemoryTokenStorage) GetTokens(refreshTokens []string) (map[string]TokenInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := make(map[string]TokenInfo, len(refreshTokens))
+	for _, refreshToken := range refreshTokens {
+		if info, ok := s.tokens[refreshToken]; ok {
+			s.touch(refreshToken)
+			found[refreshToken] = info
+		}
+	}
+	return found, nil
+}
+
+func (s *InMemoryTokenStorage) DeleteTokens(refreshTokens []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, refreshToken := range refreshTokens {
+		s.remove(refreshToken)
+	}
+	return nil
+}
+
+func (s *InMemoryTokenStorage) MarkRotated(refreshToken, successorToken string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, ok := s.tokens[refreshToken]
+	if !ok {
+		return nil
+	}
+	info.Rotated = true
+	info.RotatedAt = time.Now()
+	info.SuccessorToken = successorToken
+	s.tokens[refreshToken] = info
+	return nil
+}
+
+func (s *InMemoryTokenStorage) RotateToken(oldRefreshToken, newRefreshToken string, newInfo TokenInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	oldInfo, ok := s.tokens[oldRefreshToken]
+	if !ok {
+		return ErrTokenExpired
+	}
+	oldInfo.Rotated = true
+	oldInfo.RotatedAt = time.Now()
+	oldInfo.SuccessorToken = newRefreshToken
+	s.tokens[oldRefreshToken] = oldInfo
+	s.touch(oldRefreshToken)
+
+	s.tokens[newRefreshToken] = newInfo
+	s.indexUser(newInfo.UserID, newRefreshToken)
+	s.touch(newRefreshToken)
+	s.evictIfOverCapacity()
+	return nil
+}
+
+func (s *InMemoryTokenStorage) RevokeFamily(familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token, info := range s.tokens {
+		if info.FamilyID == familyID {
+			s.remove(token)
+		}
+	}
+	return nil
+}
+
+func (s *InMemoryTokenStorage) RevokeAllForUser(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token := range s.byUser[userID] {
+		s.remove(token)
+	}
+	return nil
+}
+
+func (s *InMemoryTokenStorage) RevokeAllForUserExceptFamily(userID, exceptFamilyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token := range s.byUser[userID] {
+		if s.tokens[token].FamilyID == exceptFamilyID {
+			continue
+		}
+		s.remove(token)
+	}
+	return nil
+}
+
+func (s *InMemoryTokenStorage) ListByUser(userID string) ([]TokenInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sessions := make([]TokenInfo, 0, len(s.byUser[userID]))
+	for token := range s.byUser[userID] {
+		sessions = append(sessions, s.tokens[token])
+	}
+	return sessions, nil
+}
+
+func (s *InMemoryTokenStorage) ListProviderSessions() (map[string]TokenInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sessions := make(map[string]TokenInfo)
+	for refreshToken, info := range s.tokens {
+		if info.Provider != "" {
+			sessions[refreshToken] = info
+		}
+	}
+	return sessions, nil
+}
+
+// Evictions returns how many tokens have been evicted for capacity so
+// far, for callers to monitor via metrics.
+func (s *InMemoryTokenStorage) Evictions() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.evictions
+}
+
+// ScanExpiring implements ExpiringTokenScanner for TokenExpirationPoller:
+// InMemoryTokenStorage has no TTL of its own (a token only leaves it via
+// Delete, a revoke, or LRU eviction), so without a poller a token past
+// ExpiresAt would otherwise sit around, still servable by Get, until
+// something else removes it.
+func (s *InMemoryTokenStorage) ScanExpiring(before time.Time) (map[string]TokenInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	expired := make(map[string]TokenInfo)
+	for refreshToken, info := range s.tokens {
+		if !info.ExpiresAt.After(before) {
+			expired[refreshToken] = info
+		}
+	}
+	return expired, nil
+}
+
+// touch must be called with mu held. It marks refreshToken as the most
+// recently used entry, moving it to the front of order.
+func (s *InMemoryTokenStorage) touch(refreshToken string) {
+	if element, ok := s.elements[refreshToken]; ok {
+		s.order.MoveToFront(element)
+		return
+	}
+	s.elements[refreshToken] = s.order.PushFront(refreshToken)
+}
+
+// evictIfOverCapacity must be called with mu held. It evicts the least
+// recently used tokens until the store is back within maxSize.
+func (s *InMemoryTokenStorage) evictIfOverCapacity() {
+	if s.maxSize <= 0 {
+		return
+	}
+	for len(s.tokens) > s.maxSize {
+		oldest := s.order.Back()
+		if oldest == nil {
+			return
+		}
+		s.remove(oldest.Value.(string))
+		s.evictions++
+	}
+}
+
+// indexUser must be called with mu held.
+func (s *InMemoryTokenStorage) indexUser(userID, refreshToken string) {
+	tokens, ok := s.byUser[userID]
+	if !ok {
+		tokens = make(map[string]struct{})
+		s.byUser[userID] = tokens
+	}
+	tokens[refreshToken] = struct{}{}
+}
+
+// remove must be called with mu held.
+func (s *InMemoryTokenStorage) remove(refreshToken string) {
+	info, ok := s.tokens[refreshToken]
+	if !ok {
+		return
+	}
+	delete(s.tokens, refreshToken)
+	delete(s.byUser[info.UserID], refreshToken)
+	if len(s.byUser[info.UserID]) == 0 {
+		delete(s.byUser, info.UserID)
+	}
+	if element, ok := s.elements[refreshToken]; ok {
+		s.order.Remove(element)
+		delete(s.elements, refreshToken)
+	}
+}
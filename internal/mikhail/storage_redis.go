@@ -0,0 +1,931 @@
+package mikhail
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrInvalidRedisURL is returned by NewRedisTokenStorageFromURL when the
+// URL is not a redis:// or redis+cluster:// URL.
+var ErrInvalidRedisURL = errors.New("mikhail: redis url must use the redis:// or redis+cluster:// scheme")
+
+// RedisTokenStorage is a TokenStorage backed by Redis, for production
+// deployments that need every Mikhail instance to share session state.
+// Tokens are stored JSON-encoded with a TTL matching their expiry, the
+// same way RedisOTPStore stores OTPs, so Redis itself reaps expired
+// tokens without a background vacuumer.
+//
+// The token and per-user index keys are hash-tagged on the owning user
+// ID (mikhail:token:{<userID>}:<refreshToken> and mikhail:user:{<userID>})
+// so they land on the same slot on a clustered deployment: Store and
+// Delete touch both together, and Cluster rejects multi-key commands
+// that cross slots. The family index (mikhail:family:<familyID>) is
+// keyed by family rather than user, so it is not co-located with the
+// token key it points at; RevokeFamily's lookup may cross slots on a
+// clustered deployment. Cluster records whether Addr was given as a
+// redis+cluster:// URL, but redisClient itself is a single-node RESP2
+// client with no MOVED/ASK redirection, so it cannot route around a
+// cross-slot command the way a real cluster client would. Point Addr at
+// a cluster-aware proxy (e.g. a Redis Cluster-mode Envoy listener) for a
+// multi-node deployment until redisClient grows real topology support.
+//
+// When built via NewRedisTokenStorageFromSentinel, sentinel holds the
+// Sentinel set to re-resolve the current primary from, and every
+// operation that hits a connection error reconnects through Sentinel
+// and retries once, so a primary failover costs one failed op rather
+// than a restart. Failovers counts how many times that reconnect landed
+// on a different address than before, for callers to alert on.
+//
+// Call SetReplicas to have Get and GetTokens read from a pool of Redis
+// read replicas round-robin instead of always hitting the primary,
+// falling back to the primary on a replica miss or error. All writes
+// and every other read still go to the primary regardless of Replicas.
+type RedisTokenStorage struct {
+	mu      sync.Mutex
+	client  *redisClient
+	addr    string
+	Cluster bool
+
+	sentinel  *RedisSentinelConfig
+	Failovers uint64
+
+	// Keyring, when set, encrypts every token's JSON payload with
+	// AES-256-GCM before writing it to Redis and decrypts it on read.
+	// Leave nil to store payloads as plain JSON, as before encryption
+	// support existed.
+	Keyring *TokenEncryptionKeyring
+
+	// Secret, when set, is used to derive every token and owner key from
+	// an HMAC-SHA256 of the refresh token rather than the refresh token
+	// itself, so a refresh token cannot be read back out of Redis key
+	// names by anyone with read access to the keyspace (e.g. via SCAN or
+	// MONITOR). Leave nil to key on the refresh token verbatim, as before
+	// hashing support existed. Get lazily migrates a key found under the
+	// old plaintext scheme to the hashed one, so Secret can be turned on
+	// for an existing deployment without a flush.
+	Secret []byte
+
+	// Tracer, when set, wraps every Redis command RedisTokenStorage
+	// issues (set/get/del/sadd/srem/mget/delMany/smembers/eval) in its
+	// own span. Since TokenStorage's methods take no context.Context,
+	// these spans start from context.Background() rather than being
+	// parented off of the RPC span the call happened under; a caller
+	// wanting that correlation needs a Tracer implementation that can
+	// pick up an ambient current-span from elsewhere (e.g. goroutine-
+	// local state a real OTel SDK does not provide either). Leave nil
+	// (the default) for no tracing.
+	Tracer Tracer
+
+	replicas    []*redisReplica
+	nextReplica uint64
+}
+
+// redisReadTarget is anything RedisTokenStorage's read paths can issue
+// GET/MGET against: the primary (*RedisTokenStorage itself, via its
+// pooled connection) or a configured read replica. Parametrizing
+// getByKeyComponent and GetTokens over this lets them run unchanged
+// against whichever target Get/GetTokens pick.
+type redisReadTarget interface {
+	get(key string) (string, bool, error)
+	mget(keys []string) ([]string, []bool, error)
+}
+
+// redisReplica is one read replica connection a RedisTokenStorage reads
+// from in preference to the primary, for GetTokenInfo-heavy call paths
+// like VerifyToken that would otherwise all land on the primary. It
+// dials lazily and redials on error rather than reconnecting through
+// Sentinel the way the primary does: a replica set is usually addressed
+// directly or through a replica-aware proxy, not Sentinel, so there is
+// no separate address to re-resolve here.
+type redisReplica struct {
+	addr string
+
+	mu     sync.Mutex
+	client *redisClient
+}
+
+func (r *redisReplica) withClient(op func(*redisClient) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.client == nil {
+		client, err := dialRedis(r.addr)
+		if err != nil {
+			return err
+		}
+		r.client = client
+	}
+	if err := op(r.client); err != nil {
+		r.client.Close()
+		r.client = nil
+		return err
+	}
+	return nil
+}
+
+func (r *redisReplica) get(key string) (string, bool, error) {
+	var value string
+	var ok bool
+	err := r.withClient(func(c *redisClient) error {
+		var opErr error
+		value, ok, opErr = c.Get(key)
+		return opErr
+	})
+	return value, ok, err
+}
+
+func (r *redisReplica) mget(keys []string) ([]string, []bool, error) {
+	var values []string
+	var oks []bool
+	err := r.withClient(func(c *redisClient) error {
+		var opErr error
+		values, oks, opErr = c.MGet(keys)
+		return opErr
+	})
+	return values, oks, err
+}
+
+// SetReplicas configures addrs as read replicas for Get and GetTokens to
+// prefer over the primary, round-robin across addrs. Connections are
+// dialed lazily on first use, so an address that is briefly unreachable
+// when SetReplicas is called does not fail it; a replica that errors on
+// a later read is simply skipped in favor of the primary for that call
+// and redialed on the next one. Pass nil to stop reading from replicas.
+func (s *RedisTokenStorage) SetReplicas(addrs []string) {
+	replicas := make([]*redisReplica, len(addrs))
+	for i, addr := range addrs {
+		replicas[i] = &redisReplica{addr: addr}
+	}
+	s.mu.Lock()
+	s.replicas = replicas
+	s.mu.Unlock()
+}
+
+// pickReplica returns the next replica to read from in round-robin
+// order, or nil if none are configured.
+func (s *RedisTokenStorage) pickReplica() *redisReplica {
+	s.mu.Lock()
+	replicas := s.replicas
+	s.mu.Unlock()
+	if len(replicas) == 0 {
+		return nil
+	}
+	idx := atomic.AddUint64(&s.nextReplica, 1)
+	return replicas[idx%uint64(len(replicas))]
+}
+
+// RedisSentinelConfig configures automatic Redis primary failover via
+// Sentinel: SentinelAddrs are queried in order for the current primary
+// behind MasterName until one answers.
+type RedisSentinelConfig struct {
+	SentinelAddrs []string
+	MasterName    string
+}
+
+// NewRedisTokenStorage dials addr ("host:port") and returns a
+// RedisTokenStorage backed by that connection.
+func NewRedisTokenStorage(addr string) (*RedisTokenStorage, error) {
+	client, err := dialRedis(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisTokenStorage{client: client, addr: addr}, nil
+}
+
+// NewRedisTokenStorageFromURL dials a redis:// or redis+cluster:// URL,
+// recording which scheme was used in Cluster. A redis+cluster:// URL may
+// list several comma-separated seed addresses (as a real cluster client
+// would take); since redisClient only ever dials one address, the first
+// is used and the rest are assumed reachable via the same proxy/cluster.
+func NewRedisTokenStorageFromURL(rawURL string) (*RedisTokenStorage, error) {
+	scheme, rest, ok := strings.Cut(rawURL, "://")
+	if !ok {
+		return nil, ErrInvalidRedisURL
+	}
+	cluster := scheme == "redis+cluster"
+	if !cluster && scheme != "redis" {
+		return nil, ErrInvalidRedisURL
+	}
+
+	addr, _, _ := strings.Cut(rest, ",")
+	storage, err := NewRedisTokenStorage(addr)
+	if err != nil {
+		return nil, err
+	}
+	storage.Cluster = cluster
+	return storage, nil
+}
+
+// NewRedisTokenStorageFromSentinel resolves the current Redis primary
+// for cfg.MasterName via cfg.SentinelAddrs and returns a
+// RedisTokenStorage connected to it that automatically re-resolves and
+// reconnects through Sentinel if that connection is lost.
+func NewRedisTokenStorageFromSentinel(cfg RedisSentinelConfig) (*RedisTokenStorage, error) {
+	addr, err := resolveSentinelMaster(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client, err := dialRedis(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisTokenStorage{client: client, addr: addr, sentinel: &cfg}, nil
+}
+
+// resolveSentinelMaster asks each Sentinel in cfg.SentinelAddrs in turn
+// for the address of the current primary behind cfg.MasterName, using
+// the first one that answers.
+func resolveSentinelMaster(cfg RedisSentinelConfig) (string, error) {
+	var lastErr error
+	for _, sentinelAddr := range cfg.SentinelAddrs {
+		addr, err := queryMasterAddr(sentinelAddr, cfg.MasterName)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return addr, nil
+	}
+	if lastErr == nil {
+		lastErr = errors.New("mikhail: no sentinel addresses configured")
+	}
+	return "", lastErr
+}
+
+func queryMasterAddr(sentinelAddr, masterName string) (string, error) {
+	sentinel, err := dialRedis(sentinelAddr)
+	if err != nil {
+		return "", err
+	}
+	defer sentinel.Close()
+
+	parts, err := sentinel.doArray("SENTINEL", "get-master-addr-by-name", masterName)
+	if err != nil {
+		return "", err
+	}
+	if len(parts) != 2 {
+		return "", errors.New("mikhail: sentinel returned no primary for " + masterName)
+	}
+	return parts[0] + ":" + parts[1], nil
+}
+
+// reconnect must be called with mu held. It re-resolves the primary via
+// Sentinel (when configured) and redials, counting a Failover when the
+// resolved address changed.
+func (s *RedisTokenStorage) reconnect() error {
+	addr := s.addr
+	if s.sentinel != nil {
+		resolved, err := resolveSentinelMaster(*s.sentinel)
+		if err != nil {
+			return err
+		}
+		addr = resolved
+	}
+
+	client, err := dialRedis(addr)
+	if err != nil {
+		return err
+	}
+	if s.client != nil {
+		s.client.Close()
+	}
+	if addr != s.addr {
+		s.Failovers++
+		log.Printf("mikhail: redis failover detected, now using %s (was %s)", addr, s.addr)
+	}
+	s.client, s.addr = client, addr
+	return nil
+}
+
+// withClient runs op against the current connection, reconnecting
+// through Sentinel and retrying once if op's first attempt fails and
+// Sentinel is configured. Non-Sentinel RedisTokenStorage instances
+// surface the error from the first attempt unchanged, same as before
+// Sentinel support existed.
+func (s *RedisTokenStorage) withClient(op func(*redisClient) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := op(s.client)
+	if err == nil || s.sentinel == nil {
+		return err
+	}
+	if reconnectErr := s.reconnect(); reconnectErr != nil {
+		return err
+	}
+	return op(s.client)
+}
+
+// tokenKeyComponent returns the string used in place of the refresh
+// token itself when building redisTokenOwnerKey and redisTokenKey: the
+// refresh token verbatim when Secret is unset, or an HMAC-SHA256 of it
+// (so the key name does not leak the token) when it is.
+func (s *RedisTokenStorage) tokenKeyComponent(refreshToken string) string {
+	if len(s.Secret) == 0 {
+		return refreshToken
+	}
+	return hashToken(s.Secret, refreshToken)
+}
+
+func hashToken(secret []byte, token string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func redisTokenOwnerKey(tokenComponent string) string {
+	return "mikhail:owner:" + tokenComponent
+}
+
+func redisTokenKey(userID, tokenComponent string) string {
+	return "mikhail:token:{" + userID + "}:" + tokenComponent
+}
+
+func redisUserIndexKey(userID string) string {
+	return "mikhail:user:{" + userID + "}"
+}
+
+func redisFamilyIndexKey(familyID string) string {
+	return "mikhail:family:" + familyID
+}
+
+const redisProviderIndexKey = "mikhail:providers"
+
+// redisAllTokensIndexKey indexes every active refresh token regardless
+// of owner, used only by RedisTokenReencryptionScheduler to find every
+// ciphertext that might need rewriting under a newer key version; no
+// per-request path needs to enumerate every token.
+const redisAllTokensIndexKey = "mikhail:tokens:all"
+
+func (s *RedisTokenStorage) set(key, value string, ttl time.Duration) (err error) {
+	_, span := startSpan(context.Background(), s.Tracer, "redis.set")
+	defer func() { endSpan(span, err) }()
+	return s.withClient(func(c *redisClient) error { return c.Set(key, value, ttl) })
+}
+
+func (s *RedisTokenStorage) get(key string) (value string, ok bool, err error) {
+	_, span := startSpan(context.Background(), s.Tracer, "redis.get")
+	defer func() { endSpan(span, err) }()
+	err = s.withClient(func(c *redisClient) error {
+		var opErr error
+		value, ok, opErr = c.Get(key)
+		return opErr
+	})
+	return value, ok, err
+}
+
+func (s *RedisTokenStorage) del(key string) (err error) {
+	_, span := startSpan(context.Background(), s.Tracer, "redis.del")
+	defer func() { endSpan(span, err) }()
+	return s.withClient(func(c *redisClient) error { return c.Del(key) })
+}
+
+func (s *RedisTokenStorage) sadd(key, member string) (err error) {
+	_, span := startSpan(context.Background(), s.Tracer, "redis.sadd")
+	defer func() { endSpan(span, err) }()
+	return s.withClient(func(c *redisClient) error { return c.SAdd(key, member) })
+}
+
+func (s *RedisTokenStorage) srem(key, member string) (err error) {
+	_, span := startSpan(context.Background(), s.Tracer, "redis.srem")
+	defer func() { endSpan(span, err) }()
+	return s.withClient(func(c *redisClient) error { return c.SRem(key, member) })
+}
+
+func (s *RedisTokenStorage) mget(keys []string) (values []string, oks []bool, err error) {
+	_, span := startSpan(context.Background(), s.Tracer, "redis.mget")
+	defer func() { endSpan(span, err) }()
+	err = s.withClient(func(c *redisClient) error {
+		var opErr error
+		values, oks, opErr = c.MGet(keys)
+		return opErr
+	})
+	return values, oks, err
+}
+
+func (s *RedisTokenStorage) delMany(keys []string) (err error) {
+	_, span := startSpan(context.Background(), s.Tracer, "redis.del_many")
+	defer func() { endSpan(span, err) }()
+	return s.withClient(func(c *redisClient) error { return c.DelMany(keys) })
+}
+
+func (s *RedisTokenStorage) smembers(key string) (members []string, err error) {
+	_, span := startSpan(context.Background(), s.Tracer, "redis.smembers")
+	defer func() { endSpan(span, err) }()
+	err = s.withClient(func(c *redisClient) error {
+		var opErr error
+		members, opErr = c.SMembers(key)
+		return opErr
+	})
+	return members, err
+}
+
+// encodePayload returns the string Store/RotateToken write as a token's
+// value: JSON, or the AES-256-GCM ciphertext of that JSON when Keyring
+// is set.
+func (s *RedisTokenStorage) encodePayload(info TokenInfo) (string, error) {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return "", err
+	}
+	if s.Keyring == nil {
+		return string(data), nil
+	}
+	return s.Keyring.Encrypt(data)
+}
+
+// ttlSeconds mirrors the clamping redisClient.Set applies: a duration
+// that has already elapsed, or is too short to round up to a whole
+// second, is still given a 1-second TTL rather than none at all.
+func ttlSeconds(expiresAt time.Time) string {
+	seconds := int(time.Until(expiresAt).Seconds())
+	if seconds <= 0 {
+		seconds = 1
+	}
+	return strconv.Itoa(seconds)
+}
+
+// Ping reports whether the Redis connection is reachable, for
+// ReadinessHandler to check. It reconnects through Sentinel the same way
+// any other operation does if the current connection has gone stale.
+func (s *RedisTokenStorage) Ping() error {
+	return s.withClient(func(c *redisClient) error {
+		return c.Ping()
+	})
+}
+
+func (s *RedisTokenStorage) Store(refreshToken string, info TokenInfo) error {
+	payload, err := s.encodePayload(info)
+	if err != nil {
+		return err
+	}
+
+	component := s.tokenKeyComponent(refreshToken)
+	ttl := time.Until(info.ExpiresAt)
+	if err := s.set(redisTokenOwnerKey(component), info.UserID, ttl); err != nil {
+		return err
+	}
+	if err := s.set(redisTokenKey(info.UserID, component), payload, ttl); err != nil {
+		return err
+	}
+	if err := s.sadd(redisUserIndexKey(info.UserID), refreshToken); err != nil {
+		return err
+	}
+	if err := s.sadd(redisFamilyIndexKey(info.FamilyID), refreshToken); err != nil {
+		return err
+	}
+	if err := s.sadd(redisAllTokensIndexKey, refreshToken); err != nil {
+		return err
+	}
+	if info.Provider != "" {
+		if err := s.sadd(redisProviderIndexKey, refreshToken); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *RedisTokenStorage) Get(refreshToken string) (TokenInfo, bool, error) {
+	component := s.tokenKeyComponent(refreshToken)
+	info, ok, err := s.getByKeyComponentPreferringReplica(component)
+	if err != nil || ok || len(s.Secret) == 0 {
+		return info, ok, err
+	}
+
+	// Not found under the hashed key: fall back to the plaintext key a
+	// token may still be sitting under from before Secret was set, and
+	// migrate it forward so this lookup only has to happen once.
+	legacyInfo, ok, err := s.getByKeyComponent(refreshToken)
+	if err != nil || !ok {
+		return TokenInfo{}, false, err
+	}
+	if err := s.Store(refreshToken, legacyInfo); err != nil {
+		return TokenInfo{}, false, err
+	}
+	if err := s.deleteTokenKeys(refreshToken, legacyInfo.UserID); err != nil {
+		return TokenInfo{}, false, err
+	}
+	return legacyInfo, true, nil
+}
+
+// getByKeyComponentPreferringReplica reads tokenComponent from a
+// configured read replica first, since VerifyToken's Get traffic is
+// exactly the read-heavy load SetReplicas exists to offload from the
+// primary. Replication lag means a token just Stored or RotateToken'd
+// might not have reached the replica yet, so a replica error or miss
+// falls back to the primary before this reports the token missing,
+// trading one extra round-trip on that rare path for never treating a
+// live session as gone just because a replica hasn't caught up.
+func (s *RedisTokenStorage) getByKeyComponentPreferringReplica(tokenComponent string) (TokenInfo, bool, error) {
+	if replica := s.pickReplica(); replica != nil {
+		if info, ok, err := s.getByKeyComponentFrom(replica, tokenComponent); err == nil && ok {
+			return info, true, nil
+		}
+	}
+	return s.getByKeyComponent(tokenComponent)
+}
+
+func (s *RedisTokenStorage) getByKeyComponent(tokenComponent string) (TokenInfo, bool, error) {
+	return s.getByKeyComponentFrom(s, tokenComponent)
+}
+
+func (s *RedisTokenStorage) getByKeyComponentFrom(target redisReadTarget, tokenComponent string) (TokenInfo, bool, error) {
+	userID, ok, err := target.get(redisTokenOwnerKey(tokenComponent))
+	if err != nil || !ok {
+		return TokenInfo{}, false, err
+	}
+	raw, ok, err := target.get(redisTokenKey(userID, tokenComponent))
+	if err != nil || !ok {
+		return TokenInfo{}, false, err
+	}
+
+	plaintext := []byte(raw)
+	if s.Keyring != nil {
+		plaintext, _, err = s.Keyring.Decrypt(raw)
+		if err != nil {
+			return TokenInfo{}, false, err
+		}
+	}
+
+	var info TokenInfo
+	if err := json.Unmarshal(plaintext, &info); err != nil {
+		return TokenInfo{}, false, err
+	}
+	return info, true, nil
+}
+
+func (s *RedisTokenStorage) Delete(refreshToken string) error {
+	info, ok, err := s.Get(refreshToken)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	return s.deleteWithIndexes(refreshToken, info)
+}
+
+// deleteTokenKeys removes just the owner and token keys for tokenComponent,
+// without touching any index set. Used both by deleteWithIndexes and by
+// Get's lazy migration, which needs to clean up a legacy plaintext key
+// without re-removing index entries Store just re-added.
+func (s *RedisTokenStorage) deleteTokenKeys(tokenComponent, userID string) error {
+	if err := s.del(redisTokenOwnerKey(tokenComponent)); err != nil {
+		return err
+	}
+	return s.del(redisTokenKey(userID, tokenComponent))
+}
+
+func (s *RedisTokenStorage) deleteWithIndexes(refreshToken string, info TokenInfo) error {
+	if err := s.deleteTokenKeys(s.tokenKeyComponent(refreshToken), info.UserID); err != nil {
+		return err
+	}
+	return s.removeFromIndexes(refreshToken, info)
+}
+
+// removeFromIndexes removes refreshToken from every index set it may be
+// a member of. It does not touch the owner/token keys themselves.
+func (s *RedisTokenStorage) removeFromIndexes(refreshToken string, info TokenInfo) error {
+	if err := s.srem(redisUserIndexKey(info.UserID), refreshToken); err != nil {
+		return err
+	}
+	if err := s.srem(redisFamilyIndexKey(info.FamilyID), refreshToken); err != nil {
+		return err
+	}
+	if err := s.srem(redisAllTokensIndexKey, refreshToken); err != nil {
+		return err
+	}
+	if info.Provider != "" {
+		if err := s.srem(redisProviderIndexKey, refreshToken); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTokens looks up every refresh token in refreshTokens with two MGET
+// round-trips (one to resolve owning users, one for the token payloads
+// themselves) instead of one Get per token. It does not perform the
+// lazy plaintext-key migration Get does: callers doing a bulk lookup of
+// tokens stored since Secret was set do not need it, and callers that
+// might hit legacy keys should fall back to Get for those misses.
+//
+// When a read replica is configured (see SetReplicas), the batch is read
+// from it first; any refresh token missing from that pass is re-queried
+// against the primary, the same replication-lag fallback Get applies to
+// a single lookup, so a replica's batch still cannot make a live session
+// look revoked just because it has not replicated yet.
+func (s *RedisTokenStorage) GetTokens(refreshTokens []string) (map[string]TokenInfo, error) {
+	if len(refreshTokens) == 0 {
+		return map[string]TokenInfo{}, nil
+	}
+
+	replica := s.pickReplica()
+	if replica == nil {
+		return s.getTokensFrom(s, refreshTokens)
+	}
+
+	found, err := s.getTokensFrom(replica, refreshTokens)
+	if err != nil {
+		return s.getTokensFrom(s, refreshTokens)
+	}
+	if len(found) == len(refreshTokens) {
+		return found, nil
+	}
+
+	var misses []string
+	for _, refreshToken := range refreshTokens {
+		if _, ok := found[refreshToken]; !ok {
+			misses = append(misses, refreshToken)
+		}
+	}
+	fromPrimary, err := s.getTokensFrom(s, misses)
+	if err != nil {
+		return nil, err
+	}
+	for refreshToken, info := range fromPrimary {
+		found[refreshToken] = info
+	}
+	return found, nil
+}
+
+func (s *RedisTokenStorage) getTokensFrom(target redisReadTarget, refreshTokens []string) (map[string]TokenInfo, error) {
+	found := make(map[string]TokenInfo, len(refreshTokens))
+	if len(refreshTokens) == 0 {
+		return found, nil
+	}
+
+	components := make([]string, len(refreshTokens))
+	ownerKeys := make([]string, len(refreshTokens))
+	for i, refreshToken := range refreshTokens {
+		components[i] = s.tokenKeyComponent(refreshToken)
+		ownerKeys[i] = redisTokenOwnerKey(components[i])
+	}
+	owners, ownerOKs, err := target.mget(ownerKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenKeys := make([]string, 0, len(refreshTokens))
+	present := make([]int, 0, len(refreshTokens))
+	for i, ok := range ownerOKs {
+		if ok {
+			tokenKeys = append(tokenKeys, redisTokenKey(owners[i], components[i]))
+			present = append(present, i)
+		}
+	}
+	if len(tokenKeys) == 0 {
+		return found, nil
+	}
+
+	payloads, payloadOKs, err := target.mget(tokenKeys)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range present {
+		if !payloadOKs[j] {
+			continue
+		}
+		plaintext := []byte(payloads[j])
+		if s.Keyring != nil {
+			plaintext, _, err = s.Keyring.Decrypt(payloads[j])
+			if err != nil {
+				return nil, err
+			}
+		}
+		var info TokenInfo
+		if err := json.Unmarshal(plaintext, &info); err != nil {
+			return nil, err
+		}
+		found[refreshTokens[i]] = info
+	}
+	return found, nil
+}
+
+// DeleteTokens deletes every refresh token in refreshTokens, batching
+// the owner/token key removals into a single DEL round-trip instead of
+// one Delete per token. Index cleanup still costs one SREM per affected
+// index per token, the same as Delete, since Redis has no batched SREM
+// across different sets.
+func (s *RedisTokenStorage) DeleteTokens(refreshTokens []string) error {
+	if len(refreshTokens) == 0 {
+		return nil
+	}
+	infos, err := s.GetTokens(refreshTokens)
+	if err != nil {
+		return err
+	}
+	if len(infos) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, 2*len(infos))
+	for refreshToken, info := range infos {
+		component := s.tokenKeyComponent(refreshToken)
+		keys = append(keys, redisTokenOwnerKey(component), redisTokenKey(info.UserID, component))
+	}
+	if err := s.delMany(keys); err != nil {
+		return err
+	}
+
+	for refreshToken, info := range infos {
+		if err := s.removeFromIndexes(refreshToken, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *RedisTokenStorage) MarkRotated(refreshToken, successorToken string) error {
+	info, ok, err := s.Get(refreshToken)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	info.Rotated = true
+	info.RotatedAt = time.Now()
+	info.SuccessorToken = successorToken
+	return s.Store(refreshToken, info)
+}
+
+// redisRotateTokenScript writes the rotated old token and the new token
+// it was exchanged for as a single atomic step: every redis.call inside
+// a Lua script Redis runs via EVAL executes without any other client's
+// command interleaving, the same isolation a MULTI/EXEC transaction
+// gives. The JSON/ciphertext payloads and TTLs are computed in Go and
+// passed in as ARGV, since encryption and JSON encoding have no reason
+// to happen inside the script itself.
+//
+// KEYS: 1 old owner key, 2 old token key, 3 new owner key, 4 new token
+// key, 5 user index key, 6 family index key, 7 all-tokens index key,
+// 8 provider index key.
+// ARGV: 1 old user id, 2 old payload, 3 old ttl seconds, 4 new user id,
+// 5 new payload, 6 new ttl seconds, 7 new refresh token, 8 new provider
+// (empty string if none).
+const redisRotateTokenScript = `
+redis.call('SET', KEYS[1], ARGV[1], 'EX', ARGV[3])
+redis.call('SET', KEYS[2], ARGV[2], 'EX', ARGV[3])
+redis.call('SET', KEYS[3], ARGV[4], 'EX', ARGV[6])
+redis.call('SET', KEYS[4], ARGV[5], 'EX', ARGV[6])
+redis.call('SADD', KEYS[5], ARGV[7])
+redis.call('SADD', KEYS[6], ARGV[7])
+redis.call('SADD', KEYS[7], ARGV[7])
+if ARGV[8] ~= '' then
+	redis.call('SADD', KEYS[8], ARGV[7])
+end
+return 'OK'
+`
+
+// RotateToken marks oldRefreshToken rotated (naming newRefreshToken as
+// its successor) and stores newInfo under newRefreshToken in a single
+// round-trip, via redisRotateTokenScript, instead of the MarkRotated
+// then Store pair a caller would otherwise need, which a crash between
+// the two could leave half-applied.
+func (s *RedisTokenStorage) RotateToken(oldRefreshToken, newRefreshToken string, newInfo TokenInfo) error {
+	oldInfo, ok, err := s.Get(oldRefreshToken)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrTokenExpired
+	}
+	oldInfo.Rotated = true
+	oldInfo.RotatedAt = time.Now()
+	oldInfo.SuccessorToken = newRefreshToken
+
+	oldPayload, err := s.encodePayload(oldInfo)
+	if err != nil {
+		return err
+	}
+	newPayload, err := s.encodePayload(newInfo)
+	if err != nil {
+		return err
+	}
+
+	oldComponent := s.tokenKeyComponent(oldRefreshToken)
+	newComponent := s.tokenKeyComponent(newRefreshToken)
+
+	keysAndArgs := []string{
+		redisTokenOwnerKey(oldComponent),
+		redisTokenKey(oldInfo.UserID, oldComponent),
+		redisTokenOwnerKey(newComponent),
+		redisTokenKey(newInfo.UserID, newComponent),
+		redisUserIndexKey(newInfo.UserID),
+		redisFamilyIndexKey(newInfo.FamilyID),
+		redisAllTokensIndexKey,
+		redisProviderIndexKey,
+		oldInfo.UserID,
+		oldPayload,
+		ttlSeconds(oldInfo.ExpiresAt),
+		newInfo.UserID,
+		newPayload,
+		ttlSeconds(newInfo.ExpiresAt),
+		newRefreshToken,
+		newInfo.Provider,
+	}
+	_, span := startSpan(context.Background(), s.Tracer, "redis.eval")
+	err = s.withClient(func(c *redisClient) error {
+		_, _, evalErr := c.Eval(redisRotateTokenScript, 8, keysAndArgs)
+		return evalErr
+	})
+	endSpan(span, err)
+	return err
+}
+
+func (s *RedisTokenStorage) RevokeFamily(familyID string) error {
+	members, err := s.smembers(redisFamilyIndexKey(familyID))
+	if err != nil {
+		return err
+	}
+	for _, refreshToken := range members {
+		if err := s.Delete(refreshToken); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *RedisTokenStorage) RevokeAllForUser(userID string) error {
+	members, err := s.smembers(redisUserIndexKey(userID))
+	if err != nil {
+		return err
+	}
+	for _, refreshToken := range members {
+		if err := s.Delete(refreshToken); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *RedisTokenStorage) RevokeAllForUserExceptFamily(userID, exceptFamilyID string) error {
+	members, err := s.smembers(redisUserIndexKey(userID))
+	if err != nil {
+		return err
+	}
+	for _, refreshToken := range members {
+		info, ok, err := s.Get(refreshToken)
+		if err != nil {
+			return err
+		}
+		if !ok || info.FamilyID == exceptFamilyID {
+			continue
+		}
+		if err := s.deleteWithIndexes(refreshToken, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *RedisTokenStorage) ListByUser(userID string) ([]TokenInfo, error) {
+	members, err := s.smembers(redisUserIndexKey(userID))
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]TokenInfo, 0, len(members))
+	for _, refreshToken := range members {
+		info, ok, err := s.Get(refreshToken)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			sessions = append(sessions, info)
+		} else {
+			// Stale index entry left behind by a token that expired via
+			// Redis's own TTL rather than an explicit Delete call.
+			s.srem(redisUserIndexKey(userID), refreshToken)
+		}
+	}
+	return sessions, nil
+}
+
+func (s *RedisTokenStorage) ListProviderSessions() (map[string]TokenInfo, error) {
+	members, err := s.smembers(redisProviderIndexKey)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make(map[string]TokenInfo, len(members))
+	for _, refreshToken := range members {
+		info, ok, err := s.Get(refreshToken)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			sessions[refreshToken] = info
+		} else {
+			s.srem(redisProviderIndexKey, refreshToken)
+		}
+	}
+	return sessions, nil
+}
@@ -0,0 +1,147 @@
+package mikhail
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+)
+
+// redisExpiredEventChannel is the pub/sub channel Redis publishes a key's
+// name on when it expires, for database 0 (the only database
+// RedisTokenStorage uses). See notify-keyspace-events in redis.conf.
+const redisExpiredEventChannel = "__keyevent@0__:expired"
+
+// RedisKeyspaceExpiryWatcher subscribes to Redis keyspace notifications
+// and turns the expiry of a RedisTokenStorage token key into a
+// TokenExpiredEvent delivered to Listener, so a session ending by TTL
+// rather than an explicit SignOut/RevokeFamily call still reaches
+// whatever audit log or event bus Listener forwards to.
+//
+// Redis only reports the name of the key that expired, not its
+// value: by the time the notification fires, GET on that key would
+// already return nothing. redisTokenKey embeds the owning UserID in the
+// key name itself, so UserID is always recoverable; RefreshToken is only
+// recoverable when RedisTokenStorage.Secret is unset, since with it set
+// the key name carries an HMAC of the refresh token rather than the
+// token itself, which cannot be reversed. The watcher cannot tell the
+// two cases apart, so it always sets both TokenExpiredEvent.RefreshToken
+// and TokenKeyComponent to the key's component: with Secret set that
+// value is the HMAC rather than a usable refresh token, still enough
+// for a listener to correlate the event against its own records keyed
+// the same way.
+type RedisKeyspaceExpiryWatcher struct {
+	addr     string
+	Listener TokenExpiryListener
+
+	stop chan struct{}
+}
+
+// NewRedisKeyspaceExpiryWatcher returns a RedisKeyspaceExpiryWatcher that
+// delivers every token key expiry it observes at addr to listener. Call
+// Start to begin watching.
+func NewRedisKeyspaceExpiryWatcher(addr string, listener TokenExpiryListener) *RedisKeyspaceExpiryWatcher {
+	return &RedisKeyspaceExpiryWatcher{
+		addr:     addr,
+		Listener: listener,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start dials its own connection (pub/sub puts a connection in a mode
+// that cannot issue other commands, so it cannot share RedisTokenStorage's
+// pooled one), best-effort enables notify-keyspace-events for expired
+// keys, subscribes, and delivers events to Listener until ctx is done or
+// Stop is called. It is meant to be run in its own goroutine.
+//
+// Start returns an error from the initial dial/subscribe; once
+// watching, a lost connection is logged and Start returns that error, since
+// redisClient's reconnect-on-failure logic lives in RedisTokenStorage's
+// pooled client, not here. A caller wanting watching to survive a Redis
+// restart should restart Start itself (e.g. from the same supervisor
+// loop that restarts the other background schedulers).
+func (w *RedisKeyspaceExpiryWatcher) Start(ctx context.Context) error {
+	conn, err := dialRedis(w.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.ConfigSet("notify-keyspace-events", "Ex"); err != nil {
+		log.Printf("mikhail: redis keyspace expiry watcher could not enable notify-keyspace-events (continuing, assuming it is already set): %v", err)
+	}
+	if err := conn.Subscribe(redisExpiredEventChannel); err != nil {
+		return err
+	}
+
+	messages := make(chan string)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			_, key, err := conn.ReceiveMessage()
+			if err != nil {
+				errs <- err
+				return
+			}
+			messages <- key
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-w.stop:
+			return nil
+		case err := <-errs:
+			log.Printf("mikhail: redis keyspace expiry watcher connection lost: %v", err)
+			return err
+		case key := <-messages:
+			w.deliver(key)
+		}
+	}
+}
+
+// Stop ends a running Start loop.
+func (w *RedisKeyspaceExpiryWatcher) Stop() {
+	close(w.stop)
+}
+
+func (w *RedisKeyspaceExpiryWatcher) deliver(key string) {
+	userID, tokenComponent, ok := parseRedisTokenKey(key)
+	if !ok {
+		return
+	}
+	event := TokenExpiredEvent{
+		UserID:            userID,
+		TokenKeyComponent: tokenComponent,
+		DetectedAt:        time.Now(),
+	}
+	// tokenKeyComponent returns the refresh token verbatim when no Secret
+	// hashes it, so an unhashed key's component doubles as the refresh
+	// token itself. There is no way to tell a hashed component from an
+	// unhashed one just by looking at it, so callers running with a
+	// Secret should treat RefreshToken as unreliable here and key off
+	// TokenKeyComponent instead; NewRedisKeyspaceExpiryWatcher has no
+	// access to RedisTokenStorage.Secret to decide for them.
+	event.RefreshToken = tokenComponent
+	w.Listener.OnTokenExpired(event)
+}
+
+// parseRedisTokenKey extracts the UserID and token component out of a
+// key in redisTokenKey's "mikhail:token:{<userID>}:<tokenComponent>"
+// format, reporting ok=false for any other key (e.g. the owner or index
+// keys, which also expire but are not what a listener wants to hear
+// about twice).
+func parseRedisTokenKey(key string) (userID, tokenComponent string, ok bool) {
+	const prefix = "mikhail:token:{"
+	if !strings.HasPrefix(key, prefix) {
+		return "", "", false
+	}
+	rest := key[len(prefix):]
+	closeIdx := strings.Index(rest, "}:")
+	if closeIdx < 0 {
+		return "", "", false
+	}
+	return rest[:closeIdx], rest[closeIdx+2:], true
+}
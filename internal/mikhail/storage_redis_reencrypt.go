@@ -0,0 +1,120 @@
+package mikhail
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RedisTokenReencryptionScheduler periodically rewrites every token in
+// a RedisTokenStorage whose ciphertext was encrypted under an older key
+// version than the keyring's current one, so a rotated-in key
+// eventually covers every stored token and the key it replaced can be
+// retired with TokenEncryptionKeyring.Forget without losing access to
+// tokens still sitting on disk under it.
+type RedisTokenReencryptionScheduler struct {
+	storage *RedisTokenStorage
+	// Interval is how often the scheduler scans for stale ciphertexts.
+	Interval time.Duration
+
+	stop chan struct{}
+}
+
+// NewRedisTokenReencryptionScheduler returns a
+// RedisTokenReencryptionScheduler that scans storage every interval.
+// storage.Keyring must be set before Start is called.
+func NewRedisTokenReencryptionScheduler(storage *RedisTokenStorage, interval time.Duration) *RedisTokenReencryptionScheduler {
+	return &RedisTokenReencryptionScheduler{
+		storage:  storage,
+		Interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start runs the scan loop until ctx is done or Stop is called. It is
+// meant to be run in its own goroutine.
+func (sch *RedisTokenReencryptionScheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(sch.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sch.stop:
+			return
+		case <-ticker.C:
+			sch.scanOnce()
+		}
+	}
+}
+
+// Stop ends a running Start loop.
+func (sch *RedisTokenReencryptionScheduler) Stop() {
+	close(sch.stop)
+}
+
+func (sch *RedisTokenReencryptionScheduler) scanOnce() {
+	members, err := sch.storage.smembers(redisAllTokensIndexKey)
+	if err != nil {
+		log.Printf("mikhail: redis token re-encryption scan failed: %v", err)
+		return
+	}
+	for _, refreshToken := range members {
+		reencrypted, err := sch.storage.reencryptIfStale(refreshToken)
+		if err != nil {
+			log.Printf("mikhail: re-encrypting token failed: %v", err)
+			continue
+		}
+		if reencrypted {
+			log.Printf("mikhail: re-encrypted a token under key version %d", sch.storage.Keyring.CurrentVersion())
+		}
+	}
+}
+
+// reencryptIfStale rewrites refreshToken's ciphertext under the
+// keyring's current key version if it was not already, reporting
+// whether it did.
+func (s *RedisTokenStorage) reencryptIfStale(refreshToken string) (bool, error) {
+	if s.Keyring == nil {
+		return false, nil
+	}
+
+	userID, ok, err := s.get(redisTokenOwnerKey(refreshToken))
+	if err != nil || !ok {
+		return false, err
+	}
+	raw, ok, err := s.get(redisTokenKey(userID, refreshToken))
+	if err != nil || !ok {
+		return false, err
+	}
+
+	version, err := ciphertextVersion(raw)
+	if err != nil {
+		return false, err
+	}
+	if version == s.Keyring.CurrentVersion() {
+		return false, nil
+	}
+
+	info, ok, err := s.Get(refreshToken)
+	if err != nil || !ok {
+		return false, err
+	}
+	return true, s.Store(refreshToken, info)
+}
+
+// ciphertextVersion extracts the key version from a
+// "v<version>:<base64>" ciphertext without decrypting it.
+func ciphertextVersion(ciphertext string) (int, error) {
+	versionTag, _, ok := strings.Cut(ciphertext, ":")
+	if !ok || !strings.HasPrefix(versionTag, "v") {
+		return 0, ErrMalformedCiphertext
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(versionTag, "v"))
+	if err != nil {
+		return 0, ErrMalformedCiphertext
+	}
+	return version, nil
+}
@@ -0,0 +1,465 @@
+package mikhail
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// SQLiteTokenStorage is a TokenStorage backed by SQLite, for standalone
+// deployments (demos, edge nodes, integration tests) that should not
+// depend on a separate Redis or Postgres instance. It expects a schema
+// along these lines:
+//
+//	CREATE TABLE tokens (
+//	    refresh_token             TEXT PRIMARY KEY,
+//	    user_id                   TEXT NOT NULL,
+//	    phone_number              TEXT,
+//	    issued_at                 INTEGER NOT NULL,
+//	    expires_at                INTEGER NOT NULL,
+//	    family_id                 TEXT NOT NULL,
+//	    device_id                 TEXT,
+//	    device_fingerprint        TEXT,
+//	    remember_me               INTEGER NOT NULL DEFAULT 0,
+//	    scopes                    TEXT,
+//	    rotated                   INTEGER NOT NULL DEFAULT 0,
+//	    rotated_at                INTEGER,
+//	    successor_token           TEXT,
+//	    provider                  TEXT,
+//	    provider_access_token     TEXT,
+//	    provider_refresh_token    TEXT,
+//	    provider_token_expires_at INTEGER
+//	);
+//	CREATE INDEX idx_tokens_user_id ON tokens(user_id);
+//	CREATE INDEX idx_tokens_family_id ON tokens(family_id);
+//
+// SQLiteTokenStorage only depends on database/sql: callers are
+// responsible for opening db with a registered SQLite driver (e.g.
+// mattn/go-sqlite3 or modernc.org/sqlite) and a DSN that enables WAL
+// mode, e.g. "file:mikhail.db?_journal_mode=WAL".
+type SQLiteTokenStorage struct {
+	db *sql.DB
+}
+
+// NewSQLiteTokenStorage wraps an already-open SQLite database handle.
+func NewSQLiteTokenStorage(db *sql.DB) *SQLiteTokenStorage {
+	return &SQLiteTokenStorage{db: db}
+}
+
+func (s *SQLiteTokenStorage) Store(refreshToken string, info TokenInfo) error {
+	scopes, err := json.Marshal(info.Scopes)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(context.Background(), `
+		INSERT INTO tokens (
+			refresh_token, user_id, phone_number, issued_at, expires_at,
+			family_id, device_id, device_fingerprint, remember_me, scopes,
+			rotated, rotated_at, successor_token,
+			provider, provider_access_token, provider_refresh_token, provider_token_expires_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(refresh_token) DO UPDATE SET
+			user_id = excluded.user_id,
+			phone_number = excluded.phone_number,
+			issued_at = excluded.issued_at,
+			expires_at = excluded.expires_at,
+			family_id = excluded.family_id,
+			device_id = excluded.device_id,
+			device_fingerprint = excluded.device_fingerprint,
+			remember_me = excluded.remember_me,
+			scopes = excluded.scopes,
+			rotated = excluded.rotated,
+			rotated_at = excluded.rotated_at,
+			successor_token = excluded.successor_token,
+			provider = excluded.provider,
+			provider_access_token = excluded.provider_access_token,
+			provider_refresh_token = excluded.provider_refresh_token,
+			provider_token_expires_at = excluded.provider_token_expires_at`,
+		refreshToken, info.UserID, info.PhoneNumber, unixOrZero(info.IssuedAt), unixOrZero(info.ExpiresAt),
+		info.FamilyID, info.DeviceID, info.DeviceFingerprint, boolToInt(info.RememberMe), string(scopes),
+		boolToInt(info.Rotated), nullableUnix(info.RotatedAt), info.SuccessorToken,
+		info.Provider, info.ProviderAccessToken, info.ProviderRefreshToken, nullableUnix(info.ProviderTokenExpiresAt))
+	return err
+}
+
+func (s *SQLiteTokenStorage) Get(refreshToken string) (TokenInfo, bool, error) {
+	row := s.db.QueryRowContext(context.Background(),
+		`SELECT user_id, phone_number, issued_at, expires_at, family_id, device_id,
+		        device_fingerprint, remember_me, scopes, rotated, rotated_at,
+		        successor_token, provider, provider_access_token, provider_refresh_token,
+		        provider_token_expires_at
+		   FROM tokens WHERE refresh_token = ?`, refreshToken)
+	info, err := scanTokenRow(row)
+	if err == sql.ErrNoRows {
+		return TokenInfo{}, false, nil
+	}
+	if err != nil {
+		return TokenInfo{}, false, err
+	}
+	return info, true, nil
+}
+
+func (s *SQLiteTokenStorage) Delete(refreshToken string) error {
+	_, err := s.db.ExecContext(context.Background(), `DELETE FROM tokens WHERE refresh_token = ?`, refreshToken)
+	return err
+}
+
+func (s *SQLiteTokenStorage) GetTokens(refreshTokens []string) (map[string]TokenInfo, error) {
+	found := make(map[string]TokenInfo, len(refreshTokens))
+	if len(refreshTokens) == 0 {
+		return found, nil
+	}
+
+	placeholders, args := inClause(refreshTokens)
+	rows, err := s.db.QueryContext(context.Background(),
+		`SELECT refresh_token, user_id, phone_number, issued_at, expires_at, family_id, device_id,
+		        device_fingerprint, remember_me, scopes, rotated, rotated_at,
+		        successor_token, provider, provider_access_token, provider_refresh_token,
+		        provider_token_expires_at
+		   FROM tokens WHERE refresh_token IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var refreshToken string
+		info, err := scanTokenRow(scanWithLeadingToken{rows, &refreshToken})
+		if err != nil {
+			return nil, err
+		}
+		found[refreshToken] = info
+	}
+	return found, rows.Err()
+}
+
+func (s *SQLiteTokenStorage) DeleteTokens(refreshTokens []string) error {
+	if len(refreshTokens) == 0 {
+		return nil
+	}
+	placeholders, args := inClause(refreshTokens)
+	_, err := s.db.ExecContext(context.Background(),
+		`DELETE FROM tokens WHERE refresh_token IN (`+placeholders+`)`, args...)
+	return err
+}
+
+// inClause builds the "?,?,?" placeholder list and matching []interface{}
+// argument slice for a SQL IN clause over values.
+func inClause(values []string) (string, []interface{}) {
+	placeholders := make([]byte, 0, 2*len(values))
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		if i > 0 {
+			placeholders = append(placeholders, ',')
+		}
+		placeholders = append(placeholders, '?')
+		args[i] = v
+	}
+	return string(placeholders), args
+}
+
+func (s *SQLiteTokenStorage) MarkRotated(refreshToken, successorToken string) error {
+	_, err := s.db.ExecContext(context.Background(),
+		`UPDATE tokens SET rotated = 1, rotated_at = ?, successor_token = ? WHERE refresh_token = ?`,
+		time.Now().Unix(), successorToken, refreshToken)
+	return err
+}
+
+// RotateToken marks oldRefreshToken rotated and inserts newInfo under
+// newRefreshToken in a single transaction, so the two writes MarkRotated
+// and Store would otherwise make separately either both commit or
+// neither does.
+func (s *SQLiteTokenStorage) RotateToken(oldRefreshToken, newRefreshToken string, newInfo TokenInfo) error {
+	ctx := context.Background()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE tokens SET rotated = 1, rotated_at = ?, successor_token = ? WHERE refresh_token = ?`,
+		time.Now().Unix(), newRefreshToken, oldRefreshToken)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrTokenExpired
+	}
+
+	scopes, err := json.Marshal(newInfo.Scopes)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO tokens (
+			refresh_token, user_id, phone_number, issued_at, expires_at,
+			family_id, device_id, device_fingerprint, remember_me, scopes,
+			rotated, rotated_at, successor_token,
+			provider, provider_access_token, provider_refresh_token, provider_token_expires_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(refresh_token) DO UPDATE SET
+			user_id = excluded.user_id,
+			phone_number = excluded.phone_number,
+			issued_at = excluded.issued_at,
+			expires_at = excluded.expires_at,
+			family_id = excluded.family_id,
+			device_id = excluded.device_id,
+			device_fingerprint = excluded.device_fingerprint,
+			remember_me = excluded.remember_me,
+			scopes = excluded.scopes,
+			rotated = excluded.rotated,
+			rotated_at = excluded.rotated_at,
+			successor_token = excluded.successor_token,
+			provider = excluded.provider,
+			provider_access_token = excluded.provider_access_token,
+			provider_refresh_token = excluded.provider_refresh_token,
+			provider_token_expires_at = excluded.provider_token_expires_at`,
+		newRefreshToken, newInfo.UserID, newInfo.PhoneNumber, unixOrZero(newInfo.IssuedAt), unixOrZero(newInfo.ExpiresAt),
+		newInfo.FamilyID, newInfo.DeviceID, newInfo.DeviceFingerprint, boolToInt(newInfo.RememberMe), string(scopes),
+		boolToInt(newInfo.Rotated), nullableUnix(newInfo.RotatedAt), newInfo.SuccessorToken,
+		newInfo.Provider, newInfo.ProviderAccessToken, newInfo.ProviderRefreshToken, nullableUnix(newInfo.ProviderTokenExpiresAt)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteTokenStorage) RevokeFamily(familyID string) error {
+	_, err := s.db.ExecContext(context.Background(), `DELETE FROM tokens WHERE family_id = ?`, familyID)
+	return err
+}
+
+func (s *SQLiteTokenStorage) RevokeAllForUser(userID string) error {
+	_, err := s.db.ExecContext(context.Background(), `DELETE FROM tokens WHERE user_id = ?`, userID)
+	return err
+}
+
+func (s *SQLiteTokenStorage) RevokeAllForUserExceptFamily(userID, exceptFamilyID string) error {
+	_, err := s.db.ExecContext(context.Background(),
+		`DELETE FROM tokens WHERE user_id = ? AND family_id != ?`, userID, exceptFamilyID)
+	return err
+}
+
+func (s *SQLiteTokenStorage) ListByUser(userID string) ([]TokenInfo, error) {
+	rows, err := s.db.QueryContext(context.Background(),
+		`SELECT user_id, phone_number, issued_at, expires_at, family_id, device_id,
+		        device_fingerprint, remember_me, scopes, rotated, rotated_at,
+		        successor_token, provider, provider_access_token, provider_refresh_token,
+		        provider_token_expires_at
+		   FROM tokens WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []TokenInfo
+	for rows.Next() {
+		info, err := scanTokenRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, info)
+	}
+	return sessions, rows.Err()
+}
+
+func (s *SQLiteTokenStorage) ListProviderSessions() (map[string]TokenInfo, error) {
+	rows, err := s.db.QueryContext(context.Background(),
+		`SELECT refresh_token, user_id, phone_number, issued_at, expires_at, family_id, device_id,
+		        device_fingerprint, remember_me, scopes, rotated, rotated_at,
+		        successor_token, provider, provider_access_token, provider_refresh_token,
+		        provider_token_expires_at
+		   FROM tokens WHERE provider IS NOT NULL AND provider != ''`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make(map[string]TokenInfo)
+	for rows.Next() {
+		var refreshToken string
+		info, err := scanTokenRow(scanWithLeadingToken{rows, &refreshToken})
+		if err != nil {
+			return nil, err
+		}
+		sessions[refreshToken] = info
+	}
+	return sessions, rows.Err()
+}
+
+// ScanExpiring implements ExpiringTokenScanner for TokenExpirationPoller:
+// unlike Redis/DynamoDB/etcd, SQLite has no native TTL, so a session
+// expiring here would otherwise only be noticed the next time something
+// calls Get on it, or not at all until VacuumExpired next runs.
+func (s *SQLiteTokenStorage) ScanExpiring(before time.Time) (map[string]TokenInfo, error) {
+	rows, err := s.db.QueryContext(context.Background(),
+		`SELECT refresh_token, user_id, phone_number, issued_at, expires_at, family_id, device_id,
+		        device_fingerprint, remember_me, scopes, rotated, rotated_at,
+		        successor_token, provider, provider_access_token, provider_refresh_token,
+		        provider_token_expires_at
+		   FROM tokens WHERE expires_at <= ?`, before.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	expired := make(map[string]TokenInfo)
+	for rows.Next() {
+		var refreshToken string
+		info, err := scanTokenRow(scanWithLeadingToken{rows, &refreshToken})
+		if err != nil {
+			return nil, err
+		}
+		expired[refreshToken] = info
+	}
+	return expired, rows.Err()
+}
+
+// VacuumExpired deletes every token whose ExpiresAt is before now,
+// reclaiming space from sessions nobody ever presented again for
+// rotation or sign-out.
+func (s *SQLiteTokenStorage) VacuumExpired(now time.Time) (int64, error) {
+	result, err := s.db.ExecContext(context.Background(), `DELETE FROM tokens WHERE expires_at < ?`, now.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows, letting
+// scanTokenRow serve Get/ListByUser (single-row and multi-row) alike.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanWithLeadingToken adapts a *sql.Rows whose first selected column is
+// refresh_token so scanTokenRow can still be used for ListProviderSessions,
+// which needs that column back out alongside the TokenInfo.
+type scanWithLeadingToken struct {
+	rows  *sql.Rows
+	token *string
+}
+
+func (s scanWithLeadingToken) Scan(dest ...interface{}) error {
+	return s.rows.Scan(append([]interface{}{s.token}, dest...)...)
+}
+
+func scanTokenRow(row rowScanner) (TokenInfo, error) {
+	var info TokenInfo
+	var issuedAt, expiresAt int64
+	var rotatedAt, providerTokenExpiresAt sql.NullInt64
+	var phoneNumber, deviceID, deviceFingerprint, scopes, successorToken, provider, providerAccessToken, providerRefreshToken sql.NullString
+	var rememberMe, rotated int
+
+	if err := row.Scan(&info.UserID, &phoneNumber, &issuedAt, &expiresAt, &info.FamilyID, &deviceID,
+		&deviceFingerprint, &rememberMe, &scopes, &rotated, &rotatedAt,
+		&successorToken, &provider, &providerAccessToken, &providerRefreshToken,
+		&providerTokenExpiresAt); err != nil {
+		return TokenInfo{}, err
+	}
+
+	info.PhoneNumber = phoneNumber.String
+	info.IssuedAt = time.Unix(issuedAt, 0)
+	info.ExpiresAt = time.Unix(expiresAt, 0)
+	info.DeviceID = deviceID.String
+	info.DeviceFingerprint = deviceFingerprint.String
+	info.RememberMe = rememberMe != 0
+	info.Rotated = rotated != 0
+	info.SuccessorToken = successorToken.String
+	info.Provider = provider.String
+	info.ProviderAccessToken = providerAccessToken.String
+	info.ProviderRefreshToken = providerRefreshToken.String
+	if rotatedAt.Valid {
+		info.RotatedAt = time.Unix(rotatedAt.Int64, 0)
+	}
+	if providerTokenExpiresAt.Valid {
+		info.ProviderTokenExpiresAt = time.Unix(providerTokenExpiresAt.Int64, 0)
+	}
+	if scopes.Valid && scopes.String != "" {
+		if err := json.Unmarshal([]byte(scopes.String), &info.Scopes); err != nil {
+			return TokenInfo{}, err
+		}
+	}
+	return info, nil
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func nullableUnix(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Unix()
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// SQLiteExpiredTokenVacuumer periodically deletes expired rows from a
+// SQLiteTokenStorage, modeled on ProviderTokenRefreshScheduler: unlike
+// Redis and Postgres, SQLite has no built-in TTL, so expired tokens
+// would otherwise accumulate in the file forever.
+type SQLiteExpiredTokenVacuumer struct {
+	storage *SQLiteTokenStorage
+	// Interval is how often the vacuumer scans for expired tokens.
+	Interval time.Duration
+
+	stop chan struct{}
+}
+
+// NewSQLiteExpiredTokenVacuumer returns a SQLiteExpiredTokenVacuumer that
+// vacuums storage every interval. Call Start to begin vacuuming.
+func NewSQLiteExpiredTokenVacuumer(storage *SQLiteTokenStorage, interval time.Duration) *SQLiteExpiredTokenVacuumer {
+	return &SQLiteExpiredTokenVacuumer{
+		storage:  storage,
+		Interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start runs the vacuum loop until ctx is done or Stop is called. It is
+// meant to be run in its own goroutine.
+func (v *SQLiteExpiredTokenVacuumer) Start(ctx context.Context) {
+	ticker := time.NewTicker(v.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-v.stop:
+			return
+		case <-ticker.C:
+			v.vacuumOnce()
+		}
+	}
+}
+
+// Stop ends a running Start loop.
+func (v *SQLiteExpiredTokenVacuumer) Stop() {
+	close(v.stop)
+}
+
+func (v *SQLiteExpiredTokenVacuumer) vacuumOnce() {
+	deleted, err := v.storage.VacuumExpired(time.Now())
+	if err != nil {
+		log.Printf("mikhail: sqlite expired token vacuum failed: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("mikhail: sqlite expired token vacuum deleted %d rows", deleted)
+	}
+}
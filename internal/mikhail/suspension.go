@@ -0,0 +1,63 @@
+package mikhail
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrAccountSuspended is returned by VerifyToken and RefreshToken (and
+// anything else that should treat a suspended account as unusable) once
+// SuspendUser has been called for it. It is distinct from
+// ErrAccountDeleted: a suspended account can be reinstated, a deleted
+// one cannot.
+var ErrAccountSuspended = errors.New("mikhail: account suspended")
+
+// SuspensionInfo is what Mikhail keeps about an admin-issued suspension.
+type SuspensionInfo struct {
+	UserID      string
+	Reason      string
+	ActorID     string
+	SuspendedAt time.Time
+}
+
+// SuspensionStore persists account suspensions, keyed by user ID.
+// Implementations must be safe for concurrent use.
+type SuspensionStore interface {
+	Suspend(info SuspensionInfo) error
+	Get(userID string) (SuspensionInfo, bool, error)
+	Reinstate(userID string) error
+}
+
+// InMemorySuspensionStore is a SuspensionStore backed by a guarded map,
+// suitable for local development and single-node deployments.
+type InMemorySuspensionStore struct {
+	mu          sync.Mutex
+	suspensions map[string]SuspensionInfo
+}
+
+// NewInMemorySuspensionStore returns an empty InMemorySuspensionStore.
+func NewInMemorySuspensionStore() *InMemorySuspensionStore {
+	return &InMemorySuspensionStore{suspensions: make(map[string]SuspensionInfo)}
+}
+
+func (s *InMemorySuspensionStore) Suspend(info SuspensionInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.suspensions[info.UserID] = info
+	return nil
+}
+
+func (s *InMemorySuspensionStore) Get(userID string) (SuspensionInfo, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, ok := s.suspensions[userID]
+	return info, ok, nil
+}
+
+func (s *InMemorySuspensionStore) Reinstate(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.suspensions, userID)
+	return nil
+}
@@ -0,0 +1,121 @@
+package mikhail
+
+import (
+	"context"
+	"sync"
+)
+
+// TenantID identifies which Kingdom-System tenant a call belongs to, in
+// a deployment serving more than one from a single Mikhail process. The
+// zero value "" means "no tenant" - the single-tenant case every
+// existing deployment is in, left entirely unaffected by multi-tenancy
+// support landing.
+type TenantID string
+
+type tenantIDKey struct{}
+
+// ContextWithTenantID returns a context carrying id, the same way
+// ContextWithPeerInfo and ContextWithClientCertIdentity attach
+// per-call state before the interceptor chain runs.
+func ContextWithTenantID(ctx context.Context, id TenantID) context.Context {
+	return context.WithValue(ctx, tenantIDKey{}, id)
+}
+
+// TenantIDFromContext returns the TenantID TenantInterceptor attached to
+// ctx, or ok=false if none was - meaning the call is unscoped, the single-tenant
+// case.
+func TenantIDFromContext(ctx context.Context) (TenantID, bool) {
+	id, ok := ctx.Value(tenantIDKey{}).(TenantID)
+	return id, ok
+}
+
+// tenantIDFromContextString returns the TenantID attached to ctx as a
+// plain string, or "" if none was attached, for call sites that build a
+// sessionParams literal and want the unscoped zero value rather than a
+// second ok bool to check.
+func tenantIDFromContextString(ctx context.Context) string {
+	if id, ok := TenantIDFromContext(ctx); ok {
+		return string(id)
+	}
+	return ""
+}
+
+// tenantMatches reports whether tokenTenantID is the tenant a call is
+// allowed to present a token for: the caller's own resolved TenantID
+// (see TenantIDFromContext), with an unresolved caller treated the same
+// as the "" tenant. VerifyToken calls this for every access and refresh
+// token it accepts (API keys carry no tenant and are not checked), so a
+// token minted under one tenant is rejected for a caller resolved
+// to a different one (or none at all) rather than validating
+// identically regardless of which tenant is asking.
+func tenantMatches(ctx context.Context, tokenTenantID string) bool {
+	return tokenTenantID == tenantIDFromContextString(ctx)
+}
+
+// TenantBindingStore resolves the TenantID an mTLS caller is registered
+// for, from the ClientCertIdentity its certificate presented - the same
+// authenticated source AuthServer.ClientCredentials already resolves a
+// client-credentials caller's tenant from (ServiceClient.TenantID),
+// rather than trusting whatever a caller put in a request header.
+type TenantBindingStore interface {
+	TenantForCert(identity ClientCertIdentity) (TenantID, bool)
+}
+
+// InMemoryTenantBindingStore is a TenantBindingStore backed by a guarded
+// map keyed on ClientCertIdentity.CommonName, the same identity
+// AdminAuthorizationInterceptor and ClientCertInterceptor already key
+// their own authorization decisions on. It is meant for local
+// development and tests; a real multi-tenant deployment would back this
+// with whatever issues its client certificates in the first place.
+type InMemoryTenantBindingStore struct {
+	mu       sync.RWMutex
+	bindings map[string]TenantID
+}
+
+// NewInMemoryTenantBindingStore returns an empty InMemoryTenantBindingStore.
+func NewInMemoryTenantBindingStore() *InMemoryTenantBindingStore {
+	return &InMemoryTenantBindingStore{bindings: make(map[string]TenantID)}
+}
+
+// Bind registers commonName - a certificate's Subject.CommonName - as
+// belonging to tenant, for TenantForCert to resolve on every later call
+// presenting that certificate.
+func (s *InMemoryTenantBindingStore) Bind(commonName string, tenant TenantID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bindings[commonName] = tenant
+}
+
+// TenantForCert implements TenantBindingStore.
+func (s *InMemoryTenantBindingStore) TenantForCert(identity ClientCertIdentity) (TenantID, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tenant, ok := s.bindings[identity.CommonName]
+	return tenant, ok
+}
+
+// TenantInterceptor is a UnaryServerInterceptor that resolves a TenantID
+// from the calling mTLS client certificate (see ClientCertIdentityFromContext)
+// through bindings, and attaches it to ctx for every later interceptor
+// and handler to read via TenantIDFromContext. A call made with no
+// client certificate, or one bindings has no tenant registered for, is
+// simply unscoped - the single-tenant case every existing deployment is
+// in. It never resolves a tenant from caller-supplied request data (an
+// earlier revision read an "x-tenant-id" metadata header here, which let
+// any caller assert membership in any tenant and made VerifyToken's
+// isolation check meaningless); only a tenant an operator has bound to
+// that certificate's identity is ever attached. Install it ahead of
+// AuthenticationInterceptor so a handler issuing tokens can stamp the
+// resolved tenant into them.
+func TenantInterceptor(bindings TenantBindingStore) UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error) {
+		if bindings != nil {
+			if identity, ok := ClientCertIdentityFromContext(ctx); ok {
+				if tenant, ok := bindings.TenantForCert(identity); ok {
+					ctx = ContextWithTenantID(ctx, tenant)
+				}
+			}
+		}
+		return handler(ctx, req)
+	}
+}
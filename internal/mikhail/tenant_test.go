@@ -0,0 +1,117 @@
+package mikhail
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TwelveFacedJanus/Kingdom-System/internal/authpb"
+)
+
+func TestTenantInterceptorResolvesTenantFromBoundCertificate(t *testing.T) {
+	bindings := NewInMemoryTenantBindingStore()
+	bindings.Bind("billing-service", TenantID("tenant-a"))
+
+	var resolved TenantID
+	var ok bool
+	interceptor := TenantInterceptor(bindings)
+	ctx := ContextWithClientCertIdentity(context.Background(), ClientCertIdentity{CommonName: "billing-service"})
+
+	_, err := interceptor(ctx, nil, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		resolved, ok = TenantIDFromContext(ctx)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+	if !ok || resolved != "tenant-a" {
+		t.Fatalf("TenantIDFromContext after TenantInterceptor = (%q, %t), want (tenant-a, true)", resolved, ok)
+	}
+}
+
+// TestTenantInterceptorIgnoresUnauthenticatedCallerInput checks the
+// actual vulnerability the review flagged: a caller presenting no client
+// certificate - the only thing an attacker fully controls - never gets a
+// tenant attached, regardless of what it claims to be. Without this, any
+// caller could assert membership in any tenant and defeat isolation.
+func TestTenantInterceptorIgnoresUnauthenticatedCallerInput(t *testing.T) {
+	bindings := NewInMemoryTenantBindingStore()
+	bindings.Bind("billing-service", TenantID("tenant-a"))
+
+	var ok bool
+	interceptor := TenantInterceptor(bindings)
+
+	_, err := interceptor(context.Background(), nil, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		_, ok = TenantIDFromContext(ctx)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+	if ok {
+		t.Fatal("TenantIDFromContext resolved a tenant for a caller with no client certificate")
+	}
+}
+
+func TestTenantInterceptorUnboundCertificateIsUnscoped(t *testing.T) {
+	bindings := NewInMemoryTenantBindingStore()
+	interceptor := TenantInterceptor(bindings)
+	ctx := ContextWithClientCertIdentity(context.Background(), ClientCertIdentity{CommonName: "unregistered-service"})
+
+	var ok bool
+	_, err := interceptor(ctx, nil, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		_, ok = TenantIDFromContext(ctx)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+	if ok {
+		t.Fatal("TenantIDFromContext resolved a tenant for a certificate with no binding registered")
+	}
+}
+
+// TestVerifyTokenRejectsTokenFromAnotherResolvedTenant is an end-to-end
+// check, through AuthServer.VerifyToken, that a token minted for one
+// caller's resolved tenant is rejected for a different caller - and, the
+// case the old header-based resolution could not actually guarantee,
+// that an unauthenticated caller cannot simply claim the first caller's
+// tenant to get its token accepted.
+func TestVerifyTokenRejectsTokenFromAnotherResolvedTenant(t *testing.T) {
+	s := newTestAuthServer(t)
+	bindings := NewInMemoryTenantBindingStore()
+	bindings.Bind("tenant-a-service", TenantID("tenant-a"))
+
+	issuerCtx := ContextWithClientCertIdentity(context.Background(), ClientCertIdentity{CommonName: "tenant-a-service"})
+	issuerCtx = ContextWithTenantID(issuerCtx, "tenant-a")
+
+	family, err := s.issueTokenFamily(sessionParams{UserID: "user-1", TenantID: "tenant-a"})
+	if err != nil {
+		t.Fatalf("issueTokenFamily: %v", err)
+	}
+
+	result, err := s.VerifyToken(issuerCtx, &authpb.VerifyTokenRequest{Token: family.AccessToken})
+	if err != nil {
+		t.Fatalf("VerifyToken from the matching tenant: %v", err)
+	}
+	if !result.Valid {
+		t.Fatal("VerifyToken from the matching tenant: got Valid=false, want true")
+	}
+
+	otherTenantCtx := ContextWithTenantID(context.Background(), "tenant-b")
+	result, err = s.VerifyToken(otherTenantCtx, &authpb.VerifyTokenRequest{Token: family.AccessToken})
+	if err != nil {
+		t.Fatalf("VerifyToken from a caller resolved to tenant-b: %v", err)
+	}
+	if result.Valid {
+		t.Fatal("VerifyToken accepted a tenant-a token for a caller resolved to tenant-b")
+	}
+
+	unscopedCtx := context.Background()
+	result, err = s.VerifyToken(unscopedCtx, &authpb.VerifyTokenRequest{Token: family.AccessToken})
+	if err != nil {
+		t.Fatalf("VerifyToken from an unresolved (no certificate) caller: %v", err)
+	}
+	if result.Valid {
+		t.Fatal("VerifyToken accepted a tenant-a token for a caller with no resolved tenant")
+	}
+}
@@ -0,0 +1,129 @@
+package mikhail
+
+import "time"
+
+// TokenInfo is what Mikhail keeps about an issued refresh token.
+type TokenInfo struct {
+	UserID      string
+	PhoneNumber string
+	IssuedAt    time.Time
+	ExpiresAt   time.Time
+
+	// FamilyID identifies the lineage of refresh tokens produced by
+	// rotating a single SignIn/SignUp session. Every token minted by
+	// RefreshToken on behalf of that session shares the same FamilyID.
+	FamilyID string
+	// DeviceID identifies the device/client that started this session,
+	// as supplied by the caller at SignIn/SignUp time. It lets
+	// ListSessions show a user their active devices.
+	DeviceID string
+	// DeviceFingerprint binds this token to the device it was issued to,
+	// so a refresh token stolen and replayed from a different device can
+	// be caught even before reuse-after-rotation would flag it.
+	DeviceFingerprint string
+	// RememberMe records whether this session was started with the
+	// long-lived "remember me" tier rather than a standard, short-lived
+	// one. RefreshToken keeps issuing refresh tokens at the matching TTL
+	// as the session rotates.
+	RememberMe bool
+	// Scopes are the scopes/roles granted to this session. RefreshToken
+	// carries them forward unchanged when it rotates a token.
+	Scopes []string
+	// Rotated is set once this token has been exchanged for a new one.
+	// A rotated token is kept around (rather than deleted outright) so
+	// that a second presentation of it can be recognized as reuse.
+	Rotated bool
+	// RotatedAt is when Rotated was set. Used to size the grace window
+	// during which a rotated token can still be presented (e.g. by a
+	// client that retried a dropped RefreshToken response) without being
+	// treated as theft.
+	RotatedAt time.Time
+	// SuccessorToken is the refresh token this one was rotated into.
+	// Presenting this token again within the grace window returns the
+	// successor's pair instead of revoking the family.
+	SuccessorToken string
+	// Provider identifies the identity provider that started this
+	// session, e.g. "yandex". Empty means the session started from
+	// Mikhail's own phone/password SignIn or SignUp.
+	Provider string
+	// ProviderAccessToken and ProviderRefreshToken are the OAuthToken
+	// Provider issued when this session started (or was last refreshed),
+	// kept so Mikhail can act on the caller's behalf at the provider
+	// (e.g. Revoke on sign-out) without asking them to log in again.
+	// Both are empty when Provider is empty.
+	ProviderAccessToken  string
+	ProviderRefreshToken string
+	// ProviderTokenExpiresAt is when ProviderAccessToken expires, used
+	// by the background refresh scheduler to find sessions nearing
+	// expiry before the provider token goes dead from disuse.
+	ProviderTokenExpiresAt time.Time
+	// TenantID is the Kingdom-System tenant this session belongs to, or
+	// "" in a single-tenant deployment. Carried forward unchanged on
+	// every rotation within a family, the same way Scopes is, so a
+	// refresh reissues an access token for the same tenant it started
+	// in without needing the tenant resolved from the request again.
+	TenantID string
+	// IssuedIP and IssuedUserAgent record the caller's network and
+	// client at the time this specific token was issued (SignIn, SignUp,
+	// OAuth2Callback, or a RefreshToken rotation), used by
+	// SessionAnomalyConfig to flag a later refresh that looks like it
+	// came from someone else entirely. Empty if the caller's PeerInfo was
+	// unavailable when the token was issued.
+	IssuedIP        string
+	IssuedUserAgent string
+}
+
+// Expired reports whether the token is past its expiry time as of now.
+func (t TokenInfo) Expired(now time.Time) bool {
+	return now.After(t.ExpiresAt)
+}
+
+// TokenStorage persists refresh tokens so RefreshToken and SignOut can
+// look them up and revoke them later. Implementations must be safe for
+// concurrent use.
+type TokenStorage interface {
+	Store(refreshToken string, info TokenInfo) error
+	Get(refreshToken string) (TokenInfo, bool, error)
+	Delete(refreshToken string) error
+
+	// GetTokens looks up every refresh token in refreshTokens in one call,
+	// returning only the ones found, keyed by refresh token. Implementations
+	// should do this as a single batched round-trip to their backend (e.g.
+	// Redis MGET, a SQL IN clause) rather than looping Get, for cleanup jobs
+	// and bulk lookups that would otherwise pay one round-trip per token.
+	GetTokens(refreshTokens []string) (map[string]TokenInfo, error)
+	// DeleteTokens deletes every refresh token in refreshTokens, the batched
+	// counterpart to Delete for the same reason GetTokens is to Get.
+	// Deleting a refresh token that is not stored is not an error.
+	DeleteTokens(refreshTokens []string) error
+
+	// MarkRotated records that refreshToken has been exchanged for
+	// successorToken, without forgetting it outright.
+	MarkRotated(refreshToken, successorToken string) error
+	// RotateToken atomically marks oldRefreshToken rotated (the same
+	// change MarkRotated makes, naming newRefreshToken as its successor)
+	// and stores newInfo under newRefreshToken, so a crash between the two
+	// writes MarkRotated and Store would otherwise risk cannot leave the
+	// old token un-rotated with no successor actually stored, or the new
+	// token stored with the old one still presentable as unrotated.
+	// oldRefreshToken must already be stored; RotateToken reports an error
+	// if it is not.
+	RotateToken(oldRefreshToken, newRefreshToken string, newInfo TokenInfo) error
+	// RevokeFamily deletes every token sharing familyID, used when token
+	// reuse is detected.
+	RevokeFamily(familyID string) error
+	// RevokeAllForUser deletes every token belonging to userID, across
+	// every family, used to sign a user out of all sessions at once.
+	RevokeAllForUser(userID string) error
+	// RevokeAllForUserExceptFamily deletes every token belonging to
+	// userID except those in exceptFamilyID, used by ChangePassword so
+	// the session making the change survives it.
+	RevokeAllForUserExceptFamily(userID, exceptFamilyID string) error
+	// ListByUser returns every stored token belonging to userID, rotated
+	// ones included, used to list a user's sessions/devices.
+	ListByUser(userID string) ([]TokenInfo, error)
+	// ListProviderSessions returns every stored token, keyed by refresh
+	// token, that has a linked third-party provider, for the background
+	// refresh scheduler to scan for provider tokens nearing expiry.
+	ListProviderSessions() (map[string]TokenInfo, error)
+}
@@ -0,0 +1,208 @@
+package mikhail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// defaultTokenUpdateQueueSize is how many pending writes
+// AsyncTokenWriter buffers before a Store/RotateToken/MarkRotated call
+// falls back to writing synchronously instead of queuing.
+const defaultTokenUpdateQueueSize = 256
+
+type tokenUpdateKind int
+
+const (
+	tokenUpdateStore tokenUpdateKind = iota
+	tokenUpdateRotate
+	tokenUpdateMarkRotated
+)
+
+// tokenUpdate is one pending write AsyncTokenWriter's worker applies to
+// the wrapped TokenStorage.
+type tokenUpdate struct {
+	kind            tokenUpdateKind
+	refreshToken    string
+	info            TokenInfo
+	oldRefreshToken string
+	newRefreshToken string
+	successorToken  string
+}
+
+// AsyncTokenWriter wraps a TokenStorage so Store, RotateToken, and
+// MarkRotated - the writes that happen on the hot path of a RPC that has
+// already decided what token to hand back to the caller - return as
+// soon as the update is queued instead of waiting on the backend's write
+// latency. A background worker applies queued updates to the wrapped
+// TokenStorage in order. Reads (Get, GetTokens) and the other writes
+// (Delete, DeleteTokens, RevokeFamily) pass straight through
+// synchronously, since those callers need the backend's own consistency
+// guarantees rather than lower latency.
+//
+// Close must be called before the process exits, and before the wrapped
+// TokenStorage is itself torn down: it drains whatever updates are still
+// queued - refresh tokens already returned to a client that a naive
+// shutdown would otherwise silently lose - before returning, falling
+// back to applying them synchronously itself if the worker does not
+// finish draining before ctx's deadline.
+type AsyncTokenWriter struct {
+	storage    TokenStorage
+	updates    chan tokenUpdate
+	workerDone chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewAsyncTokenWriter returns an AsyncTokenWriter wrapping storage with
+// a queue of defaultTokenUpdateQueueSize pending updates.
+func NewAsyncTokenWriter(storage TokenStorage) *AsyncTokenWriter {
+	w := &AsyncTokenWriter{
+		storage:    storage,
+		updates:    make(chan tokenUpdate, defaultTokenUpdateQueueSize),
+		workerDone: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *AsyncTokenWriter) run() {
+	defer close(w.workerDone)
+	for update := range w.updates {
+		if err := w.apply(update); err != nil {
+			log.Printf("mikhail: async token write failed: %v", err)
+		}
+	}
+}
+
+func (w *AsyncTokenWriter) apply(update tokenUpdate) error {
+	switch update.kind {
+	case tokenUpdateStore:
+		return w.storage.Store(update.refreshToken, update.info)
+	case tokenUpdateRotate:
+		return w.storage.RotateToken(update.oldRefreshToken, update.newRefreshToken, update.info)
+	case tokenUpdateMarkRotated:
+		return w.storage.MarkRotated(update.refreshToken, update.successorToken)
+	default:
+		return fmt.Errorf("mikhail: unknown token update kind %d", update.kind)
+	}
+}
+
+// enqueueOrApply queues update for the worker to apply, or applies it
+// synchronously itself if the queue is full, AsyncTokenWriter has
+// already been closed, or Close is in progress.
+func (w *AsyncTokenWriter) enqueueOrApply(update tokenUpdate) error {
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		return w.apply(update)
+	}
+	select {
+	case w.updates <- update:
+		w.mu.RUnlock()
+		return nil
+	default:
+		w.mu.RUnlock()
+		return w.apply(update)
+	}
+}
+
+func (w *AsyncTokenWriter) Store(refreshToken string, info TokenInfo) error {
+	return w.enqueueOrApply(tokenUpdate{kind: tokenUpdateStore, refreshToken: refreshToken, info: info})
+}
+
+func (w *AsyncTokenWriter) RotateToken(oldRefreshToken, newRefreshToken string, newInfo TokenInfo) error {
+	return w.enqueueOrApply(tokenUpdate{kind: tokenUpdateRotate, oldRefreshToken: oldRefreshToken, newRefreshToken: newRefreshToken, info: newInfo})
+}
+
+func (w *AsyncTokenWriter) MarkRotated(refreshToken, successorToken string) error {
+	return w.enqueueOrApply(tokenUpdate{kind: tokenUpdateMarkRotated, refreshToken: refreshToken, successorToken: successorToken})
+}
+
+func (w *AsyncTokenWriter) Get(refreshToken string) (TokenInfo, bool, error) {
+	return w.storage.Get(refreshToken)
+}
+
+func (w *AsyncTokenWriter) Delete(refreshToken string) error {
+	return w.storage.Delete(refreshToken)
+}
+
+func (w *AsyncTokenWriter) GetTokens(refreshTokens []string) (map[string]TokenInfo, error) {
+	return w.storage.GetTokens(refreshTokens)
+}
+
+func (w *AsyncTokenWriter) DeleteTokens(refreshTokens []string) error {
+	return w.storage.DeleteTokens(refreshTokens)
+}
+
+func (w *AsyncTokenWriter) RevokeFamily(familyID string) error {
+	return w.storage.RevokeFamily(familyID)
+}
+
+func (w *AsyncTokenWriter) RevokeAllForUser(userID string) error {
+	return w.storage.RevokeAllForUser(userID)
+}
+
+func (w *AsyncTokenWriter) RevokeAllForUserExceptFamily(userID, exceptFamilyID string) error {
+	return w.storage.RevokeAllForUserExceptFamily(userID, exceptFamilyID)
+}
+
+func (w *AsyncTokenWriter) ListByUser(userID string) ([]TokenInfo, error) {
+	return w.storage.ListByUser(userID)
+}
+
+func (w *AsyncTokenWriter) ListProviderSessions() (map[string]TokenInfo, error) {
+	return w.storage.ListProviderSessions()
+}
+
+// QueueDepth reports how many updates are currently buffered awaiting
+// the worker, for the debug server's expvar counters.
+func (w *AsyncTokenWriter) QueueDepth() int {
+	return len(w.updates)
+}
+
+// Close stops accepting new queued updates - any Store/RotateToken/
+// MarkRotated call racing with Close is applied synchronously instead -
+// then waits for the worker to drain whatever was already queued, up to
+// ctx's deadline. If the worker has not finished draining by then, Close
+// drains the remainder itself, applying each synchronously, so a queued
+// update is never silently dropped even when draining runs out of time.
+// It does not close the wrapped TokenStorage; the caller does that after
+// Close returns.
+func (w *AsyncTokenWriter) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.updates)
+	w.mu.Unlock()
+
+	select {
+	case <-w.workerDone:
+		return nil
+	case <-ctx.Done():
+	}
+
+	var drainErr error
+	for {
+		select {
+		case update, ok := <-w.updates:
+			if !ok {
+				return drainErr
+			}
+			if err := w.apply(update); err != nil {
+				drainErr = fmt.Errorf("mikhail: draining queued token updates on shutdown: %w", err)
+				log.Printf("mikhail: %v", drainErr)
+			}
+		default:
+			if drainErr == nil {
+				drainErr = fmt.Errorf("mikhail: async token writer did not finish draining before shutdown deadline")
+			}
+			return drainErr
+		}
+	}
+}
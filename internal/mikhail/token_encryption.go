@@ -0,0 +1,139 @@
+package mikhail
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownEncryptionKeyVersion is returned by
+// TokenEncryptionKeyring.Decrypt when a ciphertext names a key version
+// the keyring was not given, e.g. because that key has since been
+// retired.
+var ErrUnknownEncryptionKeyVersion = errors.New("mikhail: unknown token encryption key version")
+
+// ErrMalformedCiphertext is returned by TokenEncryptionKeyring.Decrypt
+// when given a string that is not a "v<version>:<base64>" ciphertext
+// Encrypt could have produced.
+var ErrMalformedCiphertext = errors.New("mikhail: malformed token ciphertext")
+
+// TokenEncryptionKeyring holds every AES-256-GCM key RedisTokenStorage
+// may need to decrypt an existing ciphertext, keyed by version, plus
+// which version new writes should use. Rotating a key is then a matter
+// of AddKey-ing the new version (it becomes current automatically) and,
+// once enough time has passed for RedisTokenReencryptionScheduler to
+// have rewritten old ciphertexts under it, removing the retired version
+// with Forget — all without invalidating sessions encrypted under the
+// key being rotated away from, the way swapping a single fixed key
+// would.
+type TokenEncryptionKeyring struct {
+	mu             sync.RWMutex
+	keys           map[int]cipher.AEAD
+	currentVersion int
+}
+
+// NewTokenEncryptionKeyring returns an empty keyring. Add at least one
+// key with AddKey before using it to encrypt.
+func NewTokenEncryptionKeyring() *TokenEncryptionKeyring {
+	return &TokenEncryptionKeyring{keys: make(map[int]cipher.AEAD)}
+}
+
+// AddKey registers a 32-byte AES-256 key under version. Versions are
+// compared numerically; the highest version added so far is used for
+// new encryptions, so rotating to a new key is just adding it under a
+// higher version number.
+func (k *TokenEncryptionKeyring) AddKey(version int, key []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[version] = aead
+	if version > k.currentVersion {
+		k.currentVersion = version
+	}
+	return nil
+}
+
+// Forget removes version from the keyring, so Decrypt can no longer
+// read ciphertexts produced under it. Callers should only do this once
+// a re-encryption pass has had time to rewrite every ciphertext that
+// used it.
+func (k *TokenEncryptionKeyring) Forget(version int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.keys, version)
+}
+
+// CurrentVersion returns the key version Encrypt currently writes with.
+func (k *TokenEncryptionKeyring) CurrentVersion() int {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.currentVersion
+}
+
+// Encrypt seals plaintext under the current key, returning a ciphertext
+// prefixed with the key version used ("v<version>:<base64>").
+func (k *TokenEncryptionKeyring) Encrypt(plaintext []byte) (string, error) {
+	k.mu.RLock()
+	version, aead := k.currentVersion, k.keys[k.currentVersion]
+	k.mu.RUnlock()
+	if aead == nil {
+		return "", ErrUnknownEncryptionKeyVersion
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, nil)
+	return fmt.Sprintf("v%d:%s", version, base64.RawURLEncoding.EncodeToString(sealed)), nil
+}
+
+// Decrypt opens a ciphertext produced by Encrypt, returning the
+// plaintext and the key version it was encrypted under.
+func (k *TokenEncryptionKeyring) Decrypt(ciphertext string) ([]byte, int, error) {
+	versionTag, encoded, ok := strings.Cut(ciphertext, ":")
+	if !ok || !strings.HasPrefix(versionTag, "v") {
+		return nil, 0, ErrMalformedCiphertext
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(versionTag, "v"))
+	if err != nil {
+		return nil, 0, ErrMalformedCiphertext
+	}
+
+	k.mu.RLock()
+	aead := k.keys[version]
+	k.mu.RUnlock()
+	if aead == nil {
+		return nil, 0, ErrUnknownEncryptionKeyVersion
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, 0, err
+	}
+	nonceSize := aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, 0, ErrMalformedCiphertext
+	}
+	nonce, ciphertextBytes := sealed[:nonceSize], sealed[nonceSize:]
+	plaintext, err := aead.Open(nil, nonce, ciphertextBytes, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	return plaintext, version, nil
+}
@@ -0,0 +1,92 @@
+package mikhail
+
+import "testing"
+
+func testEncryptionKey(b byte) []byte {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = b
+	}
+	return key
+}
+
+func TestTokenEncryptionKeyringRoundTrip(t *testing.T) {
+	k := NewTokenEncryptionKeyring()
+	if err := k.AddKey(1, testEncryptionKey(1)); err != nil {
+		t.Fatalf("AddKey: %v", err)
+	}
+
+	ciphertext, err := k.Encrypt([]byte("a refresh token worth protecting"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	plaintext, version, err := k.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if string(plaintext) != "a refresh token worth protecting" {
+		t.Fatalf("Decrypt returned %q, want the original plaintext", plaintext)
+	}
+	if version != 1 {
+		t.Fatalf("Decrypt reported version %d, want 1", version)
+	}
+}
+
+// TestTokenEncryptionKeyringRotation checks the key-rotation story AddKey's
+// doc comment describes: adding a higher version makes it current for new
+// encryptions, while ciphertexts written under the old version still
+// decrypt until it is explicitly Forget-ten.
+func TestTokenEncryptionKeyringRotation(t *testing.T) {
+	k := NewTokenEncryptionKeyring()
+	if err := k.AddKey(1, testEncryptionKey(1)); err != nil {
+		t.Fatalf("AddKey(1): %v", err)
+	}
+	old, err := k.Encrypt([]byte("encrypted-under-v1"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	if err := k.AddKey(2, testEncryptionKey(2)); err != nil {
+		t.Fatalf("AddKey(2): %v", err)
+	}
+	if got := k.CurrentVersion(); got != 2 {
+		t.Fatalf("CurrentVersion after adding v2: got %d, want 2", got)
+	}
+
+	fresh, err := k.Encrypt([]byte("encrypted-under-v2"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if _, version, err := k.Decrypt(fresh); err != nil || version != 2 {
+		t.Fatalf("Decrypt(fresh): got version %d, err %v; want version 2, nil", version, err)
+	}
+
+	if _, _, err := k.Decrypt(old); err != nil {
+		t.Fatalf("Decrypt of a v1 ciphertext after rotating to v2: got err %v, want nil", err)
+	}
+
+	k.Forget(1)
+	if _, _, err := k.Decrypt(old); err != ErrUnknownEncryptionKeyVersion {
+		t.Fatalf("Decrypt of a v1 ciphertext after Forget(1): got err %v, want ErrUnknownEncryptionKeyVersion", err)
+	}
+}
+
+func TestTokenEncryptionKeyringDecryptRejectsMalformedCiphertext(t *testing.T) {
+	k := NewTokenEncryptionKeyring()
+	if err := k.AddKey(1, testEncryptionKey(1)); err != nil {
+		t.Fatalf("AddKey: %v", err)
+	}
+	for _, ciphertext := range []string{"", "no-version-prefix", "v:missing-number"} {
+		if _, _, err := k.Decrypt(ciphertext); err != ErrMalformedCiphertext {
+			t.Fatalf("Decrypt(%q): got err %v, want ErrMalformedCiphertext", ciphertext, err)
+		}
+	}
+}
+
+func TestTokenEncryptionKeyringEncryptWithNoKeysFails(t *testing.T) {
+	k := NewTokenEncryptionKeyring()
+	if _, err := k.Encrypt([]byte("anything")); err != ErrUnknownEncryptionKeyVersion {
+		t.Fatalf("Encrypt with no keys added: got err %v, want ErrUnknownEncryptionKeyVersion", err)
+	}
+}
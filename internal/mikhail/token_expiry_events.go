@@ -0,0 +1,50 @@
+package mikhail
+
+import "time"
+
+// TokenExpiredEvent describes a refresh token that has lapsed, either
+// because a backend's native TTL reaped it (observed by
+// RedisKeyspaceExpiryWatcher) or because a TokenExpirationPoller found
+// it past TokenInfo.ExpiresAt during a scan. UserID, FamilyID and
+// Provider are populated whenever the detector still had the full
+// TokenInfo on hand to read them from; RedisKeyspaceExpiryWatcher learns
+// about expiry only after Redis has already discarded the value, so it
+// can only populate UserID and the key's token component (in both
+// RefreshToken and TokenKeyComponent), which is the refresh token itself
+// only when RedisTokenStorage.Secret is unset — see its doc comment for why.
+type TokenExpiredEvent struct {
+	RefreshToken string
+	// TokenKeyComponent is set by detectors that only know the backend's
+	// key for a token rather than the refresh token itself (a Redis key
+	// name, hashed or not); empty for detectors that always have the real token.
+	TokenKeyComponent string
+	UserID            string
+	FamilyID          string
+	Provider          string
+	ExpiresAt         time.Time
+	DetectedAt        time.Time
+}
+
+// TokenExpiryListener is the caller-supplied sink TokenExpiredEvents are
+// delivered to. Mikhail has no audit log or event bus of its own; a
+// caller wanting session-end events to reach one implements this
+// interface over their own (publishing to a queue, writing an audit
+// row, incrementing a metric) and passes it to
+// NewRedisKeyspaceExpiryWatcher or NewTokenExpirationPoller.
+//
+// OnTokenExpired is called synchronously from the detector's own
+// goroutine; an implementation doing anything slower than an in-memory
+// append should hand the event off to its own queue rather than block
+// the watcher or poller loop.
+type TokenExpiryListener interface {
+	OnTokenExpired(TokenExpiredEvent)
+}
+
+// TokenExpiryListenerFunc adapts a plain function to a
+// TokenExpiryListener.
+type TokenExpiryListenerFunc func(TokenExpiredEvent)
+
+// OnTokenExpired calls f.
+func (f TokenExpiryListenerFunc) OnTokenExpired(event TokenExpiredEvent) {
+	f(event)
+}
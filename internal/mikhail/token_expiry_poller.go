@@ -0,0 +1,101 @@
+package mikhail
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// ExpiringTokenScanner is satisfied by a TokenStorage backend that can
+// report which of its tokens are already past TokenInfo.ExpiresAt as of
+// a given time, for TokenExpirationPoller to drive TokenExpiredEvents
+// off of. Backends with native TTL-based expiry (Redis, DynamoDB, etcd)
+// reap tokens themselves and do not need this: Redis session-end events
+// are observed instead by RedisKeyspaceExpiryWatcher, and DynamoDB/etcd
+// have no push notification Mikhail can subscribe to, so a session
+// ending there is not yet reported as a TokenExpiredEvent.
+type ExpiringTokenScanner interface {
+	// ScanExpiring returns every refresh token whose TokenInfo.ExpiresAt
+	// is at or before before, along with the TokenInfo each still held
+	// at scan time.
+	ScanExpiring(before time.Time) (map[string]TokenInfo, error)
+}
+
+// TokenExpirationPoller periodically scans a backend satisfying
+// ExpiringTokenScanner for tokens that have passed their ExpiresAt,
+// reports each as a TokenExpiredEvent to Listener, and then deletes it
+// from storage. It is modeled on SQLiteExpiredTokenVacuumer, which reaps
+// the same rows silently; this additionally surfaces the event a
+// native-TTL backend's own reaping would otherwise lose.
+type TokenExpirationPoller struct {
+	storage  TokenStorage
+	scanner  ExpiringTokenScanner
+	Listener TokenExpiryListener
+	// Interval is how often the poller scans for expired tokens.
+	Interval time.Duration
+
+	stop chan struct{}
+}
+
+// NewTokenExpirationPoller returns a TokenExpirationPoller that scans
+// scanner every interval, reports what it finds to listener, and deletes
+// each from storage. storage and scanner are usually the same backend
+// value (e.g. a *SQLiteTokenStorage satisfies both TokenStorage and
+// ExpiringTokenScanner); they are taken separately since a caller
+// wrapping storage in CachedTokenStorage or InstrumentedTokenStorage
+// still wants deletes to go through the wrapper while scanning the
+// concrete backend underneath, which is the one that implements
+// ExpiringTokenScanner. Call Start to begin polling.
+func NewTokenExpirationPoller(storage TokenStorage, scanner ExpiringTokenScanner, listener TokenExpiryListener, interval time.Duration) *TokenExpirationPoller {
+	return &TokenExpirationPoller{
+		storage:  storage,
+		scanner:  scanner,
+		Listener: listener,
+		Interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start runs the scan loop until ctx is done or Stop is called. It is
+// meant to be run in its own goroutine.
+func (p *TokenExpirationPoller) Start(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.pollOnce()
+		}
+	}
+}
+
+// Stop ends a running Start loop.
+func (p *TokenExpirationPoller) Stop() {
+	close(p.stop)
+}
+
+func (p *TokenExpirationPoller) pollOnce() {
+	now := time.Now()
+	expired, err := p.scanner.ScanExpiring(now)
+	if err != nil {
+		log.Printf("mikhail: token expiration poll failed: %v", err)
+		return
+	}
+	for refreshToken, info := range expired {
+		p.Listener.OnTokenExpired(TokenExpiredEvent{
+			RefreshToken: refreshToken,
+			UserID:       info.UserID,
+			FamilyID:     info.FamilyID,
+			Provider:     info.Provider,
+			ExpiresAt:    info.ExpiresAt,
+			DetectedAt:   now,
+		})
+		if err := p.storage.Delete(refreshToken); err != nil {
+			log.Printf("mikhail: token expiration poll could not delete expired token for user %q: %v", info.UserID, err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package mikhail
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	totpPeriod = 30 * time.Second
+	totpDigits = 6
+)
+
+// NewTOTPSecret returns a fresh base32-encoded TOTP secret, suitable for
+// embedding in an otpauth:// provisioning URI or QR code.
+func NewTOTPSecret() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf), nil
+}
+
+// generateTOTP computes the RFC 6238 TOTP code for secret at time t.
+func generateTOTP(secret string, t time.Time) (string, error) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	counter := uint64(t.Unix() / int64(totpPeriod.Seconds()))
+	return hotp(key, counter), nil
+}
+
+// hotp computes the RFC 4226 HOTP code for key at counter.
+func hotp(key []byte, counter uint64) string {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(buf)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := (uint32(sum[offset])&0x7f)<<24 |
+		uint32(sum[offset+1])<<16 |
+		uint32(sum[offset+2])<<8 |
+		uint32(sum[offset+3])
+
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, code%mod)
+}
+
+// validateTOTP reports whether code matches secret at now, tolerating
+// skew adjacent 30-second periods on either side to absorb clock drift
+// between the server and the authenticator app.
+func validateTOTP(secret, code string, skew int, now time.Time) bool {
+	for i := -skew; i <= skew; i++ {
+		want, err := generateTOTP(secret, now.Add(time.Duration(i)*totpPeriod))
+		if err != nil {
+			return false
+		}
+		if hmac.Equal([]byte(want), []byte(code)) {
+			return true
+		}
+	}
+	return false
+}
+
+// totpProvisioningURI builds the otpauth:// URI authenticator apps scan
+// to enroll secret for accountName under issuer "Mikhail".
+func totpProvisioningURI(accountName, secret string) string {
+	return fmt.Sprintf("otpauth://totp/Mikhail:%s?secret=%s&issuer=Mikhail&digits=%d&period=%d",
+		accountName, secret, totpDigits, int(totpPeriod.Seconds()))
+}
@@ -0,0 +1,64 @@
+package mikhail
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrTOTPNotEnrolled is returned when a TOTP operation is attempted for a
+// user that has never called EnrollTotp.
+var ErrTOTPNotEnrolled = errors.New("mikhail: totp not enrolled")
+
+// ErrTOTPCodeInvalid is returned by ConfirmTotp, DisableTotp, and the
+// SignIn challenge when the presented code does not validate.
+var ErrTOTPCodeInvalid = errors.New("mikhail: totp code invalid")
+
+// TOTPEnrollment is what Mikhail keeps about a user's TOTP secret.
+type TOTPEnrollment struct {
+	Secret string
+	// Confirmed is set once ConfirmTotp accepts a code generated from
+	// Secret, proving the user actually has it loaded in an authenticator
+	// app. SignIn only challenges for 2FA once Confirmed is true.
+	Confirmed bool
+}
+
+// TOTPStore persists TOTP enrollments, keyed by user ID. Implementations
+// must be safe for concurrent use.
+type TOTPStore interface {
+	Store(userID string, enrollment TOTPEnrollment) error
+	Get(userID string) (TOTPEnrollment, bool, error)
+	Delete(userID string) error
+}
+
+// InMemoryTOTPStore is a TOTPStore backed by a guarded map, suitable for
+// local development and single-node deployments.
+type InMemoryTOTPStore struct {
+	mu          sync.Mutex
+	enrollments map[string]TOTPEnrollment
+}
+
+// NewInMemoryTOTPStore returns an empty InMemoryTOTPStore.
+func NewInMemoryTOTPStore() *InMemoryTOTPStore {
+	return &InMemoryTOTPStore{enrollments: make(map[string]TOTPEnrollment)}
+}
+
+func (s *InMemoryTOTPStore) Store(userID string, enrollment TOTPEnrollment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enrollments[userID] = enrollment
+	return nil
+}
+
+func (s *InMemoryTOTPStore) Get(userID string) (TOTPEnrollment, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	enrollment, ok := s.enrollments[userID]
+	return enrollment, ok, nil
+}
+
+func (s *InMemoryTOTPStore) Delete(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.enrollments, userID)
+	return nil
+}
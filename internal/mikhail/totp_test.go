@@ -0,0 +1,55 @@
+package mikhail
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateTOTPAcceptsCurrentCode(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatalf("NewTOTPSecret: %v", err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	code, err := generateTOTP(secret, now)
+	if err != nil {
+		t.Fatalf("generateTOTP: %v", err)
+	}
+	if !validateTOTP(secret, code, 1, now) {
+		t.Fatal("validateTOTP rejected the code it was just generated for")
+	}
+}
+
+func TestValidateTOTPRejectsWrongCode(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatalf("NewTOTPSecret: %v", err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	if validateTOTP(secret, "000000", 1, now) {
+		t.Fatal("validateTOTP accepted an arbitrary code")
+	}
+}
+
+// TestValidateTOTPToleratesClockSkew checks that a code from one period
+// adjacent to now still validates when skew allows it, and no longer does
+// once it falls outside the tolerated window.
+func TestValidateTOTPToleratesClockSkew(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatalf("NewTOTPSecret: %v", err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	previousPeriod := now.Add(-totpPeriod)
+	code, err := generateTOTP(secret, previousPeriod)
+	if err != nil {
+		t.Fatalf("generateTOTP: %v", err)
+	}
+
+	if validateTOTP(secret, code, 0, now) {
+		t.Fatal("validateTOTP with skew=0 accepted a code from the previous period")
+	}
+	if !validateTOTP(secret, code, 1, now) {
+		t.Fatal("validateTOTP with skew=1 rejected a code from the previous period")
+	}
+}
@@ -0,0 +1,69 @@
+package mikhail
+
+import "context"
+
+// Span is the minimal tracing span surface Mikhail's instrumentation
+// needs: enough to end a span and record an error or attribute on it,
+// satisfied by wrapping a real OpenTelemetry span
+// (go.opentelemetry.io/otel/trace.Span) in a caller-supplied Tracer.
+type Span interface {
+	// SetAttribute records one key/value pair on the span.
+	SetAttribute(key string, value interface{})
+	// SetError records err on the span, if non-nil.
+	SetError(err error)
+	// End finishes the span.
+	End()
+}
+
+// Tracer starts spans for Mikhail's instrumented operations: one per RPC
+// (via UnaryTracingInterceptor), one per YandexOAuthClient HTTP request,
+// and one per RedisTokenStorage command. Mikhail has no OpenTelemetry
+// dependency of its own (this environment cannot vendor one); a caller
+// wanting spans exported to an OTLP endpoint implements Tracer over a
+// real otel.Tracer (Start maps directly to tracer.Start) and assigns it
+// to AuthServer.Tracer/YandexOAuthClient.Tracer/RedisTokenStorage.Tracer.
+// A nil Tracer on any of those, the default, means tracing is off there.
+type Tracer interface {
+	// Start begins a span named name. When ctx already carries a span
+	// (e.g. attached from an incoming RPC's trace context by whatever
+	// transport decodes it), the new span is its child, so a trace
+	// started by a caller stays one trace through Mikhail rather than
+	// fragmenting. The returned context carries the new span for
+	// anything nested under it to parent off of in turn.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// startSpan is a nil-safe Tracer.Start: it returns ctx and a nil Span
+// unchanged when tracer is nil, so every instrumented call site can
+// unconditionally call startSpan and endSpan rather than branching on
+// whether tracing is configured.
+func startSpan(ctx context.Context, tracer Tracer, name string) (context.Context, Span) {
+	if tracer == nil {
+		return ctx, nil
+	}
+	return tracer.Start(ctx, name)
+}
+
+// endSpan records err (if any) on span and ends it. It is a no-op if
+// span is nil, i.e. startSpan was called with a nil Tracer.
+func endSpan(span Span, err error) {
+	if span == nil {
+		return
+	}
+	span.SetError(err)
+	span.End()
+}
+
+// UnaryTracingInterceptor returns a UnaryServerInterceptor that starts a
+// span named info.FullMethod around every RPC, accepting whatever trace
+// context the caller attached to ctx before dispatching to it. Install
+// it first in the interceptor chain so every other interceptor's work
+// happens inside the RPC's span.
+func UnaryTracingInterceptor(tracer Tracer) UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error) {
+		ctx, span := startSpan(ctx, tracer, info.FullMethod)
+		resp, err := handler(ctx, req)
+		endSpan(span, err)
+		return resp, err
+	}
+}
@@ -0,0 +1,31 @@
+package mikhail
+
+import "time"
+
+// Version, GitCommit, and BuildDate identify the build running, meant to
+// be set at link time with:
+//
+//	go build -ldflags "-X github.com/TwelveFacedJanus/Kingdom-System/internal/mikhail.Version=1.2.3 \
+//	  -X github.com/TwelveFacedJanus/Kingdom-System/internal/mikhail.GitCommit=$(git rev-parse HEAD) \
+//	  -X github.com/TwelveFacedJanus/Kingdom-System/internal/mikhail.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// A binary built without those flags (e.g. `go run`, or a plain `go
+// build` during development) reports the zero-value defaults below
+// instead of an empty string, so GetServerInfo never returns a blank
+// field that looks like a bug.
+var (
+	Version   = "dev"
+	GitCommit = "unknown"
+	BuildDate = "unknown"
+)
+
+// processStart is captured once, at package initialization, so
+// AdminServer.GetServerInfo can report how long this process has been
+// running.
+var processStart = time.Now()
+
+// Uptime returns how long this process has been running, measured from
+// package initialization.
+func Uptime() time.Duration {
+	return time.Since(processStart)
+}
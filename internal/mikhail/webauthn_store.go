@@ -0,0 +1,170 @@
+package mikhail
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// WebAuthnCredential is a registered passkey's public key material, as
+// recorded by FinishWebAuthnRegistration.
+type WebAuthnCredential struct {
+	CredentialID []byte
+	UserID       string
+	PublicKeyX   []byte
+	PublicKeyY   []byte
+	SignCount    uint32
+}
+
+// WebAuthnCredentialStore persists registered passkeys. It is kept
+// separate from credentialStore since passkeys are an additional sign-in
+// method layered on top of (not a replacement for) phone/password
+// accounts. Implementations must be safe for concurrent use.
+type WebAuthnCredentialStore interface {
+	Store(cred WebAuthnCredential) error
+	Get(credentialID []byte) (WebAuthnCredential, bool, error)
+	ListByUser(userID string) ([]WebAuthnCredential, error)
+}
+
+// InMemoryWebAuthnCredentialStore is a WebAuthnCredentialStore backed by
+// a guarded map, suitable for local development and single-node
+// deployments.
+type InMemoryWebAuthnCredentialStore struct {
+	mu          sync.Mutex
+	credentials map[string]WebAuthnCredential
+}
+
+// NewInMemoryWebAuthnCredentialStore returns an empty
+// InMemoryWebAuthnCredentialStore.
+func NewInMemoryWebAuthnCredentialStore() *InMemoryWebAuthnCredentialStore {
+	return &InMemoryWebAuthnCredentialStore{credentials: make(map[string]WebAuthnCredential)}
+}
+
+func (s *InMemoryWebAuthnCredentialStore) Store(cred WebAuthnCredential) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.credentials[string(cred.CredentialID)] = cred
+	return nil
+}
+
+func (s *InMemoryWebAuthnCredentialStore) Get(credentialID []byte) (WebAuthnCredential, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cred, ok := s.credentials[string(credentialID)]
+	return cred, ok, nil
+}
+
+func (s *InMemoryWebAuthnCredentialStore) ListByUser(userID string) ([]WebAuthnCredential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var creds []WebAuthnCredential
+	for _, cred := range s.credentials {
+		if cred.UserID == userID {
+			creds = append(creds, cred)
+		}
+	}
+	return creds, nil
+}
+
+// WebAuthnChallengeInfo is what Mikhail keeps about an outstanding
+// registration or assertion challenge.
+type WebAuthnChallengeInfo struct {
+	UserID    string
+	ExpiresAt time.Time
+}
+
+// Expired reports whether the challenge is past its expiry time as of
+// now.
+func (c WebAuthnChallengeInfo) Expired(now time.Time) bool {
+	return now.After(c.ExpiresAt)
+}
+
+// WebAuthnChallengeStore persists outstanding WebAuthn challenges, keyed
+// by the challenge value itself. Challenges are single-use: Take removes
+// the entry it returns.
+type WebAuthnChallengeStore interface {
+	Store(challenge string, info WebAuthnChallengeInfo) error
+	Take(challenge string) (WebAuthnChallengeInfo, bool, error)
+}
+
+// InMemoryWebAuthnChallengeStore is a WebAuthnChallengeStore backed by a
+// guarded map, suitable for local development and single-node
+// deployments.
+type InMemoryWebAuthnChallengeStore struct {
+	mu         sync.Mutex
+	challenges map[string]WebAuthnChallengeInfo
+}
+
+// NewInMemoryWebAuthnChallengeStore returns an empty
+// InMemoryWebAuthnChallengeStore.
+func NewInMemoryWebAuthnChallengeStore() *InMemoryWebAuthnChallengeStore {
+	return &InMemoryWebAuthnChallengeStore{challenges: make(map[string]WebAuthnChallengeInfo)}
+}
+
+func (s *InMemoryWebAuthnChallengeStore) Store(challenge string, info WebAuthnChallengeInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.challenges[challenge] = info
+	return nil
+}
+
+func (s *InMemoryWebAuthnChallengeStore) Take(challenge string) (WebAuthnChallengeInfo, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, ok := s.challenges[challenge]
+	if ok {
+		delete(s.challenges, challenge)
+	}
+	return info, ok, nil
+}
+
+// RedisWebAuthnChallengeStore is a WebAuthnChallengeStore backed by
+// Redis, so registration/assertion challenges are visible to every
+// Mikhail instance behind a load balancer, not just the one that issued
+// them.
+type RedisWebAuthnChallengeStore struct {
+	client *redisClient
+}
+
+// NewRedisWebAuthnChallengeStore dials addr ("host:port") and returns a
+// RedisWebAuthnChallengeStore backed by that connection.
+func NewRedisWebAuthnChallengeStore(addr string) (*RedisWebAuthnChallengeStore, error) {
+	client, err := dialRedis(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisWebAuthnChallengeStore{client: client}, nil
+}
+
+func webAuthnChallengeRedisKey(challenge string) string {
+	return "mikhail:webauthn:challenge:" + challenge
+}
+
+func (s *RedisWebAuthnChallengeStore) Store(challenge string, info WebAuthnChallengeInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(webAuthnChallengeRedisKey(challenge), string(data), time.Until(info.ExpiresAt))
+}
+
+func (s *RedisWebAuthnChallengeStore) Take(challenge string) (WebAuthnChallengeInfo, bool, error) {
+	key := webAuthnChallengeRedisKey(challenge)
+	raw, ok, err := s.client.Get(key)
+	if err != nil || !ok {
+		return WebAuthnChallengeInfo{}, false, err
+	}
+	if err := s.client.Del(key); err != nil {
+		return WebAuthnChallengeInfo{}, false, err
+	}
+	var info WebAuthnChallengeInfo
+	if err := json.Unmarshal([]byte(raw), &info); err != nil {
+		return WebAuthnChallengeInfo{}, false, err
+	}
+	return info, true, nil
+}
+
+func encodeCredentialID(id []byte) string {
+	return base64.RawURLEncoding.EncodeToString(id)
+}